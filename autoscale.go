@@ -0,0 +1,89 @@
+package inferable
+
+import (
+	"context"
+	"time"
+)
+
+// DefaultUtilizationInterval is how often utilization is reported, see ServiceOptions.UtilizationReporter
+const DefaultUtilizationInterval = 15 * time.Second
+
+// UtilizationReport describes the load of a service, e.g. to drive custom metrics of a KEDA or HPA autoscaler
+type UtilizationReport struct {
+	Service string
+	// InFlight is the number of calls being handled
+	InFlight int
+	// Capacity is the number of calls the service handles at once, see ServiceOptions.MaxConcurrentCalls
+	Capacity int
+	// Utilization is InFlight divided by Capacity
+	Utilization float64
+	// QueueDepth is the approximate number of calls waiting to be received, or -1 if it is unknown
+	QueueDepth int64
+	// Time is when the report was taken
+	Time time.Time
+}
+
+// UtilizationReporter receives the utilization of a service periodically
+type UtilizationReporter interface {
+	ReportUtilization(report UtilizationReport)
+}
+
+// UtilizationReporterFunc adapts a function to a UtilizationReporter
+type UtilizationReporterFunc func(report UtilizationReport)
+
+// ReportUtilization calls f(report)
+func (f UtilizationReporterFunc) ReportUtilization(report UtilizationReport) {
+	f(report)
+}
+
+// queueDepther is implemented by transports which can tell how many calls are waiting, such as SQSConsumer
+type queueDepther interface {
+	QueueDepth(ctx context.Context) (int64, error)
+}
+
+// Utilization returns the current utilization of the service
+func (s *Service) Utilization(ctx context.Context) UtilizationReport {
+	report := UtilizationReport{
+		Service:    s.Name,
+		InFlight:   int(s.inFlight.Load()),
+		Capacity:   s.maxConcurrentCalls(),
+		QueueDepth: -1,
+		Time:       time.Now(),
+	}
+	report.Utilization = float64(report.InFlight) / float64(report.Capacity)
+
+	s.consumerMu.Lock()
+	consumer := s.consumer
+	s.consumerMu.Unlock()
+
+	if depther, ok := consumer.(queueDepther); ok {
+		depth, err := depther.QueueDepth(ctx)
+		if err != nil {
+			s.inferable.logf(LogLevelDebug, "Failed to get queue depth of service '%s': %v", s.Name, err)
+		} else {
+			report.QueueDepth = depth
+		}
+	}
+
+	return report
+}
+
+func (s *Service) utilizationInterval() time.Duration {
+	if s.options.UtilizationInterval <= 0 {
+		return DefaultUtilizationInterval
+	}
+
+	return s.options.UtilizationInterval
+}
+
+// reportUtilizationLoop reports the utilization of the service to ServiceOptions.UtilizationReporter until ctx is done
+func (s *Service) reportUtilizationLoop(ctx context.Context) {
+	reporter := s.options.UtilizationReporter
+	if reporter == nil {
+		return
+	}
+
+	for sleepContext(ctx, s.utilizationInterval()) {
+		reporter.ReportUtilization(s.Utilization(ctx))
+	}
+}
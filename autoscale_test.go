@@ -0,0 +1,77 @@
+package inferable
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReportUtilization(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Amz-Target") == "AmazonSQS.GetQueueAttributes" {
+			w.Write([]byte(`{"Attributes": {"ApproximateNumberOfMessages": "7"}}`))
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	i, err := New(InferableOptions{
+		APIEndpoint:   server.URL,
+		APISecret:     "test-secret",
+		QueueEndpoint: server.URL,
+	})
+	require.NoError(t, err)
+
+	reports := make(chan UtilizationReport, 10)
+	service, err := i.RegisterServiceWithOptions("scaled", ServiceOptions{
+		MaxConcurrentCalls:  4,
+		UtilizationInterval: 10 * time.Millisecond,
+		UtilizationReporter: UtilizationReporterFunc(func(report UtilizationReport) { reports <- report }),
+	})
+	require.NoError(t, err)
+
+	// The queue depth is unknown until the service has a consumer
+	report := service.Utilization(context.Background())
+	assert.Equal(t, 4, report.Capacity)
+	assert.Equal(t, int64(-1), report.QueueDepth)
+
+	service.region = "us-east-1"
+	service.queueURL = server.URL + "/queue"
+	service.credentials.AccessKeyID = "key"
+	service.credentials.SecretAccessKey = "secret"
+
+	consumer, err := service.newQueueConsumer()
+	require.NoError(t, err)
+	service.consumerMu.Lock()
+	service.consumer = consumer
+	service.consumerMu.Unlock()
+	service.inFlight.Add(1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		service.reportUtilizationLoop(ctx)
+	}()
+
+	select {
+	case report = <-reports:
+	case <-time.After(5 * time.Second):
+		t.Fatal("utilization was not reported")
+	}
+	cancel()
+	<-done
+
+	assert.Equal(t, "scaled", report.Service)
+	assert.Equal(t, 1, report.InFlight)
+	assert.Equal(t, 4, report.Capacity)
+	assert.Equal(t, 0.25, report.Utilization)
+	assert.Equal(t, int64(7), report.QueueDepth)
+	assert.False(t, report.Time.IsZero())
+}
@@ -0,0 +1,104 @@
+package inferable
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// beginBatch starts collecting the results of the calls received in a poll
+func (s *Service) beginBatch() {
+	s.batchMu.Lock()
+	defer s.batchMu.Unlock()
+
+	s.batch = []pendingPersist{}
+}
+
+// addToBatch adds a result to the batch being collected. It reports false if no batch is being collected.
+func (s *Service) addToBatch(ctx context.Context, call CallInfo, result CallResult, meta resultMetadata) bool {
+	s.batchMu.Lock()
+	defer s.batchMu.Unlock()
+
+	if s.batch == nil {
+		return false
+	}
+
+	s.batch = append(s.batch, pendingPersist{ctx: ctx, call: call, result: result, meta: meta})
+	return true
+}
+
+// flushBatch submits the collected results in a single request. If the API does not support batched
+// results, they are persisted one by one. Results which time out are handed to the background worker.
+func (s *Service) flushBatch(ctx context.Context) (err error) {
+	defer func() {
+		if err != nil {
+			s.reportError(err, ErrorContext{Kind: ErrorKindPersist})
+		}
+	}()
+
+	s.batchMu.Lock()
+	batch := s.batch
+	s.batch = nil
+	s.batchMu.Unlock()
+
+	if len(batch) == 0 {
+		return nil
+	}
+
+	persistCtx, cancel := context.WithTimeout(ctx, s.persistTimeout())
+	defer cancel()
+
+	err = s.persistBatch(persistCtx, batch)
+
+	var apiErr *APIError
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound:
+		s.inferable.logf(LogLevelDebug, "Batched results are not supported by the API, persisting %d results individually", len(batch))
+		for _, pending := range batch {
+			if err := s.persistWithTimeout(pending.ctx, pending.call, pending.result, pending.meta); err != nil {
+				return err
+			}
+		}
+		return nil
+	case errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil:
+		for _, pending := range batch {
+			if err := s.enqueuePersist(pending, err); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return err
+}
+
+func (s *Service) persistBatch(ctx context.Context, batch []pendingPersist) error {
+	results := make([]persistedResult, 0, len(batch))
+	for _, pending := range batch {
+		result := newPersistedResult(pending.result, pending.meta)
+		result.JobID = pending.call.ID
+		results = append(results, result)
+	}
+
+	payloadJSON, err := json.Marshal(map[string]interface{}{"results": results})
+	if err != nil {
+		return fmt.Errorf("failed to marshal batched results: %v", err)
+	}
+
+	_, err = s.inferable.FetchData(FetchDataOptions{
+		Path:    "/jobs/results",
+		Method:  "POST",
+		Headers: s.machineHeaders(),
+		Body:    string(payloadJSON),
+		Context: ctx,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to persist %d batched results: %w", len(batch), err)
+	}
+
+	return nil
+}
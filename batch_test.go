@@ -0,0 +1,117 @@
+package inferable
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newBatchTestServer(t *testing.T, supportsBatch bool) (*httptest.Server, func() map[string]int) {
+	var mu sync.Mutex
+	requests := map[string]int{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		action := r.Header.Get("X-Amz-Target")
+		switch {
+		case action == "AmazonSQS.ReceiveMessage":
+			messages := []map[string]string{}
+			for idx := 1; idx <= 3; idx++ {
+				body := fmt.Sprintf(`{"value": {"id": "call-%d", "service": "batched", "targetFn": "Quick", "targetArgs": "{\"value\": {}}"}}`, idx)
+				sum := md5.Sum([]byte(body))
+				messages = append(messages, map[string]string{
+					"MessageId": fmt.Sprint(idx), "ReceiptHandle": fmt.Sprint(idx), "Body": body, "MD5OfBody": hex.EncodeToString(sum[:]),
+				})
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"Messages": messages})
+			return
+		case action != "":
+			requests[action]++
+		case r.URL.Path == "/jobs/results":
+			requests[r.URL.Path]++
+			if !supportsBatch {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+
+			var payload struct {
+				Results []persistedResult `json:"results"`
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+			requests["batched"] += len(payload.Results)
+			assert.Equal(t, "call-1", payload.Results[0].JobID)
+		case strings.HasSuffix(r.URL.Path, "/result"):
+			requests["single"]++
+		}
+		w.Write([]byte(`{}`))
+	}))
+
+	return server, func() map[string]int {
+		mu.Lock()
+		defer mu.Unlock()
+		return requests
+	}
+}
+
+func pollBatched(t *testing.T, server *httptest.Server) {
+	i, err := New(InferableOptions{
+		APIEndpoint:   server.URL,
+		APISecret:     "test-secret",
+		QueueEndpoint: server.URL,
+	})
+	require.NoError(t, err)
+
+	service, err := i.RegisterServiceWithOptions("batched", ServiceOptions{BatchResults: true})
+	require.NoError(t, err)
+
+	type TestInput struct{}
+	require.NoError(t, service.RegisterFunc(Function{
+		Name: "Quick",
+		Func: func(input TestInput) string { return "done" },
+	}))
+
+	service.region = "us-east-1"
+	service.queueURL = server.URL + "/queue"
+	service.credentials.AccessKeyID = "key"
+	service.credentials.SecretAccessKey = "secret"
+
+	consumer, err := service.newQueueConsumer()
+	require.NoError(t, err)
+	require.NoError(t, consumer.poll(context.Background()))
+}
+
+func TestBatchResults(t *testing.T) {
+	server, requests := newBatchTestServer(t, true)
+	defer server.Close()
+
+	pollBatched(t, server)
+
+	assert.Equal(t, 1, requests()["/jobs/results"])
+	assert.Equal(t, 3, requests()["batched"])
+	assert.Equal(t, 0, requests()["single"])
+	assert.Equal(t, 3, requests()["AmazonSQS.DeleteMessage"])
+}
+
+func TestBatchResultsUnsupported(t *testing.T) {
+	server, requests := newBatchTestServer(t, false)
+	defer server.Close()
+
+	pollBatched(t, server)
+
+	// Results fall back to being persisted one by one
+	assert.Equal(t, 1, requests()["/jobs/results"])
+	assert.Equal(t, 3, requests()["single"])
+	assert.Equal(t, 3, requests()["AmazonSQS.DeleteMessage"])
+}
@@ -0,0 +1,208 @@
+package inferable
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxLocalCacheEntries bounds the number of results cached by a service with CacheConfig.Local
+const maxLocalCacheEntries = 1000
+
+// CacheConfig declares that calls of a function with the same key have the same result for the TTL
+type CacheConfig struct {
+	// KeyPath is a JSONPath into the input identifying calls with the same result, e.g. "$.customerId".
+	// Defaults to "$", the whole input.
+	KeyPath string
+	// TTL is how long results are cached
+	TTL time.Duration
+	// Local also caches results on the machine, so that calls with the same key are answered without
+	// calling the function. It is only suitable for pure functions.
+	Local bool
+}
+
+// functionRegistrationCache is the cache configuration of a function, as sent at registration
+type functionRegistrationCache struct {
+	KeyPath    string `json:"keyPath"`
+	TTLSeconds int64  `json:"ttlSeconds"`
+}
+
+func (c *CacheConfig) validate() error {
+	if c.TTL < time.Second {
+		return fmt.Errorf("cache TTL must be at least a second, got %s", c.TTL)
+	}
+
+	if _, err := parseKeyPath(c.keyPath()); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *CacheConfig) keyPath() string {
+	if c.KeyPath == "" {
+		return "$"
+	}
+
+	return c.KeyPath
+}
+
+func (c *CacheConfig) registrationConfig() *functionRegistrationCache {
+	if c == nil {
+		return nil
+	}
+
+	return &functionRegistrationCache{KeyPath: c.keyPath(), TTLSeconds: int64(c.TTL / time.Second)}
+}
+
+// parseKeyPath parses a JSONPath of object fields, e.g. "$.customer.id"
+func parseKeyPath(path string) ([]string, error) {
+	if path != "$" && !strings.HasPrefix(path, "$.") {
+		return nil, fmt.Errorf("cache key path must be '$' or start with '$.', got '%s'", path)
+	}
+
+	fields := strings.Split(path, ".")[1:]
+	for _, field := range fields {
+		if field == "" {
+			return nil, fmt.Errorf("invalid cache key path '%s'", path)
+		}
+	}
+
+	return fields, nil
+}
+
+// cacheKey returns the key of a call to fn with the given input ({"value": ...}), or false if the
+// input has no value at the key path
+func cacheKey(fn Function, targetArgs []byte) (string, bool) {
+	fields, err := parseKeyPath(fn.Config.Cache.keyPath())
+	if err != nil {
+		return "", false
+	}
+
+	var input struct {
+		Value json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal(targetArgs, &input); err != nil || input.Value == nil {
+		return "", false
+	}
+
+	value := input.Value
+	for _, field := range fields {
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(value, &object); err != nil {
+			return "", false
+		}
+		if value = object[field]; value == nil {
+			return "", false
+		}
+	}
+
+	// Normalize the value, so that formatting does not change the key
+	if normalized, err := normalizeJSON(value); err == nil {
+		value = normalized
+	}
+
+	sum := sha256.Sum256(value)
+	return fn.Name + "@" + fn.Version + ":" + hex.EncodeToString(sum[:]), true
+}
+
+// normalizeJSON re-encodes a JSON value, which sorts the keys of objects and removes whitespace.
+// Numbers keep their exact digits, so that large integer IDs do not collapse into the same key.
+func normalizeJSON(value json.RawMessage) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(value))
+	decoder.UseNumber()
+
+	var decoded interface{}
+	if err := decoder.Decode(&decoded); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(decoded)
+}
+
+type cachedResult struct {
+	result  CallResult
+	expires time.Time
+}
+
+// resultCache holds results of functions with CacheConfig.Local
+type resultCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedResult
+}
+
+// get returns the cached result of a call to fn, if any
+func (c *resultCache) get(fn Function, targetArgs []byte) (CallResult, bool) {
+	if fn.Config.Cache == nil || !fn.Config.Cache.Local {
+		return CallResult{}, false
+	}
+
+	key, ok := cacheKey(fn, targetArgs)
+	if !ok {
+		return CallResult{}, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return CallResult{}, false
+	}
+	if time.Now().After(entry.expires) {
+		delete(c.entries, key)
+		return CallResult{}, false
+	}
+
+	return entry.result, true
+}
+
+// put caches the result of a call to fn, if it resolved
+func (c *resultCache) put(fn Function, targetArgs []byte, result CallResult) {
+	if fn.Config.Cache == nil || !fn.Config.Cache.Local || result.Type != "resolution" {
+		return
+	}
+
+	key, ok := cacheKey(fn, targetArgs)
+	if !ok {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entries == nil {
+		c.entries = map[string]cachedResult{}
+	}
+
+	if len(c.entries) >= maxLocalCacheEntries {
+		c.evict()
+	}
+
+	c.entries[key] = cachedResult{result: result, expires: time.Now().Add(fn.Config.Cache.TTL)}
+}
+
+// evict removes the expired entries, or else the entry closest to expiring
+func (c *resultCache) evict() {
+	now := time.Now()
+	oldestKey := ""
+	var oldest time.Time
+	for key, entry := range c.entries {
+		if now.After(entry.expires) {
+			delete(c.entries, key)
+			continue
+		}
+		if oldestKey == "" || entry.expires.Before(oldest) {
+			oldestKey, oldest = key, entry.expires
+		}
+	}
+
+	if len(c.entries) >= maxLocalCacheEntries {
+		delete(c.entries, oldestKey)
+	}
+}
@@ -0,0 +1,121 @@
+package inferable
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFunctionCache(t *testing.T) {
+	var registration struct {
+		Functions []json.RawMessage `json:"functions"`
+	}
+	results := map[string]persistedResult{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/machines":
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&registration))
+		case "/jobs/call-1/result", "/jobs/call-2/result", "/jobs/call-3/result":
+			var persisted persistedResult
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&persisted))
+			results[r.URL.Path] = persisted
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	i, err := New(InferableOptions{
+		APIEndpoint: server.URL,
+		APISecret:   "test-secret",
+	})
+	require.NoError(t, err)
+
+	type TestInput struct {
+		CustomerID string `json:"customerId"`
+		Verbose    bool   `json:"verbose"`
+	}
+
+	calls := 0
+	require.NoError(t, i.Default.RegisterFunc(Function{
+		Name: "GetCustomer",
+		Func: func(input TestInput) string {
+			calls++
+			return "customer " + input.CustomerID
+		},
+		Config: FunctionConfig{Cache: &CacheConfig{KeyPath: "$.customerId", TTL: time.Minute, Local: true}},
+	}))
+
+	require.NoError(t, i.Default.registerMachine())
+	require.Len(t, registration.Functions, 1)
+	assert.Contains(t, string(registration.Functions[0]), `"cache":{"keyPath":"$.customerId","ttlSeconds":60}`)
+
+	call := func(id, input string) {
+		body, err := json.Marshal(map[string]interface{}{
+			"value": map[string]string{"id": id, "service": "default", "targetFn": "GetCustomer", "targetArgs": input},
+		})
+		require.NoError(t, err)
+		require.NoError(t, i.Default.handleMessage(&sqs.Message{Body: aws.String(string(body))}, time.Now()))
+	}
+
+	// Calls with the same key are answered from the cache
+	call("call-1", `{"value": {"customerId": "c1"}}`)
+	call("call-2", `{"value": {"customerId": "c1", "verbose": true}}`)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, results["/jobs/call-1/result"].Result, results["/jobs/call-2/result"].Result)
+	assert.False(t, results["/jobs/call-1/result"].Meta.Cached)
+	assert.True(t, results["/jobs/call-2/result"].Meta.Cached)
+
+	call("call-3", `{"value": {"customerId": "c2"}}`)
+	assert.Equal(t, 2, calls)
+
+	type EmptyInput struct{}
+	for _, cache := range []*CacheConfig{{TTL: time.Millisecond}, {KeyPath: "customerId", TTL: time.Minute}, {KeyPath: "$..id", TTL: time.Minute}} {
+		err := i.Default.RegisterFunc(Function{Name: "Invalid", Func: func(input EmptyInput) int { return 1 }, Config: FunctionConfig{Cache: cache}})
+		assert.Error(t, err)
+	}
+}
+
+func TestResultCacheEviction(t *testing.T) {
+	fn := Function{Name: "Pure", Config: FunctionConfig{Cache: &CacheConfig{TTL: time.Minute, Local: true}}}
+	cache := resultCache{}
+
+	for n := 0; n <= maxLocalCacheEntries; n++ {
+		input, err := json.Marshal(map[string]int{"value": n})
+		require.NoError(t, err)
+		cache.put(fn, input, CallResult{Type: "resolution", Value: "1"})
+	}
+	assert.Len(t, cache.entries, maxLocalCacheEntries)
+
+	// Rejections are not cached
+	cache.put(fn, []byte(`{"value": "rejected"}`), CallResult{Type: "rejection"})
+	_, ok := cache.get(fn, []byte(`{"value": "rejected"}`))
+	assert.False(t, ok)
+}
+
+func TestCacheKeyLargeIntegers(t *testing.T) {
+	fn := Function{Name: "Customer", Config: FunctionConfig{Cache: &CacheConfig{TTL: time.Minute, KeyPath: "$.customerId", Local: true}}}
+	cache := resultCache{}
+
+	// Adjacent integers above 2^53 are identical as float64, but belong to different customers
+	first, ok := cacheKey(fn, []byte(`{"value": {"customerId": 9007199254740993}}`))
+	require.True(t, ok)
+	second, ok := cacheKey(fn, []byte(`{"value": {"customerId": 9007199254740992}}`))
+	require.True(t, ok)
+	assert.NotEqual(t, first, second)
+
+	cache.put(fn, []byte(`{"value": {"customerId": 9007199254740993}}`), CallResult{Type: "resolution", Value: `"first"`})
+	_, ok = cache.get(fn, []byte(`{"value": {"customerId": 9007199254740992}}`))
+	assert.False(t, ok)
+
+	// Formatting still does not change the key
+	formatted, ok := cacheKey(fn, []byte(`{"value": {"customerId":   9007199254740993 }}`))
+	require.True(t, ok)
+	assert.Equal(t, first, formatted)
+}
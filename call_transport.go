@@ -0,0 +1,202 @@
+package inferable
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+)
+
+// Transport delivers the calls of a service from the cluster to its message handler.
+// SQSConsumer is the transport of clusters which issue queue credentials at registration.
+type Transport interface {
+	// Start delivers calls until ctx is done. An error stops the service.
+	Start(ctx context.Context) error
+	// LastActivity is when the transport last made progress. The watchdog restarts transports
+	// which make no progress for longer than their IdleCycle.
+	LastActivity() time.Time
+	// IdleCycle is how long a healthy transport may make no progress, e.g. while waiting to poll
+	IdleCycle() time.Duration
+}
+
+var (
+	_ Transport   = (*SQSConsumer)(nil)
+	_ pollStopper = (*SQSConsumer)(nil)
+)
+
+const (
+	// credentialsRefreshWindow is how long before they expire queue credentials are refreshed
+	credentialsRefreshWindow = 5 * time.Minute
+	// credentialsRetryInterval is how long a failed refresh of queue credentials waits to be retried
+	credentialsRetryInterval = 30 * time.Second
+)
+
+// SQSCredentials are the temporary credentials of a queue, as issued at registration
+type SQSCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// Expiration is when the credentials expire. Credentials without an expiration are never refreshed.
+	Expiration time.Time
+}
+
+// refreshingCredentials provides queue credentials to the AWS SDK, refreshing them before they expire
+type refreshingCredentials struct {
+	mu      sync.Mutex
+	current SQSCredentials
+	window  time.Duration
+	refresh func() (SQSCredentials, error)
+}
+
+// Retrieve returns the current credentials, refreshing them if they are about to expire.
+// Credentials which failed to refresh are used until they have expired.
+func (p *refreshingCredentials) Retrieve() (credentials.Value, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.expiring() {
+		refreshed, err := p.refresh()
+		switch {
+		case err == nil:
+			p.current = refreshed
+		case !time.Now().Before(p.current.Expiration):
+			return credentials.Value{}, fmt.Errorf("failed to refresh expired queue credentials: %v", err)
+		}
+	}
+
+	return credentials.Value{
+		AccessKeyID:     p.current.AccessKeyID,
+		SecretAccessKey: p.current.SecretAccessKey,
+		SessionToken:    p.current.SessionToken,
+		ProviderName:    "InferableRegistration",
+	}, nil
+}
+
+// IsExpired reports whether the credentials are due to be refreshed
+func (p *refreshingCredentials) IsExpired() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.expiring()
+}
+
+func (p *refreshingCredentials) expiring() bool {
+	return !p.current.Expiration.IsZero() && time.Now().After(p.current.Expiration.Add(-p.window))
+}
+
+// sqsCredentials returns the queue credentials issued at the last registration
+func (s *Service) sqsCredentials() SQSCredentials {
+	s.credentialsMu.RLock()
+	defer s.credentialsMu.RUnlock()
+
+	return SQSCredentials{
+		AccessKeyID:     s.credentials.AccessKeyID,
+		SecretAccessKey: s.credentials.SecretAccessKey,
+		SessionToken:    s.credentials.SessionToken,
+		Expiration:      s.expiration,
+	}
+}
+
+// refreshCredentials registers the machine again, which issues new queue credentials. It is called by both
+// refreshCredentialsLoop and the transport, so a refresh which waited for another one to finish returns the
+// credentials issued by it instead of registering again.
+func (s *Service) refreshCredentials() (SQSCredentials, error) {
+	stale := s.sqsCredentials()
+
+	s.refreshMu.Lock()
+	defer s.refreshMu.Unlock()
+
+	if current := s.sqsCredentials(); current.Expiration.After(stale.Expiration) {
+		return current, nil
+	}
+
+	s.inferable.logf(LogLevelInfo, "Refreshing queue credentials of service '%s'", s.Name)
+
+	if err := s.registerMachine(); err != nil {
+		s.inferable.logf(LogLevelError, "Failed to refresh queue credentials of service '%s': %v", s.Name, err)
+		return SQSCredentials{}, err
+	}
+
+	return s.sqsCredentials(), nil
+}
+
+// credentialsUpdater is implemented by transports whose credentials can be replaced while they run
+type credentialsUpdater interface {
+	UpdateCredentials(credentials SQSCredentials)
+}
+
+// concurrencySetter is implemented by transports whose concurrency can be changed while they run
+type concurrencySetter interface {
+	SetConcurrency(n int)
+}
+
+// pollStopper is implemented by transports which can stop receiving calls while the calls they received
+// are still being handled, see Service.Drain
+type pollStopper interface {
+	StopPolling()
+}
+
+// refreshCredentialsLoop registers the machine again ahead of the expiration of its queue credentials, and
+// hands the new credentials to the running transport without restarting it, until ctx is done
+func (s *Service) refreshCredentialsLoop(ctx context.Context) {
+	for {
+		expiration := s.sqsCredentials().Expiration
+		if expiration.IsZero() {
+			return
+		}
+
+		if !sleepContext(ctx, time.Until(expiration.Add(-credentialsRefreshWindow))) {
+			return
+		}
+
+		// The credentials may have been refreshed by the transport in the meantime
+		if current := s.sqsCredentials(); current.Expiration.After(expiration) {
+			continue
+		}
+
+		credentials, err := s.refreshCredentials()
+		if errors.Is(err, ErrAuthExpired) {
+			// The secret stays rejected after refreshing it, so the service stops instead of retrying
+			s.closeDone(fmt.Errorf("refreshing queue credentials of service '%s' failed: %w", s.Name, err))
+			s.Stop()
+			return
+		}
+		if err != nil || !credentials.Expiration.After(expiration) {
+			if err == nil {
+				s.inferable.logf(LogLevelError, "Registration of service '%s' did not extend its queue credentials", s.Name)
+			}
+			if !sleepContext(ctx, credentialsRetryInterval) {
+				return
+			}
+			continue
+		}
+
+		s.consumerMu.Lock()
+		consumer := s.consumer
+		s.consumerMu.Unlock()
+
+		if updater, ok := consumer.(credentialsUpdater); ok {
+			updater.UpdateCredentials(credentials)
+		}
+	}
+}
+
+// sleepContext waits for d, returning false if ctx is done first
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,91 @@
+package inferable
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// callGroupWaitTimeout is how long a finished call waits for its goroutines to exit after canceling them
+const callGroupWaitTimeout = 5 * time.Second
+
+type callGroupKey struct{}
+
+// callGroup tracks the goroutines spawned with Go while handling a call
+type callGroup struct {
+	wg     sync.WaitGroup
+	cancel context.CancelFunc
+
+	mu  sync.Mutex
+	err error
+}
+
+// withCallGroup returns a context which tracks the goroutines spawned with Go,
+// and is canceled by the returned group once the call has finished
+func withCallGroup(ctx context.Context) (context.Context, *callGroup) {
+	ctx, cancel := context.WithCancel(ctx)
+	group := &callGroup{cancel: cancel}
+
+	return context.WithValue(ctx, callGroupKey{}, group), group
+}
+
+// Go runs fn in a goroutine which is tied to the call being handled by ctx. When the function handling
+// the call returns, the context passed to fn is canceled and the goroutine is awaited, so that tool
+// implementations do not leak goroutines. Use Wait to await the goroutines and collect their first error.
+//
+// If ctx was not passed to a function by the service, fn is run in an untracked goroutine.
+func Go(ctx context.Context, fn func(ctx context.Context) error) {
+	group, ok := ctx.Value(callGroupKey{}).(*callGroup)
+	if !ok {
+		go fn(ctx)
+		return
+	}
+
+	group.wg.Add(1)
+	go func() {
+		defer group.wg.Done()
+
+		if err := fn(ctx); err != nil {
+			group.mu.Lock()
+			if group.err == nil {
+				group.err = err
+			}
+			group.mu.Unlock()
+		}
+	}()
+}
+
+// Wait blocks until all goroutines spawned with Go for the call have returned,
+// returning the first error any of them returned
+func Wait(ctx context.Context) error {
+	group, ok := ctx.Value(callGroupKey{}).(*callGroup)
+	if !ok {
+		return nil
+	}
+
+	group.wg.Wait()
+
+	group.mu.Lock()
+	defer group.mu.Unlock()
+
+	return group.err
+}
+
+// finish cancels the goroutines of the call and waits up to timeout for them to exit.
+// It reports whether all goroutines exited in time.
+func (g *callGroup) finish(timeout time.Duration) bool {
+	g.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
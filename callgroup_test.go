@@ -0,0 +1,82 @@
+package inferable
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCallGroup(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	i, err := New(InferableOptions{
+		APIEndpoint: server.URL,
+		APISecret:   "test-secret",
+	})
+	require.NoError(t, err)
+
+	type TestInput struct{}
+
+	var canceled atomic.Bool
+	var waitErr error
+
+	err = i.Default.RegisterFunc(Function{
+		Name: "Spawn",
+		Func: func(ctx context.Context, input TestInput) string {
+			Go(ctx, func(ctx context.Context) error {
+				return fmt.Errorf("lookup failed")
+			})
+			Go(ctx, func(ctx context.Context) error {
+				return nil
+			})
+			waitErr = Wait(ctx)
+
+			// Left running when the function returns
+			Go(ctx, func(ctx context.Context) error {
+				select {
+				case <-ctx.Done():
+					canceled.Store(true)
+				case <-time.After(10 * time.Second):
+				}
+				return nil
+			})
+
+			return "done"
+		},
+	})
+	require.NoError(t, err)
+
+	body := `{"value": {"id": "call-1", "service": "default", "targetFn": "Spawn", "targetArgs": "{\"value\": {}}"}}`
+	require.NoError(t, i.Default.handleMessage(&sqs.Message{Body: aws.String(body)}, time.Now()))
+
+	assert.EqualError(t, waitErr, "lookup failed")
+	// The goroutine was canceled and awaited before the call finished
+	assert.True(t, canceled.Load())
+}
+
+func TestGoWithoutCall(t *testing.T) {
+	done := make(chan struct{})
+	Go(context.Background(), func(ctx context.Context) error {
+		close(done)
+		return nil
+	})
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("goroutine did not run")
+	}
+	assert.NoError(t, Wait(context.Background()))
+}
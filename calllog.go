@@ -0,0 +1,104 @@
+package inferable
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"time"
+)
+
+// CallLogging configures the structured logs of the lifecycle of calls: when each call is received,
+// when its function starts and when it finishes. Logs are written at info level, see InferableOptions.Logger.
+type CallLogging struct {
+	// SampleRate is the fraction of calls which are logged, between 0 and 1. Calls are not logged if it is 0.
+	// All logs of a call are sampled alike, including retries.
+	SampleRate float64
+	// IncludeInput and IncludeResult add the input and result of calls to the logs, with the fields
+	// tagged as secret redacted. They are never added for functions with FunctionConfig.Sensitive.
+	IncludeInput  bool
+	IncludeResult bool
+}
+
+func (c CallLogging) validate() error {
+	if c.SampleRate < 0 || c.SampleRate > 1 {
+		return fmt.Errorf("call log sample rate must be between 0 and 1, got %v", c.SampleRate)
+	}
+
+	return nil
+}
+
+// sampled reports whether the calls with callID are logged
+func (c CallLogging) sampled(callID string) bool {
+	if c.SampleRate <= 0 {
+		return false
+	}
+	if c.SampleRate >= 1 {
+		return true
+	}
+
+	hash := fnv.New32a()
+	hash.Write([]byte(callID))
+	return float64(hash.Sum32()%10000) < c.SampleRate*10000
+}
+
+// logCall writes a call lifecycle log if the call is sampled
+func (s *Service) logCall(call CallInfo, msg string, fields map[string]interface{}) {
+	if !s.options.CallLogging.sampled(call.ID) {
+		return
+	}
+
+	fields["callId"] = call.ID
+	fields["service"] = call.Service
+	fields["function"] = call.Function
+	s.inferable.logFields(LogLevelInfo, msg, fields)
+}
+
+func (s *Service) logCallReceived(call CallInfo, fn Function, registered bool, targetArgs []byte) {
+	fields := map[string]interface{}{"attempt": call.Attempt}
+	if s.options.CallLogging.IncludeInput && !fn.Config.Sensitive {
+		input := targetArgs
+		if redacted, ok := redactedInput(fn, registered, targetArgs); ok {
+			input = redacted
+		}
+		var args struct {
+			Value json.RawMessage `json:"value"`
+		}
+		if err := json.Unmarshal(input, &args); err == nil && args.Value != nil {
+			fields["input"] = string(args.Value)
+		}
+	}
+
+	s.logCall(call, "Call received", fields)
+}
+
+func (s *Service) logCallStarted(call CallInfo, meta resultMetadata) {
+	s.logCall(call, "Call started", map[string]interface{}{"queueWaitTime": meta.QueueWaitTime})
+}
+
+func (s *Service) logCallFinished(call CallInfo, fn Function, result CallResult, duration time.Duration, meta resultMetadata) {
+	fields := map[string]interface{}{
+		"duration":   duration.Milliseconds(),
+		"resultType": result.Type,
+		"cached":     meta.Cached,
+	}
+	if s.options.CallLogging.IncludeResult && !fn.Config.Sensitive && !meta.Chunked {
+		fields["result"] = redactedResult(fn, result)
+	}
+
+	s.logCall(call, "Call finished", fields)
+}
+
+// redactedResult returns the value of a result with the secret fields of the result type of fn redacted
+func redactedResult(fn Function, result CallResult) string {
+	if result.Type != "resolution" || fn.Func == nil {
+		return result.Value
+	}
+
+	fnType := reflect.TypeOf(fn.Func)
+	if fnType.Kind() != reflect.Func || fnType.NumOut() == 0 {
+		return result.Value
+	}
+
+	return string(redactionFor(fnType.Out(0)).apply(json.RawMessage(result.Value)))
+}
@@ -0,0 +1,98 @@
+package inferable
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type capturedLog struct {
+	msg    string
+	fields map[string]interface{}
+}
+
+type captureLogger struct {
+	mu   sync.Mutex
+	logs []capturedLog
+}
+
+func (l *captureLogger) Log(level LogLevel, msg string, fields map[string]interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if fields != nil {
+		l.logs = append(l.logs, capturedLog{msg: msg, fields: fields})
+	}
+}
+
+func TestCallLogging(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	logger := &captureLogger{}
+	i, err := New(InferableOptions{
+		APIEndpoint: server.URL,
+		APISecret:   "test-secret",
+		Logger:      logger,
+	})
+	require.NoError(t, err)
+
+	type Credentials struct {
+		User     string `json:"user"`
+		Password string `json:"password" inferable:"secret"`
+	}
+	service, err := i.RegisterServiceWithOptions("logged", ServiceOptions{
+		CallLogging: CallLogging{SampleRate: 1, IncludeInput: true, IncludeResult: true},
+	})
+	require.NoError(t, err)
+	require.NoError(t, service.RegisterFunc(Function{
+		Name: "Login",
+		Func: func(input Credentials) Credentials { return input },
+	}))
+
+	body := `{"value": {"id": "call-1", "service": "logged", "targetFn": "Login", "targetArgs": "{\"value\": {\"user\": \"jane\", \"password\": \"hunter2\"}}"}}`
+	require.NoError(t, service.handleMessage(&sqs.Message{Body: aws.String(body)}, time.Now()))
+
+	require.Len(t, logger.logs, 3)
+	assert.Equal(t, "Call received", logger.logs[0].msg)
+	assert.Equal(t, "call-1", logger.logs[0].fields["callId"])
+	assert.Equal(t, "Login", logger.logs[0].fields["function"])
+	assert.Equal(t, 1, logger.logs[0].fields["attempt"])
+	assert.JSONEq(t, `{"user": "jane", "password": "[REDACTED]"}`, logger.logs[0].fields["input"].(string))
+
+	assert.Equal(t, "Call started", logger.logs[1].msg)
+	assert.Contains(t, logger.logs[1].fields, "queueWaitTime")
+
+	assert.Equal(t, "Call finished", logger.logs[2].msg)
+	assert.Equal(t, "resolution", logger.logs[2].fields["resultType"])
+	assert.Contains(t, logger.logs[2].fields, "duration")
+	assert.JSONEq(t, `{"user": "jane", "password": "[REDACTED]"}`, logger.logs[2].fields["result"].(string))
+
+	assert.Error(t, ServiceOptions{CallLogging: CallLogging{SampleRate: 1.5}}.validate())
+}
+
+func TestCallLoggingSampling(t *testing.T) {
+	assert.False(t, CallLogging{}.sampled("call-1"))
+	assert.True(t, CallLogging{SampleRate: 1}.sampled("call-1"))
+
+	sampling := CallLogging{SampleRate: 0.25}
+	sampled := 0
+	for n := 0; n < 10000; n++ {
+		id := fmt.Sprintf("call-%d", n)
+		if sampling.sampled(id) {
+			sampled++
+		}
+		// Every log of a call is sampled alike
+		assert.Equal(t, sampling.sampled(id), sampling.sampled(id))
+	}
+	assert.InDelta(t, 2500, sampled, 250)
+}
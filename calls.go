@@ -0,0 +1,104 @@
+package inferable
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// DefaultCallWaitTime is how long Call waits for a result when CallInput.WaitTime is not set
+const DefaultCallWaitTime = 30 * time.Second
+
+type CallInput struct {
+	Service  string
+	Function string
+	Input    interface{}
+	// WaitTime is how long the API waits for the call to complete. Defaults to DefaultCallWaitTime.
+	WaitTime time.Duration
+}
+
+// CallRejectedError is returned when the function rejected the call
+type CallRejectedError struct {
+	Service  string
+	Function string
+	// Value is the JSON encoded rejection
+	Value json.RawMessage
+}
+
+func (e *CallRejectedError) Error() string {
+	return fmt.Sprintf("call to '%s.%s' was rejected: %s", e.Service, e.Function, string(e.Value))
+}
+
+// Call executes a function in the cluster and blocks until its result is available.
+// The result is unmarshaled into out, unless out is nil. If the function rejected the call,
+// a *CallRejectedError is returned.
+func (i *Inferable) Call(ctx context.Context, input CallInput, out interface{}) error {
+	if i.clusterID == "" {
+		return fmt.Errorf("cluster ID must be provided to execute calls")
+	}
+
+	jsonPayload, err := input.payload()
+	if err != nil {
+		return err
+	}
+
+	waitTime := input.WaitTime
+	if waitTime == 0 {
+		waitTime = DefaultCallWaitTime
+	}
+
+	responseData, err := i.FetchData(FetchDataOptions{
+		Path:        fmt.Sprintf("/clusters/%s/execute", i.clusterID),
+		Method:      "POST",
+		Body:        string(jsonPayload),
+		QueryParams: map[string]string{"waitTime": strconv.Itoa(int(waitTime.Seconds()))},
+		Context:     ctx,
+		// The API holds the request until the call completes or waitTime has passed
+		Timeout: i.client.longPollTimeout(waitTime),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to execute call: %w", err)
+	}
+
+	status := &JobStatus{}
+	if err := json.Unmarshal(responseData, status); err != nil {
+		return fmt.Errorf("failed to parse call response: %v", err)
+	}
+
+	if status.ResultType != "rejection" && status.Status != JobStatusSuccess {
+		return fmt.Errorf("call to '%s.%s' did not complete within %s (status: %s)", input.Service, input.Function, waitTime, status.Status)
+	}
+
+	return status.decode(input.Service, input.Function, out)
+}
+
+func (c CallInput) payload() ([]byte, error) {
+	if c.Service == "" || c.Function == "" {
+		return nil, fmt.Errorf("service and function are required")
+	}
+
+	jsonPayload, err := json.Marshal(map[string]interface{}{
+		"service":  c.Service,
+		"function": c.Function,
+		"input":    c.Input,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal call payload: %v", err)
+	}
+
+	return jsonPayload, nil
+}
+
+// unwrapResultValue extracts the value from a result persisted in the {"value": ...} envelope
+func unwrapResultValue(result json.RawMessage) json.RawMessage {
+	var envelope map[string]json.RawMessage
+	if err := json.Unmarshal(result, &envelope); err == nil && len(envelope) == 1 {
+		if value, ok := envelope["value"]; ok {
+			return value
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,93 @@
+package inferable
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCall(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/clusters/test-cluster/execute" {
+			w.Write([]byte(`{}`))
+			return
+		}
+
+		var payload struct {
+			Function string            `json:"function"`
+			Input    map[string]string `json:"input"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		assert.Equal(t, "5", r.URL.Query().Get("waitTime"))
+
+		if payload.Function == "fail" {
+			w.Write([]byte(`{"status": "success", "resultType": "rejection", "result": {"value": "not found"}}`))
+			return
+		}
+
+		w.Write([]byte(`{"status": "success", "resultType": "resolution", "result": {"value": {"greeting": "Hello, ` + payload.Input["name"] + `"}}}`))
+	}))
+	defer server.Close()
+
+	i, err := New(InferableOptions{
+		APIEndpoint: server.URL,
+		APISecret:   "test-secret",
+		ClusterID:   "test-cluster",
+	})
+	require.NoError(t, err)
+
+	var out struct {
+		Greeting string `json:"greeting"`
+	}
+	err = i.Call(context.Background(), CallInput{
+		Service:  "default",
+		Function: "greet",
+		Input:    map[string]string{"name": "Inferable"},
+		WaitTime: 5e9,
+	}, &out)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello, Inferable", out.Greeting)
+
+	err = i.Call(context.Background(), CallInput{
+		Service:  "default",
+		Function: "fail",
+		WaitTime: 5e9,
+	}, nil)
+	var rejected *CallRejectedError
+	require.True(t, errors.As(err, &rejected))
+	assert.Equal(t, `"not found"`, string(rejected.Value))
+}
+
+func TestCallLongPollTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The API holds the response for longer than the request timeout, until the call completes
+		time.Sleep(200 * time.Millisecond)
+		w.Write([]byte(`{"status": "success", "resultType": "resolution", "result": {"value": "done"}}`))
+	}))
+	defer server.Close()
+
+	i, err := New(InferableOptions{
+		APIEndpoint:    server.URL,
+		APISecret:      "test-secret",
+		ClusterID:      "test-cluster",
+		RequestTimeout: 50 * time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	var out string
+	require.NoError(t, i.Call(context.Background(), CallInput{Service: "default", Function: "slow", WaitTime: time.Second}, &out))
+	assert.Equal(t, "done", out)
+
+	// Calls waiting for the default wait time outlive the default request timeout
+	i, err = New(InferableOptions{APIEndpoint: server.URL, APISecret: "test-secret"})
+	require.NoError(t, err)
+	assert.Greater(t, i.client.longPollTimeout(DefaultCallWaitTime), DefaultRequestTimeout)
+	assert.Greater(t, i.client.longPollTimeout(time.Minute), time.Minute)
+}
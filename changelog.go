@@ -0,0 +1,122 @@
+package inferable
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// RegistrationChanges describes how the functions of a service changed since its previous registration
+type RegistrationChanges struct {
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+	// Changed functions have a different description, schema or configuration
+	Changed []string `json:"changed,omitempty"`
+}
+
+// Empty reports whether the registration is unchanged
+func (c RegistrationChanges) Empty() bool {
+	return len(c.Added) == 0 && len(c.Removed) == 0 && len(c.Changed) == 0
+}
+
+// diffRegistrations compares the functions of two registrations of a service
+func diffRegistrations(previous, current []functionRegistration) RegistrationChanges {
+	previousByName := map[string]functionRegistration{}
+	for _, fn := range previous {
+		previousByName[fn.Name] = fn
+	}
+
+	changes := RegistrationChanges{}
+	for _, fn := range current {
+		prev, ok := previousByName[fn.Name]
+		delete(previousByName, fn.Name)
+
+		if !ok {
+			changes.Added = append(changes.Added, fn.Name)
+			continue
+		}
+
+		prevJSON, _ := json.Marshal(prev)
+		currJSON, _ := json.Marshal(fn)
+		if string(prevJSON) != string(currJSON) {
+			changes.Changed = append(changes.Changed, fn.Name)
+		}
+	}
+
+	for name := range previousByName {
+		changes.Removed = append(changes.Removed, name)
+	}
+
+	sort.Strings(changes.Added)
+	sort.Strings(changes.Removed)
+	sort.Strings(changes.Changed)
+
+	return changes
+}
+
+func registrationHistoryPath(dir, service string) string {
+	return filepath.Join(dir, fmt.Sprintf("%s.registration.json", service))
+}
+
+// loadRegistration reads the functions persisted by the previous registration of a service.
+// It reports false if the service has not been registered before.
+func loadRegistration(path string) ([]functionRegistration, bool, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read registration history %s: %v", path, err)
+	}
+
+	functions := []functionRegistration{}
+	if err := json.Unmarshal(data, &functions); err != nil {
+		return nil, false, fmt.Errorf("failed to parse registration history %s: %v", path, err)
+	}
+
+	return functions, true, nil
+}
+
+func saveRegistration(path string, functions []functionRegistration) error {
+	data, err := json.MarshalIndent(functions, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal registration history: %v", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create registration history directory: %v", err)
+	}
+
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write registration history %s: %v", path, err)
+	}
+
+	return nil
+}
+
+// registrationChanges compares the functions being registered with the previous registration of the service,
+// logging any changes. It returns nil if there is no registration history.
+func (s *Service) registrationChanges(functions []functionRegistration) *RegistrationChanges {
+	if s.inferable.registrationHistoryDir == "" {
+		return nil
+	}
+
+	previous, ok, err := loadRegistration(registrationHistoryPath(s.inferable.registrationHistoryDir, s.Name))
+	if err != nil {
+		s.inferable.logf(LogLevelError, "Failed to load previous registration of service '%s': %v", s.Name, err)
+		return nil
+	}
+	if !ok {
+		return nil
+	}
+
+	changes := diffRegistrations(previous, functions)
+	if !changes.Empty() {
+		changesJSON, _ := json.Marshal(changes)
+		s.inferable.logf(LogLevelInfo, "Functions of service '%s' changed since the previous registration: %s", s.Name, changesJSON)
+	}
+
+	return &changes
+}
@@ -0,0 +1,75 @@
+package inferable
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistrationChanges(t *testing.T) {
+	var registered struct {
+		Changes *RegistrationChanges `json:"changes"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/machines" {
+			registered.Changes = nil
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&registered))
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+
+	type TestInput struct{}
+	noop := func(input TestInput) string { return "ok" }
+
+	register := func(functions ...Function) {
+		i, err := New(InferableOptions{
+			APIEndpoint:            server.URL,
+			APISecret:              "test-secret",
+			RegistrationHistoryDir: dir,
+		})
+		require.NoError(t, err)
+
+		service, err := i.RegisterServiceWithOptions("tools", ServiceOptions{ReportRegistrationChanges: true})
+		require.NoError(t, err)
+
+		for _, fn := range functions {
+			require.NoError(t, service.RegisterFunc(fn))
+		}
+		require.NoError(t, service.registerMachine())
+	}
+
+	register(
+		Function{Name: "kept", Func: noop},
+		Function{Name: "edited", Description: "Before", Func: noop},
+		Function{Name: "dropped", Func: noop},
+	)
+	// There is no previous registration to compare with
+	assert.Nil(t, registered.Changes)
+
+	register(
+		Function{Name: "kept", Func: noop},
+		Function{Name: "edited", Description: "After", Func: noop},
+		Function{Name: "new", Func: noop},
+	)
+	require.NotNil(t, registered.Changes)
+	assert.Equal(t, RegistrationChanges{
+		Added:   []string{"new"},
+		Removed: []string{"dropped"},
+		Changed: []string{"edited"},
+	}, *registered.Changes)
+
+	register(
+		Function{Name: "kept", Func: noop},
+		Function{Name: "edited", Description: "After", Func: noop},
+		Function{Name: "new", Func: noop},
+	)
+	require.NotNil(t, registered.Changes)
+	assert.True(t, registered.Changes.Empty())
+}
@@ -1,22 +1,82 @@
 package inferable
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// DefaultRequestTimeout is how long a request to the API may take, including reading the response
+const DefaultRequestTimeout = 30 * time.Second
+
+// longPollMargin is how much longer than the time the API waits a long-polling request may take
+const longPollMargin = 10 * time.Second
+
 // Client represents an Inferable API client
 type Client struct {
-	endpoint   string
-	secret     string
-	httpClient *http.Client
+	endpoint       string
+	secretProvider SecretProvider
+	httpClient     *http.Client
+	timeout        time.Duration
+
+	// secretRefreshInterval is how long a secret is used before the provider is asked for the current one
+	secretRefreshInterval time.Duration
+	// userAgent is sent with every request, see ClientOptions.UserAgent
+	userAgent string
+	// endpoints holds the endpoint followed by the fallback endpoints, nil if there are none
+	endpoints *endpointPool
+	// limiter limits the rate of requests, nil if no rate is set, see setRateLimit
+	limiter atomic.Pointer[tokenBucket]
+	// requestsPerSecond and requestBurst are the rate limit of ClientOptions
+	requestsPerSecond float64
+	requestBurst      int
+
+	mu     sync.RWMutex
+	secret string
+	// secretRefreshedAt is when the secret was last set
+	secretRefreshedAt time.Time
 }
 
+// SecretProvider returns the current API secret, e.g. from Vault or AWS Secrets Manager. It is called
+// when the API rejects the secret in use, and periodically with ClientOptions.SecretRefreshInterval,
+// so that a rotated secret is picked up without restarting.
+type SecretProvider func(ctx context.Context) (string, error)
+
 type ClientOptions struct {
 	Endpoint string
 	Secret   string
+	// SecretProvider refreshes the secret when a request is rejected with 401 or 403.
+	// If Secret is empty, it is also used to fetch the initial secret.
+	SecretProvider SecretProvider
+	// SecretRefreshInterval makes requests fetch the current secret from the SecretProvider once the
+	// secret in use is older than the interval, so that a rotated secret is used before the previous
+	// one is revoked. Disabled by default.
+	SecretRefreshInterval time.Duration
+	// Timeout is how long a request may take, so that a stalled connection cannot block the caller
+	// indefinitely. Defaults to DefaultRequestTimeout. A negative value disables the timeout.
+	Timeout time.Duration
+	// Transport tunes connection reuse and HTTP/2
+	Transport TransportOptions
+	// FallbackEndpoints are tried in order when the endpoint can not be reached, e.g. other regions.
+	// An endpoint which could not be reached is skipped for EndpointCooldown before it is tried again.
+	FallbackEndpoints []string
+	// EndpointCooldown defaults to DefaultEndpointCooldown
+	EndpointCooldown time.Duration
+	// RequestsPerSecond limits the rate of requests, including polls of the queue by services, so that large
+	// fleets stay below the rate limits of the API. Rate limited responses hold back all requests until
+	// their Retry-After has passed. Unlimited by default.
+	RequestsPerSecond float64
+	// RequestBurst is how many requests may be sent at once while below RequestsPerSecond. Defaults to 1.
+	RequestBurst int
+	// UserAgent identifies the application making the requests (e.g. "my-billing-service/1.4.2"),
+	// so that server side logs can tell applications apart. It is appended to the User-Agent of the SDK.
+	UserAgent string
 }
 
 // NewClient creates a new Inferable API client
@@ -25,11 +85,93 @@ func NewClient(options ClientOptions) (*Client, error) {
 		return nil, fmt.Errorf("invalid URL: %s", options.Endpoint)
 	}
 
-	return &Client{
-		endpoint:   options.Endpoint,
-		secret:     options.Secret,
-		httpClient: &http.Client{},
-	}, nil
+	if err := validateUserAgent(options.UserAgent); err != nil {
+		return nil, err
+	}
+
+	for _, endpoint := range options.FallbackEndpoints {
+		if !strings.HasPrefix(endpoint, "http://") && !strings.HasPrefix(endpoint, "https://") {
+			return nil, fmt.Errorf("invalid fallback URL: %s", endpoint)
+		}
+	}
+
+	if options.RequestsPerSecond < 0 || options.RequestBurst < 0 {
+		return nil, fmt.Errorf("request rate and burst must not be negative")
+	}
+
+	if options.EndpointCooldown < 0 {
+		return nil, fmt.Errorf("endpoint cooldown must not be negative, got %s", options.EndpointCooldown)
+	}
+
+	transport, err := options.Transport.newTransport()
+	if err != nil {
+		return nil, err
+	}
+
+	var endpoints *endpointPool
+	if len(options.FallbackEndpoints) > 0 {
+		endpoints = newEndpointPool(append([]string{options.Endpoint}, options.FallbackEndpoints...), options.EndpointCooldown)
+	}
+
+	client := &Client{
+		requestsPerSecond:     options.RequestsPerSecond,
+		requestBurst:          options.RequestBurst,
+		endpoints:             endpoints,
+		endpoint:              options.Endpoint,
+		secret:                options.Secret,
+		secretRefreshedAt:     time.Now(),
+		secretProvider:        options.SecretProvider,
+		secretRefreshInterval: options.SecretRefreshInterval,
+		httpClient:            &http.Client{Transport: transport},
+		timeout:               options.Timeout,
+		userAgent:             userAgent(options.UserAgent),
+	}
+	client.setRateLimit(options.RequestsPerSecond, options.RequestBurst)
+
+	return client, nil
+}
+
+// setRateLimit limits the rate of requests, or removes the limit if requestsPerSecond is not positive.
+// The limiter is kept if the limit is unchanged, so that reapplying it does not refill the burst.
+func (c *Client) setRateLimit(requestsPerSecond float64, burst int) {
+	if requestsPerSecond <= 0 {
+		c.limiter.Store(nil)
+		return
+	}
+
+	if current := c.limiter.Load(); current != nil && current.rate == requestsPerSecond && current.burst == float64(max(burst, 1)) {
+		return
+	}
+	c.limiter.Store(newTokenBucket(requestsPerSecond, burst))
+}
+
+// waitRateLimit blocks until the rate limit allows another request, or ctx is done
+func (c *Client) waitRateLimit(ctx context.Context) error {
+	if limiter := c.limiter.Load(); limiter != nil {
+		return limiter.wait(ctx)
+	}
+
+	return nil
+}
+
+// userAgent returns the User-Agent of the SDK, followed by the application if one is given
+func userAgent(application string) string {
+	if application == "" {
+		return "inferable-go/" + Version
+	}
+
+	return "inferable-go/" + Version + " " + application
+}
+
+// validateUserAgent checks that an application identifier can be sent in a header
+func validateUserAgent(application string) error {
+	for _, r := range application {
+		if r < ' ' || r > '~' {
+			return fmt.Errorf("user agent must only contain printable ASCII characters, got %q", application)
+		}
+	}
+
+	return nil
 }
 
 type FetchDataOptions struct {
@@ -38,21 +180,156 @@ type FetchDataOptions struct {
 	QueryParams map[string]string
 	Body        string
 	Method      string
+	// Context of the request. Defaults to context.Background.
+	Context context.Context
+	// Timeout overrides ClientOptions.Timeout for this request. A negative value disables the timeout.
+	Timeout time.Duration
 }
 
+// FetchData sends a request to the API. If the secret is rejected, the request is retried once with the
+// secret set by UpdateSecret in the meantime, or else refreshed from the SecretProvider if one is configured. Errors for rejected secrets match ErrAuthExpired.
+//
+// With ClientOptions.FallbackEndpoints, requests which could not reach an endpoint are sent to the next one.
 func (c *Client) FetchData(options FetchDataOptions) (string, error) {
-	fullURL := fmt.Sprintf("%s%s", c.endpoint, options.Path)
+	ctx := options.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err := c.waitRateLimit(ctx); err != nil {
+		return "", fmt.Errorf("error waiting for rate limit: %w", err)
+	}
+
+	data, err := c.fetch(options)
+
+	var rateLimited *RateLimitedError
+	if limiter := c.limiter.Load(); limiter != nil && errors.As(err, &rateLimited) {
+		limiter.pause(rateLimited.RetryAfter)
+	}
+
+	return data, err
+}
+
+// fetch sends a request to the first endpoint which can be reached
+func (c *Client) fetch(options FetchDataOptions) (string, error) {
+	if c.endpoints == nil {
+		return c.fetchFrom(c.endpoint, options)
+	}
+
+	var data string
+	var err error
+	for _, idx := range c.endpoints.candidates(time.Now()) {
+		data, err = c.fetchFrom(c.endpoints.endpoints[idx], options)
+		if !isFailoverError(err) {
+			c.endpoints.markHealthy(idx)
+			return data, err
+		}
+
+		c.endpoints.markUnhealthy(idx, time.Now())
+		if options.Context != nil && options.Context.Err() != nil {
+			break
+		}
+	}
+
+	return data, err
+}
+
+// fetchFrom sends a request to the API at endpoint
+func (c *Client) fetchFrom(endpoint string, options FetchDataOptions) (string, error) {
+	fullURL := fmt.Sprintf("%s%s", endpoint, options.Path)
 
 	if !strings.HasPrefix(fullURL, "http://") && !strings.HasPrefix(fullURL, "https://") {
 		return "", fmt.Errorf("invalid URL: %s", fullURL)
 	}
 
-	req, err := http.NewRequest(options.Method, fullURL, strings.NewReader(options.Body))
+	ctx := options.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	secret := c.currentSecret()
+	if secret == "" && c.secretProvider != nil {
+		refreshed, err := c.refreshSecret(ctx)
+		if err != nil {
+			return "", err
+		}
+		secret = refreshed
+	} else if c.secretStale() {
+		// The secret in use remains valid until it is rejected, so a failed refresh is retried on the next request
+		if refreshed, err := c.refreshSecret(ctx); err == nil {
+			secret = refreshed
+		}
+	}
+
+	data, err := c.do(ctx, fullURL, secret, options)
+	if !errors.Is(err, ErrAuthExpired) {
+		return data, err
+	}
+
+	// The secret was updated while the request was in flight
+	if updated := c.currentSecret(); updated != secret {
+		return c.do(ctx, fullURL, updated, options)
+	}
+
+	if c.secretProvider == nil {
+		return data, err
+	}
+
+	refreshed, refreshErr := c.refreshSecret(ctx)
+	if refreshErr != nil {
+		return "", refreshErr
+	}
+
+	return c.do(ctx, fullURL, refreshed, options)
+}
+
+// requestTimeout returns the timeout of a request, or 0 if it has none
+func (c *Client) requestTimeout(options FetchDataOptions) time.Duration {
+	timeout := options.Timeout
+	if timeout == 0 {
+		timeout = c.timeout
+	}
+
+	switch {
+	case timeout == 0:
+		return DefaultRequestTimeout
+	case timeout < 0:
+		return 0
+	}
+
+	return timeout
+}
+
+// longPollTimeout returns the timeout of a request for which the API waits up to waitTime before it
+// responds, which exceeds the request timeout. A negative value disables the timeout, as it is for the client.
+func (c *Client) longPollTimeout(waitTime time.Duration) time.Duration {
+	timeout := c.requestTimeout(FetchDataOptions{})
+	if timeout == 0 {
+		return -1
+	}
+
+	return max(timeout, waitTime+longPollMargin)
+}
+
+func (c *Client) do(ctx context.Context, fullURL string, secret string, options FetchDataOptions) (string, error) {
+	if timeout := c.requestTimeout(options); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, options.Method, fullURL, strings.NewReader(options.Body))
 	if err != nil {
 		return "", fmt.Errorf("error creating request: %v", err)
 	}
 
-	req.Header.Set("Authorization", "Bearer "+c.secret)
+	// Machines authenticated with a client certificate may have no secret
+	if secret != "" {
+		req.Header.Set("Authorization", "Bearer "+secret)
+	}
+
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
 
 	// Add custom headers
 	for key, value := range options.Headers {
@@ -66,14 +343,14 @@ func (c *Client) FetchData(options FetchDataOptions) (string, error) {
 	}
 	req.URL.RawQuery = q.Encode()
 
-	// Set Content-Type header if body is not empty
-	if options.Body != "" {
+	// Set Content-Type header if body is not empty, unless a custom one was given
+	if options.Body != "" && req.Header.Get("Content-Type") == "" {
 		req.Header.Set("Content-Type", "application/json")
 	}
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("error making request: %v", err)
+		return "", fmt.Errorf("error making request: %w", err)
 	}
 	defer resp.Body.Close()
 
@@ -83,8 +360,51 @@ func (c *Client) FetchData(options FetchDataOptions) (string, error) {
 	}
 
 	if resp.StatusCode >= 400 {
-		return "", fmt.Errorf("API error: %s (status code: %d)", string(body), resp.StatusCode)
+		return "", newAPIError(resp, body)
 	}
 
 	return string(body), nil
 }
+
+// UpdateSecret replaces the secret used by subsequent requests. Requests in flight which are rejected
+// with the previous secret are retried with the new one.
+func (c *Client) UpdateSecret(secret string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.secret = secret
+	c.secretRefreshedAt = time.Now()
+}
+
+func (c *Client) currentSecret() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.secret
+}
+
+// secretStale reports whether the secret is due to be refreshed from the provider
+func (c *Client) secretStale() bool {
+	if c.secretProvider == nil || c.secretRefreshInterval <= 0 {
+		return false
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return time.Since(c.secretRefreshedAt) >= c.secretRefreshInterval
+}
+
+func (c *Client) refreshSecret(ctx context.Context) (string, error) {
+	secret, err := c.secretProvider(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to refresh API secret: %v", err)
+	}
+
+	c.mu.Lock()
+	c.secret = secret
+	c.secretRefreshedAt = time.Now()
+	c.mu.Unlock()
+
+	return secret, nil
+}
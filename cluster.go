@@ -0,0 +1,62 @@
+package inferable
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Cluster is the metadata and settings of the cluster the machine is registered with
+type Cluster struct {
+	ID          string        `json:"id"`
+	Name        string        `json:"name"`
+	Description string        `json:"description,omitempty"`
+	Limits      ClusterLimits `json:"limits"`
+	// Features are the names of the features enabled for the cluster
+	Features []string `json:"enabledFeatures,omitempty"`
+}
+
+// ClusterLimits are the limits applied by the cluster. Zero values mean the limit is not set.
+type ClusterLimits struct {
+	// MaxPayloadSize is the maximum size of a call input or result, in bytes
+	MaxPayloadSize int `json:"maxPayloadSize,omitempty"`
+	// MaxConcurrentRuns is the maximum number of runs executing at once
+	MaxConcurrentRuns int `json:"maxConcurrentRuns,omitempty"`
+	// MaxFunctions is the maximum number of functions which may be registered
+	MaxFunctions int `json:"maxFunctions,omitempty"`
+}
+
+// HasFeature reports whether feature is enabled for the cluster
+func (c *Cluster) HasFeature(feature string) bool {
+	for _, enabled := range c.Features {
+		if enabled == feature {
+			return true
+		}
+	}
+
+	return false
+}
+
+// GetCluster fetches the metadata and settings of the cluster, so that machines can adapt
+// to its limits and enabled features
+func (i *Inferable) GetCluster(ctx context.Context) (*Cluster, error) {
+	if i.clusterID == "" {
+		return nil, fmt.Errorf("cluster ID must be provided to get the cluster")
+	}
+
+	responseData, err := i.FetchData(FetchDataOptions{
+		Path:    fmt.Sprintf("/clusters/%s", i.clusterID),
+		Method:  "GET",
+		Context: ctx,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cluster: %w", err)
+	}
+
+	cluster := &Cluster{}
+	if err := json.Unmarshal(responseData, cluster); err != nil {
+		return nil, fmt.Errorf("failed to parse cluster: %v", err)
+	}
+
+	return cluster, nil
+}
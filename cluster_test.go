@@ -0,0 +1,38 @@
+package inferable
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetCluster(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/clusters/test-cluster" {
+			w.Write([]byte(`{}`))
+			return
+		}
+		w.Write([]byte(`{"id": "test-cluster", "name": "Support", "limits": {"maxPayloadSize": 1048576}, "enabledFeatures": ["runs", "attachments"]}`))
+	}))
+	defer server.Close()
+
+	i, err := New(InferableOptions{
+		APIEndpoint: server.URL,
+		APISecret:   "test-secret",
+		ClusterID:   "test-cluster",
+	})
+	require.NoError(t, err)
+
+	cluster, err := i.GetCluster(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, "Support", cluster.Name)
+	assert.Equal(t, 1048576, cluster.Limits.MaxPayloadSize)
+	assert.Equal(t, 0, cluster.Limits.MaxConcurrentRuns)
+	assert.True(t, cluster.HasFeature("attachments"))
+	assert.False(t, cluster.HasFeature("webhooks"))
+}
@@ -0,0 +1,615 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// generator emits the code for the types and functions of a single package
+type generator struct {
+	fset    *token.FileSet
+	pkg     string
+	structs map[string]*ast.StructType
+	named   map[string]ast.Expr
+	funcs   map[string]*ast.FuncDecl
+	imports map[string]bool
+	buf     bytes.Buffer
+}
+
+// member is a key of an object schema, which keeps the order of keys stable
+type member struct {
+	key   string
+	value interface{}
+}
+
+type object []member
+
+func (o object) has(key string) bool {
+	for _, m := range o {
+		if m.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func (o object) MarshalJSON() ([]byte, error) {
+	buf := bytes.Buffer{}
+	buf.WriteByte('{')
+	for idx, m := range o {
+		if idx > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(m.key)
+		value, err := json.Marshal(m.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// field is an exported field of a struct with its JSON encoding options
+type field struct {
+	goName    string
+	jsonName  string
+	typ       ast.Expr
+	omitEmpty bool
+	schema    string
+	doc       string
+}
+
+func generate(dir, output string, types, funcs []string) ([]byte, error) {
+	g := &generator{
+		fset:    token.NewFileSet(),
+		structs: map[string]*ast.StructType{},
+		named:   map[string]ast.Expr{},
+		funcs:   map[string]*ast.FuncDecl{},
+		imports: map[string]bool{},
+	}
+
+	if err := g.parse(dir, output); err != nil {
+		return nil, err
+	}
+
+	body := &g.buf
+	for _, name := range types {
+		if err := g.generateType(name); err != nil {
+			return nil, fmt.Errorf("type %s: %v", name, err)
+		}
+	}
+	for _, name := range funcs {
+		if err := g.generateInvoker(name); err != nil {
+			return nil, fmt.Errorf("func %s: %v", name, err)
+		}
+	}
+
+	imports := make([]string, 0, len(g.imports))
+	for path := range g.imports {
+		imports = append(imports, strconv.Quote(path))
+	}
+	sort.Strings(imports)
+
+	src := bytes.Buffer{}
+	fmt.Fprintf(&src, "// Code generated by inferable-gen. DO NOT EDIT.\n\npackage %s\n\n", g.pkg)
+	if len(imports) > 0 {
+		fmt.Fprintf(&src, "import (\n%s\n)\n", strings.Join(imports, "\n"))
+	}
+	src.Write(body.Bytes())
+
+	formatted, err := format.Source(src.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to format generated code: %v", err)
+	}
+
+	return formatted, nil
+}
+
+func (g *generator) parse(dir, output string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") || name == output {
+			continue
+		}
+
+		file, err := parser.ParseFile(g.fset, filepath.Join(dir, name), nil, parser.SkipObjectResolution|parser.ParseComments)
+		if err != nil {
+			return err
+		}
+		g.pkg = file.Name.Name
+
+		for _, decl := range file.Decls {
+			switch decl := decl.(type) {
+			case *ast.GenDecl:
+				for _, spec := range decl.Specs {
+					typeSpec, ok := spec.(*ast.TypeSpec)
+					if !ok || typeSpec.TypeParams != nil {
+						continue
+					}
+					if structType, ok := typeSpec.Type.(*ast.StructType); ok {
+						g.structs[typeSpec.Name.Name] = structType
+					} else {
+						g.named[typeSpec.Name.Name] = typeSpec.Type
+					}
+				}
+			case *ast.FuncDecl:
+				if decl.Recv == nil {
+					g.funcs[decl.Name.Name] = decl
+				}
+			}
+		}
+	}
+
+	if g.pkg == "" {
+		return fmt.Errorf("no Go files found in %s", dir)
+	}
+
+	return nil
+}
+
+func (g *generator) generateType(name string) error {
+	structType, ok := g.structs[name]
+	if !ok {
+		return fmt.Errorf("struct type not found")
+	}
+
+	fields, err := g.fields(structType)
+	if err != nil {
+		return err
+	}
+
+	schema, err := g.structSchema(fields, false, map[string]bool{name: true})
+	if err != nil {
+		return err
+	}
+	schemaJSON, err := json.Marshal(schema)
+	if err != nil {
+		return err
+	}
+
+	g.imports["encoding/json"] = true
+	g.imports["fmt"] = true
+	b := &g.buf
+
+	fmt.Fprintf(b, "\n// InferableSchema returns the JSON schema of %s, see inferable.SchemaProvider\n", name)
+	fmt.Fprintf(b, "func (%s) InferableSchema() json.RawMessage {\n\treturn json.RawMessage(%s)\n}\n", name, "`"+string(schemaJSON)+"`")
+
+	fmt.Fprintf(b, "\n// DecodeInferable decodes %s from the input of a call, see inferable.InputDecoder\n", name)
+	fmt.Fprintf(b, "func (x *%s) DecodeInferable(data []byte) error {\n", name)
+	fmt.Fprintf(b, "\tvar fields map[string]json.RawMessage\n\tif err := json.Unmarshal(data, &fields); err != nil {\n\t\treturn err\n\t}\n")
+	for _, f := range fields {
+		fmt.Fprintf(b, "\tif raw, ok := fields[%q]; ok {\n", f.jsonName)
+		fmt.Fprintf(b, "\t\tif err := json.Unmarshal(raw, &x.%s); err != nil {\n", f.goName)
+		fmt.Fprintf(b, "\t\t\treturn fmt.Errorf(\"invalid field %s: %%v\", err)\n\t\t}\n\t}\n", f.jsonName)
+	}
+	fmt.Fprintf(b, "\treturn nil\n}\n")
+
+	fmt.Fprintf(b, "\n// EncodeInferable encodes %s as JSON, see inferable.ResultEncoder\n", name)
+	fmt.Fprintf(b, "func (x %s) EncodeInferable() ([]byte, error) {\n", name)
+	fmt.Fprintf(b, "\tbuf := make([]byte, 0, 128)\n\tbuf = append(buf, '{')\n")
+	for idx, f := range fields {
+		key, _ := json.Marshal(f.jsonName)
+		indent := "\t"
+		if f.omitEmpty {
+			if cond := g.nonEmpty("x."+f.goName, f.typ); cond != "" {
+				fmt.Fprintf(b, "\tif %s {\n", cond)
+				indent = "\t\t"
+			}
+		}
+		if idx > 0 {
+			fmt.Fprintf(b, "%sif len(buf) > 1 {\n%s\tbuf = append(buf, ',')\n%s}\n", indent, indent, indent)
+		}
+		fmt.Fprintf(b, "%sbuf = append(buf, %s...)\n", indent, strconv.Quote(string(key)+":"))
+		g.encodeValue(b, indent, "x."+f.goName, f.typ)
+		if indent != "\t" {
+			fmt.Fprintf(b, "\t}\n")
+		}
+	}
+	fmt.Fprintf(b, "\tbuf = append(buf, '}')\n\treturn buf, nil\n}\n")
+
+	return nil
+}
+
+// encodeValue emits code appending the JSON encoding of value to buf
+func (g *generator) encodeValue(b *bytes.Buffer, indent, value string, typ ast.Expr) {
+	if ident, ok := typ.(*ast.Ident); ok {
+		switch ident.Name {
+		case "bool":
+			g.imports["strconv"] = true
+			fmt.Fprintf(b, "%sbuf = strconv.AppendBool(buf, %s)\n", indent, value)
+			return
+		case "int", "int8", "int16", "int32", "int64":
+			g.imports["strconv"] = true
+			fmt.Fprintf(b, "%sbuf = strconv.AppendInt(buf, int64(%s), 10)\n", indent, value)
+			return
+		case "uint", "uint8", "uint16", "uint32", "uint64":
+			g.imports["strconv"] = true
+			fmt.Fprintf(b, "%sbuf = strconv.AppendUint(buf, uint64(%s), 10)\n", indent, value)
+			return
+		}
+	}
+
+	fmt.Fprintf(b, "%sif encoded, err := json.Marshal(%s); err != nil {\n", indent, value)
+	fmt.Fprintf(b, "%s\treturn nil, err\n%s} else {\n%s\tbuf = append(buf, encoded...)\n%s}\n", indent, indent, indent, indent)
+}
+
+// nonEmpty returns the condition under which encoding/json does not omit a value, or "" if it never does
+func (g *generator) nonEmpty(value string, typ ast.Expr) string {
+	switch t := typ.(type) {
+	case *ast.Ident:
+		switch t.Name {
+		case "string":
+			return value + ` != ""`
+		case "bool":
+			return value
+		case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64", "float32", "float64", "byte", "rune":
+			return value + " != 0"
+		case "any":
+			return value + " != nil"
+		}
+		if underlying, ok := g.named[t.Name]; ok {
+			return g.nonEmpty(value, underlying)
+		}
+	case *ast.StarExpr, *ast.InterfaceType:
+		return value + " != nil"
+	case *ast.MapType:
+		return "len(" + value + ") != 0"
+	case *ast.ArrayType:
+		if t.Len == nil {
+			return "len(" + value + ") != 0"
+		}
+	case *ast.SelectorExpr:
+		if g.exprString(t) == "json.RawMessage" {
+			return "len(" + value + ") != 0"
+		}
+	}
+
+	return ""
+}
+
+func (g *generator) fields(structType *ast.StructType) ([]field, error) {
+	fields := []field{}
+
+	for _, f := range structType.Fields.List {
+		if len(f.Names) == 0 {
+			return nil, fmt.Errorf("embedded fields are not supported")
+		}
+
+		var tag reflect.StructTag
+		if f.Tag != nil {
+			value, err := strconv.Unquote(f.Tag.Value)
+			if err != nil {
+				return nil, err
+			}
+			tag = reflect.StructTag(value)
+		}
+
+		jsonName, options, _ := strings.Cut(tag.Get("json"), ",")
+		if jsonName == "-" && options == "" {
+			continue
+		}
+		if strings.Contains(","+options+",", ",string,") {
+			return nil, fmt.Errorf("the json string option is not supported")
+		}
+
+		for _, name := range f.Names {
+			if !name.IsExported() {
+				continue
+			}
+
+			fieldName := jsonName
+			if fieldName == "" {
+				fieldName = name.Name
+			}
+
+			doc := f.Doc
+			if doc == nil {
+				doc = f.Comment
+			}
+
+			fields = append(fields, field{
+				goName:    name.Name,
+				jsonName:  fieldName,
+				typ:       f.Type,
+				omitEmpty: strings.Contains(","+options+",", ",omitempty,"),
+				schema:    tag.Get("jsonschema"),
+				doc:       docText(doc),
+			})
+		}
+	}
+
+	return fields, nil
+}
+
+// structSchema mirrors the schema reflected by invopop/jsonschema, with nested structs inlined
+func (g *generator) structSchema(fields []field, nested bool, visiting map[string]bool) (object, error) {
+	properties := object{}
+	required := []string{}
+
+	for _, f := range fields {
+		schema, err := g.schema(f.typ, visiting)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %v", f.goName, err)
+		}
+
+		forceRequired := false
+		if f.schema != "" {
+			if schema == nil {
+				schema = object{}
+			}
+			schema, forceRequired, err = applySchemaTag(schema, f.schema)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %v", f.goName, err)
+			}
+		}
+
+		// Doc comments describe fields unless the jsonschema tag does
+		if f.doc != "" && !schema.has("description") {
+			if schema == nil {
+				schema = object{}
+			}
+			schema = append(schema, member{"description", f.doc})
+		}
+
+		var value interface{} = true
+		if schema != nil {
+			value = schema
+		}
+		properties = append(properties, member{f.jsonName, value})
+
+		if !f.omitEmpty || forceRequired {
+			required = append(required, f.jsonName)
+		}
+	}
+
+	schema := object{{"properties", properties}}
+	if nested {
+		schema = append(schema, member{"additionalProperties", false})
+	}
+	schema = append(schema, member{"type", "object"})
+	if len(required) > 0 {
+		schema = append(schema, member{"required", required})
+	}
+
+	return schema, nil
+}
+
+// schema returns the schema of a type, or nil if it accepts any value
+func (g *generator) schema(typ ast.Expr, visiting map[string]bool) (object, error) {
+	switch t := typ.(type) {
+	case *ast.Ident:
+		switch t.Name {
+		case "string":
+			return object{{"type", "string"}}, nil
+		case "bool":
+			return object{{"type", "boolean"}}, nil
+		case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64", "byte", "rune":
+			return object{{"type", "integer"}}, nil
+		case "float32", "float64":
+			return object{{"type", "number"}}, nil
+		case "any":
+			return nil, nil
+		}
+
+		if structType, ok := g.structs[t.Name]; ok {
+			if visiting[t.Name] {
+				return nil, fmt.Errorf("recursive type %s is not supported", t.Name)
+			}
+			fields, err := g.fields(structType)
+			if err != nil {
+				return nil, err
+			}
+			visiting[t.Name] = true
+			defer delete(visiting, t.Name)
+			return g.structSchema(fields, true, visiting)
+		}
+
+		if underlying, ok := g.named[t.Name]; ok {
+			return g.schema(underlying, visiting)
+		}
+	case *ast.StarExpr:
+		return g.schema(t.X, visiting)
+	case *ast.InterfaceType:
+		if len(t.Methods.List) == 0 {
+			return nil, nil
+		}
+	case *ast.ArrayType:
+		if ident, ok := t.Elt.(*ast.Ident); ok && ident.Name == "byte" && t.Len == nil {
+			return object{{"type", "string"}, {"contentEncoding", "base64"}}, nil
+		}
+		items, err := g.schema(t.Elt, visiting)
+		if err != nil {
+			return nil, err
+		}
+		var itemsValue interface{} = true
+		if items != nil {
+			itemsValue = items
+		}
+		return object{{"items", itemsValue}, {"type", "array"}}, nil
+	case *ast.MapType:
+		if key, ok := t.Key.(*ast.Ident); !ok || key.Name != "string" {
+			return nil, fmt.Errorf("only maps with string keys are supported")
+		}
+		values, err := g.schema(t.Value, visiting)
+		if err != nil {
+			return nil, err
+		}
+		var valuesValue interface{} = true
+		if values != nil {
+			valuesValue = values
+		}
+		return object{{"additionalProperties", valuesValue}, {"type", "object"}}, nil
+	case *ast.SelectorExpr:
+		switch g.exprString(t) {
+		case "time.Time":
+			return object{{"type", "string"}, {"format", "date-time"}}, nil
+		case "json.RawMessage":
+			return nil, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unsupported type %s", g.exprString(typ))
+}
+
+// applySchemaTag applies the options of a jsonschema struct tag, returning whether the field is required
+func applySchemaTag(schema object, tag string) (object, bool, error) {
+	required := false
+	enum := []string{}
+
+	for _, option := range strings.Split(tag, ",") {
+		key, value, _ := strings.Cut(option, "=")
+		switch key {
+		case "required":
+			required = true
+		case "description", "title", "format", "pattern":
+			schema = append(schema, member{key, value})
+		case "enum":
+			enum = append(enum, value)
+		case "minimum", "maximum", "minLength", "maxLength", "minItems", "maxItems":
+			number, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, false, fmt.Errorf("invalid jsonschema %s '%s'", key, value)
+			}
+			schema = append(schema, member{key, number})
+		default:
+			return nil, false, fmt.Errorf("unsupported jsonschema option '%s'", key)
+		}
+	}
+
+	if len(enum) > 0 {
+		schema = append(schema, member{"enum", enum})
+	}
+
+	return schema, required, nil
+}
+
+func (g *generator) generateInvoker(name string) error {
+	decl, ok := g.funcs[name]
+	if !ok {
+		return fmt.Errorf("function not found")
+	}
+	if decl.Type.TypeParams != nil {
+		return fmt.Errorf("generic functions are not supported")
+	}
+
+	params := []*ast.Field{}
+	for _, param := range decl.Type.Params.List {
+		names := len(param.Names)
+		if names == 0 {
+			names = 1
+		}
+		for idx := 0; idx < names; idx++ {
+			params = append(params, param)
+		}
+	}
+
+	args := ""
+	switch {
+	case len(params) == 1:
+	case len(params) == 2 && g.exprString(params[0].Type) == "context.Context":
+		args = "ctx, "
+	default:
+		return fmt.Errorf("must take the input struct, optionally preceded by a context.Context")
+	}
+	inputType := g.exprString(params[len(params)-1].Type)
+	args += "input.(" + inputType + ")"
+
+	results := []string{}
+	if decl.Type.Results != nil {
+		for _, result := range decl.Type.Results.List {
+			names := len(result.Names)
+			if names == 0 {
+				names = 1
+			}
+			for idx := 0; idx < names; idx++ {
+				results = append(results, g.exprString(result.Type))
+			}
+		}
+	}
+
+	var call string
+	switch {
+	case len(results) == 2 && results[1] == "error":
+		call = "return f(" + args + ")"
+	case len(results) == 1 && results[0] == "error":
+		call = "return nil, f(" + args + ")"
+	case len(results) == 1:
+		call = "return f(" + args + "), nil"
+	default:
+		return fmt.Errorf("must return a result, an error, or a result and an error")
+	}
+
+	signature := "func(" + g.exprString(decl.Type.Params) + ")"
+	if len(results) == 1 {
+		signature += " " + results[0]
+	} else {
+		signature += " (" + strings.Join(results, ", ") + ")"
+	}
+
+	g.imports["context"] = true
+	typeName := name + "Invoker"
+	b := &g.buf
+
+	fmt.Fprintf(b, "\n// %s calls %s without reflection, see inferable.FuncInvoker\n", typeName, name)
+	fmt.Fprintf(b, "type %s %s\n", typeName, signature)
+	fmt.Fprintf(b, "\n// InvokeInferable calls %s with the decoded input\n", name)
+	fmt.Fprintf(b, "func (f %s) InvokeInferable(ctx context.Context, input interface{}) (interface{}, error) {\n\t%s\n}\n", typeName, call)
+
+	if description := docText(decl.Doc); description != "" {
+		fmt.Fprintf(b, "\n// InferableDescription returns the doc comment of %s, see inferable.DescriptionProvider\n", name)
+		fmt.Fprintf(b, "func (f %s) InferableDescription() string {\n\treturn %s\n}\n", typeName, strconv.Quote(description))
+	}
+
+	return nil
+}
+
+// docText returns a doc comment as a single line, or "" if there is none
+func docText(doc *ast.CommentGroup) string {
+	if doc == nil {
+		return ""
+	}
+	return strings.Join(strings.Fields(doc.Text()), " ")
+}
+
+func (g *generator) exprString(node ast.Node) string {
+	if fields, ok := node.(*ast.FieldList); ok {
+		parts := []string{}
+		for _, f := range fields.List {
+			part := g.exprString(f.Type)
+			if len(f.Names) > 0 {
+				names := make([]string, len(f.Names))
+				for idx, name := range f.Names {
+					names[idx] = name.Name
+				}
+				part = strings.Join(names, ", ") + " " + part
+			}
+			parts = append(parts, part)
+		}
+		return strings.Join(parts, ", ")
+	}
+
+	buf := bytes.Buffer{}
+	printer.Fprint(&buf, g.fset, node)
+	return buf.String()
+}
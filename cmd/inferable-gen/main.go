@@ -0,0 +1,65 @@
+// Command inferable-gen emits static JSON schemas, decoders, encoders and invokers for the functions
+// of a package, so that registering and calling them needs neither schema reflection nor reflect.Call.
+//
+// Add a go:generate directive to the package declaring the functions:
+//
+//	//go:generate go run github.com/inferablehq/inferable-go/cmd/inferable-gen -type=GreetInput,Greeting -func=greet
+//
+// Input and result types listed with -type implement inferable.SchemaProvider, inferable.InputDecoder and
+// inferable.ResultEncoder. Each function listed with -func gets a named function type implementing
+// inferable.FuncInvoker, e.g. greetInvoker, which is registered in place of the function:
+//
+//	service.RegisterFunc(inferable.Function{Name: "greet", Func: greetInvoker(greet)})
+//
+// Doc comments are kept in one place: the doc comment of a function becomes the description of the function
+// unless Function.Description is set, and field doc comments become property descriptions unless the
+// jsonschema tag has one.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	types := flag.String("type", "", "comma separated list of struct types to generate schemas, decoders and encoders for")
+	funcs := flag.String("func", "", "comma separated list of functions to generate invokers for")
+	output := flag.String("output", "inferable_gen.go", "output file, relative to the package directory")
+	flag.Parse()
+
+	dir := "."
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+
+	if err := run(dir, splitList(*types), splitList(*funcs), *output); err != nil {
+		fmt.Fprintf(os.Stderr, "inferable-gen: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(dir string, types, funcs []string, output string) error {
+	if len(types) == 0 && len(funcs) == 0 {
+		return fmt.Errorf("at least one of -type or -func is required")
+	}
+
+	src, err := generate(dir, filepath.Base(output), types, funcs)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(dir, output), src, 0644)
+}
+
+func splitList(value string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate(t *testing.T) {
+	dir := filepath.Join("testdata", "example")
+
+	src, err := generate(dir, "inferable_gen.go", []string{"GreetInput", "Greeting"}, []string{"greet", "validate"})
+	require.NoError(t, err)
+
+	// The golden file is regenerated with: go run . -type=GreetInput,Greeting -func=greet,validate testdata/example
+	golden, err := os.ReadFile(filepath.Join(dir, "inferable_gen.go"))
+	require.NoError(t, err)
+	assert.Equal(t, string(golden), string(src))
+}
+
+func TestGenerateErrors(t *testing.T) {
+	dir := filepath.Join("testdata", "example")
+
+	_, err := generate(dir, "inferable_gen.go", []string{"Missing"}, nil)
+	assert.EqualError(t, err, "type Missing: struct type not found")
+
+	_, err = generate(dir, "inferable_gen.go", nil, []string{"missing"})
+	assert.EqualError(t, err, "func missing: function not found")
+
+	assert.EqualError(t, run(dir, nil, nil, "inferable_gen.go"), "at least one of -type or -func is required")
+}
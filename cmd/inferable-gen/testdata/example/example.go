@@ -0,0 +1,47 @@
+package example
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type Role string
+
+type Address struct {
+	// City or town
+	City    string `json:"city"`
+	Country string `json:"country,omitempty"`
+}
+
+type GreetInput struct {
+	Name     string            `json:"name" jsonschema:"description=Name of the person"`
+	Age      int               `json:"age,omitempty" jsonschema:"minimum=0"` // Age in years
+	Role     Role              `json:"role,omitempty" jsonschema:"enum=admin,enum=member"`
+	Tags     []string          `json:"tags,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Address  *Address          `json:"address,omitempty"`
+	Since    time.Time         `json:"since"`
+	Verified bool              `json:"verified"`
+	Internal string            `json:"-"`
+	secret   string
+}
+
+type Greeting struct {
+	Message string  `json:"message"`
+	Count   uint    `json:"count,omitempty"`
+	Score   float64 `json:"score,omitempty"`
+}
+
+// greet greets a person by name,
+// counting the greetings sent.
+func greet(ctx context.Context, input GreetInput) (Greeting, error) {
+	if input.Name == "" {
+		return Greeting{}, fmt.Errorf("name is required")
+	}
+	return Greeting{Message: "Hello, " + input.Name, Count: 1}, nil
+}
+
+func validate(input GreetInput) error {
+	return nil
+}
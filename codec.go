@@ -0,0 +1,49 @@
+package inferable
+
+import "encoding/json"
+
+// JSONCodec decodes the inputs and encodes the results of calls. Deployments where serialization dominates
+// the cost of calls can swap encoding/json for a faster compatible codec, see ServiceOptions.JSONCodec.
+// jsoniter.ConfigCompatibleWithStandardLibrary implements it as is, and codecs with package level
+// functions, such as goccy/go-json, can be adapted with NewJSONCodec.
+type JSONCodec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// StdJSONCodec is the JSONCodec backed by encoding/json, which is used by default
+type StdJSONCodec struct{}
+
+func (StdJSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (StdJSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// NewJSONCodec returns a JSONCodec calling the given functions, e.g. NewJSONCodec(gojson.Marshal, gojson.Unmarshal)
+func NewJSONCodec(marshal func(v interface{}) ([]byte, error), unmarshal func(data []byte, v interface{}) error) JSONCodec {
+	return jsonCodecFuncs{marshal: marshal, unmarshal: unmarshal}
+}
+
+type jsonCodecFuncs struct {
+	marshal   func(v interface{}) ([]byte, error)
+	unmarshal func(data []byte, v interface{}) error
+}
+
+func (c jsonCodecFuncs) Marshal(v interface{}) ([]byte, error) {
+	return c.marshal(v)
+}
+
+func (c jsonCodecFuncs) Unmarshal(data []byte, v interface{}) error {
+	return c.unmarshal(data, v)
+}
+
+func (s *Service) jsonCodec() JSONCodec {
+	if s.options.JSONCodec == nil {
+		return StdJSONCodec{}
+	}
+
+	return s.options.JSONCodec
+}
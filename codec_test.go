@@ -0,0 +1,114 @@
+package inferable
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingCodec counts the values it encodes and decodes with encoding/json
+type countingCodec struct {
+	marshaled, unmarshaled atomic.Int32
+}
+
+func (c *countingCodec) Marshal(v interface{}) ([]byte, error) {
+	c.marshaled.Add(1)
+	return json.Marshal(v)
+}
+
+func (c *countingCodec) Unmarshal(data []byte, v interface{}) error {
+	c.unmarshaled.Add(1)
+	return json.Unmarshal(data, v)
+}
+
+func TestJSONCodec(t *testing.T) {
+	var result atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/result") {
+			var persisted persistedResult
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&persisted))
+			result.Store(persisted.Result)
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	i, err := New(InferableOptions{
+		APIEndpoint: server.URL,
+		APISecret:   "test-secret",
+	})
+	require.NoError(t, err)
+
+	codec := &countingCodec{}
+	service, err := i.RegisterServiceWithOptions("codec", ServiceOptions{JSONCodec: codec})
+	require.NoError(t, err)
+	require.NoError(t, service.RegisterFunc(Function{Name: "Echo", Func: benchmarkFunc}))
+
+	body := `{"value": {"id": "call-1", "service": "codec", "targetFn": "Echo", "targetArgs": "{\"value\": {\"name\": \"Ada\"}}"}}`
+	require.NoError(t, service.handleMessage(&sqs.Message{Body: aws.String(body)}, time.Now()))
+
+	assert.Equal(t, int32(1), codec.unmarshaled.Load())
+	assert.Equal(t, int32(1), codec.marshaled.Load())
+	assert.JSONEq(t, `{"value": "Ada"}`, result.Load().(string))
+
+	adapted := NewJSONCodec(json.Marshal, json.Unmarshal)
+	data, err := adapted.Marshal(benchmarkInput{Name: "Ada"})
+	require.NoError(t, err)
+	var decoded benchmarkInput
+	require.NoError(t, adapted.Unmarshal(data, &decoded))
+	assert.Equal(t, "Ada", decoded.Name)
+}
+
+var benchmarkCodecs = map[string]JSONCodec{
+	"encoding/json": StdJSONCodec{},
+	"adapted":       NewJSONCodec(json.Marshal, json.Unmarshal),
+}
+
+// BenchmarkCallSerialization measures decoding inputs and encoding results against calling the function,
+// which is the share of the cost of calls a faster JSONCodec reduces
+func BenchmarkCallSerialization(b *testing.B) {
+	compiled := compileFunction(benchmarkFunc)
+	argPtr, err := compiled.decodeArgs(benchmarkArgs)
+	if err != nil {
+		b.Fatal(err)
+	}
+	ctx := context.Background()
+	result := benchmarkInput{Name: "Ada", Count: 3, Tags: []string{"a", "b"}}
+
+	for name, codec := range benchmarkCodecs {
+		b.Run("decode/"+name, func(b *testing.B) {
+			b.ReportAllocs()
+			for n := 0; n < b.N; n++ {
+				if _, err := compiled.decodeArgsWith(codec, benchmarkArgs); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		b.Run("encode/"+name, func(b *testing.B) {
+			b.ReportAllocs()
+			for n := 0; n < b.N; n++ {
+				if _, err := encodeResult(result, nil, codec); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+
+	b.Run("call", func(b *testing.B) {
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			compiled.call(ctx, argPtr.Elem())
+		}
+	})
+}
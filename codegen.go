@@ -0,0 +1,83 @@
+package inferable
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// The interfaces below are implemented by the code emitted by cmd/inferable-gen, which lets
+// latency-sensitive deployments skip schema reflection on registration and reflect.Call on each call.
+
+// SchemaProvider is implemented by input types with a static JSON schema.
+// The schema is used instead of reflecting the type on registration.
+type SchemaProvider interface {
+	InferableSchema() json.RawMessage
+}
+
+// InputDecoder is implemented by pointers to input types which decode themselves from the call input
+type InputDecoder interface {
+	DecodeInferable(data []byte) error
+}
+
+// ResultEncoder is implemented by result types which encode themselves to JSON
+type ResultEncoder interface {
+	EncodeInferable() ([]byte, error)
+}
+
+// FuncInvoker is implemented by named function types which call the underlying function without reflection.
+// The input is the decoded input struct. Functions without a result return nil.
+type FuncInvoker interface {
+	InvokeInferable(ctx context.Context, input interface{}) (interface{}, error)
+}
+
+// DescriptionProvider is implemented by functions which describe themselves, e.g. generated invokers
+// returning the doc comment of the function. It is used when Function.Description is empty.
+type DescriptionProvider interface {
+	InferableDescription() string
+}
+
+var schemaProviderType = reflect.TypeOf((*SchemaProvider)(nil)).Elem()
+
+// providedSchema returns the static schema of argType, if it implements SchemaProvider
+func providedSchema(fnName string, argType reflect.Type) (json.RawMessage, bool, error) {
+	if !argType.Implements(schemaProviderType) {
+		return nil, false, nil
+	}
+
+	schema := reflect.Zero(argType).Interface().(SchemaProvider).InferableSchema()
+
+	var object map[string]interface{}
+	if err := json.Unmarshal(schema, &object); err != nil {
+		return nil, true, fmt.Errorf("static schema for function '%s' must be a JSON object: %v", fnName, err)
+	}
+
+	return schema, true, nil
+}
+
+// encodeResult marshals a result with the function's marshaler, or the generated encoder if there is one,
+// or else with codec
+func encodeResult(value interface{}, marshal func(interface{}) ([]byte, error), codec JSONCodec) ([]byte, error) {
+	if marshal != nil {
+		data, err := marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		if !json.Valid(data) {
+			return nil, fmt.Errorf("result marshaler produced invalid JSON")
+		}
+		return data, nil
+	}
+
+	if encoder, ok := value.(ResultEncoder); ok {
+		return encoder.EncodeInferable()
+	}
+
+	return codec.Marshal(value)
+}
+
+// invokerValues adapts the result of a FuncInvoker to the return values of a reflected call
+func invokerValues(value interface{}, err error) []reflect.Value {
+	return []reflect.Value{reflect.ValueOf(&value).Elem(), reflect.ValueOf(&err).Elem()}
+}
@@ -0,0 +1,93 @@
+package inferable
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Hand-written equivalents of the code emitted by cmd/inferable-gen
+type generatedInput struct {
+	Name string `json:"name"`
+}
+
+func (generatedInput) InferableSchema() json.RawMessage {
+	return json.RawMessage(`{"properties":{"name":{"type":"string"}},"type":"object","required":["name"]}`)
+}
+
+func (x *generatedInput) DecodeInferable(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	return json.Unmarshal(fields["name"], &x.Name)
+}
+
+type generatedResult struct {
+	Greeting string
+}
+
+func (x generatedResult) EncodeInferable() ([]byte, error) {
+	return []byte(`{"encoded":"` + x.Greeting + `"}`), nil
+}
+
+type generatedInvoker func(ctx context.Context, input generatedInput) (generatedResult, error)
+
+func (f generatedInvoker) InvokeInferable(ctx context.Context, input interface{}) (interface{}, error) {
+	return f(ctx, input.(generatedInput))
+}
+
+func (f generatedInvoker) InferableDescription() string {
+	return "greet greets a person by name."
+}
+
+func TestGeneratedFunction(t *testing.T) {
+	var persisted persistedResult
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/jobs/call-1/result" {
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&persisted))
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	i, err := New(InferableOptions{
+		APIEndpoint: server.URL,
+		APISecret:   "test-secret",
+	})
+	require.NoError(t, err)
+
+	err = i.Default.RegisterFunc(Function{
+		Name: "greet",
+		Func: generatedInvoker(func(ctx context.Context, input generatedInput) (generatedResult, error) {
+			return generatedResult{Greeting: "Hello, " + input.Name}, nil
+		}),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, generatedInput{}.InferableSchema(), i.Default.Functions["greet"].schema)
+	assert.Equal(t, "greet greets a person by name.", i.Default.Functions["greet"].Description)
+
+	// An explicit description takes precedence over the doc comment
+	require.NoError(t, i.Default.RegisterFunc(Function{
+		Name:        "described",
+		Description: "Says hello",
+		Func: generatedInvoker(func(ctx context.Context, input generatedInput) (generatedResult, error) {
+			return generatedResult{}, nil
+		}),
+	}))
+	assert.Equal(t, "Says hello", i.Default.Functions["described"].Description)
+
+	body := `{"value": {"id": "call-1", "service": "default", "targetFn": "greet", "targetArgs": "{\"value\": {\"name\": \"Ada\"}}"}}`
+	require.NoError(t, i.Default.handleMessage(&sqs.Message{Body: aws.String(body)}, time.Now()))
+
+	assert.Equal(t, "resolution", persisted.ResultType)
+	assert.JSONEq(t, `{"value": {"encoded": "Hello, Ada"}}`, persisted.Result)
+}
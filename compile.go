@@ -0,0 +1,151 @@
+package inferable
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sync"
+
+	"github.com/invopop/jsonschema"
+)
+
+// compiledFunction holds the type information of a function, derived once on registration
+// so that handling a call does not re-derive it
+type compiledFunction struct {
+	value   reflect.Value
+	argType reflect.Type
+	// argsType wraps the input type in the {"value": ...} envelope of the call input,
+	// so that the input is decoded in a single pass
+	argsType    reflect.Type
+	withContext bool
+	// decodes is true if pointers to the input type implement InputDecoder
+	decodes bool
+	// durations is true if the input type contains a time.Duration, which is decoded from a string
+	durations bool
+	invoker   FuncInvoker
+}
+
+var errMissingValue = errors.New("'value' field not found in target arguments")
+
+var inputDecoderType = reflect.TypeOf((*InputDecoder)(nil)).Elem()
+
+func compileFunction(fn interface{}) *compiledFunction {
+	fnType := reflect.TypeOf(fn)
+	argType := inputType(fnType)
+	invoker, _ := fn.(FuncInvoker)
+
+	return &compiledFunction{
+		value:   reflect.ValueOf(fn),
+		argType: argType,
+		argsType: reflect.StructOf([]reflect.StructField{{
+			Name: "Value",
+			Type: reflect.PointerTo(argType),
+			Tag:  `json:"value"`,
+		}}),
+		withContext: acceptsContext(fnType),
+		decodes:     reflect.PointerTo(argType).Implements(inputDecoderType),
+		durations:   containsDuration(argType, map[reflect.Type]bool{}),
+		invoker:     invoker,
+	}
+}
+
+// compiled returns the compiled function, compiling it if it was not added through RegisterFunc
+func (fn Function) compiled() *compiledFunction {
+	if fn.compiledFn != nil {
+		return fn.compiledFn
+	}
+
+	return compileFunction(fn.Func)
+}
+
+// decodeArgs unmarshals the value of the call input into a new instance of the input type, returning a pointer to it
+func (c *compiledFunction) decodeArgs(targetArgs []byte) (reflect.Value, error) {
+	return c.decodeArgsWith(StdJSONCodec{}, targetArgs)
+}
+
+// decodeArgsWith decodes the call input like decodeArgs, unmarshaling it with codec
+func (c *compiledFunction) decodeArgsWith(codec JSONCodec, targetArgs []byte) (reflect.Value, error) {
+	if c.decodes {
+		var args struct {
+			Value json.RawMessage `json:"value"`
+		}
+		if err := json.Unmarshal(targetArgs, &args); err != nil {
+			return reflect.Value{}, err
+		}
+		if args.Value == nil {
+			return reflect.Value{}, errMissingValue
+		}
+
+		argPtr := reflect.New(c.argType)
+		return argPtr, argPtr.Interface().(InputDecoder).DecodeInferable(args.Value)
+	}
+
+	if c.durations {
+		normalized, err := c.normalizeDurations(targetArgs)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		targetArgs = normalized
+	}
+
+	args := reflect.New(c.argsType)
+	if err := codec.Unmarshal(targetArgs, args.Interface()); err != nil {
+		return reflect.Value{}, err
+	}
+
+	argPtr := args.Elem().Field(0)
+	if argPtr.IsNil() {
+		return reflect.Value{}, errMissingValue
+	}
+
+	return argPtr, nil
+}
+
+// normalizeDurations rewrites the durations of the call input, which may be given as Go or ISO 8601 duration
+// strings, to the number of nanoseconds which encoding/json expects
+func (c *compiledFunction) normalizeDurations(targetArgs []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(targetArgs))
+	decoder.UseNumber()
+
+	var args map[string]interface{}
+	if err := decoder.Decode(&args); err != nil {
+		return nil, err
+	}
+
+	value, err := normalizeDurations(args["value"], c.argType)
+	if err != nil {
+		return nil, err
+	}
+	args["value"] = value
+
+	return json.Marshal(args)
+}
+
+// call calls the function with the decoded input
+func (c *compiledFunction) call(ctx context.Context, arg reflect.Value) []reflect.Value {
+	if c.invoker != nil {
+		// Generated invokers call the function directly
+		return invokerValues(c.invoker.InvokeInferable(ctx, arg.Interface()))
+	}
+
+	if c.withContext {
+		return c.value.Call([]reflect.Value{reflect.ValueOf(ctx), arg})
+	}
+
+	return c.value.Call([]reflect.Value{arg})
+}
+
+// schemaCache holds the schemas reflected from input types, so that types shared by several functions
+// (or services) are reflected once
+var schemaCache sync.Map // reflect.Type -> *jsonschema.Schema
+
+func cachedSchema(argType reflect.Type) (*jsonschema.Schema, bool) {
+	schema, ok := schemaCache.Load(argType)
+	if !ok {
+		return nil, false
+	}
+
+	return schema.(*jsonschema.Schema), true
+}
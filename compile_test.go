@@ -0,0 +1,119 @@
+package inferable
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/invopop/jsonschema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type benchmarkInput struct {
+	Name  string   `json:"name"`
+	Count int      `json:"count"`
+	Tags  []string `json:"tags"`
+}
+
+func benchmarkFunc(ctx context.Context, input benchmarkInput) (string, error) {
+	return input.Name, nil
+}
+
+var benchmarkArgs = []byte(`{"value": {"name": "Ada", "count": 3, "tags": ["a", "b"]}}`)
+
+func TestSchemaCache(t *testing.T) {
+	first, err := reflectSchema("first", reflect.TypeOf(benchmarkInput{}))
+	require.NoError(t, err)
+
+	second, err := reflectSchema("second", reflect.TypeOf(benchmarkInput{}))
+	require.NoError(t, err)
+	assert.Same(t, first, second)
+}
+
+func TestCompiledFunction(t *testing.T) {
+	compiled := compileFunction(benchmarkFunc)
+	assert.True(t, compiled.withContext)
+
+	argPtr, err := compiled.decodeArgs([]byte(`{"value": {"name": "Ada"}}`))
+	require.NoError(t, err)
+
+	returnValues := compiled.call(context.Background(), argPtr.Elem())
+	require.Len(t, returnValues, 2)
+	assert.Equal(t, "Ada", returnValues[0].Interface())
+
+	_, err = compiled.decodeArgs([]byte(`{}`))
+	assert.Equal(t, errMissingValue, err)
+}
+
+func TestCallInput(t *testing.T) {
+	input, err := callInput(json.RawMessage(`"{\"value\": {}}"`))
+	require.NoError(t, err)
+	assert.Equal(t, `{"value": {}}`, string(input))
+
+	input, err = callInput(json.RawMessage(`{"value": {}}`))
+	require.NoError(t, err)
+	assert.Equal(t, `{"value": {}}`, string(input))
+}
+
+// BenchmarkReflectSchema compares registering functions with a shared input type against reflecting it every time
+func BenchmarkReflectSchema(b *testing.B) {
+	argType := reflect.TypeOf(benchmarkInput{})
+
+	b.Run("uncached", func(b *testing.B) {
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			reflector := jsonschema.Reflector{}
+			reflector.Reflect(reflect.New(argType).Interface())
+		}
+	})
+
+	b.Run("cached", func(b *testing.B) {
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			if _, err := reflectSchema("benchmark", argType); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// BenchmarkHandleCall compares decoding and calling a function with type information derived
+// on every call against the type information compiled on registration
+func BenchmarkHandleCall(b *testing.B) {
+	ctx := context.Background()
+
+	b.Run("uncompiled", func(b *testing.B) {
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			var argsMap map[string]json.RawMessage
+			if err := json.Unmarshal(benchmarkArgs, &argsMap); err != nil {
+				b.Fatal(err)
+			}
+			fnType := reflect.TypeOf(benchmarkFunc)
+			argPtr := reflect.New(inputType(fnType))
+			if err := json.Unmarshal(argsMap["value"], argPtr.Interface()); err != nil {
+				b.Fatal(err)
+			}
+			args := []reflect.Value{argPtr.Elem()}
+			if acceptsContext(fnType) {
+				args = append([]reflect.Value{reflect.ValueOf(ctx)}, args...)
+			}
+			reflect.ValueOf(benchmarkFunc).Call(args)
+		}
+	})
+
+	b.Run("compiled", func(b *testing.B) {
+		compiled := compileFunction(benchmarkFunc)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for n := 0; n < b.N; n++ {
+			argPtr, err := compiled.decodeArgs(benchmarkArgs)
+			if err != nil {
+				b.Fatal(err)
+			}
+			compiled.call(ctx, argPtr.Elem())
+		}
+	})
+}
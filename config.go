@@ -0,0 +1,114 @@
+package inferable
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// ServiceConfig is the effective configuration of a service, as used once it is started
+type ServiceConfig struct {
+	Service       string   `json:"service"`
+	Endpoint      string   `json:"endpoint"`
+	QueueEndpoint string   `json:"queueEndpoint,omitempty"`
+	ClusterID     string   `json:"clusterId,omitempty"`
+	MachineID     string   `json:"machineId"`
+	MachineLabels []string `json:"machineLabels,omitempty"`
+	SDKVersion    string   `json:"sdkVersion"`
+	LogLevel      LogLevel `json:"logLevel"`
+
+	Functions         []string `json:"functions"`
+	FunctionCount     int      `json:"functionCount"`
+	DisabledFunctions []string `json:"disabledFunctions,omitempty"`
+
+	PollInterval      time.Duration `json:"pollInterval"`
+	MaxPollInterval   time.Duration `json:"maxPollInterval,omitempty"`
+	PollWaitTime      time.Duration `json:"pollWaitTime"`
+	MaxMessages       int64         `json:"maxMessages"`
+	VisibilityTimeout time.Duration `json:"visibilityTimeout"`
+	HeartbeatInterval time.Duration `json:"heartbeatInterval"`
+	PollTimeout       time.Duration `json:"pollTimeout"`
+	RequestTimeout    time.Duration `json:"requestTimeout"`
+
+	AutoAcknowledge         bool          `json:"autoAcknowledge"`
+	Tracing                 bool          `json:"tracing"`
+	BatchResults            bool          `json:"batchResults"`
+	MaxConcurrentCalls      int           `json:"maxConcurrentCalls"`
+	RegistrationRetryWindow time.Duration `json:"registrationRetryWindow"`
+	WatchdogInterval        time.Duration `json:"watchdogInterval"`
+	MaxCallAttempts         int           `json:"maxCallAttempts"`
+	RetryDelay              time.Duration `json:"retryDelay"`
+}
+
+// EffectiveConfig returns the configuration of the service, with defaults applied
+func (s *Service) EffectiveConfig() ServiceConfig {
+	functions := []string{}
+	disabled := []string{}
+	for _, fn := range s.functions() {
+		functions = append(functions, fn.Name)
+		if s.inferable.isFunctionDisabled(s.Name, fn.Name) {
+			disabled = append(disabled, fn.Name)
+		}
+	}
+	sort.Strings(functions)
+	sort.Strings(disabled)
+
+	config := ServiceConfig{
+		Service:       s.Name,
+		Endpoint:      s.inferable.apiEndpoint,
+		QueueEndpoint: s.inferable.queueEndpoint,
+		ClusterID:     s.ClusterID(),
+		MachineID:     s.inferable.machineID,
+		MachineLabels: s.inferable.machineLabels,
+		SDKVersion:    Version,
+		LogLevel:      s.inferable.logLevel(),
+
+		Functions:         functions,
+		FunctionCount:     len(functions),
+		DisabledFunctions: disabled,
+
+		PollInterval:      s.pollInterval(),
+		MaxPollInterval:   s.options.MaxPollInterval,
+		PollWaitTime:      s.pollWaitTime(),
+		MaxMessages:       int64(s.pollLimit()),
+		VisibilityTimeout: DefaultVisibilityTimeout * time.Second,
+		HeartbeatInterval: DefaultVisibilityTimeout * time.Second / 2,
+		PollTimeout:       s.pollTimeout(),
+		RequestTimeout:    s.inferable.client.requestTimeout(FetchDataOptions{}),
+
+		AutoAcknowledge:         !s.options.DisableAutoAcknowledge,
+		Tracing:                 s.options.StartTrace != nil,
+		BatchResults:            s.options.BatchResults,
+		MaxConcurrentCalls:      s.maxConcurrentCalls(),
+		RegistrationRetryWindow: s.registrationRetryWindow(),
+		WatchdogInterval:        s.watchdogInterval(),
+		MaxCallAttempts:         s.maxCallAttempts(),
+		RetryDelay:              s.retryDelay(),
+	}
+
+	s.consumerMu.Lock()
+	defer s.consumerMu.Unlock()
+
+	if consumer, ok := s.consumer.(*SQSConsumer); ok {
+		config.PollInterval = consumer.pollInterval
+		config.PollWaitTime = consumer.waitTime
+		config.MaxMessages = consumer.maxMessages
+		config.VisibilityTimeout = time.Duration(consumer.visibleTimeout) * time.Second
+		config.HeartbeatInterval = consumer.effectiveHeartbeatInterval()
+		config.PollTimeout = consumer.effectivePollTimeout()
+	}
+
+	return config
+}
+
+// logStartupBanner logs the effective configuration as a single line of JSON,
+// so that misconfiguration is obvious in deploy logs
+func (s *Service) logStartupBanner() {
+	banner, err := json.Marshal(s.EffectiveConfig())
+	if err != nil {
+		s.inferable.logf(LogLevelError, "Failed to marshal configuration of service '%s': %v", s.Name, err)
+		return
+	}
+
+	s.inferable.logf(LogLevelInfo, "Service '%s' starting with configuration: %s", s.Name, banner)
+}
@@ -0,0 +1,92 @@
+package inferable
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEffectiveConfig(t *testing.T) {
+	i, err := New(InferableOptions{
+		APIEndpoint:   "http://localhost:4000",
+		APISecret:     "test-secret",
+		MachineID:     "machine-1",
+		MachineLabels: []string{"gpu"},
+	})
+	require.NoError(t, err)
+
+	type TestInput struct{}
+	noop := func(input TestInput) string { return "ok" }
+
+	require.NoError(t, i.Default.RegisterFunc(Function{Name: "b", Func: noop}))
+	require.NoError(t, i.Default.RegisterFunc(Function{Name: "a", Func: noop}))
+	require.NoError(t, i.ApplyRuntimeConfig(RuntimeConfig{DisabledFunctions: []string{"default.b"}}))
+
+	config := i.Default.EffectiveConfig()
+	assert.Equal(t, "http://localhost:4000", config.Endpoint)
+	assert.Equal(t, "machine-1", config.MachineID)
+	assert.Equal(t, []string{"gpu"}, config.MachineLabels)
+	assert.Equal(t, []string{"a", "b"}, config.Functions)
+	assert.Equal(t, 2, config.FunctionCount)
+	assert.Equal(t, []string{"b"}, config.DisabledFunctions)
+	assert.Equal(t, DefaultPollInterval, config.PollInterval)
+	assert.Equal(t, int64(DefaultMaxMessages), config.MaxMessages)
+	assert.Equal(t, DefaultRegistrationRetryWindow, config.RegistrationRetryWindow)
+	assert.Equal(t, LogLevelInfo, config.LogLevel)
+	assert.True(t, config.AutoAcknowledge)
+	assert.False(t, config.Tracing)
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	i.Default.logStartupBanner()
+
+	// The banner is a single line, ending in the configuration as JSON
+	line := strings.TrimSpace(logs.String())
+	assert.NotContains(t, line, "\n")
+
+	var logged ServiceConfig
+	require.NoError(t, json.Unmarshal([]byte(line[strings.Index(line, "{"):]), &logged))
+	assert.Equal(t, config, logged)
+	assert.Equal(t, 20*time.Second, logged.PollWaitTime)
+}
+
+func TestPollOptions(t *testing.T) {
+	i, err := New(InferableOptions{
+		APIEndpoint: "http://localhost:4000",
+		APISecret:   "test-secret",
+	})
+	require.NoError(t, err)
+
+	service, err := i.RegisterServiceWithOptions("tuned", ServiceOptions{
+		PollLimit: 5,
+		WaitTime:  -1,
+		Interval:  time.Second,
+	})
+	require.NoError(t, err)
+
+	config := service.EffectiveConfig()
+	assert.Equal(t, int64(5), config.MaxMessages)
+	assert.Equal(t, time.Duration(0), config.PollWaitTime)
+	assert.Equal(t, time.Second, config.PollInterval)
+
+	consumer, err := service.newQueueConsumer()
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), consumer.maxMessages)
+	assert.Equal(t, time.Duration(0), consumer.waitTime)
+	assert.Equal(t, time.Second, consumer.pollInterval)
+
+	_, err = i.RegisterServiceWithOptions("too-many", ServiceOptions{PollLimit: 11})
+	assert.Error(t, err)
+
+	_, err = i.RegisterServiceWithOptions("too-long", ServiceOptions{WaitTime: time.Minute})
+	assert.Error(t, err)
+}
@@ -0,0 +1,43 @@
+package inferable
+
+import (
+	"context"
+	"reflect"
+)
+
+var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+
+type callContextKey struct{}
+
+// CallInfo describes the call being handled by a function
+type CallInfo struct {
+	ID       string
+	Service  string
+	Function string
+	// Attempt counts how often the call has been dispatched, starting at 1. Calls are dispatched again
+	// when they fail with a retryable error, see Retryable.
+	Attempt int
+	// TraceID is the ID of the trace the call is handled in, if ServiceOptions.StartTrace is set.
+	// It can be attached to metrics recorded in hooks as an exemplar.
+	TraceID string
+}
+
+// CallInfoFromContext returns the call being handled, if ctx was passed to a function by the service
+func CallInfoFromContext(ctx context.Context) (CallInfo, bool) {
+	info, ok := ctx.Value(callContextKey{}).(CallInfo)
+	return info, ok
+}
+
+func withCallInfo(ctx context.Context, info CallInfo) context.Context {
+	return context.WithValue(ctx, callContextKey{}, info)
+}
+
+// acceptsContext reports whether fnType takes a context.Context as its first argument
+func acceptsContext(fnType reflect.Type) bool {
+	return fnType.NumIn() == 2 && fnType.In(0) == contextType
+}
+
+// inputType returns the type of the input struct of fnType
+func inputType(fnType reflect.Type) reflect.Type {
+	return fnType.In(fnType.NumIn() - 1)
+}
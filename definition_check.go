@@ -0,0 +1,180 @@
+package inferable
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// SchemaProblem is a problem with the definition of a function, found by Service.Validate
+type SchemaProblem struct {
+	Function string
+	// Path of the problem within the input schema, e.g. "$.properties.age"
+	Path    string
+	Message string
+}
+
+func (p SchemaProblem) String() string {
+	if p.Path == "" {
+		return fmt.Sprintf("function '%s': %s", p.Function, p.Message)
+	}
+	return fmt.Sprintf("function '%s' at %s: %s", p.Function, p.Path, p.Message)
+}
+
+// SchemaError lists all problems found with the functions of a service
+type SchemaError struct {
+	Service  string
+	Problems []SchemaProblem
+}
+
+func (e *SchemaError) Error() string {
+	problems := make([]string, len(e.Problems))
+	for idx, problem := range e.Problems {
+		problems[idx] = problem.String()
+	}
+	return fmt.Sprintf("service '%s' has %d schema problems: %s", e.Service, len(e.Problems), strings.Join(problems, "; "))
+}
+
+var schemaTypes = map[string]bool{
+	"string": true, "number": true, "integer": true, "boolean": true, "object": true, "array": true, "null": true,
+}
+
+// Validate checks the definitions of the functions of the service as they would be registered, and reports
+// all problems at once as a *SchemaError, rather than one at a time during registration in production
+func (s *Service) Validate(ctx context.Context) error {
+	functions := s.functions()
+	if len(functions) == 0 {
+		return fmt.Errorf("cannot register service '%s': no functions registered", s.Name)
+	}
+
+	problems := []SchemaProblem{}
+	for _, fn := range functions {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		schemaJSON, err := json.Marshal(fn.schema)
+		if err != nil {
+			problems = append(problems, SchemaProblem{Function: fn.Name, Message: fmt.Sprintf("failed to marshal schema: %v", err)})
+			continue
+		}
+
+		var schema interface{}
+		if err := json.Unmarshal(schemaJSON, &schema); err != nil {
+			problems = append(problems, SchemaProblem{Function: fn.Name, Message: fmt.Sprintf("invalid schema: %v", err)})
+			continue
+		}
+
+		root, ok := schema.(map[string]interface{})
+		if !ok || root["type"] != "object" {
+			problems = append(problems, SchemaProblem{Function: fn.Name, Path: "$", Message: "input schema must be of type object"})
+		}
+
+		for _, problem := range schemaProblems(schema, "$") {
+			problem.Function = fn.Name
+			problems = append(problems, problem)
+		}
+	}
+
+	if len(problems) > 0 {
+		return &SchemaError{Service: s.Name, Problems: problems}
+	}
+
+	return nil
+}
+
+// schemaProblems returns the problems of a schema and its subschemas
+func schemaProblems(value interface{}, path string) []SchemaProblem {
+	schema, ok := value.(map[string]interface{})
+	if !ok {
+		// Boolean schemas accept or reject any value
+		return nil
+	}
+
+	problems := []SchemaProblem{}
+	report := func(format string, args ...interface{}) {
+		problems = append(problems, SchemaProblem{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if _, ok := schema["$ref"]; ok {
+		report("references are not supported")
+	}
+
+	switch schemaType := schema["type"].(type) {
+	case nil:
+	case string:
+		if !schemaTypes[schemaType] {
+			report("unknown type '%s'", schemaType)
+		}
+	case []interface{}:
+		for _, item := range schemaType {
+			if name, ok := item.(string); !ok || !schemaTypes[name] {
+				report("unknown type '%v'", item)
+			}
+		}
+	default:
+		report("type must be a string or an array of strings")
+	}
+
+	properties, _ := schema["properties"].(map[string]interface{})
+	if required, ok := schema["required"].([]interface{}); ok {
+		for _, name := range required {
+			if _, ok := properties[fmt.Sprint(name)]; !ok {
+				report("required property '%v' is not defined", name)
+			}
+		}
+	}
+
+	if enum, ok := schema["enum"]; ok {
+		if values, ok := enum.([]interface{}); !ok || len(values) == 0 {
+			report("enum must be a non-empty array")
+		}
+	}
+
+	if pattern, ok := schema["pattern"].(string); ok {
+		if _, err := regexp.Compile(pattern); err != nil {
+			report("invalid pattern: %v", err)
+		}
+	}
+
+	for _, bounds := range [][2]string{{"minimum", "maximum"}, {"minLength", "maxLength"}, {"minItems", "maxItems"}, {"minProperties", "maxProperties"}} {
+		lower, lowerOk := schema[bounds[0]].(float64)
+		upper, upperOk := schema[bounds[1]].(float64)
+		if lowerOk && upperOk && lower > upper {
+			report("%s %v exceeds %s %v", bounds[0], lower, bounds[1], upper)
+		}
+	}
+
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		problems = append(problems, schemaProblems(properties[name], path+".properties."+name)...)
+	}
+
+	for _, key := range []string{"items", "additionalProperties"} {
+		problems = append(problems, schemaProblems(schema[key], path+"."+key)...)
+	}
+
+	for _, key := range []string{"oneOf", "anyOf", "allOf"} {
+		subschemas, ok := schema[key]
+		if !ok {
+			continue
+		}
+		items, ok := subschemas.([]interface{})
+		if !ok || len(items) == 0 {
+			report("%s must be a non-empty array", key)
+			continue
+		}
+		for idx, item := range items {
+			problems = append(problems, schemaProblems(item, fmt.Sprintf("%s.%s[%d]", path, key, idx))...)
+		}
+	}
+
+	return problems
+}
@@ -0,0 +1,65 @@
+package inferable
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServiceValidate(t *testing.T) {
+	i, err := New(InferableOptions{
+		APIEndpoint: DefaultAPIEndpoint,
+		APISecret:   "test-secret",
+	})
+	require.NoError(t, err)
+
+	service, err := i.RegisterService("checked")
+	require.NoError(t, err)
+	assert.Error(t, service.Validate(context.Background()))
+
+	type TestInput struct {
+		A int `json:"a" validate:"min=1,max=5"`
+	}
+	require.NoError(t, service.RegisterFunc(Function{
+		Name: "valid",
+		Func: func(input TestInput) int { return input.A },
+	}))
+	require.NoError(t, service.Validate(context.Background()))
+
+	require.NoError(t, service.RegisterFunc(Function{
+		Name: "invalid",
+		Func: func(input json.RawMessage) int { return 1 },
+		InputSchema: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"age": {"type": "int", "minimum": 10, "maximum": 1},
+				"code": {"type": "string", "pattern": "(unclosed"},
+				"tags": {"type": "array", "items": {"$ref": "#/$defs/Tag"}}
+			},
+			"required": ["age", "name"]
+		}`),
+	}))
+
+	err = service.Validate(context.Background())
+	var schemaErr *SchemaError
+	require.True(t, errors.As(err, &schemaErr))
+	assert.Equal(t, "checked", schemaErr.Service)
+
+	messages := []string{}
+	for _, problem := range schemaErr.Problems {
+		assert.Equal(t, "invalid", problem.Function)
+		messages = append(messages, problem.Path+": "+problem.Message)
+	}
+	assert.Equal(t, []string{
+		"$: required property 'name' is not defined",
+		"$.properties.age: unknown type 'int'",
+		"$.properties.age: minimum 10 exceeds maximum 1",
+		"$.properties.code: invalid pattern: error parsing regexp: missing closing ): `(unclosed`",
+		"$.properties.tags.items: references are not supported",
+	}, messages)
+	assert.Contains(t, err.Error(), "service 'checked' has 5 schema problems")
+}
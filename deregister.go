@@ -0,0 +1,95 @@
+package inferable
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// DeregisterFunc removes a function from the service. If the service has registered, its registration
+// is updated so that the cluster stops routing calls to the function. A registered service left
+// without functions is deregistered from the cluster and stopped.
+func (s *Service) DeregisterFunc(name string) error {
+	s.functionsMu.Lock()
+	if _, exists := s.Functions[name]; !exists {
+		s.functionsMu.Unlock()
+		return fmt.Errorf("function with name '%s' not registered for service '%s'", name, s.Name)
+	}
+	delete(s.Functions, name)
+	remaining := len(s.Functions)
+	s.functionsMu.Unlock()
+
+	if !s.IsRegistered() {
+		return nil
+	}
+
+	if remaining == 0 {
+		err := s.deregisterMachine()
+		s.Stop()
+		return err
+	}
+
+	if err := s.registerMachine(); err != nil {
+		return fmt.Errorf("failed to update registration of service '%s': %w", s.Name, err)
+	}
+
+	return nil
+}
+
+// DeregisterService stops the service and removes it, so that it is no longer reported to the cluster
+// as active. If the service has registered, the cluster is told that it no longer has any functions.
+// The default service can not be deregistered.
+func (i *Inferable) DeregisterService(name string) error {
+	if name == "default" {
+		return fmt.Errorf("the default service can not be deregistered")
+	}
+
+	service, exists := i.functionRegistry.remove(name)
+	if !exists {
+		return fmt.Errorf("service with name '%s' not found", name)
+	}
+
+	var err error
+	if service.IsRegistered() {
+		err = service.deregisterMachine()
+	}
+
+	service.Stop()
+	return err
+}
+
+// deregisterMachine registers the service without functions, so that the cluster stops routing calls
+// to the functions of its previous registration
+func (s *Service) deregisterMachine() error {
+	payload := struct {
+		Service   string                 `json:"service"`
+		Labels    []string               `json:"labels,omitempty"`
+		Functions []functionRegistration `json:"functions"`
+	}{
+		Service:   s.Name,
+		Labels:    s.inferable.machineLabels,
+		Functions: []functionRegistration{},
+	}
+
+	jsonPayload, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %v", err)
+	}
+
+	_, err = s.inferable.FetchData(FetchDataOptions{
+		Path:    "/machines",
+		Method:  "POST",
+		Headers: s.machineHeaders(),
+		Body:    string(jsonPayload),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to deregister service '%s': %w", s.Name, err)
+	}
+
+	if s.inferable.registrationHistoryDir != "" {
+		if err := saveRegistration(registrationHistoryPath(s.inferable.registrationHistoryDir, s.Name), payload.Functions); err != nil {
+			s.inferable.logf(LogLevelError, "Failed to save registration of service '%s': %v", s.Name, err)
+		}
+	}
+
+	return nil
+}
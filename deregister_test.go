@@ -0,0 +1,84 @@
+package inferable
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeregistration(t *testing.T) {
+	var mu sync.Mutex
+	var registrations [][]string
+	var pinged []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/ping" {
+			var payload struct {
+				Services []string `json:"services"`
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+			mu.Lock()
+			pinged = payload.Services
+			mu.Unlock()
+		}
+		if r.URL.Path == "/machines" {
+			var payload struct {
+				Functions []functionRegistration `json:"functions"`
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+			names := []string{}
+			for _, fn := range payload.Functions {
+				names = append(names, fn.Name)
+			}
+			mu.Lock()
+			registrations = append(registrations, names)
+			mu.Unlock()
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	i, err := New(InferableOptions{
+		APIEndpoint: server.URL,
+		APISecret:   "test-secret",
+	})
+	require.NoError(t, err)
+
+	service, err := i.RegisterService("tools")
+	require.NoError(t, err)
+
+	type TestInput struct{}
+	require.NoError(t, service.RegisterFunc(Function{Name: "Keep", Func: func(input TestInput) int { return 1 }}))
+	require.NoError(t, service.RegisterFunc(Function{Name: "Remove", Func: func(input TestInput) int { return 2 }}))
+	require.NoError(t, service.registerMachine())
+
+	// The registration is updated without the removed function
+	require.NoError(t, service.DeregisterFunc("Remove"))
+	assert.Error(t, service.DeregisterFunc("Remove"))
+	_, exists := service.function("Remove")
+	assert.False(t, exists)
+
+	mu.Lock()
+	assert.Equal(t, [][]string{{"Keep", "Remove"}, {"Keep"}}, registrations)
+	mu.Unlock()
+
+	// Removing the last function deregisters and stops the service, which is no longer pinged as active
+	require.NoError(t, service.DeregisterFunc("Keep"))
+	assert.False(t, service.IsRegistered())
+	i.pingCluster()
+
+	mu.Lock()
+	assert.Equal(t, [][]string{{"Keep", "Remove"}, {"Keep"}, {}}, registrations)
+	assert.Equal(t, []string{"default"}, pinged)
+	mu.Unlock()
+
+	require.NoError(t, i.DeregisterService("tools"))
+	_, exists = i.functionRegistry.get("tools")
+	assert.False(t, exists)
+	assert.Error(t, i.DeregisterService("tools"))
+	assert.Error(t, i.DeregisterService("default"))
+}
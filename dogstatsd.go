@@ -0,0 +1,71 @@
+package inferable
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DogStatsDSink is a MetricsSink which sends metrics to a DogStatsD agent (e.g. the Datadog agent) over UDP
+type DogStatsDSink struct {
+	mu   sync.Mutex
+	conn net.Conn
+	// tags are added to every metric, e.g. "env:prod"
+	tags []string
+}
+
+// NewDogStatsDSink sends metrics to the DogStatsD agent at addr (usually "localhost:8125"), tagging each
+// with tags in the "key:value" form in addition to their own
+func NewDogStatsDSink(addr string, tags ...string) (*DogStatsDSink, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to DogStatsD agent: %v", err)
+	}
+
+	return &DogStatsDSink{conn: conn, tags: tags}, nil
+}
+
+// Count sends a counter increment
+func (d *DogStatsDSink) Count(name string, value int64, tags map[string]string) {
+	d.send(name, fmt.Sprint(value), "c", tags)
+}
+
+// Gauge sends the current value of a gauge
+func (d *DogStatsDSink) Gauge(name string, value float64, tags map[string]string) {
+	d.send(name, fmt.Sprint(value), "g", tags)
+}
+
+// Histogram sends a value to be aggregated in a histogram
+func (d *DogStatsDSink) Histogram(name string, value float64, tags map[string]string) {
+	d.send(name, fmt.Sprint(value), "h", tags)
+}
+
+// Close closes the connection to the agent
+func (d *DogStatsDSink) Close() error {
+	return d.conn.Close()
+}
+
+// send writes a metric in the DogStatsD datagram format: <name>:<value>|<type>|#<tag>,<tag>
+func (d *DogStatsDSink) send(name, value, kind string, tags map[string]string) {
+	all := append([]string{}, d.tags...)
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		all = append(all, key+":"+tags[key])
+	}
+
+	datagram := name + ":" + value + "|" + kind
+	if len(all) > 0 {
+		datagram += "|#" + strings.Join(all, ",")
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	// Metrics are sent on a best effort basis, like DogStatsD clients do
+	d.conn.Write([]byte(datagram))
+}
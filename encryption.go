@@ -0,0 +1,158 @@
+package inferable
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// resultEncryptionAlgorithm identifies the hybrid scheme used to encrypt sensitive result fields:
+// a random AES-256-GCM key per field, encrypted with the RSA-OAEP (SHA-256) public key of the cluster
+const resultEncryptionAlgorithm = "RSA-OAEP-256+A256GCM"
+
+// encryptedField replaces the value of a sensitive field in an encrypted result
+type encryptedField struct {
+	Encrypted encryptedValue `json:"$encrypted"`
+}
+
+type encryptedValue struct {
+	Algorithm string `json:"alg"`
+	Key       []byte `json:"key"`
+	Nonce     []byte `json:"nonce"`
+	Data      []byte `json:"data"`
+}
+
+// parseResultEncryptionKey parses the PEM encoded RSA public key provided by the cluster at registration
+func parseResultEncryptionKey(encoded string) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode([]byte(encoded))
+	if block == nil {
+		return nil, fmt.Errorf("result encryption key is not PEM encoded")
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse result encryption key: %v", err)
+	}
+
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("result encryption key must be an RSA key, got %T", key)
+	}
+
+	return rsaKey, nil
+}
+
+// resultEncryptionKey returns the key provided by the cluster at the last registration, nil if there is none
+func (s *Service) resultEncryptionKey() *rsa.PublicKey {
+	s.credentialsMu.RLock()
+	defer s.credentialsMu.RUnlock()
+
+	return s.resultKey
+}
+
+// sensitiveFields returns the JSON names of the fields of a struct result tagged `inferable:"sensitive"`
+func sensitiveFields(value interface{}) []string {
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	fields := []string{}
+	for idx := 0; idx < v.NumField(); idx++ {
+		field := v.Type().Field(idx)
+		if !field.IsExported() || !hasInferableOption(field, "sensitive") {
+			continue
+		}
+
+		name := field.Name
+		if tag, _, _ := strings.Cut(field.Tag.Get("json"), ","); tag == "-" {
+			continue
+		} else if tag != "" {
+			name = tag
+		}
+		fields = append(fields, name)
+	}
+
+	return fields
+}
+
+// encryptSensitiveFields encrypts the fields of resultJSON marked as sensitive on value with key.
+// Results without sensitive fields are returned unchanged.
+func encryptSensitiveFields(value interface{}, resultJSON []byte, key *rsa.PublicKey) ([]byte, error) {
+	fields := sensitiveFields(value)
+	if len(fields) == 0 {
+		return resultJSON, nil
+	}
+
+	var result map[string]json.RawMessage
+	if err := json.Unmarshal(resultJSON, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal result for encryption: %v", err)
+	}
+
+	for _, name := range fields {
+		plaintext, ok := result[name]
+		if !ok {
+			continue
+		}
+
+		encrypted, err := encryptValue(plaintext, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt field '%s': %v", name, err)
+		}
+
+		result[name], err = json.Marshal(encryptedField{Encrypted: encrypted})
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal encrypted field '%s': %v", name, err)
+		}
+	}
+
+	return json.Marshal(result)
+}
+
+func encryptValue(plaintext []byte, key *rsa.PublicKey) (encryptedValue, error) {
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return encryptedValue{}, err
+	}
+
+	block, err := aes.NewCipher(dataKey)
+	if err != nil {
+		return encryptedValue{}, err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return encryptedValue{}, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return encryptedValue{}, err
+	}
+
+	encryptedKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, key, dataKey, nil)
+	if err != nil {
+		return encryptedValue{}, err
+	}
+
+	return encryptedValue{
+		Algorithm: resultEncryptionAlgorithm,
+		Key:       encryptedKey,
+		Nonce:     nonce,
+		Data:      gcm.Seal(nil, nonce, plaintext, nil),
+	}, nil
+}
@@ -0,0 +1,103 @@
+package inferable
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResultEncryption(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	publicKey, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	require.NoError(t, err)
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKey})
+
+	var persisted struct {
+		Result string `json:"result"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/machines":
+			json.NewEncoder(w).Encode(map[string]string{"queueUrl": "queue", "resultEncryptionKey": string(publicPEM)})
+		case "/jobs/call-1/result":
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&persisted))
+			w.Write([]byte(`{}`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer server.Close()
+
+	i, err := New(InferableOptions{
+		APIEndpoint: server.URL,
+		APISecret:   "test-secret",
+	})
+	require.NoError(t, err)
+
+	type TestInput struct{}
+	type Patient struct {
+		Name string `json:"name"`
+		SSN  string `json:"ssn" inferable:"sensitive"`
+	}
+
+	require.NoError(t, i.Default.RegisterFunc(Function{
+		Name: "GetPatient",
+		Func: func(input TestInput) Patient { return Patient{Name: "Jane", SSN: "123-45-6789"} },
+	}))
+	require.NoError(t, i.Default.registerMachine())
+	require.NotNil(t, i.Default.resultKey)
+
+	body := `{"value": {"id": "call-1", "service": "default", "targetFn": "GetPatient", "targetArgs": "{\"value\": {}}"}}`
+	require.NoError(t, i.Default.handleMessage(&sqs.Message{Body: aws.String(body)}, time.Now()))
+
+	assert.NotContains(t, persisted.Result, "123-45-6789")
+
+	var result struct {
+		Value struct {
+			Name string         `json:"name"`
+			SSN  encryptedField `json:"ssn"`
+		} `json:"value"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(persisted.Result), &result))
+	assert.Equal(t, "Jane", result.Value.Name)
+
+	encrypted := result.Value.SSN.Encrypted
+	assert.Equal(t, resultEncryptionAlgorithm, encrypted.Algorithm)
+
+	dataKey, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, privateKey, encrypted.Key, nil)
+	require.NoError(t, err)
+	block, err := aes.NewCipher(dataKey)
+	require.NoError(t, err)
+	gcm, err := cipher.NewGCM(block)
+	require.NoError(t, err)
+	plaintext, err := gcm.Open(nil, encrypted.Nonce, encrypted.Data, nil)
+	require.NoError(t, err)
+	assert.Equal(t, `"123-45-6789"`, string(plaintext))
+}
+
+func TestSensitiveFields(t *testing.T) {
+	type Result struct {
+		Public  string
+		Secret  string `inferable:"sensitive"`
+		Renamed string `json:"token,omitempty" inferable:"sensitive"`
+		Skipped string `json:"-" inferable:"sensitive"`
+	}
+
+	assert.Equal(t, []string{"Secret", "token"}, sensitiveFields(&Result{}))
+	assert.Empty(t, sensitiveFields("not a struct"))
+}
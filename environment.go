@@ -0,0 +1,69 @@
+package inferable
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Environment is a named preset for the Inferable API endpoint
+type Environment string
+
+// EnvironmentProduction is the hosted Inferable API at DefaultAPIEndpoint. Other deployments, e.g. staging
+// or self-hosted control planes, set InferableOptions.APIEndpoint instead.
+const EnvironmentProduction Environment = "production"
+
+var environmentEndpoints = map[Environment]string{
+	EnvironmentProduction: DefaultAPIEndpoint,
+}
+
+// resolveEndpoint determines the API endpoint from the environment and endpoint options.
+// Self-hosted deployments set the endpoint directly and leave the environment empty.
+func resolveEndpoint(environment Environment, endpoint string) (string, error) {
+	if environment == "" {
+		if endpoint == "" {
+			return DefaultAPIEndpoint, nil
+		}
+		return endpoint, nil
+	}
+
+	presetEndpoint, ok := environmentEndpoints[environment]
+	if !ok {
+		return "", fmt.Errorf("unknown environment '%s'", environment)
+	}
+
+	if endpoint != "" && strings.TrimSuffix(endpoint, "/") != presetEndpoint {
+		return "", fmt.Errorf("API endpoint %s does not match environment '%s' (%s)", endpoint, environment, presetEndpoint)
+	}
+
+	return presetEndpoint, nil
+}
+
+// CheckEnvironment verifies that the API endpoint is reachable and that the API secret has access to the
+// configured cluster (InferableOptions.ClusterID) there. This catches e.g. a production secret used
+// against a staging endpoint. The cluster ID is required, as the check can not be performed without it.
+func (i *Inferable) CheckEnvironment() error {
+	if i.clusterID == "" {
+		return fmt.Errorf("cluster ID must be provided to check the environment")
+	}
+
+	if err := i.ServerOk(); err != nil {
+		return fmt.Errorf("API endpoint %s is not reachable: %w", i.apiEndpoint, err)
+	}
+
+	_, err := i.GetCluster(context.Background())
+
+	var apiErr *APIError
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, ErrAuthExpired):
+		return fmt.Errorf("API secret was rejected by %s. check that the secret belongs to cluster '%s' in this environment: %w", i.apiEndpoint, i.clusterID, err)
+	case errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound:
+		return fmt.Errorf("cluster '%s' was not found at %s. check that the endpoint is the environment of the cluster: %w", i.clusterID, i.apiEndpoint, err)
+	default:
+		return fmt.Errorf("failed to verify API secret against %s: %w", i.apiEndpoint, err)
+	}
+}
@@ -0,0 +1,163 @@
+package inferable
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// requestIDHeaders are checked in order for the ID the API assigned to a request
+var requestIDHeaders = []string{"X-Request-Id", "X-Trace-Id"}
+
+// APIError is returned when the Inferable API responds with an error status code.
+// RequestID can be used to cross-reference the failure with Inferable support.
+type APIError struct {
+	StatusCode int
+	Body       string
+	RequestID  string
+	// Retryable is true if the request may succeed when retried later
+	Retryable bool
+	// rejectedOnStartup is set for rejected credentials on registration or the first poll, which match
+	// ErrUnauthorized rather than ErrAuthExpired, see unauthorizedError
+	rejectedOnStartup bool
+}
+
+func (e *APIError) Error() string {
+	if e.RequestID != "" {
+		return fmt.Sprintf("API error: %s (status code: %d, request ID: %s)", e.Body, e.StatusCode, e.RequestID)
+	}
+
+	return fmt.Sprintf("API error: %s (status code: %d)", e.Body, e.StatusCode)
+}
+
+// ErrAuthExpired matches errors for requests where the API rejected the secret (401 or 403) while the
+// machine was running, even after refreshing it, e.g. because it was rotated. Check for it with errors.Is.
+var ErrAuthExpired = errors.New("API secret was rejected")
+
+// ErrUnauthorized matches errors for credentials which were never valid, e.g. a consume secret used to
+// register a machine. Registration and the first poll fail fast with it instead of being retried.
+// Check for it with errors.Is.
+var ErrUnauthorized = errors.New("credentials are not authorized")
+
+// Is reports whether the error matches target, so that rejected secrets match ErrUnauthorized when they
+// were rejected on startup, and ErrAuthExpired otherwise
+func (e *APIError) Is(target error) bool {
+	if target != ErrAuthExpired && target != ErrUnauthorized {
+		return false
+	}
+	if (target == ErrUnauthorized) != e.rejectedOnStartup {
+		return false
+	}
+
+	return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+}
+
+// queueCredentialsGuidance explains which credentials are used to poll the queue
+const queueCredentialsGuidance = "polling uses the queue credentials issued to the machine at registration"
+
+// machineSecretGuidance explains which secret is expected by requests made on behalf of the machine
+const machineSecretGuidance = "registering and polling require a machine secret for the cluster, " +
+	"consume secrets can only be used to manage runs"
+
+// unauthorizedError makes err match ErrUnauthorized if the API rejected the credentials on startup, and
+// adds guidance on the expected secret
+func unauthorizedError(err error, guidance string) error {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || !errors.Is(apiErr, ErrAuthExpired) {
+		return err
+	}
+	apiErr.rejectedOnStartup = true
+
+	return fmt.Errorf("%w (%s)", err, guidance)
+}
+
+// RateLimitedError is returned when the Inferable API responds with 429 Too Many Requests.
+// RetryAfter is the delay requested by the API before retrying, or zero if none was given.
+type RateLimitedError struct {
+	*APIError
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitedError) Unwrap() error {
+	return e.APIError
+}
+
+// parseRetryAfter parses a Retry-After header value given either in seconds or as an HTTP-date
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		if delay := date.Sub(now); delay > 0 {
+			return delay
+		}
+	}
+
+	return 0
+}
+
+func newAPIError(resp *http.Response, body []byte) error {
+	apiErr := newBaseAPIError(resp, body)
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return &RateLimitedError{
+			APIError:   apiErr,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		}
+	}
+
+	return apiErr
+}
+
+func newBaseAPIError(resp *http.Response, body []byte) *APIError {
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+		Body:       string(body),
+		Retryable:  isRetryableStatus(resp.StatusCode),
+	}
+
+	for _, header := range requestIDHeaders {
+		if requestID := resp.Header.Get(header); requestID != "" {
+			apiErr.RequestID = requestID
+			break
+		}
+	}
+
+	return apiErr
+}
+
+// isRetryableError reports whether a failed request may succeed when retried,
+// i.e. the API returned a retryable status or the request did not reach the API
+func isRetryableError(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Retryable
+	}
+
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+func isRetryableStatus(statusCode int) bool {
+	switch statusCode {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return true
+	case http.StatusNotImplemented, http.StatusHTTPVersionNotSupported:
+		return false
+	}
+
+	return statusCode >= 500
+}
@@ -0,0 +1,179 @@
+package inferable
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, 30*time.Second, parseRetryAfter("30", now))
+	assert.Equal(t, 90*time.Second, parseRetryAfter(now.Add(90*time.Second).Format(http.TimeFormat), now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter(now.Add(-time.Minute).Format(http.TimeFormat), now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("soon", now))
+}
+
+func TestRateLimitedError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "7")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	client, err := NewClient(ClientOptions{Endpoint: server.URL})
+	require.NoError(t, err)
+
+	_, err = client.FetchData(FetchDataOptions{Path: "/live", Method: "GET"})
+	require.Error(t, err)
+
+	var rateLimited *RateLimitedError
+	require.True(t, errors.As(err, &rateLimited))
+	assert.Equal(t, 7*time.Second, rateLimited.RetryAfter)
+
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.True(t, apiErr.Retryable)
+}
+
+func TestAuthExpiredRecovery(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Header.Get("Authorization"))
+		if r.Header.Get("Authorization") != "Bearer rotated" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	refreshes := 0
+	client, err := NewClient(ClientOptions{
+		Endpoint: server.URL,
+		Secret:   "stale",
+		SecretProvider: func(ctx context.Context) (string, error) {
+			refreshes++
+			return "rotated", nil
+		},
+	})
+	require.NoError(t, err)
+
+	_, err = client.FetchData(FetchDataOptions{Path: "/live", Method: "GET"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Bearer stale", "Bearer rotated"}, requests)
+
+	// The refreshed secret is used for subsequent requests
+	_, err = client.FetchData(FetchDataOptions{Path: "/live", Method: "GET"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, refreshes)
+
+	client, err = NewClient(ClientOptions{
+		Endpoint: server.URL,
+		Secret:   "stale",
+		SecretProvider: func(ctx context.Context) (string, error) {
+			return "still-stale", nil
+		},
+	})
+	require.NoError(t, err)
+
+	requests = nil
+	_, err = client.FetchData(FetchDataOptions{Path: "/live", Method: "GET"})
+	assert.True(t, errors.Is(err, ErrAuthExpired))
+	assert.False(t, errors.Is(err, ErrUnauthorized))
+	assert.Len(t, requests, 2)
+
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+}
+
+func TestRequestTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(500 * time.Millisecond):
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(ClientOptions{Endpoint: server.URL, Secret: "secret", Timeout: 50 * time.Millisecond})
+	require.NoError(t, err)
+
+	_, err = client.FetchData(FetchDataOptions{Path: "/live", Method: "GET"})
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	// Requests can override the timeout
+	_, err = client.FetchData(FetchDataOptions{Path: "/live", Method: "GET", Timeout: time.Second})
+	assert.NoError(t, err)
+
+	client, err = NewClient(ClientOptions{Endpoint: server.URL, Secret: "secret"})
+	require.NoError(t, err)
+	assert.Equal(t, DefaultRequestTimeout, client.requestTimeout(FetchDataOptions{}))
+	assert.Equal(t, time.Duration(0), client.requestTimeout(FetchDataOptions{Timeout: -1}))
+}
+
+func TestSecretRefreshInterval(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Header.Get("Authorization"))
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	current := "first"
+	client, err := NewClient(ClientOptions{
+		Endpoint: server.URL,
+		SecretProvider: func(ctx context.Context) (string, error) {
+			return current, nil
+		},
+		SecretRefreshInterval: 50 * time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	_, err = client.FetchData(FetchDataOptions{Path: "/live", Method: "GET"})
+	require.NoError(t, err)
+
+	// The rotated secret is used once the interval has passed, without a request being rejected
+	current = "second"
+	_, err = client.FetchData(FetchDataOptions{Path: "/live", Method: "GET"})
+	require.NoError(t, err)
+	time.Sleep(60 * time.Millisecond)
+	_, err = client.FetchData(FetchDataOptions{Path: "/live", Method: "GET"})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"Bearer first", "Bearer first", "Bearer second"}, requests)
+}
+
+func TestUpdateSecret(t *testing.T) {
+	var requests []string
+	var client *Client
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Header.Get("Authorization"))
+		if r.Header.Get("Authorization") != "Bearer new" {
+			// The secret is rotated while the request is in flight
+			client.UpdateSecret("new")
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	var err error
+	client, err = NewClient(ClientOptions{Endpoint: server.URL, Secret: "old"})
+	require.NoError(t, err)
+
+	_, err = client.FetchData(FetchDataOptions{Path: "/live", Method: "GET"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Bearer old", "Bearer new"}, requests)
+}
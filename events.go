@@ -0,0 +1,89 @@
+package inferable
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Event is an entry in the cluster event and audit log
+type Event struct {
+	ID        string                 `json:"id"`
+	Type      string                 `json:"type"`
+	CreatedAt time.Time              `json:"createdAt"`
+	RunID     string                 `json:"runId,omitempty"`
+	CallID    string                 `json:"jobId,omitempty"`
+	MachineID string                 `json:"machineId,omitempty"`
+	Service   string                 `json:"service,omitempty"`
+	Function  string                 `json:"targetFn,omitempty"`
+	Meta      map[string]interface{} `json:"meta,omitempty"`
+}
+
+// ListEventsInput filters the events returned by ListEvents. All filters are optional.
+type ListEventsInput struct {
+	RunID     string
+	CallID    string
+	MachineID string
+	// Type restricts the events to a single event type
+	Type string
+	// From and To bound the time at which the events were created
+	From time.Time
+	To   time.Time
+	// Limit is the maximum number of events to return. Defaults to the limit of the API.
+	Limit int
+}
+
+func (input ListEventsInput) queryParams() map[string]string {
+	params := map[string]string{}
+
+	set := func(key, value string) {
+		if value != "" {
+			params[key] = value
+		}
+	}
+
+	set("runId", input.RunID)
+	set("jobId", input.CallID)
+	set("machineId", input.MachineID)
+	set("type", input.Type)
+
+	if !input.From.IsZero() {
+		params["from"] = input.From.UTC().Format(time.RFC3339)
+	}
+	if !input.To.IsZero() {
+		params["to"] = input.To.UTC().Format(time.RFC3339)
+	}
+	if input.Limit > 0 {
+		params["limit"] = strconv.Itoa(input.Limit)
+	}
+
+	return params
+}
+
+// ListEvents returns the events of the cluster matching the filters, e.g. to build dashboards or debugging tools
+func (i *Inferable) ListEvents(input ListEventsInput) ([]Event, error) {
+	if i.clusterID == "" {
+		return nil, fmt.Errorf("cluster ID must be provided to list events")
+	}
+
+	if !input.From.IsZero() && !input.To.IsZero() && input.To.Before(input.From) {
+		return nil, fmt.Errorf("invalid time range: %s is before %s", input.To, input.From)
+	}
+
+	responseData, err := i.FetchData(FetchDataOptions{
+		Path:        fmt.Sprintf("/clusters/%s/events", i.clusterID),
+		Method:      "GET",
+		QueryParams: input.queryParams(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list events: %w", err)
+	}
+
+	events := []Event{}
+	if err := json.Unmarshal(responseData, &events); err != nil {
+		return nil, fmt.Errorf("failed to parse events: %v", err)
+	}
+
+	return events, nil
+}
@@ -0,0 +1,60 @@
+package inferable
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListEvents(t *testing.T) {
+	var query map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/clusters/test-cluster/events" {
+			w.Write([]byte(`{}`))
+			return
+		}
+
+		query = map[string]string{}
+		for key := range r.URL.Query() {
+			query[key] = r.URL.Query().Get(key)
+		}
+		w.Write([]byte(`[{"id": "evt-1", "type": "jobResulted", "createdAt": "2024-05-01T10:00:00Z", "runId": "run-1", "jobId": "call-1", "machineId": "machine-1"}]`))
+	}))
+	defer server.Close()
+
+	i, err := New(InferableOptions{
+		APIEndpoint: server.URL,
+		APISecret:   "test-secret",
+		ClusterID:   "test-cluster",
+	})
+	require.NoError(t, err)
+
+	from := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	events, err := i.ListEvents(ListEventsInput{
+		RunID:     "run-1",
+		MachineID: "machine-1",
+		From:      from,
+		To:        from.Add(24 * time.Hour),
+		Limit:     10,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{
+		"runId":     "run-1",
+		"machineId": "machine-1",
+		"from":      "2024-05-01T00:00:00Z",
+		"to":        "2024-05-02T00:00:00Z",
+		"limit":     "10",
+	}, query)
+
+	require.Len(t, events, 1)
+	assert.Equal(t, "call-1", events[0].CallID)
+	assert.Equal(t, from.Add(10*time.Hour), events[0].CreatedAt)
+
+	_, err = i.ListEvents(ListEventsInput{From: from, To: from.Add(-time.Hour)})
+	assert.Error(t, err)
+}
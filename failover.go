@@ -0,0 +1,96 @@
+package inferable
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultEndpointCooldown is how long an endpoint which could not be reached is skipped, see ClientOptions.FallbackEndpoints
+const DefaultEndpointCooldown = 30 * time.Second
+
+// endpointPool tracks the health of the endpoints of the API, in order of preference
+type endpointPool struct {
+	endpoints []string
+	cooldown  time.Duration
+
+	mu sync.Mutex
+	// unhealthyUntil is when each endpoint is tried again, zero for healthy endpoints
+	unhealthyUntil []time.Time
+}
+
+func newEndpointPool(endpoints []string, cooldown time.Duration) *endpointPool {
+	if cooldown == 0 {
+		cooldown = DefaultEndpointCooldown
+	}
+
+	return &endpointPool{
+		endpoints:      endpoints,
+		cooldown:       cooldown,
+		unhealthyUntil: make([]time.Time, len(endpoints)),
+	}
+}
+
+// candidates returns the indexes of the endpoints to try: the healthy ones in order of preference,
+// followed by the unhealthy ones in the order they recover, so that a request is never refused outright
+func (p *endpointPool) candidates(now time.Time) []int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	healthy := []int{}
+	unhealthy := []int{}
+	for idx, until := range p.unhealthyUntil {
+		if now.Before(until) {
+			unhealthy = append(unhealthy, idx)
+		} else {
+			healthy = append(healthy, idx)
+		}
+	}
+	sort.SliceStable(unhealthy, func(a, b int) bool {
+		return p.unhealthyUntil[unhealthy[a]].Before(p.unhealthyUntil[unhealthy[b]])
+	})
+
+	return append(healthy, unhealthy...)
+}
+
+func (p *endpointPool) markUnhealthy(idx int, now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.unhealthyUntil[idx] = now.Add(p.cooldown)
+}
+
+func (p *endpointPool) markHealthy(idx int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.unhealthyUntil[idx] = time.Time{}
+}
+
+// isFailoverError reports whether a request failed because the endpoint could not be reached, so that it
+// can be sent to another endpoint without risking that it is processed twice
+func isFailoverError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true
+	}
+
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
@@ -0,0 +1,105 @@
+package inferable
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEndpointFailover(t *testing.T) {
+	var primaryDown atomic.Bool
+	var primaryRequests, fallbackRequests atomic.Int32
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		primaryRequests.Add(1)
+		if primaryDown.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`"primary"`))
+	}))
+	defer primary.Close()
+
+	fallback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fallbackRequests.Add(1)
+		w.Write([]byte(`"fallback"`))
+	}))
+	defer fallback.Close()
+
+	unreachable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	unreachable.Close()
+
+	client, err := NewClient(ClientOptions{
+		Endpoint:          primary.URL,
+		Secret:            "test-secret",
+		FallbackEndpoints: []string{unreachable.URL, fallback.URL},
+		EndpointCooldown:  100 * time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	data, err := client.FetchData(FetchDataOptions{Path: "/live", Method: "GET"})
+	require.NoError(t, err)
+	assert.Equal(t, `"primary"`, data)
+
+	// Requests fail over past endpoints which are down or unreachable
+	primaryDown.Store(true)
+	data, err = client.FetchData(FetchDataOptions{Path: "/live", Method: "GET"})
+	require.NoError(t, err)
+	assert.Equal(t, `"fallback"`, data)
+	assert.Equal(t, int32(2), primaryRequests.Load())
+
+	// Endpoints which failed are skipped until their cooldown has passed
+	primaryDown.Store(false)
+	data, err = client.FetchData(FetchDataOptions{Path: "/live", Method: "GET"})
+	require.NoError(t, err)
+	assert.Equal(t, `"fallback"`, data)
+	assert.Equal(t, int32(2), primaryRequests.Load())
+
+	time.Sleep(150 * time.Millisecond)
+	data, err = client.FetchData(FetchDataOptions{Path: "/live", Method: "GET"})
+	require.NoError(t, err)
+	assert.Equal(t, `"primary"`, data)
+	assert.Equal(t, int32(2), fallbackRequests.Load())
+}
+
+func TestEndpointFailoverErrors(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	client, err := NewClient(ClientOptions{
+		Endpoint:          server.URL,
+		Secret:            "test-secret",
+		FallbackEndpoints: []string{server.URL},
+	})
+	require.NoError(t, err)
+
+	// Requests which reached the API are not sent again, as they may have been processed
+	_, err = client.FetchData(FetchDataOptions{Path: "/live", Method: "POST", Body: `{}`})
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), requests.Load())
+
+	_, err = NewClient(ClientOptions{Endpoint: server.URL, FallbackEndpoints: []string{"eu.inferable.ai"}})
+	assert.Error(t, err)
+}
+
+func TestEndpointPoolCandidates(t *testing.T) {
+	pool := newEndpointPool([]string{"a", "b", "c"}, time.Minute)
+	now := time.Now()
+	assert.Equal(t, []int{0, 1, 2}, pool.candidates(now))
+
+	pool.markUnhealthy(1, now)
+	pool.markUnhealthy(0, now.Add(time.Second))
+	assert.Equal(t, []int{2, 1, 0}, pool.candidates(now.Add(2*time.Second)))
+
+	pool.markHealthy(0)
+	assert.Equal(t, []int{0, 2, 1}, pool.candidates(now.Add(2*time.Second)))
+	assert.Equal(t, []int{0, 1, 2}, pool.candidates(now.Add(2*time.Minute)))
+}
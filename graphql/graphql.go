@@ -0,0 +1,407 @@
+// Package graphql registers the query and mutation fields of a GraphQL API as Inferable functions.
+//
+// The schema is introspected from the endpoint. Each root field becomes a function whose input schema
+// is derived from the field's arguments, and which selects the scalar fields of the result up to
+// Options.MaxDepth levels deep.
+//
+//	err := graphql.Register(ctx, i.Default, graphql.Options{Endpoint: "https://api.example.com/graphql"})
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	inferable "github.com/inferablehq/inferable-go"
+)
+
+// DefaultMaxDepth is the depth of the selection sets when Options.MaxDepth is not set
+const DefaultMaxDepth = 2
+
+// Options configures the introspection and the registered functions
+type Options struct {
+	// Endpoint of the GraphQL API. Required.
+	Endpoint string
+	// Operations restricts the registered functions to these root fields. All fields are registered if empty.
+	Operations []string
+	// Mutations registers the fields of the mutation type in addition to the query type
+	Mutations bool
+	// MaxDepth limits how deep nested objects are selected in results. Defaults to DefaultMaxDepth.
+	MaxDepth int
+	// Headers are added to every request, e.g. for authentication
+	Headers map[string]string
+	// HTTPClient used for requests. Defaults to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+const introspectionQuery = `query IntrospectionQuery {
+  __schema {
+    queryType { name }
+    mutationType { name }
+    types {
+      kind
+      name
+      description
+      fields { name description args { ...InputValue } type { ...TypeRef } }
+      inputFields { ...InputValue }
+      enumValues { name }
+    }
+  }
+}
+
+fragment InputValue on __InputValue {
+  name
+  description
+  type { ...TypeRef }
+}
+
+fragment TypeRef on __Type {
+  kind
+  name
+  ofType { kind name ofType { kind name ofType { kind name ofType { kind name ofType { kind name } } } } }
+}`
+
+type schema struct {
+	QueryType    *struct{ Name string } `json:"queryType"`
+	MutationType *struct{ Name string } `json:"mutationType"`
+	Types        []fullType             `json:"types"`
+}
+
+type fullType struct {
+	Kind        string       `json:"kind"`
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	Fields      []field      `json:"fields"`
+	InputFields []inputValue `json:"inputFields"`
+	EnumValues  []struct {
+		Name string `json:"name"`
+	} `json:"enumValues"`
+}
+
+type field struct {
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	Args        []inputValue `json:"args"`
+	Type        typeRef      `json:"type"`
+}
+
+type inputValue struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Type        typeRef `json:"type"`
+}
+
+type typeRef struct {
+	Kind   string   `json:"kind"`
+	Name   string   `json:"name"`
+	OfType *typeRef `json:"ofType"`
+}
+
+// String renders the type as in a GraphQL document, e.g. [ID!]!
+func (t typeRef) String() string {
+	switch {
+	case t.Kind == "NON_NULL" && t.OfType != nil:
+		return t.OfType.String() + "!"
+	case t.Kind == "LIST" && t.OfType != nil:
+		return "[" + t.OfType.String() + "]"
+	}
+	return t.Name
+}
+
+// named returns the innermost named type
+func (t typeRef) named() typeRef {
+	if t.OfType != nil {
+		return t.OfType.named()
+	}
+	return t
+}
+
+type client struct {
+	endpoint string
+	headers  map[string]string
+	http     *http.Client
+}
+
+type response struct {
+	Data   map[string]json.RawMessage `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// Register introspects the schema of the endpoint and registers a function for each root field
+func Register(ctx context.Context, registrar inferable.FunctionRegistrar, options Options) error {
+	if options.Endpoint == "" {
+		return fmt.Errorf("endpoint is required")
+	}
+
+	c := &client{endpoint: options.Endpoint, headers: options.Headers, http: options.HTTPClient}
+	if c.http == nil {
+		c.http = http.DefaultClient
+	}
+
+	maxDepth := options.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = DefaultMaxDepth
+	}
+
+	data, err := c.do(ctx, introspectionQuery, nil)
+	if err != nil {
+		return fmt.Errorf("failed to introspect schema: %v", err)
+	}
+
+	introspection := schema{}
+	if err := json.Unmarshal(data["__schema"], &introspection); err != nil {
+		return fmt.Errorf("failed to parse introspection result: %v", err)
+	}
+
+	types := map[string]fullType{}
+	for _, t := range introspection.Types {
+		types[t.Name] = t
+	}
+
+	allowed := map[string]bool{}
+	for _, name := range options.Operations {
+		allowed[name] = true
+	}
+
+	roots := map[string]string{}
+	if introspection.QueryType != nil {
+		roots["query"] = introspection.QueryType.Name
+	}
+	if options.Mutations && introspection.MutationType != nil {
+		roots["mutation"] = introspection.MutationType.Name
+	}
+
+	registered := map[string]bool{}
+	for _, operation := range []string{"query", "mutation"} {
+		root, ok := types[roots[operation]]
+		if !ok {
+			continue
+		}
+
+		for _, f := range root.Fields {
+			if strings.HasPrefix(f.Name, "__") || (len(allowed) > 0 && !allowed[f.Name]) {
+				continue
+			}
+
+			fn, err := c.function(types, operation, f, maxDepth)
+			if err != nil {
+				return fmt.Errorf("failed to register %s '%s': %v", operation, f.Name, err)
+			}
+			if err := registrar.RegisterFunc(fn); err != nil {
+				return err
+			}
+			registered[f.Name] = true
+		}
+	}
+
+	for _, name := range options.Operations {
+		if !registered[name] {
+			return fmt.Errorf("operation '%s' not found in schema", name)
+		}
+	}
+
+	return nil
+}
+
+func (c *client) function(types map[string]fullType, operation string, f field, maxDepth int) (inferable.Function, error) {
+	properties := map[string]interface{}{}
+	required := []string{}
+	variables := []string{}
+	arguments := []string{}
+
+	for _, arg := range f.Args {
+		property := inputSchema(types, arg.Type, 0)
+		if arg.Description != "" {
+			property["description"] = arg.Description
+		}
+		properties[arg.Name] = property
+		if arg.Type.Kind == "NON_NULL" {
+			required = append(required, arg.Name)
+		}
+
+		variables = append(variables, fmt.Sprintf("$%s: %s", arg.Name, arg.Type))
+		arguments = append(arguments, fmt.Sprintf("%s: $%s", arg.Name, arg.Name))
+	}
+
+	inputSchema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		inputSchema["required"] = required
+	}
+	schemaJSON, err := json.Marshal(inputSchema)
+	if err != nil {
+		return inferable.Function{}, fmt.Errorf("failed to marshal schema: %v", err)
+	}
+
+	document := operation
+	if len(variables) > 0 {
+		document += "(" + strings.Join(variables, ", ") + ")"
+	}
+	document += " { " + f.Name
+	if len(arguments) > 0 {
+		document += "(" + strings.Join(arguments, ", ") + ")"
+	}
+	document += selection(types, f.Type, 0, maxDepth) + " }"
+
+	description := f.Description
+	if description == "" {
+		description = fmt.Sprintf("Runs the GraphQL %s %s", operation, f.Name)
+	}
+
+	return inferable.Function{
+		Name:        f.Name,
+		Description: description,
+		InputSchema: schemaJSON,
+		Func: func(ctx context.Context, input json.RawMessage) (json.RawMessage, error) {
+			data, err := c.do(ctx, document, input)
+			if err != nil {
+				return nil, err
+			}
+			return data[f.Name], nil
+		},
+	}, nil
+}
+
+// inputSchema converts an argument type to a JSON schema
+func inputSchema(types map[string]fullType, t typeRef, depth int) map[string]interface{} {
+	switch t.Kind {
+	case "NON_NULL":
+		return inputSchema(types, *t.OfType, depth)
+	case "LIST":
+		return map[string]interface{}{"type": "array", "items": inputSchema(types, *t.OfType, depth)}
+	case "ENUM":
+		values := []string{}
+		for _, value := range types[t.Name].EnumValues {
+			values = append(values, value.Name)
+		}
+		return map[string]interface{}{"type": "string", "enum": values}
+	case "INPUT_OBJECT":
+		// Recursive input types are left open beyond a reasonable depth
+		if depth > 5 {
+			return map[string]interface{}{"type": "object"}
+		}
+		properties := map[string]interface{}{}
+		required := []string{}
+		for _, inputField := range types[t.Name].InputFields {
+			properties[inputField.Name] = inputSchema(types, inputField.Type, depth+1)
+			if inputField.Type.Kind == "NON_NULL" {
+				required = append(required, inputField.Name)
+			}
+		}
+		schema := map[string]interface{}{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+	}
+
+	switch t.Name {
+	case "Int":
+		return map[string]interface{}{"type": "integer"}
+	case "Float":
+		return map[string]interface{}{"type": "number"}
+	case "Boolean":
+		return map[string]interface{}{"type": "boolean"}
+	case "String", "ID":
+		return map[string]interface{}{"type": "string"}
+	}
+
+	// Custom scalars can be represented by any JSON value
+	return map[string]interface{}{}
+}
+
+// selection builds the selection set of a result type, skipping fields which require arguments
+func selection(types map[string]fullType, t typeRef, depth, maxDepth int) string {
+	named := types[t.named().Name]
+	if named.Kind != "OBJECT" && named.Kind != "INTERFACE" {
+		return ""
+	}
+
+	fields := []string{}
+	for _, f := range named.Fields {
+		if hasRequiredArgs(f) {
+			continue
+		}
+
+		switch types[f.Type.named().Name].Kind {
+		case "SCALAR", "ENUM":
+			fields = append(fields, f.Name)
+		case "OBJECT", "INTERFACE":
+			if depth+1 < maxDepth {
+				if nested := selection(types, f.Type, depth+1, maxDepth); nested != "" {
+					fields = append(fields, f.Name+nested)
+				}
+			}
+		}
+	}
+	if len(fields) == 0 {
+		fields = append(fields, "__typename")
+	}
+	sort.Strings(fields)
+
+	return " { " + strings.Join(fields, " ") + " }"
+}
+
+func hasRequiredArgs(f field) bool {
+	for _, arg := range f.Args {
+		if arg.Type.Kind == "NON_NULL" {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *client) do(ctx context.Context, query string, variables json.RawMessage) (map[string]json.RawMessage, error) {
+	body, err := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range c.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %v", err)
+	}
+
+	result := response{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("unexpected response (status %d): %s", resp.StatusCode, string(data))
+	}
+
+	if len(result.Errors) > 0 {
+		messages := make([]string, len(result.Errors))
+		for idx, e := range result.Errors {
+			messages[idx] = e.Message
+		}
+		return nil, fmt.Errorf("GraphQL errors: %s", strings.Join(messages, "; "))
+	}
+
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("request returned status %d: %s", resp.StatusCode, string(data))
+	}
+
+	return result.Data, nil
+}
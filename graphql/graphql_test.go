@@ -0,0 +1,109 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	inferable "github.com/inferablehq/inferable-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const introspectionResult = `{"data": {"__schema": {
+	"queryType": {"name": "Query"},
+	"mutationType": {"name": "Mutation"},
+	"types": [
+		{"kind": "OBJECT", "name": "Query", "fields": [
+			{"name": "user", "description": "Finds a user", "args": [
+				{"name": "id", "type": {"kind": "NON_NULL", "ofType": {"kind": "SCALAR", "name": "ID"}}}
+			], "type": {"kind": "OBJECT", "name": "User"}}
+		]},
+		{"kind": "OBJECT", "name": "Mutation", "fields": [
+			{"name": "createUser", "args": [
+				{"name": "input", "type": {"kind": "NON_NULL", "ofType": {"kind": "INPUT_OBJECT", "name": "UserInput"}}}
+			], "type": {"kind": "OBJECT", "name": "User"}}
+		]},
+		{"kind": "OBJECT", "name": "User", "fields": [
+			{"name": "id", "args": [], "type": {"kind": "NON_NULL", "ofType": {"kind": "SCALAR", "name": "ID"}}},
+			{"name": "role", "args": [], "type": {"kind": "ENUM", "name": "Role"}},
+			{"name": "manager", "args": [], "type": {"kind": "OBJECT", "name": "User"}},
+			{"name": "posts", "args": [{"name": "first", "type": {"kind": "NON_NULL", "ofType": {"kind": "SCALAR", "name": "Int"}}}], "type": {"kind": "LIST", "ofType": {"kind": "OBJECT", "name": "Post"}}}
+		]},
+		{"kind": "INPUT_OBJECT", "name": "UserInput", "inputFields": [
+			{"name": "name", "type": {"kind": "NON_NULL", "ofType": {"kind": "SCALAR", "name": "String"}}},
+			{"name": "role", "type": {"kind": "ENUM", "name": "Role"}}
+		]},
+		{"kind": "ENUM", "name": "Role", "enumValues": [{"name": "ADMIN"}, {"name": "MEMBER"}]},
+		{"kind": "SCALAR", "name": "ID"},
+		{"kind": "SCALAR", "name": "String"},
+		{"kind": "SCALAR", "name": "Int"}
+	]
+}}}`
+
+type registrar struct {
+	functions map[string]inferable.Function
+}
+
+func (r *registrar) RegisterFunc(fn inferable.Function) error {
+	r.functions[fn.Name] = fn
+	return nil
+}
+
+func TestRegister(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Query     string                 `json:"query"`
+			Variables map[string]interface{} `json:"variables"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+
+		switch {
+		case strings.Contains(request.Query, "__schema"):
+			w.Write([]byte(introspectionResult))
+		case request.Variables["id"] == "1":
+			assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+			assert.Equal(t, "query($id: ID!) { user(id: $id) { id manager { id role } role } }", request.Query)
+			w.Write([]byte(`{"data": {"user": {"id": "1", "role": "ADMIN", "manager": null}}}`))
+		default:
+			w.Write([]byte(`{"data": null, "errors": [{"message": "user not found"}]}`))
+		}
+	}))
+	defer server.Close()
+
+	r := &registrar{functions: map[string]inferable.Function{}}
+	err := Register(context.Background(), r, Options{
+		Endpoint: server.URL,
+		Headers:  map[string]string{"Authorization": "Bearer token"},
+	})
+	require.NoError(t, err)
+	require.Len(t, r.functions, 1)
+
+	user := r.functions["user"]
+	assert.Equal(t, "Finds a user", user.Description)
+	assert.JSONEq(t, `{"type": "object", "properties": {"id": {"type": "string"}}, "required": ["id"]}`, string(user.InputSchema))
+
+	call := user.Func.(func(context.Context, json.RawMessage) (json.RawMessage, error))
+
+	result, err := call(context.Background(), json.RawMessage(`{"id": "1"}`))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"id": "1", "role": "ADMIN", "manager": null}`, string(result))
+
+	_, err = call(context.Background(), json.RawMessage(`{"id": "2"}`))
+	assert.ErrorContains(t, err, "user not found")
+
+	r = &registrar{functions: map[string]inferable.Function{}}
+	err = Register(context.Background(), r, Options{Endpoint: server.URL, Mutations: true, Operations: []string{"createUser"}})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type": "object", "properties": {"input": {
+		"type": "object",
+		"properties": {"name": {"type": "string"}, "role": {"type": "string", "enum": ["ADMIN", "MEMBER"]}},
+		"required": ["name"]
+	}}, "required": ["input"]}`, string(r.functions["createUser"].InputSchema))
+
+	err = Register(context.Background(), r, Options{Endpoint: server.URL, Operations: []string{"createUser"}})
+	assert.ErrorContains(t, err, "operation 'createUser' not found")
+}
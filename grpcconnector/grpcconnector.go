@@ -0,0 +1,135 @@
+// Package grpcconnector exposes RPCs of a gRPC client as Inferable functions.
+//
+// The input schema of each function is reflected from the generated Go type of the request message,
+// whose json tags are the field names of the protobuf definition. To keep the SDK free of a gRPC
+// dependency, the connection is accepted through the Conn interface, which a *grpc.ClientConn
+// satisfies with a one line adapter:
+//
+//	type conn struct{ *grpc.ClientConn }
+//
+//	func (c conn) Invoke(ctx context.Context, method string, args, reply interface{}) error {
+//		return c.ClientConn.Invoke(ctx, method, args, reply)
+//	}
+//
+//	err := grpcconnector.Register(i.Default, conn{cc}, []grpcconnector.Method{
+//		{FullMethod: "/helloworld.Greeter/SayHello", Request: &pb.HelloRequest{}, Response: &pb.HelloReply{}},
+//	})
+//
+// Inputs are decoded with encoding/json, so messages using oneofs or well-known types such as
+// Timestamp should be wrapped in a regular function instead.
+package grpcconnector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	inferable "github.com/inferablehq/inferable-go"
+	"github.com/invopop/jsonschema"
+)
+
+// Conn invokes unary RPCs. It matches the Invoke method of *grpc.ClientConn without call options.
+type Conn interface {
+	Invoke(ctx context.Context, method string, args, reply interface{}) error
+}
+
+// Method selects an RPC to expose as a function
+type Method struct {
+	// FullMethod is the full name of the RPC, e.g. "/helloworld.Greeter/SayHello"
+	FullMethod string
+	// Name of the function. Defaults to the name of the RPC.
+	Name        string
+	Description string
+	// Request is a pointer to the request message type, e.g. &pb.HelloRequest{}
+	Request interface{}
+	// Response is a pointer to the response message type, e.g. &pb.HelloReply{}
+	Response interface{}
+	Config   inferable.FunctionConfig
+}
+
+// Register registers a function for each of the methods, calling the RPC through conn
+func Register(registrar inferable.FunctionRegistrar, conn Conn, methods []Method) error {
+	if conn == nil {
+		return fmt.Errorf("conn is required")
+	}
+
+	for _, method := range methods {
+		fn, err := newFunction(conn, method)
+		if err != nil {
+			return fmt.Errorf("failed to register RPC '%s': %v", method.FullMethod, err)
+		}
+
+		if err := registrar.RegisterFunc(fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func newFunction(conn Conn, method Method) (inferable.Function, error) {
+	service, rpc, ok := strings.Cut(strings.TrimPrefix(method.FullMethod, "/"), "/")
+	if !ok || service == "" || rpc == "" {
+		return inferable.Function{}, fmt.Errorf("full method must have the form /package.Service/Method")
+	}
+
+	requestType, err := messageType(method.Request)
+	if err != nil {
+		return inferable.Function{}, fmt.Errorf("invalid request: %v", err)
+	}
+	responseType, err := messageType(method.Response)
+	if err != nil {
+		return inferable.Function{}, fmt.Errorf("invalid response: %v", err)
+	}
+
+	reflector := jsonschema.Reflector{DoNotReference: true}
+	schema := reflector.ReflectFromType(requestType)
+	schema.Version = ""
+	schema.ID = ""
+	schemaJSON, err := json.Marshal(schema)
+	if err != nil {
+		return inferable.Function{}, fmt.Errorf("failed to marshal request schema: %v", err)
+	}
+
+	name := method.Name
+	if name == "" {
+		name = rpc
+	}
+
+	description := method.Description
+	if description == "" {
+		description = fmt.Sprintf("Calls %s.%s", service, rpc)
+	}
+
+	return inferable.Function{
+		Name:        name,
+		Description: description,
+		InputSchema: schemaJSON,
+		Config:      method.Config,
+		Func: func(ctx context.Context, input json.RawMessage) (interface{}, error) {
+			request := reflect.New(requestType).Interface()
+			if err := json.Unmarshal(input, request); err != nil {
+				return nil, fmt.Errorf("invalid request: %v", err)
+			}
+
+			response := reflect.New(responseType).Interface()
+			if err := conn.Invoke(ctx, method.FullMethod, request, response); err != nil {
+				return nil, err
+			}
+
+			return response, nil
+		},
+	}, nil
+}
+
+// messageType returns the struct type of a pointer to a message
+func messageType(message interface{}) (reflect.Type, error) {
+	t := reflect.TypeOf(message)
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("must be a pointer to a message struct")
+	}
+
+	return t.Elem(), nil
+}
@@ -0,0 +1,99 @@
+package grpcconnector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	inferable "github.com/inferablehq/inferable-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Shaped like the output of protoc-gen-go, including its unexported bookkeeping fields
+type Address struct {
+	sizeCache int32
+	City      string `protobuf:"bytes,1,opt,name=city,proto3" json:"city,omitempty"`
+}
+
+type HelloRequest struct {
+	sizeCache int32
+	Name      string   `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Address   *Address `protobuf:"bytes,2,opt,name=address,proto3" json:"address,omitempty"`
+}
+
+type HelloReply struct {
+	sizeCache int32
+	Message   string `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"`
+}
+
+type fakeConn struct {
+	methods []string
+}
+
+func (c *fakeConn) Invoke(ctx context.Context, method string, args, reply interface{}) error {
+	c.methods = append(c.methods, method)
+
+	request := args.(*HelloRequest)
+	if request.Name == "" {
+		return fmt.Errorf("rpc error: code = InvalidArgument desc = name is required")
+	}
+
+	reply.(*HelloReply).Message = "Hello, " + request.Name + " from " + request.Address.City
+	return nil
+}
+
+type registrar struct {
+	functions map[string]inferable.Function
+}
+
+func (r *registrar) RegisterFunc(fn inferable.Function) error {
+	r.functions[fn.Name] = fn
+	return nil
+}
+
+func TestRegister(t *testing.T) {
+	conn := &fakeConn{}
+	r := &registrar{functions: map[string]inferable.Function{}}
+
+	err := Register(r, conn, []Method{
+		{FullMethod: "/helloworld.Greeter/SayHello", Request: &HelloRequest{}, Response: &HelloReply{}},
+	})
+	require.NoError(t, err)
+
+	fn, ok := r.functions["SayHello"]
+	require.True(t, ok)
+	assert.Equal(t, "Calls helloworld.Greeter.SayHello", fn.Description)
+
+	var schema struct {
+		Properties map[string]struct {
+			Type       string                 `json:"type"`
+			Properties map[string]interface{} `json:"properties"`
+		} `json:"properties"`
+	}
+	require.NoError(t, json.Unmarshal(fn.InputSchema, &schema))
+	assert.Equal(t, "string", schema.Properties["name"].Type)
+	assert.Contains(t, schema.Properties["address"].Properties, "city")
+	assert.NotContains(t, string(fn.InputSchema), "$ref")
+
+	call := fn.Func.(func(context.Context, json.RawMessage) (interface{}, error))
+
+	result, err := call(context.Background(), json.RawMessage(`{"name": "Ada", "address": {"city": "London"}}`))
+	require.NoError(t, err)
+	assert.Equal(t, "Hello, Ada from London", result.(*HelloReply).Message)
+	assert.Equal(t, []string{"/helloworld.Greeter/SayHello"}, conn.methods)
+
+	_, err = call(context.Background(), json.RawMessage(`{}`))
+	assert.ErrorContains(t, err, "name is required")
+}
+
+func TestRegisterInvalidMethod(t *testing.T) {
+	r := &registrar{functions: map[string]inferable.Function{}}
+
+	err := Register(r, &fakeConn{}, []Method{{FullMethod: "SayHello", Request: &HelloRequest{}, Response: &HelloReply{}}})
+	assert.ErrorContains(t, err, "full method must have the form")
+
+	err = Register(r, &fakeConn{}, []Method{{FullMethod: "/helloworld.Greeter/SayHello", Request: HelloRequest{}, Response: &HelloReply{}}})
+	assert.ErrorContains(t, err, "must be a pointer to a message struct")
+}
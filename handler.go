@@ -0,0 +1,143 @@
+package inferable
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+)
+
+// WrapHandler surfaces an HTTP handler as a function, so that existing endpoints can be used as tools.
+// The pattern has the form of http.ServeMux patterns, e.g. "GET /users/{id}", and defaults to POST.
+// Calls are served in-process: input fields matching path wildcards are substituted into the path
+// (and available through Request.PathValue), the remaining fields are sent as query parameters for
+// GET, HEAD and DELETE requests, and as a JSON body otherwise. Responses with a status of 400 or more
+// are returned as errors, JSON responses are returned as is, and other responses as strings.
+//
+// The returned function still needs a name before it is registered. WrapHandler panics if the pattern is invalid.
+func WrapHandler(pattern string, schema json.RawMessage, handler http.Handler) Function {
+	method, path, ok := strings.Cut(pattern, " ")
+	if !ok {
+		method, path = "POST", pattern
+	}
+	path = strings.TrimSpace(path)
+	if !strings.HasPrefix(path, "/") {
+		panic(fmt.Sprintf("inferable: invalid handler pattern '%s'", pattern))
+	}
+	path = strings.TrimSuffix(path, "{$}")
+
+	wildcards := []string{}
+	for _, segment := range strings.Split(path, "/") {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			wildcards = append(wildcards, strings.TrimSuffix(strings.Trim(segment, "{}"), "..."))
+		}
+	}
+
+	return Function{
+		Description: fmt.Sprintf("Calls %s %s", method, path),
+		InputSchema: schema,
+		Func: func(ctx context.Context, input json.RawMessage) (json.RawMessage, error) {
+			req, err := handlerRequest(ctx, method, path, wildcards, input)
+			if err != nil {
+				return nil, err
+			}
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+
+			return handlerResult(recorder.Result())
+		},
+	}
+}
+
+func handlerRequest(ctx context.Context, method, path string, wildcards []string, input json.RawMessage) (*http.Request, error) {
+	args := map[string]json.RawMessage{}
+	if len(input) > 0 {
+		if err := json.Unmarshal(input, &args); err != nil {
+			return nil, fmt.Errorf("input must be a JSON object: %v", err)
+		}
+	}
+
+	values := map[string]string{}
+	for _, name := range wildcards {
+		raw, ok := args[name]
+		if !ok {
+			return nil, fmt.Errorf("input is missing path parameter '%s'", name)
+		}
+		values[name] = handlerParam(raw)
+		delete(args, name)
+
+		escaped := url.PathEscape(values[name])
+		if strings.Contains(path, "{"+name+"...}") {
+			escaped = strings.ReplaceAll(escaped, "%2F", "/")
+		}
+		path = strings.NewReplacer("{"+name+"}", escaped, "{"+name+"...}", escaped).Replace(path)
+	}
+
+	var body io.Reader
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodDelete:
+		query := url.Values{}
+		for name, raw := range args {
+			query.Set(name, handlerParam(raw))
+		}
+		if len(query) > 0 {
+			path += "?" + query.Encode()
+		}
+	default:
+		jsonBody, err := json.Marshal(args)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request body: %v", err)
+		}
+		body = bytes.NewReader(jsonBody)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, path, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for name, value := range values {
+		req.SetPathValue(name, value)
+	}
+
+	return req, nil
+}
+
+func handlerResult(resp *http.Response) (json.RawMessage, error) {
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %v", err)
+	}
+
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("handler returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+
+	if len(bytes.TrimSpace(data)) == 0 {
+		return json.RawMessage("null"), nil
+	}
+
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") && json.Valid(data) {
+		return data, nil
+	}
+
+	return json.Marshal(string(data))
+}
+
+// handlerParam formats an input value as a path or query parameter, without quotes for strings
+func handlerParam(raw json.RawMessage) string {
+	var value string
+	if err := json.Unmarshal(raw, &value); err == nil {
+		return value
+	}
+
+	return string(raw)
+}
@@ -0,0 +1,58 @@
+package inferable
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrapHandler(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("id") != "42" {
+			http.Error(w, "user not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id": "42", "fields": "` + r.URL.Query().Get("fields") + `"}`))
+	})
+	mux.HandleFunc("POST /users/{id}/notes", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Text string `json:"text"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Write([]byte("added note to " + r.PathValue("id") + ": " + body.Text))
+	})
+
+	schema := json.RawMessage(`{"type": "object", "properties": {"id": {"type": "string"}}}`)
+	call := func(fn Function, input string) (json.RawMessage, error) {
+		return fn.Func.(func(context.Context, json.RawMessage) (json.RawMessage, error))(context.Background(), json.RawMessage(input))
+	}
+
+	getUser := WrapHandler("GET /users/{id}", schema, mux)
+	assert.Equal(t, "Calls GET /users/{id}", getUser.Description)
+
+	result, err := call(getUser, `{"id": "42", "fields": "name"}`)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"id": "42", "fields": "name"}`, string(result))
+
+	_, err = call(getUser, `{"id": "7"}`)
+	assert.EqualError(t, err, "handler returned status 404: user not found")
+
+	_, err = call(getUser, `{}`)
+	assert.ErrorContains(t, err, "missing path parameter 'id'")
+
+	result, err = call(WrapHandler("POST /users/{id}/notes", schema, mux), `{"id": 42, "text": "hello"}`)
+	require.NoError(t, err)
+	assert.Equal(t, `"added note to 42: hello"`, string(result))
+
+	assert.Panics(t, func() { WrapHandler("GET users", schema, mux) })
+
+	fn := WrapHandler("GET /users/{id}", schema, mux)
+	fn.Name = "getUser"
+	require.NoError(t, (&Service{Name: "test", Functions: map[string]Function{}}).RegisterFunc(fn))
+}
@@ -0,0 +1,150 @@
+package inferable
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// DefaultHealthFailureThreshold is the number of consecutive failed polls after which a service is unhealthy
+const DefaultHealthFailureThreshold = 5
+
+// ServiceHealth reports the state of a service, e.g. for liveness and readiness probes
+type ServiceHealth struct {
+	Service    string `json:"service"`
+	Registered bool   `json:"registered"`
+	// Paused is true while the service does not poll for new calls, see Service.Pause
+	Paused bool `json:"paused"`
+	// LastPoll is when the queue was last polled successfully, zero if it has not been
+	LastPoll time.Time `json:"lastPoll,omitempty"`
+	// ConsecutiveFailures is the number of polls which failed since the last successful one
+	ConsecutiveFailures int `json:"consecutiveFailures"`
+	// InFlight is the number of calls being handled
+	InFlight int `json:"inFlight"`
+}
+
+// Health returns the current health of the service
+func (s *Service) Health() ServiceHealth {
+	health := ServiceHealth{
+		Service:             s.Name,
+		Registered:          s.IsRegistered(),
+		Paused:              s.IsPaused(),
+		ConsecutiveFailures: int(s.pollFailures.Load()),
+		InFlight:            int(s.inFlight.Load()),
+	}
+	if lastPoll := s.lastPoll.Load(); lastPoll != 0 {
+		health.LastPoll = time.Unix(0, lastPoll)
+	}
+
+	return health
+}
+
+// observePoll records the outcome of a poll of the queue
+func (s *Service) observePoll(err error) {
+	now := time.Now()
+	s.recordPoll(err, now)
+	if err != nil {
+		s.reportError(err, ErrorContext{Kind: ErrorKindPoll})
+		s.pollFailures.Add(1)
+		s.stats.lastFailedPoll.Store(now.UnixNano())
+		return
+	}
+
+	s.lastPoll.Store(now.UnixNano())
+	s.pollFailures.Store(0)
+}
+
+// healthReport is the response of the health endpoints
+type healthReport struct {
+	Status   string          `json:"status"`
+	Services []ServiceHealth `json:"services"`
+}
+
+// servicesHealth returns the health of every service which has functions, ordered by name
+func (i *Inferable) servicesHealth() []ServiceHealth {
+	services := []ServiceHealth{}
+	for _, service := range i.functionRegistry.list() {
+		if len(service.functions()) > 0 {
+			services = append(services, service.Health())
+		}
+	}
+
+	return services
+}
+
+// RegisterHealthHandlers mounts health endpoints for Kubernetes probes onto mux:
+//
+//   - /healthz fails once a service failed DefaultHealthFailureThreshold consecutive polls
+//   - /readyz fails until every service with functions has registered, and while a service is paused
+//
+// Both respond with the health of each service as JSON. Metrics are not mounted, as muxes of applications
+// often serve their own /metrics already, see MetricsHandler.
+func (i *Inferable) RegisterHealthHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		services := i.servicesHealth()
+		healthy := true
+		for _, service := range services {
+			if service.ConsecutiveFailures >= DefaultHealthFailureThreshold {
+				healthy = false
+			}
+		}
+		writeHealthReport(w, healthy, services)
+	})
+
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		services := i.servicesHealth()
+		ready := true
+		for _, service := range services {
+			if !service.Registered || service.Paused {
+				ready = false
+			}
+		}
+		writeHealthReport(w, ready, services)
+	})
+}
+
+// MetricsHandler serves the stats of each service with functions (see Service.Stats) in the Prometheus
+// text format, to be mounted wherever the application serves metrics
+func (i *Inferable) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeMetrics(w, i.servicesWithFunctions())
+	})
+}
+
+// StartHealthServer serves the health endpoints (see RegisterHealthHandlers) and the metrics
+// (see MetricsHandler) on /metrics on addr in the background.
+// The returned server should be shut down when the machine stops.
+func (i *Inferable) StartHealthServer(addr string) (*http.Server, error) {
+	mux := http.NewServeMux()
+	i.RegisterHealthHandlers(mux)
+	mux.Handle("/metrics", i.MetricsHandler())
+
+	server := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start health server: %v", err)
+	}
+
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			i.logf(LogLevelError, "Health server stopped: %v", err)
+		}
+	}()
+
+	return server, nil
+}
+
+func writeHealthReport(w http.ResponseWriter, ok bool, services []ServiceHealth) {
+	report := healthReport{Status: "ok", Services: services}
+	status := http.StatusOK
+	if !ok {
+		report.Status = "unavailable"
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,94 @@
+package inferable
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthHandlers(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	i, err := New(InferableOptions{
+		APIEndpoint: server.URL,
+		APISecret:   "test-secret",
+	})
+	require.NoError(t, err)
+
+	type TestInput struct{}
+	require.NoError(t, i.Default.RegisterFunc(Function{Name: "TestFunc", Func: func(input TestInput) int { return 1 }}))
+
+	mux := http.NewServeMux()
+	i.RegisterHealthHandlers(mux)
+
+	probe := func(path string) (int, healthReport) {
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		var report healthReport
+		require.NoError(t, json.NewDecoder(recorder.Body).Decode(&report))
+		return recorder.Code, report
+	}
+
+	// Not ready until registered
+	status, report := probe("/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	require.Len(t, report.Services, 1)
+	assert.False(t, report.Services[0].Registered)
+
+	require.NoError(t, i.Default.registerMachine())
+	status, _ = probe("/readyz")
+	assert.Equal(t, http.StatusOK, status)
+
+	// Paused services are not ready
+	i.Default.Pause()
+	status, report = probe("/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.True(t, report.Services[0].Paused)
+	i.Default.Resume()
+	status, _ = probe("/readyz")
+	assert.Equal(t, http.StatusOK, status)
+
+	i.Default.observePoll(nil)
+	status, report = probe("/healthz")
+	assert.Equal(t, http.StatusOK, status)
+	assert.False(t, report.Services[0].LastPoll.IsZero())
+
+	for n := 0; n < DefaultHealthFailureThreshold; n++ {
+		i.Default.observePoll(errors.New("poll failed"))
+	}
+	status, report = probe("/healthz")
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, DefaultHealthFailureThreshold, report.Services[0].ConsecutiveFailures)
+
+	// A successful poll resets the failures
+	i.Default.observePoll(nil)
+	status, _ = probe("/healthz")
+	assert.Equal(t, http.StatusOK, status)
+}
+
+func TestStartHealthServer(t *testing.T) {
+	i, err := New(InferableOptions{
+		APIEndpoint: DefaultAPIEndpoint,
+		APISecret:   "test-secret",
+	})
+	require.NoError(t, err)
+
+	server, err := i.StartHealthServer("127.0.0.1:0")
+	require.NoError(t, err)
+	defer server.Close()
+
+	// The private mux of the health server also serves the metrics
+	for _, path := range []string{"/healthz", "/metrics"} {
+		recorder := httptest.NewRecorder()
+		server.Handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusOK, recorder.Code, path)
+	}
+}
@@ -0,0 +1,72 @@
+package inferable
+
+import (
+	"context"
+	"time"
+)
+
+// Hooks are invoked around the lifecycle of a service and every call it handles.
+// They can be used to add logging, metrics, auth checks or audit trails without wrapping every function.
+// All hooks are optional.
+type Hooks struct {
+	// OnStart is invoked once the service has registered and started polling
+	OnStart func(service *Service)
+	// OnStop is invoked when the service is stopped
+	OnStop func(service *Service)
+	// OnRegisterRetry is invoked when registering the service failed with a retryable error,
+	// before waiting delay for the next attempt
+	OnRegisterRetry func(service *Service, attempt int, err error, delay time.Duration)
+	// OnRestart is invoked when the watchdog restarted the poll loop of the service, with the reason
+	OnRestart func(service *Service, reason string)
+	// OnCall is invoked with the decoded input before the function executes.
+	// Returning an error rejects the call without executing the function.
+	OnCall func(ctx context.Context, call CallInfo, input interface{}) error
+	// OnResult is invoked with the result of every call before it is persisted
+	OnResult func(ctx context.Context, call CallInfo, result CallResult, duration time.Duration)
+	// OnError is invoked when a call could not be handled, or its result could not be persisted
+	OnError func(ctx context.Context, call CallInfo, err error)
+}
+
+func (h Hooks) onStart(service *Service) {
+	if h.OnStart != nil {
+		h.OnStart(service)
+	}
+}
+
+func (h Hooks) onStop(service *Service) {
+	if h.OnStop != nil {
+		h.OnStop(service)
+	}
+}
+
+func (h Hooks) onRegisterRetry(service *Service, attempt int, err error, delay time.Duration) {
+	if h.OnRegisterRetry != nil {
+		h.OnRegisterRetry(service, attempt, err, delay)
+	}
+}
+
+func (h Hooks) onRestart(service *Service, reason string) {
+	if h.OnRestart != nil {
+		h.OnRestart(service, reason)
+	}
+}
+
+func (h Hooks) onCall(ctx context.Context, call CallInfo, input interface{}) error {
+	if h.OnCall == nil {
+		return nil
+	}
+
+	return h.OnCall(ctx, call, input)
+}
+
+func (h Hooks) onResult(ctx context.Context, call CallInfo, result CallResult, duration time.Duration) {
+	if h.OnResult != nil {
+		h.OnResult(ctx, call, result, duration)
+	}
+}
+
+func (h Hooks) onError(ctx context.Context, call CallInfo, err error) {
+	if h.OnError != nil {
+		h.OnError(ctx, call, err)
+	}
+}
@@ -0,0 +1,90 @@
+package inferable
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServiceHooks(t *testing.T) {
+	persisted := map[string]CallResult{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Result     string `json:"result"`
+			ResultType string `json:"resultType"`
+		}
+		if r.Method == "POST" && r.URL.Path != "/v2/ping" {
+			json.NewDecoder(r.Body).Decode(&body)
+			persisted[r.URL.Path] = CallResult{Value: body.Result, Type: body.ResultType}
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	i, err := New(InferableOptions{
+		APIEndpoint: server.URL,
+		APISecret:   "test-secret",
+	})
+	require.NoError(t, err)
+
+	type TestInput struct {
+		User string `json:"user"`
+	}
+
+	var results []CallResult
+	var errs []error
+	executed := 0
+
+	service, err := i.RegisterServiceWithOptions("TestService", ServiceOptions{
+		Hooks: Hooks{
+			OnCall: func(ctx context.Context, call CallInfo, input interface{}) error {
+				if input.(TestInput).User != "admin" {
+					return fmt.Errorf("user is not allowed to call '%s'", call.Function)
+				}
+				return nil
+			},
+			OnResult: func(ctx context.Context, call CallInfo, result CallResult, duration time.Duration) {
+				results = append(results, result)
+			},
+			OnError: func(ctx context.Context, call CallInfo, err error) {
+				errs = append(errs, err)
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	err = service.RegisterFunc(Function{
+		Name: "TestFunc",
+		Func: func(input TestInput) string {
+			executed++
+			return "ok"
+		},
+	})
+	require.NoError(t, err)
+
+	message := func(id, fn, user string) *sqs.Message {
+		return &sqs.Message{Body: aws.String(fmt.Sprintf(`{"value": {"id": "%s", "targetFn": "%s", "targetArgs": "{\"value\": {\"user\": \"%s\"}}"}}`, id, fn, user))}
+	}
+
+	require.NoError(t, service.handleMessage(message("call-1", "TestFunc", "admin"), time.Now()))
+	require.NoError(t, service.handleMessage(message("call-2", "TestFunc", "guest"), time.Now()))
+	assert.Error(t, service.handleMessage(message("call-3", "Missing", "admin"), time.Now()))
+
+	assert.Equal(t, 1, executed)
+	require.Len(t, results, 2)
+	assert.Equal(t, CallResult{Value: `"ok"`, Type: "resolution"}, results[0])
+	assert.Equal(t, CallResult{Value: `"user is not allowed to call 'TestFunc'"`, Type: "rejection"}, results[1])
+	assert.Equal(t, "rejection", persisted["/jobs/call-2/result"].Type)
+
+	require.Len(t, errs, 1)
+	assert.ErrorContains(t, errs[0], "function not found")
+}
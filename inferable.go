@@ -3,8 +3,15 @@ package inferable
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
 	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -16,49 +23,232 @@ const (
 )
 
 type FunctionRegistry struct {
+	mu       sync.RWMutex
 	services map[string]*Service
 }
 
+// get returns the service with the given name
+func (r *FunctionRegistry) get(name string) (*Service, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	service, ok := r.services[name]
+	return service, ok
+}
+
+// list returns a snapshot of the services, ordered by name
+func (r *FunctionRegistry) list() []*Service {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	services := make([]*Service, 0, len(r.services))
+	for _, service := range r.services {
+		services = append(services, service)
+	}
+
+	sort.Slice(services, func(a, b int) bool {
+		return services[a].Name < services[b].Name
+	})
+
+	return services
+}
+
+// remove unregisters the service with the given name and returns it
+func (r *FunctionRegistry) remove(name string) (*Service, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	service, ok := r.services[name]
+	delete(r.services, name)
+	return service, ok
+}
+
+// add registers service, unless a service with the same name is registered
+func (r *FunctionRegistry) add(service *Service) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.services[service.Name]; exists {
+		return fmt.Errorf("service with name '%s' already registered", service.Name)
+	}
+
+	r.services[service.Name] = service
+	return nil
+}
+
 type Inferable struct {
-	client           *Client
-	apiEndpoint      string
-	apiSecret        string
-	functionRegistry FunctionRegistry
-	machineID        string
-	pingInterval     time.Duration
-	Default          *Service
+	client                 *Client
+	apiEndpoint            string
+	apiSecret              string
+	clusterID              string
+	functionRegistry       FunctionRegistry
+	machineID              string
+	machineLabels          []string
+	queueEndpoint          string
+	transport              TransportOptions
+	registrationHistoryDir string
+	journalDir             string
+	pingInterval           time.Duration
+	logger                 Logger
+	metricsSink            MetricsSink
+	onError                func(err error, errCtx ErrorContext)
+	application            string
+	Default                *Service
+	// grpcPlugins are the connections to external gRPC handlers loaded by LoadPluginsWithOptions, by target
+	pluginsMu   sync.Mutex
+	grpcPlugins map[string]*grpcPlugin
+	// Tunables which can be changed at runtime, see ApplyRuntimeConfig. The log level falls back to
+	// configuredLogLevel (InferableOptions.LogLevel) while the runtime config sets none.
+	configuredLogLevel LogLevel
+	logSeverity        atomic.Int32
+	disabledFunctions  atomic.Value
+	maxConcurrentCalls atomic.Int64
+	// secretRejected stops pinging the cluster once the API rejected the secret, until it is updated
+	secretRejected atomic.Bool
+	// machineErrorsUnsupported stops machine error reports once the API turned out not to support them
+	machineErrorsUnsupported atomic.Bool
 }
 
 type InferableOptions struct {
 	APIEndpoint string
 	APISecret   string
-	MachineID   string
+	// APISecretProvider is called to refresh the API secret when it is rejected, e.g. after rotation.
+	// APISecret may be left empty, in which case the initial secret is also fetched from the provider.
+	APISecretProvider SecretProvider
+	// APISecretRefreshInterval periodically fetches the current secret from APISecretProvider,
+	// see ClientOptions.SecretRefreshInterval
+	APISecretRefreshInterval time.Duration
+	MachineID                string
+	// MachineIDPath is a file (or directory) where the machine ID is persisted so that
+	// the machine keeps its identity across restarts. Ignored if MachineID is set.
+	MachineIDPath string
+	// RegistrationHistoryDir is a directory where the functions of each service are persisted on registration,
+	// so that the changes made by each deploy are logged when the service registers again
+	RegistrationHistoryDir string
+	// JournalDir is a directory where the calls being handled are journaled, so that calls interrupted by
+	// a crash are rejected with a retryable ToolError (see InterruptedErrorCode) when the machine restarts,
+	// instead of stalling until they time out. Calls are journaled per machine ID, which must persist
+	// across restarts for interrupted calls to be recovered. Unless set by MachineID, MachineIDEnvVar or
+	// MachineIDPath, the machine ID is persisted in JournalDir. Disabled by default.
+	JournalDir string
+	// ClusterID is required to manage runs in the cluster
+	ClusterID string
+	// MachineLabels advertises the capabilities of this machine (e.g. "gpu", "vpn", "region:eu")
+	// so that functions with FunctionConfig.RequiredLabels are only routed to capable machines.
+	MachineLabels []string
+	// LogLevel of the SDK logs. Defaults to info.
+	LogLevel LogLevel
+	// APIFallbackEndpoints are used when APIEndpoint can not be reached, e.g. the endpoints of other regions
+	APIFallbackEndpoints []string
+	// RequestsPerSecond and RequestBurst limit the rate of requests to the API and polls of the queue,
+	// see ClientOptions.RequestsPerSecond
+	RequestsPerSecond float64
+	RequestBurst      int
+	// UserAgent identifies the application the machine belongs to (e.g. "my-billing-service/1.4.2") in the
+	// User-Agent and X-Machine-SDK-Application headers, so that server side logs can tell applications apart
+	UserAgent string
+	// Logger receives the SDK logs. Defaults to the standard library logger.
+	Logger Logger
+	// MetricsSink receives the metrics of the SDK as they are recorded, see NewDogStatsDSink
+	MetricsSink MetricsSink
+	// OnError is invoked with the errors of all services which are not returned to the caller: failed polls,
+	// panics and results which could not be persisted. It can report them to error trackers such as Sentry.
+	OnError func(err error, errCtx ErrorContext)
+	// QueueEndpoint overrides the SQS endpoint used to receive calls, e.g. for self-hosted
+	// clusters, LocalStack or the inferabletest fake server.
+	QueueEndpoint string
+	// Environment selects a preset API endpoint. If APIEndpoint is also set, it must match the preset.
+	Environment Environment
+	// CheckEnvironment verifies on startup that the API secret has access to ClusterID at the API endpoint,
+	// see Inferable.CheckEnvironment. Requires ClusterID.
+	CheckEnvironment bool
+	// RequestTimeout is how long a request to the API may take. Defaults to DefaultRequestTimeout.
+	// A negative value disables the timeout.
+	RequestTimeout time.Duration
+	// Transport tunes the connections made to the API, e.g. connection reuse and HTTP/2
+	Transport TransportOptions
 }
 
 func New(options InferableOptions) (*Inferable, error) {
-	if options.APIEndpoint == "" {
-		options.APIEndpoint = DefaultAPIEndpoint
+	endpoint, err := resolveEndpoint(options.Environment, options.APIEndpoint)
+	if err != nil {
+		return nil, err
 	}
+	options.APIEndpoint = endpoint
+
 	client, err := NewClient(ClientOptions{
-		Endpoint: options.APIEndpoint,
-		Secret:   options.APISecret,
+		Endpoint:              options.APIEndpoint,
+		Secret:                options.APISecret,
+		SecretProvider:        options.APISecretProvider,
+		SecretRefreshInterval: options.APISecretRefreshInterval,
+		Timeout:               options.RequestTimeout,
+		Transport:             options.Transport,
+		UserAgent:             options.UserAgent,
+		FallbackEndpoints:     options.APIFallbackEndpoints,
+		RequestsPerSecond:     options.RequestsPerSecond,
+		RequestBurst:          options.RequestBurst,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("error creating client: %v", err)
 	}
 
 	machineID := options.MachineID
+	if machineID == "" {
+		machineID = os.Getenv(MachineIDEnvVar)
+	}
+	if machineID == "" && options.MachineIDPath != "" {
+		machineID, err = loadOrCreateMachineID(options.MachineIDPath)
+		if err != nil {
+			return nil, fmt.Errorf("error loading machine ID: %v", err)
+		}
+	}
+	// Calls are journaled per machine ID, so a random ID would leave the calls of previous runs behind
+	if machineID == "" && options.JournalDir != "" {
+		machineID, err = loadOrCreateMachineID(filepath.Join(options.JournalDir, MachineIDFile))
+		if err != nil {
+			return nil, fmt.Errorf("error loading machine ID: %v", err)
+		}
+	}
 	if machineID == "" {
 		machineID = generateMachineID(8)
 	}
 
+	if err := validateLabels(options.MachineLabels); err != nil {
+		return nil, fmt.Errorf("invalid machine labels: %v", err)
+	}
+
 	inferable := &Inferable{
-		client:           client,
-		apiEndpoint:      options.APIEndpoint,
-		apiSecret:        options.APISecret,
-		functionRegistry: FunctionRegistry{services: make(map[string]*Service)},
-		machineID:        machineID,
-		pingInterval:     10 * time.Second,
+		client:                 client,
+		apiEndpoint:            options.APIEndpoint,
+		apiSecret:              options.APISecret,
+		clusterID:              options.ClusterID,
+		functionRegistry:       FunctionRegistry{services: make(map[string]*Service)},
+		machineID:              machineID,
+		machineLabels:          options.MachineLabels,
+		queueEndpoint:          options.QueueEndpoint,
+		transport:              options.Transport,
+		registrationHistoryDir: options.RegistrationHistoryDir,
+		journalDir:             options.JournalDir,
+		pingInterval:           10 * time.Second,
+		logger:                 options.Logger,
+		metricsSink:            options.MetricsSink,
+		onError:                options.OnError,
+		application:            options.UserAgent,
+		configuredLogLevel:     options.LogLevel,
+	}
+
+	if err := inferable.setLogLevel(options.LogLevel); err != nil {
+		return nil, err
+	}
+
+	if err := validateJournalDir(options.JournalDir); err != nil {
+		return nil, err
+	}
+
+	if options.CheckEnvironment {
+		if err := inferable.CheckEnvironment(); err != nil {
+			return nil, err
+		}
 	}
 
 	go inferable.startPingCluster()
@@ -84,18 +274,22 @@ func (i *Inferable) startPingCluster() {
 
 func (i *Inferable) pingCluster() {
 	activeServices := []string{}
-	for serviceName := range i.functionRegistry.services {
-		activeServices = append(activeServices, serviceName)
+	for _, service := range i.functionRegistry.list() {
+		// Stopped services no longer take calls, so the cluster must not consider them active
+		if service.stopped.Load() {
+			continue
+		}
+		activeServices = append(activeServices, service.Name)
 	}
 
-	if len(activeServices) > 0 {
+	if len(activeServices) > 0 && !i.secretRejected.Load() {
 		body := map[string]interface{}{
 			"services": activeServices,
 		}
 
 		jsonBody, err := json.Marshal(body)
 		if err != nil {
-			fmt.Printf("Error marshaling ping body: %v\n", err)
+			i.logf(LogLevelError, "Error marshaling ping body: %v", err)
 			return
 		}
 
@@ -106,41 +300,55 @@ func (i *Inferable) pingCluster() {
 			Headers: map[string]string{"Content-Type": "application/json"},
 		})
 
-		if err != nil {
-			fmt.Printf("Error pinging cluster. Will try again next interval: %v\n", err)
+		// Without a provider to refresh it from, the secret stays rejected until UpdateSecret is called
+		if errors.Is(err, ErrAuthExpired) && i.client.secretProvider == nil {
+			i.secretRejected.Store(true)
+			i.logf(LogLevelError, "Pinging cluster failed, pausing pings until the secret is updated: %v (%s)", err, machineSecretGuidance)
+		} else if err != nil {
+			i.logf(LogLevelError, "Error pinging cluster. Will try again next interval: %v", err)
 		}
 	}
 }
 
 // Convenience reference to a service with name 'default'.
 func (i *Inferable) DefaultService() (*Service, error) {
-	if _, exists := i.functionRegistry.services["default"]; exists {
-		return i.functionRegistry.services["default"], nil
+	if service, exists := i.functionRegistry.get("default"); exists {
+		return service, nil
 	}
 
 	return nil, fmt.Errorf("default service not found")
 }
 
 func (i *Inferable) RegisterService(serviceName string) (*Service, error) {
-	if _, exists := i.functionRegistry.services[serviceName]; exists {
+	return i.RegisterServiceWithOptions(serviceName, ServiceOptions{})
+}
+
+func (i *Inferable) RegisterServiceWithOptions(serviceName string, options ServiceOptions) (*Service, error) {
+	if _, exists := i.functionRegistry.get(serviceName); exists {
 		return nil, fmt.Errorf("service with name '%s' already registered", serviceName)
 	}
+	if err := options.validate(); err != nil {
+		return nil, fmt.Errorf("invalid options for service '%s': %v", serviceName, err)
+	}
 	service := &Service{
 		Name:      serviceName,
 		Functions: make(map[string]Function),
 		inferable: i, // Set the reference to the Inferable instance
+		options:   options,
+	}
+	if err := i.functionRegistry.add(service); err != nil {
+		return nil, err
 	}
-	i.functionRegistry.services[serviceName] = service
 	return service, nil
 }
 
 func (i *Inferable) CallFunc(serviceName, funcName string, args ...interface{}) ([]reflect.Value, error) {
-	service, exists := i.functionRegistry.services[serviceName]
+	service, exists := i.functionRegistry.get(serviceName)
 	if !exists {
 		return nil, fmt.Errorf("service with name '%s' not found", serviceName)
 	}
 
-	fn, exists := service.Functions[funcName]
+	fn, exists := service.function(funcName)
 	if !exists {
 		return nil, fmt.Errorf("function with name '%s' not found in service '%s'", funcName, serviceName)
 	}
@@ -164,28 +372,58 @@ func (i *Inferable) CallFunc(serviceName, funcName string, args ...interface{})
 }
 
 func (i *Inferable) ToJSONDefinition() ([]byte, error) {
+	return json.MarshalIndent(i.definitions(), "", "  ")
+}
+
+// ExportDefinitions writes the definitions of all services, their functions and schemas as a stable,
+// sorted JSON document, e.g. to compare against a golden file so that schema changes are caught in review
+func (i *Inferable) ExportDefinitions(w io.Writer) error {
+	data, err := i.ToJSONDefinition()
+	if err != nil {
+		return fmt.Errorf("failed to marshal definitions: %v", err)
+	}
+
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// definitions returns the definitions of all services and their functions, sorted by name
+func (i *Inferable) definitions() []map[string]interface{} {
 	definitions := make([]map[string]interface{}, 0)
 
-	for serviceName, service := range i.functionRegistry.services {
+	for _, service := range i.functionRegistry.list() {
 		serviceDef := make(map[string]interface{})
 		functions := make([]map[string]interface{}, 0)
 
-		for _, function := range service.Functions {
+		for _, function := range service.functions() {
 			funcDef := map[string]interface{}{
-				"name":        function.Name,
-				"description": function.Description,
-				"schema":      function.schema,
+				"name":           function.Name,
+				"description":    function.Description,
+				"schema":         function.schema,
+				"canReturnError": returnsError(reflect.TypeOf(function.Func)),
+			}
+			if len(function.Config.ErrorCodes) > 0 {
+				funcDef["errorCodes"] = function.Config.ErrorCodes
+			}
+			if function.Version != "" {
+				funcDef["version"] = function.Version
+			}
+			if function.Config.Private {
+				funcDef["private"] = true
+			}
+			if config := function.Config.registrationConfig(); config != nil {
+				funcDef["config"] = config
 			}
 			functions = append(functions, funcDef)
 		}
 
-		serviceDef["service"] = serviceName
+		serviceDef["service"] = service.Name
 		serviceDef["functions"] = functions
 
 		definitions = append(definitions, serviceDef)
 	}
 
-	return json.MarshalIndent(definitions, "", "  ")
+	return definitions
 }
 
 func (i *Inferable) FetchData(options FetchDataOptions) ([]byte, error) {
@@ -201,17 +439,49 @@ func (i *Inferable) FetchData(options FetchDataOptions) ([]byte, error) {
 	return []byte(data), err
 }
 
+// UpdateSecret rotates the API secret without restarting. It is used by result submission, polling
+// and all other requests from then on, and requests rejected with the previous secret are retried.
+func (i *Inferable) UpdateSecret(secret string) error {
+	if secret == "" {
+		return fmt.Errorf("secret must not be empty")
+	}
+
+	i.client.UpdateSecret(secret)
+	i.secretRejected.Store(false)
+	return nil
+}
+
 func (i *Inferable) GetMachineID() string {
 	return i.machineID
 }
 
+// MachineID returns the ID this machine identifies itself with to the cluster
+func (i *Inferable) MachineID() string {
+	return i.machineID
+}
+
+// ClusterID returns InferableOptions.ClusterID, or else the cluster reported by the API when a service registered
+func (i *Inferable) ClusterID() string {
+	if i.clusterID != "" {
+		return i.clusterID
+	}
+
+	for _, service := range i.functionRegistry.list() {
+		if id := service.ClusterID(); id != "" {
+			return id
+		}
+	}
+
+	return ""
+}
+
 func (i *Inferable) ServerOk() error {
 	data, err := i.client.FetchData(FetchDataOptions{
 		Path:   "/live",
 		Method: "GET",
 	})
 	if err != nil {
-		return fmt.Errorf("error fetching data from /live: %v", err)
+		return fmt.Errorf("error fetching data from /live: %w", err)
 	}
 
 	var response struct {
@@ -0,0 +1,83 @@
+// Package inferable is the former import path of the Inferable Go client.
+//
+// Deprecated: the client is a single package at the root of the module. Import
+// github.com/inferablehq/inferable-go instead. This package re-exports the core API of the root
+// package, so that existing importers keep compiling, and will be removed in a future release.
+package inferable
+
+import (
+	root "github.com/inferablehq/inferable-go"
+)
+
+// Version is the version of the SDK
+const Version = root.Version
+
+// DefaultAPIEndpoint is the endpoint of the Inferable API
+const DefaultAPIEndpoint = root.DefaultAPIEndpoint
+
+type (
+	// Deprecated: use github.com/inferablehq/inferable-go.Inferable
+	Inferable = root.Inferable
+	// Deprecated: use github.com/inferablehq/inferable-go.InferableOptions
+	InferableOptions = root.InferableOptions
+	// Deprecated: use github.com/inferablehq/inferable-go.Service
+	Service = root.Service
+	// Deprecated: use github.com/inferablehq/inferable-go.ServiceOptions
+	ServiceOptions = root.ServiceOptions
+	// Deprecated: use github.com/inferablehq/inferable-go.Function
+	Function = root.Function
+	// Deprecated: use github.com/inferablehq/inferable-go.FunctionConfig
+	FunctionConfig = root.FunctionConfig
+	// Deprecated: use github.com/inferablehq/inferable-go.Client
+	Client = root.Client
+	// Deprecated: use github.com/inferablehq/inferable-go.ClientOptions
+	ClientOptions = root.ClientOptions
+	// Deprecated: use github.com/inferablehq/inferable-go.FetchDataOptions
+	FetchDataOptions = root.FetchDataOptions
+	// Deprecated: use github.com/inferablehq/inferable-go.CallInfo
+	CallInfo = root.CallInfo
+	// Deprecated: use github.com/inferablehq/inferable-go.CallResult
+	CallResult = root.CallResult
+	// Deprecated: use github.com/inferablehq/inferable-go.ToolError
+	ToolError = root.ToolError
+	// Deprecated: use github.com/inferablehq/inferable-go.APIError
+	APIError = root.APIError
+	// Deprecated: use github.com/inferablehq/inferable-go.ErrorCode
+	ErrorCode = root.ErrorCode
+	// Deprecated: use github.com/inferablehq/inferable-go.Environment
+	Environment = root.Environment
+	// Deprecated: use github.com/inferablehq/inferable-go.LogLevel
+	LogLevel = root.LogLevel
+)
+
+var (
+	// Deprecated: use github.com/inferablehq/inferable-go.ErrAuthExpired
+	ErrAuthExpired = root.ErrAuthExpired
+	// Deprecated: use github.com/inferablehq/inferable-go.ErrUnauthorized
+	ErrUnauthorized = root.ErrUnauthorized
+)
+
+// New creates a new Inferable client with the API secret and endpoint, as the former package did.
+// The endpoint defaults to DefaultAPIEndpoint if it is empty.
+//
+// Deprecated: use github.com/inferablehq/inferable-go.New
+func New(apiSecret string, apiEndpoint string) (*Inferable, error) {
+	return root.New(InferableOptions{
+		APIEndpoint: apiEndpoint,
+		APISecret:   apiSecret,
+	})
+}
+
+// NewWithOptions creates a new Inferable client with the options of the root package.
+//
+// Deprecated: use github.com/inferablehq/inferable-go.New
+func NewWithOptions(options InferableOptions) (*Inferable, error) {
+	return root.New(options)
+}
+
+// NewClient creates a new Inferable API client.
+//
+// Deprecated: use github.com/inferablehq/inferable-go.NewClient
+func NewClient(options ClientOptions) (*Client, error) {
+	return root.NewClient(options)
+}
@@ -0,0 +1,39 @@
+package inferable
+
+import (
+	"testing"
+
+	root "github.com/inferablehq/inferable-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type TestInput struct {
+	A int `json:"a"`
+}
+
+func TestShim(t *testing.T) {
+	// The legacy signature of the former package keeps compiling
+	i, err := New("test-secret", DefaultAPIEndpoint)
+	require.NoError(t, err)
+
+	// Values of the shim are values of the root package
+	var client *root.Inferable = i
+	require.NoError(t, client.Default.RegisterFunc(Function{
+		Name: "double",
+		Func: func(input TestInput) int { return input.A * 2 },
+	}))
+
+	result, err := i.CallFunc("default", "double", TestInput{A: 2})
+	require.NoError(t, err)
+	assert.Equal(t, 4, result[0].Interface())
+}
+
+func TestShimDefaultEndpoint(t *testing.T) {
+	i, err := New("test-secret", "")
+	require.NoError(t, err)
+	assert.NotNil(t, i.Default)
+
+	_, err = NewWithOptions(InferableOptions{APISecret: "test-secret"})
+	require.NoError(t, err)
+}
@@ -1,10 +1,15 @@
 package inferable
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"reflect"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -100,12 +105,16 @@ func TestToJSONDefinition(t *testing.T) {
 	err = json.Unmarshal(jsonDef, &definitions)
 	require.NoError(t, err)
 
-	assert.Equal(t, "TestService", definitions[1]["service"])
-	functions := definitions[1]["functions"].([]interface{})
+	assert.Equal(t, "TestService", definitions[0]["service"])
+	functions := definitions[0]["functions"].([]interface{})
 	assert.Len(t, functions, 1)
 	funcDef := functions[0].(map[string]interface{})
 	assert.Equal(t, "TestFunc", funcDef["name"])
 	assert.Equal(t, "Test function", funcDef["description"])
+
+	exported := bytes.Buffer{}
+	require.NoError(t, i.ExportDefinitions(&exported))
+	assert.Equal(t, string(jsonDef)+"\n", exported.String())
 }
 
 func TestServerOk(t *testing.T) {
@@ -126,6 +135,30 @@ func TestServerOk(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestAPIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", "req-123")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"error": "unavailable"}`))
+	}))
+	defer server.Close()
+
+	i, _ := New(InferableOptions{
+		APIEndpoint: server.URL,
+		APISecret:   "test-secret",
+	})
+	err := i.ServerOk()
+	require.Error(t, err)
+
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusServiceUnavailable, apiErr.StatusCode)
+	assert.Equal(t, "req-123", apiErr.RequestID)
+	assert.Equal(t, `{"error": "unavailable"}`, apiErr.Body)
+	assert.True(t, apiErr.Retryable)
+	assert.Contains(t, err.Error(), "req-123")
+}
+
 func TestGetMachineID(t *testing.T) {
 	i, _ := New(InferableOptions{
 		APIEndpoint: DefaultAPIEndpoint,
@@ -142,6 +175,39 @@ func TestGetMachineID(t *testing.T) {
 	assert.Equal(t, machineID, i2.GetMachineID())
 }
 
+func TestPersistentMachineID(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "machine", MachineIDFile)
+
+	i, err := New(InferableOptions{
+		APIEndpoint:   DefaultAPIEndpoint,
+		APISecret:     "test-secret",
+		MachineIDPath: path,
+	})
+	require.NoError(t, err)
+	assert.FileExists(t, path)
+
+	// Overwrite the persisted ID, it should be picked up by the next instance
+	require.NoError(t, os.WriteFile(path, []byte(`{"machineId": "persisted-id"}`), 0o644))
+
+	i2, err := New(InferableOptions{
+		APIEndpoint:   DefaultAPIEndpoint,
+		APISecret:     "test-secret",
+		MachineIDPath: path,
+	})
+	require.NoError(t, err)
+	assert.NotEqual(t, i.GetMachineID(), i2.GetMachineID())
+	assert.Equal(t, "persisted-id", i2.GetMachineID())
+
+	t.Setenv(MachineIDEnvVar, "env-id")
+	i3, err := New(InferableOptions{
+		APIEndpoint:   DefaultAPIEndpoint,
+		APISecret:     "test-secret",
+		MachineIDPath: path,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "env-id", i3.GetMachineID())
+}
+
 func TestGetSchema(t *testing.T) {
 	i, _ := New(InferableOptions{
 		APIEndpoint: DefaultAPIEndpoint,
@@ -218,7 +284,7 @@ func TestGetSchema(t *testing.T) {
 }
 
 func TestPingCluster(t *testing.T) {
-	pingCount := 0
+	var pingCount atomic.Int32
 
 	// Create a mock server
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -253,7 +319,7 @@ func TestPingCluster(t *testing.T) {
 		// Send a successful response
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte(`{"status":"ok"}`))
-		pingCount++
+		pingCount.Add(1)
 	}))
 	defer server.Close()
 
@@ -274,5 +340,114 @@ func TestPingCluster(t *testing.T) {
 
 	// wait 2s. pingCluster should have been called at least once
 	time.Sleep(2 * time.Second)
-	assert.Greater(t, pingCount, 0)
+	assert.Greater(t, pingCount.Load(), int32(0))
+}
+
+func TestEnvironment(t *testing.T) {
+	i, err := New(InferableOptions{
+		Environment: EnvironmentProduction,
+		APISecret:   "test-secret",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, DefaultAPIEndpoint, i.apiEndpoint)
+
+	_, err = New(InferableOptions{
+		Environment: EnvironmentProduction,
+		APIEndpoint: "https://staging.example.com",
+		APISecret:   "test-secret",
+	})
+	assert.Error(t, err)
+
+	_, err = New(InferableOptions{
+		Environment: "staging",
+		APISecret:   "test-secret",
+	})
+	assert.Error(t, err)
+}
+
+func TestCheckEnvironment(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/live" {
+			w.Write([]byte(`{"status": "ok"}`))
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer valid-secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/clusters/prod-cluster" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"id": "prod-cluster"}`))
+	}))
+	defer server.Close()
+
+	_, err := New(InferableOptions{
+		APIEndpoint:      server.URL,
+		APISecret:        "valid-secret",
+		ClusterID:        "prod-cluster",
+		CheckEnvironment: true,
+	})
+	require.NoError(t, err)
+
+	_, err = New(InferableOptions{
+		APIEndpoint:      server.URL,
+		APISecret:        "other-environment-secret",
+		ClusterID:        "prod-cluster",
+		CheckEnvironment: true,
+	})
+	assert.ErrorContains(t, err, "check that the secret belongs to cluster 'prod-cluster' in this environment")
+
+	_, err = New(InferableOptions{
+		APIEndpoint:      server.URL,
+		APISecret:        "valid-secret",
+		ClusterID:        "staging-cluster",
+		CheckEnvironment: true,
+	})
+	assert.ErrorContains(t, err, "cluster 'staging-cluster' was not found")
+
+	// Without a cluster ID the check can not be performed, rather than passing
+	_, err = New(InferableOptions{
+		APIEndpoint:      server.URL,
+		APISecret:        "valid-secret",
+		CheckEnvironment: true,
+	})
+	assert.ErrorContains(t, err, "cluster ID must be provided")
+}
+
+func TestUserAgent(t *testing.T) {
+	headers := make(chan http.Header, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers <- r.Header.Clone()
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	i, err := New(InferableOptions{
+		APIEndpoint: server.URL,
+		APISecret:   "test-secret",
+		UserAgent:   "my-billing-service/1.4.2",
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, i.Default.acknowledgeJob("call-1"))
+	var header http.Header
+	for header = range headers {
+		if header.Get("X-Machine-ID") != "" {
+			break
+		}
+	}
+	assert.Equal(t, "inferable-go/"+Version+" my-billing-service/1.4.2", header.Get("User-Agent"))
+	assert.Equal(t, "my-billing-service/1.4.2", header.Get("X-Machine-SDK-Application"))
+
+	// Without an application, the SDK identifies itself
+	assert.Equal(t, "inferable-go/"+Version, userAgent(""))
+
+	_, err = New(InferableOptions{
+		APIEndpoint: server.URL,
+		APISecret:   "test-secret",
+		UserAgent:   "billing\r\nX-Injected: true",
+	})
+	assert.Error(t, err)
 }
@@ -0,0 +1,68 @@
+package inferabletest
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	inferable "github.com/inferablehq/inferable-go"
+)
+
+// UpdateGoldenEnvVar is the environment variable which makes AssertDefinitions write the golden file
+// instead of comparing against it, e.g. INFERABLE_UPDATE_GOLDEN=1 go test ./...
+const UpdateGoldenEnvVar = "INFERABLE_UPDATE_GOLDEN"
+
+// AssertDefinitions compares the definitions exported by client against the golden file at path, and fails
+// the test if they differ, so that schema changes are caught in code review before they reach agents.
+// The golden file is written if it does not exist, or if UpdateGoldenEnvVar is set.
+func AssertDefinitions(t testing.TB, client *inferable.Inferable, path string) {
+	t.Helper()
+
+	exported := bytes.Buffer{}
+	if err := client.ExportDefinitions(&exported); err != nil {
+		t.Fatalf("failed to export definitions: %v", err)
+	}
+
+	golden, err := os.ReadFile(path)
+	if os.IsNotExist(err) || os.Getenv(UpdateGoldenEnvVar) != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("failed to create directory of golden file: %v", err)
+		}
+		if err := os.WriteFile(path, exported.Bytes(), 0o644); err != nil {
+			t.Fatalf("failed to write golden file: %v", err)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatalf("failed to read golden file: %v", err)
+	}
+
+	if !bytes.Equal(golden, exported.Bytes()) {
+		t.Errorf("definitions differ from %s, run the tests with %s=1 to update it:\n%s",
+			path, UpdateGoldenEnvVar, firstDifference(string(golden), exported.String()))
+	}
+}
+
+// firstDifference describes the first line which differs between the golden and exported definitions
+func firstDifference(golden, exported string) string {
+	goldenLines := strings.Split(golden, "\n")
+	exportedLines := strings.Split(exported, "\n")
+
+	for idx := 0; idx < len(goldenLines) || idx < len(exportedLines); idx++ {
+		var want, got string
+		if idx < len(goldenLines) {
+			want = goldenLines[idx]
+		}
+		if idx < len(exportedLines) {
+			got = exportedLines[idx]
+		}
+		if want != got {
+			return "line " + strconv.Itoa(idx+1) + ":\n- " + want + "\n+ " + got
+		}
+	}
+
+	return ""
+}
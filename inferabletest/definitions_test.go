@@ -0,0 +1,60 @@
+package inferabletest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	inferable "github.com/inferablehq/inferable-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingT records failures instead of failing the test
+type recordingT struct {
+	testing.TB
+	failed bool
+}
+
+func (r *recordingT) Errorf(format string, args ...interface{}) {
+	r.failed = true
+}
+
+func TestAssertDefinitions(t *testing.T) {
+	i, err := inferable.New(inferable.InferableOptions{
+		APIEndpoint: inferable.DefaultAPIEndpoint,
+		APISecret:   APISecret,
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, i.Default.RegisterFunc(inferable.Function{
+		Name:        "greet",
+		Description: "Greets a person",
+		Func:        greet,
+	}))
+
+	path := filepath.Join(t.TempDir(), "testdata", "definitions.json")
+
+	// The golden file is written on the first run, and matches from then on
+	AssertDefinitions(t, i, path)
+	golden, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(golden), `"name": "greet"`)
+
+	recorder := &recordingT{TB: t}
+	AssertDefinitions(recorder, i, path)
+	assert.False(t, recorder.failed)
+
+	require.NoError(t, i.Default.RegisterFunc(inferable.Function{
+		Name: "farewell",
+		Func: greet,
+	}))
+	AssertDefinitions(recorder, i, path)
+	assert.True(t, recorder.failed)
+
+	t.Setenv(UpdateGoldenEnvVar, "1")
+	AssertDefinitions(t, i, path)
+	updated, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(updated), `"name": "farewell"`)
+}
@@ -0,0 +1,535 @@
+// Package inferabletest provides an in-memory fake of the Inferable control plane for unit testing services.
+//
+// The fake implements the registration, acknowledgement, result and machine error endpoints of the API,
+// including batched and chunked results, as well as the subset of the SQS API used to deliver calls, so
+// that services can be registered and their functions executed hermetically, without a live cluster or secrets.
+//
+//	server := inferabletest.NewServer()
+//	defer server.Close()
+//
+//	i, _ := inferable.New(server.Options())
+//	i.Default.RegisterFunc(inferable.Function{Name: "echo", Func: echo})
+//	i.Default.Start()
+//	defer i.Default.Stop()
+//
+//	callID, _ := server.Call("default", "echo", EchoInput{Input: "hello"})
+//	result, _ := server.WaitForResult(ctx, callID)
+package inferabletest
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	inferable "github.com/inferablehq/inferable-go"
+)
+
+// APISecret is the secret accepted by the fake server
+const APISecret = "inferabletest-secret"
+
+// maxWaitTime caps the long polling of the fake queue, so that stopped services are not kept waiting
+const maxWaitTime = 2 * time.Second
+
+// Registration is a machine registration received by the fake server
+type Registration struct {
+	MachineID string     `json:"-"`
+	Service   string     `json:"service"`
+	Labels    []string   `json:"labels"`
+	Functions []Function `json:"functions"`
+}
+
+// Function is a function definition received as part of a Registration
+type Function struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Schema      string `json:"schema"`
+}
+
+// Result is the result persisted for a call
+type Result struct {
+	// Type is either "resolution" or "rejection"
+	Type string
+	// Value is the JSON encoded value returned by the function
+	Value json.RawMessage
+	Meta  json.RawMessage
+}
+
+// Decode unmarshals the result value into v
+func (r Result) Decode(v interface{}) error {
+	return json.Unmarshal(r.Value, v)
+}
+
+// MachineError is a machine error reported to the fake server
+type MachineError struct {
+	Service string `json:"service"`
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+	CallID  string `json:"jobId"`
+}
+
+type message struct {
+	id   string
+	body string
+}
+
+// Server is an in-memory fake of the Inferable control plane
+type Server struct {
+	URL string
+
+	server *httptest.Server
+
+	mu            sync.Mutex
+	nextID        int
+	registrations []Registration
+	queues        map[string][]message
+	acknowledged  map[string]bool
+	results       map[string]Result
+	chunks        map[string]map[int][]byte
+	machineErrors []MachineError
+	changed       chan struct{}
+}
+
+// NewServer starts a new fake server. It should be closed with Close once the test is done.
+func NewServer() *Server {
+	s := &Server{
+		queues:       make(map[string][]message),
+		acknowledged: make(map[string]bool),
+		results:      make(map[string]Result),
+		chunks:       make(map[string]map[int][]byte),
+		changed:      make(chan struct{}),
+	}
+
+	s.server = httptest.NewServer(http.HandlerFunc(s.handle))
+	s.URL = s.server.URL
+
+	return s
+}
+
+// Close shuts down the server
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// Options returns InferableOptions pointing at the fake server
+func (s *Server) Options() inferable.InferableOptions {
+	return inferable.InferableOptions{
+		APIEndpoint:   s.URL,
+		APISecret:     APISecret,
+		QueueEndpoint: s.URL,
+		MachineID:     "inferabletest",
+	}
+}
+
+// Registrations returns the machine registrations received so far
+func (s *Server) Registrations() []Registration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Registration(nil), s.registrations...)
+}
+
+// Call enqueues a call to function in service with input, returning the ID of the call.
+// Like the cluster, the fake stops routing calls to a function once the service registered without it,
+// e.g. after it was deregistered. Calls to services which have not registered yet are queued.
+func (s *Server) Call(service, function string, input interface{}) (string, error) {
+	args, err := json.Marshal(map[string]interface{}{"value": input})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal input: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.routable(service, function) {
+		return "", fmt.Errorf("function '%s' of service '%s' is not registered", function, service)
+	}
+
+	s.nextID++
+	callID := fmt.Sprintf("call-%d", s.nextID)
+
+	body, err := json.Marshal(map[string]interface{}{
+		"value": map[string]interface{}{
+			"id":         callID,
+			"service":    service,
+			"targetFn":   function,
+			"targetArgs": string(args),
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal call: %v", err)
+	}
+
+	s.queues[service] = append(s.queues[service], message{id: callID, body: string(body)})
+	s.notify()
+
+	return callID, nil
+}
+
+// routable reports whether the latest registration of service on any machine lists function, or the service
+// has not registered yet. Must be called with mu held.
+func (s *Server) routable(service, function string) bool {
+	latest := map[string]Registration{}
+	for _, registration := range s.registrations {
+		if registration.Service == service {
+			latest[registration.MachineID] = registration
+		}
+	}
+	if len(latest) == 0 {
+		return true
+	}
+
+	for _, registration := range latest {
+		for _, fn := range registration.Functions {
+			if fn.Name == function {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Acknowledged reports whether the call has been acknowledged by the machine
+func (s *Server) Acknowledged(callID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.acknowledged[callID]
+}
+
+// Result returns the result of the call, if it has been persisted
+func (s *Server) Result(callID string) (Result, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result, ok := s.results[callID]
+	return result, ok
+}
+
+// ResultChunks returns the chunks uploaded for a streamed or oversized result, joined in order of their index
+func (s *Server) ResultChunks(callID string) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	indexes := make([]int, 0, len(s.chunks[callID]))
+	for index := range s.chunks[callID] {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	var joined []byte
+	for _, index := range indexes {
+		joined = append(joined, s.chunks[callID][index]...)
+	}
+
+	return joined
+}
+
+// MachineErrors returns the machine errors reported so far
+func (s *Server) MachineErrors() []MachineError {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]MachineError(nil), s.machineErrors...)
+}
+
+// WaitForResult blocks until the result of the call has been persisted, or ctx is done
+func (s *Server) WaitForResult(ctx context.Context, callID string) (Result, error) {
+	for {
+		s.mu.Lock()
+		result, ok := s.results[callID]
+		changed := s.changed
+		s.mu.Unlock()
+
+		if ok {
+			return result, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return Result{}, fmt.Errorf("waiting for result of '%s': %w", callID, ctx.Err())
+		case <-changed:
+		}
+	}
+}
+
+// notify wakes up anything waiting for a change in state. Must be called with mu held.
+func (s *Server) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	if target := r.Header.Get("X-Amz-Target"); target != "" {
+		s.handleQueue(w, r, strings.TrimPrefix(target, "AmazonSQS."))
+		return
+	}
+
+	if r.URL.Path == "/live" {
+		writeJSON(w, map[string]string{"status": "ok"})
+		return
+	}
+
+	if r.Header.Get("Authorization") != "Bearer "+APISecret {
+		http.Error(w, `{"error": "unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/machines":
+		s.handleRegistration(w, r)
+	case r.Method == http.MethodPost && r.URL.Path == "/machines/errors":
+		s.handleMachineError(w, r)
+	case r.Method == http.MethodPost && r.URL.Path == "/v2/ping":
+		writeJSON(w, map[string]string{"status": "ok"})
+	case r.Method == http.MethodPost && r.URL.Path == "/jobs/results":
+		s.handleBatchedResults(w, r)
+	case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/jobs/"):
+		s.handleAcknowledge(w, strings.TrimPrefix(r.URL.Path, "/jobs/"))
+	case r.Method == http.MethodPost && strings.HasPrefix(r.URL.Path, "/jobs/") && strings.HasSuffix(r.URL.Path, "/result"):
+		s.handleResult(w, r, strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/jobs/"), "/result"))
+	case r.Method == http.MethodPost && strings.HasPrefix(r.URL.Path, "/jobs/") && strings.HasSuffix(r.URL.Path, "/result/chunks"):
+		s.handleResultChunk(w, r, strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/jobs/"), "/result/chunks"))
+	default:
+		http.Error(w, `{"error": "not found"}`, http.StatusNotFound)
+	}
+}
+
+func (s *Server) handleRegistration(w http.ResponseWriter, r *http.Request) {
+	var registration Registration
+	if err := json.NewDecoder(r.Body).Decode(&registration); err != nil {
+		http.Error(w, `{"error": "invalid registration"}`, http.StatusBadRequest)
+		return
+	}
+	registration.MachineID = r.Header.Get("X-Machine-ID")
+
+	s.mu.Lock()
+	s.registrations = append(s.registrations, registration)
+	s.mu.Unlock()
+
+	writeJSON(w, map[string]interface{}{
+		"queueUrl":   s.URL + "/queues/" + registration.Service,
+		"region":     "us-east-1",
+		"enabled":    true,
+		"expiration": time.Now().Add(time.Hour),
+		"credentials": map[string]string{
+			"accessKeyId":     "inferabletest",
+			"secretAccessKey": "inferabletest",
+			"sessionToken":    "inferabletest",
+		},
+	})
+}
+
+func (s *Server) handleAcknowledge(w http.ResponseWriter, callID string) {
+	s.mu.Lock()
+	s.acknowledged[callID] = true
+	s.notify()
+	s.mu.Unlock()
+
+	writeJSON(w, map[string]string{})
+}
+
+// persistedResult is the payload of a result, on its own or as part of a batch
+type persistedResult struct {
+	JobID      string          `json:"jobId"`
+	Result     string          `json:"result"`
+	ResultType string          `json:"resultType"`
+	Meta       json.RawMessage `json:"meta"`
+}
+
+func (p persistedResult) decode() (Result, error) {
+	var result struct {
+		Value json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal([]byte(p.Result), &result); err != nil {
+		return Result{}, err
+	}
+
+	return Result{Type: p.ResultType, Value: result.Value, Meta: p.Meta}, nil
+}
+
+func (s *Server) handleResult(w http.ResponseWriter, r *http.Request, callID string) {
+	var payload persistedResult
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, `{"error": "invalid result"}`, http.StatusBadRequest)
+		return
+	}
+
+	result, err := payload.decode()
+	if err != nil {
+		http.Error(w, `{"error": "invalid result"}`, http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.results[callID] = result
+	s.notify()
+	s.mu.Unlock()
+
+	writeJSON(w, map[string]string{})
+}
+
+func (s *Server) handleBatchedResults(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		Results []persistedResult `json:"results"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, `{"error": "invalid results"}`, http.StatusBadRequest)
+		return
+	}
+
+	results := make(map[string]Result, len(payload.Results))
+	for _, persisted := range payload.Results {
+		result, err := persisted.decode()
+		if err != nil || persisted.JobID == "" {
+			http.Error(w, `{"error": "invalid results"}`, http.StatusBadRequest)
+			return
+		}
+		results[persisted.JobID] = result
+	}
+
+	s.mu.Lock()
+	for callID, result := range results {
+		s.results[callID] = result
+	}
+	s.notify()
+	s.mu.Unlock()
+
+	writeJSON(w, map[string]string{})
+}
+
+func (s *Server) handleResultChunk(w http.ResponseWriter, r *http.Request, callID string) {
+	index, err := strconv.Atoi(r.URL.Query().Get("index"))
+	if err != nil || index < 0 {
+		http.Error(w, `{"error": "invalid chunk index"}`, http.StatusBadRequest)
+		return
+	}
+
+	chunk, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, `{"error": "invalid chunk"}`, http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	if s.chunks[callID] == nil {
+		s.chunks[callID] = make(map[int][]byte)
+	}
+	s.chunks[callID][index] = chunk
+	s.mu.Unlock()
+
+	writeJSON(w, map[string]string{})
+}
+
+func (s *Server) handleMachineError(w http.ResponseWriter, r *http.Request) {
+	var machineError MachineError
+	if err := json.NewDecoder(r.Body).Decode(&machineError); err != nil {
+		http.Error(w, `{"error": "invalid machine error"}`, http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.machineErrors = append(s.machineErrors, machineError)
+	s.notify()
+	s.mu.Unlock()
+
+	writeJSON(w, map[string]string{})
+}
+
+// handleQueue implements the ReceiveMessage, DeleteMessage, ChangeMessageVisibility and GetQueueAttributes
+// actions of the SQS JSON protocol
+func (s *Server) handleQueue(w http.ResponseWriter, r *http.Request, action string) {
+	var input struct {
+		QueueURL            string `json:"QueueUrl"`
+		MaxNumberOfMessages int    `json:"MaxNumberOfMessages"`
+		WaitTimeSeconds     int    `json:"WaitTimeSeconds"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, `{"__type": "InvalidParameterValue"}`, http.StatusBadRequest)
+		return
+	}
+
+	service := input.QueueURL[strings.LastIndex(input.QueueURL, "/")+1:]
+
+	switch action {
+	case "ReceiveMessage":
+		s.receiveMessages(w, r, service, input.MaxNumberOfMessages, time.Duration(input.WaitTimeSeconds)*time.Second)
+	case "DeleteMessage", "ChangeMessageVisibility":
+		// Messages are removed from the queue as they are received, so they never become visible again
+		writeJSON(w, map[string]string{})
+	case "GetQueueAttributes":
+		s.mu.Lock()
+		depth := len(s.queues[service])
+		s.mu.Unlock()
+
+		writeJSON(w, map[string]interface{}{
+			"Attributes": map[string]string{"ApproximateNumberOfMessages": strconv.Itoa(depth)},
+		})
+	default:
+		http.Error(w, `{"__type": "UnsupportedOperation"}`, http.StatusBadRequest)
+	}
+}
+
+func (s *Server) receiveMessages(w http.ResponseWriter, r *http.Request, service string, limit int, wait time.Duration) {
+	if limit <= 0 {
+		limit = 1
+	}
+	if wait > maxWaitTime {
+		wait = maxWaitTime
+	}
+
+	timeout := time.NewTimer(wait)
+	defer timeout.Stop()
+
+	for {
+		s.mu.Lock()
+		queue := s.queues[service]
+		changed := s.changed
+		if len(queue) > 0 || wait == 0 {
+			if len(queue) < limit {
+				limit = len(queue)
+			}
+			received := queue[:limit]
+			s.queues[service] = queue[limit:]
+			s.mu.Unlock()
+
+			messages := make([]map[string]string, 0, len(received))
+			for _, msg := range received {
+				sum := md5.Sum([]byte(msg.body))
+				messages = append(messages, map[string]string{
+					"MessageId":     msg.id,
+					"ReceiptHandle": msg.id,
+					"Body":          msg.body,
+					"MD5OfBody":     hex.EncodeToString(sum[:]),
+				})
+			}
+
+			writeJSON(w, map[string]interface{}{"Messages": messages})
+			return
+		}
+		s.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-timeout.C:
+			wait = 0
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,284 @@
+package inferabletest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	inferable "github.com/inferablehq/inferable-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type GreetInput struct {
+	Name string `json:"name"`
+}
+
+func greet(input GreetInput) (string, error) {
+	if input.Name == "" {
+		return "", fmt.Errorf("name is required")
+	}
+	return "Hello, " + input.Name, nil
+}
+
+func TestServer(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+
+	i, err := inferable.New(server.Options())
+	require.NoError(t, err)
+
+	err = i.Default.RegisterFunc(inferable.Function{
+		Name:        "greet",
+		Description: "Greets a person",
+		Func:        greet,
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, i.ServerOk())
+	require.NoError(t, i.Default.Start())
+	defer i.Default.Stop()
+
+	registrations := server.Registrations()
+	require.Len(t, registrations, 1)
+	assert.Equal(t, "default", registrations[0].Service)
+	assert.Equal(t, "inferabletest", registrations[0].MachineID)
+	require.Len(t, registrations[0].Functions, 1)
+	assert.Equal(t, "greet", registrations[0].Functions[0].Name)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	callID, err := server.Call("default", "greet", GreetInput{Name: "Inferable"})
+	require.NoError(t, err)
+
+	result, err := server.WaitForResult(ctx, callID)
+	require.NoError(t, err)
+	assert.True(t, server.Acknowledged(callID))
+	assert.Equal(t, "resolution", result.Type)
+
+	var greeting string
+	require.NoError(t, result.Decode(&greeting))
+	assert.Equal(t, "Hello, Inferable", greeting)
+}
+
+// recordingLogger records the messages of the SDK logs
+type recordingLogger struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (l *recordingLogger) Log(level inferable.LogLevel, msg string, fields map[string]interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.messages = append(l.messages, msg)
+}
+
+func (l *recordingLogger) contains(substr string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, msg := range l.messages {
+		if strings.Contains(msg, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestServerBatchedResults(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+
+	logger := &recordingLogger{}
+	options := server.Options()
+	options.Logger = logger
+	options.LogLevel = inferable.LogLevelDebug
+
+	i, err := inferable.New(options)
+	require.NoError(t, err)
+
+	service, err := i.RegisterServiceWithOptions("batched", inferable.ServiceOptions{BatchResults: true, PollLimit: 10})
+	require.NoError(t, err)
+	require.NoError(t, service.RegisterFunc(inferable.Function{Name: "greet", Func: greet}))
+
+	// Both calls are queued before the service starts, so that they are received in one poll
+	first, err := server.Call("batched", "greet", GreetInput{Name: "first"})
+	require.NoError(t, err)
+	second, err := server.Call("batched", "greet", GreetInput{Name: "second"})
+	require.NoError(t, err)
+
+	require.NoError(t, service.Start())
+	defer service.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for callID, expected := range map[string]string{first: "Hello, first", second: "Hello, second"} {
+		result, err := server.WaitForResult(ctx, callID)
+		require.NoError(t, err)
+
+		var greeting string
+		require.NoError(t, result.Decode(&greeting))
+		assert.Equal(t, expected, greeting)
+	}
+
+	assert.False(t, logger.contains("Batched results are not supported"))
+}
+
+func TestServerResultChunks(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+
+	i, err := inferable.New(server.Options())
+	require.NoError(t, err)
+
+	service, err := i.RegisterServiceWithOptions("streaming", inferable.ServiceOptions{ResultChunkSize: 4})
+	require.NoError(t, err)
+	require.NoError(t, service.RegisterFunc(inferable.Function{
+		Name: "stream",
+		Func: func(input GreetInput) (io.Reader, error) {
+			return strings.NewReader("0123456789"), nil
+		},
+	}))
+
+	require.NoError(t, service.Start())
+	defer service.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	callID, err := server.Call("streaming", "stream", GreetInput{Name: "Inferable"})
+	require.NoError(t, err)
+
+	result, err := server.WaitForResult(ctx, callID)
+	require.NoError(t, err)
+	assert.Equal(t, "resolution", result.Type)
+	assert.JSONEq(t, `{"chunks": 3, "size": 10}`, string(result.Value))
+	assert.Contains(t, string(result.Meta), `"chunked":true`)
+	assert.Equal(t, "0123456789", string(server.ResultChunks(callID)))
+}
+
+func TestServerMachineErrors(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+
+	i, err := inferable.New(server.Options())
+	require.NoError(t, err)
+
+	service, err := i.RegisterServiceWithOptions("limited", inferable.ServiceOptions{MaxInputSize: 16})
+	require.NoError(t, err)
+	require.NoError(t, service.RegisterFunc(inferable.Function{Name: "greet", Func: greet}))
+
+	require.NoError(t, service.Start())
+	defer service.Stop()
+
+	// The message is far beyond the input limits, so it is dropped and reported as a machine error
+	_, err = server.Call("limited", "greet", GreetInput{Name: strings.Repeat("a", 10000)})
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		return len(server.MachineErrors()) > 0
+	}, 10*time.Second, 10*time.Millisecond)
+
+	machineErrors := server.MachineErrors()
+	require.Len(t, machineErrors, 1)
+	assert.Equal(t, "limited", machineErrors[0].Service)
+	assert.Equal(t, string(inferable.MachineErrorMessageRejected), machineErrors[0].Kind)
+	assert.NotEmpty(t, machineErrors[0].Message)
+}
+
+func TestServerQueueDepth(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+
+	i, err := inferable.New(server.Options())
+	require.NoError(t, err)
+
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+
+	service, err := i.RegisterService("busy")
+	require.NoError(t, err)
+	require.NoError(t, service.RegisterFunc(inferable.Function{
+		Name: "wait",
+		Func: func(input GreetInput) (string, error) {
+			started <- struct{}{}
+			<-release
+			return input.Name, nil
+		},
+	}))
+
+	require.NoError(t, service.Start())
+	defer service.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err = server.Call("busy", "wait", GreetInput{Name: "first"})
+	require.NoError(t, err)
+
+	select {
+	case <-started:
+	case <-ctx.Done():
+		t.Fatal("the first call was not received")
+	}
+
+	// While the first call is being handled, the others wait in the queue
+	for n := 0; n < 2; n++ {
+		_, err = server.Call("busy", "wait", GreetInput{Name: "waiting"})
+		require.NoError(t, err)
+	}
+
+	report := service.Utilization(ctx)
+	assert.Equal(t, 1, report.InFlight)
+	assert.Equal(t, int64(2), report.QueueDepth)
+
+	close(release)
+}
+
+func TestServerDeregistration(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+
+	i, err := inferable.New(server.Options())
+	require.NoError(t, err)
+
+	service, err := i.RegisterServiceWithOptions("tools", inferable.ServiceOptions{Interval: 10 * time.Millisecond})
+	require.NoError(t, err)
+	require.NoError(t, service.RegisterFunc(inferable.Function{Name: "greet", Func: greet}))
+	require.NoError(t, service.RegisterFunc(inferable.Function{Name: "farewell", Func: greet}))
+	require.NoError(t, service.Start())
+	defer service.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	callID, err := server.Call("tools", "farewell", GreetInput{Name: "Inferable"})
+	require.NoError(t, err)
+	_, err = server.WaitForResult(ctx, callID)
+	require.NoError(t, err)
+
+	// The cluster stops routing calls to a removed function, and to a service left without functions
+	require.NoError(t, service.DeregisterFunc("farewell"))
+	_, err = server.Call("tools", "farewell", GreetInput{Name: "Inferable"})
+	assert.ErrorContains(t, err, "not registered")
+
+	callID, err = server.Call("tools", "greet", GreetInput{Name: "Inferable"})
+	require.NoError(t, err)
+	result, err := server.WaitForResult(ctx, callID)
+	require.NoError(t, err)
+	assert.Equal(t, "resolution", result.Type)
+
+	require.NoError(t, service.DeregisterFunc("greet"))
+	_, err = server.Call("tools", "greet", GreetInput{Name: "Inferable"})
+	assert.ErrorContains(t, err, "not registered")
+
+	registrations := server.Registrations()
+	assert.Empty(t, registrations[len(registrations)-1].Functions)
+}
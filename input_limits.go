@@ -0,0 +1,176 @@
+package inferable
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	// DefaultMaxInputSize is the size in bytes of the largest input accepted when
+	// ServiceOptions.MaxInputSize is not set
+	DefaultMaxInputSize = 1 << 20
+	// DefaultMaxInputDepth is how deeply arrays and objects of inputs may be nested when
+	// ServiceOptions.MaxInputDepth is not set
+	DefaultMaxInputDepth = 64
+
+	// InputLimitErrorCode is the code of the ToolError calls are rejected with when their input
+	// exceeds ServiceOptions.MaxInputSize or ServiceOptions.MaxInputDepth
+	InputLimitErrorCode = "INPUT_LIMIT_EXCEEDED"
+
+	// envelopeAllowance is the size of the message body around the input of a call
+	envelopeAllowance = 4 << 10
+	// envelopeDepth is how deeply the input of a call is nested in the message body ({"value": {"targetArgs": {"value": ...}}})
+	envelopeDepth = 3
+	// messageLimitFactor is how many times the input limits message bodies may exceed, so that inputs slightly
+	// over the limits (which may be escaped as JSON strings) are rejected as calls, and only bodies far beyond
+	// any accepted input are dropped before decoding
+	messageLimitFactor = 4
+)
+
+func (s *Service) maxInputSize() int {
+	if s.options.MaxInputSize <= 0 {
+		return DefaultMaxInputSize
+	}
+
+	return s.options.MaxInputSize
+}
+
+func (s *Service) maxInputDepth() int {
+	if s.options.MaxInputDepth <= 0 {
+		return DefaultMaxInputDepth
+	}
+
+	return s.options.MaxInputDepth
+}
+
+// checkMessageLimits rejects message bodies far larger or deeper than any accepted input, before the body is
+// decoded. Inputs exceeding the limits by less are rejected as calls by checkInputLimits.
+func (s *Service) checkMessageLimits(body string) error {
+	return checkJSONLimits([]byte(body), messageLimitFactor*s.maxInputSize()+envelopeAllowance, messageLimitFactor*s.maxInputDepth(), envelopeDepth)
+}
+
+// checkInputLimits rejects inputs exceeding ServiceOptions.MaxInputSize or ServiceOptions.MaxInputDepth,
+// before they are decoded. targetArgs holds the input under "value".
+func (s *Service) checkInputLimits(targetArgs []byte) error {
+	return checkJSONLimits(targetArgs, s.maxInputSize(), s.maxInputDepth(), 1)
+}
+
+// checkJSONLimits scans data for its size and the nesting of its arrays and objects, without decoding it.
+// The outer wrapping levels of data, which hold the input, do not count towards maxDepth.
+func checkJSONLimits(data []byte, maxSize, maxDepth, wrapping int) error {
+	if len(data) > maxSize {
+		return fmt.Errorf("input of %d bytes exceeds the maximum of %d bytes", len(data), maxSize)
+	}
+
+	depth := 0
+	inString, escaped := false, false
+	for _, b := range data {
+		switch {
+		case escaped:
+			escaped = false
+		case inString:
+			switch b {
+			case '\\':
+				escaped = true
+			case '"':
+				inString = false
+			}
+		case b == '"':
+			inString = true
+		case b == '{' || b == '[':
+			depth++
+			if depth > maxDepth+wrapping {
+				return fmt.Errorf("input is nested more than %d levels deep", maxDepth)
+			}
+		case b == '}' || b == ']':
+			depth--
+		}
+	}
+
+	return nil
+}
+
+// rejectOversizedMessage rejects the call of a message whose body exceeds the message limits, so that it
+// does not stall until it times out. Messages whose call ID is not known (see messageCallID) can only be dropped.
+func (s *Service) rejectOversizedMessage(ctx context.Context, callID string, limitErr error) error {
+	if callID == "" {
+		return nil
+	}
+
+	rejection, err := rejectionResult(inputLimitRejection(limitErr))
+	if err != nil {
+		return err
+	}
+
+	meta := resultMetadata{MachineID: s.inferable.machineID, SDKVersion: Version}
+	if err := s.persistJobResult(ctx, callID, rejection, meta); err != nil {
+		return fmt.Errorf("failed to reject call '%s': %w", callID, err)
+	}
+
+	s.inferable.logf(LogLevelError, "Rejected call '%s' of service '%s': %v", callID, s.Name, limitErr)
+	return nil
+}
+
+// messageCallID reads the call ID (value.id) from the first envelopeAllowance bytes of a message body,
+// returning "" if it is not found there
+func messageCallID(body string) string {
+	decoder := json.NewDecoder(io.LimitReader(strings.NewReader(body), envelopeAllowance))
+
+	for _, key := range []string{"value", "id"} {
+		if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+			return ""
+		}
+
+		for {
+			if !decoder.More() {
+				return ""
+			}
+			token, err := decoder.Token()
+			if err != nil {
+				return ""
+			}
+			if token == key {
+				break
+			}
+			if err := skipJSONValue(decoder); err != nil {
+				return ""
+			}
+		}
+	}
+
+	token, err := decoder.Token()
+	if err != nil {
+		return ""
+	}
+	id, _ := token.(string)
+	return id
+}
+
+// skipJSONValue reads the next value from decoder, token by token
+func skipJSONValue(decoder *json.Decoder) error {
+	depth := 0
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+
+		switch token {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+// inputLimitRejection returns the ToolError a call whose input exceeds the limits is rejected with
+func inputLimitRejection(err error) *ToolError {
+	return &ToolError{Code: InputLimitErrorCode, Message: err.Error(), Err: err}
+}
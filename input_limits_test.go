@@ -0,0 +1,130 @@
+package inferable
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckJSONLimits(t *testing.T) {
+	assert.NoError(t, checkJSONLimits([]byte(`{"a": [[1]], "b": "[[[[{{{{"}`), 100, 3, 0))
+	assert.ErrorContains(t, checkJSONLimits([]byte(`{"a": [[[1]]]}`), 100, 3, 0), "nested more than 3 levels")
+	assert.NoError(t, checkJSONLimits([]byte(`{"a": "\"[[[["}`), 100, 1, 0))
+	assert.ErrorContains(t, checkJSONLimits([]byte(`{"a": 1}`), 4, 3, 0), "exceeds the maximum of 4 bytes")
+
+	// Wrapping levels are allowed on top of the limit, which is reported as configured
+	assert.NoError(t, checkJSONLimits([]byte(`{"value": [[1]]}`), 100, 2, 1))
+	assert.EqualError(t, checkJSONLimits([]byte(`{"value": [[[1]]]}`), 100, 2, 1), "input is nested more than 2 levels deep")
+}
+
+func TestMessageCallID(t *testing.T) {
+	assert.Equal(t, "call-1", messageCallID(`{"value": {"id": "call-1", "targetArgs": "{}"}}`))
+	assert.Equal(t, "call-1", messageCallID(`{"type": {"a": [1, {"b": 2}]}, "value": {"service": "s", "id": "call-1"}}`))
+	assert.Equal(t, "", messageCallID(`{"value": {"targetArgs": {}}}`))
+	assert.Equal(t, "", messageCallID(`{"value": {"id": 1}}`))
+	assert.Equal(t, "", messageCallID(`[`))
+
+	// The ID is only looked for in the first bytes of the body
+	assert.Equal(t, "", messageCallID(`{"value": {"targetArgs": "`+strings.Repeat("a", envelopeAllowance)+`", "id": "call-1"}}`))
+}
+
+func TestInputLimits(t *testing.T) {
+	var mu sync.Mutex
+	persisted := map[string]persistedResult{}
+	machineErrors := []machineError{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/result"):
+			var result persistedResult
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&result))
+			persisted[r.URL.Path] = result
+		case r.URL.Path == "/machines/errors":
+			var report machineError
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&report))
+			machineErrors = append(machineErrors, report)
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	i, err := New(InferableOptions{
+		APIEndpoint: server.URL,
+		APISecret:   "test-secret",
+	})
+	require.NoError(t, err)
+
+	_, err = i.RegisterServiceWithOptions("invalid", ServiceOptions{MaxInputDepth: -1})
+	assert.Error(t, err)
+
+	service, err := i.RegisterServiceWithOptions("limited", ServiceOptions{MaxInputSize: 64, MaxInputDepth: 3})
+	require.NoError(t, err)
+
+	called := 0
+	type TestInput struct {
+		Items [][]int `json:"items"`
+		Text  string  `json:"text"`
+	}
+	require.NoError(t, service.RegisterFunc(Function{
+		Name: "Echo",
+		Func: func(input TestInput) int {
+			called++
+			return len(input.Items)
+		},
+	}))
+
+	call := func(id, input string) error {
+		encoded, err := json.Marshal(`{"value": ` + input + `}`)
+		require.NoError(t, err)
+		body := `{"value": {"id": "` + id + `", "service": "limited", "targetFn": "Echo", "targetArgs": ` + string(encoded) + `}}`
+		return service.handleMessage(&sqs.Message{Body: aws.String(body)}, time.Now())
+	}
+	// targetArgs may also be sent as an object, nesting the input as deeply in the body as the limits allow
+	callObject := func(id, input string) error {
+		body := `{"value": {"id": "` + id + `", "service": "limited", "targetFn": "Echo", "targetArgs": {"value": ` + input + `}}}`
+		return service.handleMessage(&sqs.Message{Body: aws.String(body)}, time.Now())
+	}
+
+	require.NoError(t, call("within", `{"items": [[1, 2]]}`))
+	require.NoError(t, call("deep", `{"items": [[[1]]]}`))
+	require.NoError(t, call("large", `{"text": "`+strings.Repeat("a", 100)+`"}`))
+	require.NoError(t, callObject("object-within", `{"items": [[1, 2]]}`))
+	require.NoError(t, callObject("object-deep", `{"items": [[[1]]]}`))
+
+	// Calls with bodies far beyond any accepted input are rejected without decoding the body, and their
+	// messages dropped, so that they are not delivered again
+	require.NoError(t, callObject("body", `{"text": "`+strings.Repeat("a", 10000)+`"}`))
+	require.NoError(t, callObject("body-deep", strings.Repeat("[", 20)+strings.Repeat("]", 20)))
+	// Bodies whose call ID is not found are dropped
+	body := `{"value": {"targetArgs": {"value": {"text": "` + strings.Repeat("a", 10000) + `"}}, "id": "late-id"}}`
+	require.NoError(t, service.handleMessage(&sqs.Message{Body: aws.String(body)}, time.Now()))
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	assert.Equal(t, 2, called)
+	assert.Equal(t, "resolution", persisted["/jobs/within/result"].ResultType)
+	assert.Equal(t, "resolution", persisted["/jobs/object-within/result"].ResultType)
+	for _, id := range []string{"deep", "large", "object-deep", "body", "body-deep"} {
+		result := persisted["/jobs/"+id+"/result"]
+		assert.Equal(t, "rejection", result.ResultType)
+		assert.Contains(t, result.Result, InputLimitErrorCode)
+	}
+	assert.NotContains(t, persisted, "/jobs/late-id/result")
+	require.Len(t, machineErrors, 3)
+	assert.Equal(t, MachineErrorMessageRejected, machineErrors[0].Kind)
+	assert.Equal(t, "limited", machineErrors[0].Service)
+	assert.Equal(t, "body", machineErrors[0].CallID)
+	assert.Equal(t, "", machineErrors[2].CallID)
+}
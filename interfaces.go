@@ -0,0 +1,36 @@
+package inferable
+
+import "context"
+
+// The interfaces below are small subsets of the SDK, so that applications can mock it in their own unit tests.
+
+// Registrar registers functions and runs them. It is implemented by *Service.
+type Registrar interface {
+	RegisterFunc(fn Function) error
+	Start() error
+	Stop()
+}
+
+// FunctionRegistrar registers functions. It is implemented by *Service and *Scope.
+type FunctionRegistrar interface {
+	RegisterFunc(fn Function) error
+}
+
+// Invoker executes functions in the cluster. It is implemented by *Inferable.
+type Invoker interface {
+	Call(ctx context.Context, input CallInput, out interface{}) error
+	CallAsync(ctx context.Context, input CallInput) (*JobHandle, error)
+}
+
+// Runner creates agent runs in the cluster. It is implemented by *Inferable.
+type Runner interface {
+	CreateRun(input CreateRunInput) (*Run, error)
+}
+
+var (
+	_ Registrar         = (*Service)(nil)
+	_ FunctionRegistrar = (*Service)(nil)
+	_ FunctionRegistrar = (*Scope)(nil)
+	_ Invoker           = (*Inferable)(nil)
+	_ Runner            = (*Inferable)(nil)
+)
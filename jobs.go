@@ -0,0 +1,175 @@
+package inferable
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	JobStatusPending   = "pending"
+	JobStatusRunning   = "running"
+	JobStatusSuccess   = "success"
+	JobStatusFailure   = "failure"
+	JobStatusCancelled = "cancelled"
+)
+
+// DefaultJobPollInterval is how often JobHandle.Wait polls for the status of the job
+const DefaultJobPollInterval = 1 * time.Second
+
+// JobHandle references a call executed asynchronously with CallAsync
+type JobHandle struct {
+	ID       string
+	Service  string
+	Function string
+	// PollInterval is how often Wait polls for the status of the job. Defaults to DefaultJobPollInterval.
+	PollInterval time.Duration
+	inferable    *Inferable
+}
+
+// JobStatus is the status of a job, including its result once it has completed
+type JobStatus struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	// ResultType is either "resolution" or "rejection" once the job has completed
+	ResultType string          `json:"resultType,omitempty"`
+	Result     json.RawMessage `json:"result,omitempty"`
+}
+
+// Done reports whether the job has reached a terminal status
+func (s *JobStatus) Done() bool {
+	switch s.Status {
+	case JobStatusSuccess, JobStatusFailure, JobStatusCancelled:
+		return true
+	}
+
+	return s.ResultType != ""
+}
+
+// Decode unmarshals the result of the job into out.
+// If the function rejected the call, a *CallRejectedError is returned.
+func (s *JobStatus) Decode(out interface{}) error {
+	return s.decode("", "", out)
+}
+
+func (s *JobStatus) decode(service, function string, out interface{}) error {
+	value := unwrapResultValue(s.Result)
+
+	if s.ResultType == "rejection" {
+		return &CallRejectedError{Service: service, Function: function, Value: value}
+	}
+
+	if out == nil || len(value) == 0 {
+		return nil
+	}
+
+	if err := json.Unmarshal(value, out); err != nil {
+		return fmt.Errorf("failed to unmarshal call result: %v", err)
+	}
+
+	return nil
+}
+
+// CallAsync executes a function in the cluster without waiting for its result.
+// The returned handle can be used to check on, wait for or cancel the job.
+func (i *Inferable) CallAsync(ctx context.Context, input CallInput) (*JobHandle, error) {
+	if i.clusterID == "" {
+		return nil, fmt.Errorf("cluster ID must be provided to execute calls")
+	}
+
+	jsonPayload, err := input.payload()
+	if err != nil {
+		return nil, err
+	}
+
+	responseData, err := i.FetchData(FetchDataOptions{
+		Path:    fmt.Sprintf("/clusters/%s/calls", i.clusterID),
+		Method:  "POST",
+		Body:    string(jsonPayload),
+		Context: ctx,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create call: %w", err)
+	}
+
+	var response struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(responseData, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse call response: %v", err)
+	}
+
+	if response.ID == "" {
+		return nil, fmt.Errorf("call response did not include an ID")
+	}
+
+	return &JobHandle{
+		ID:        response.ID,
+		Service:   input.Service,
+		Function:  input.Function,
+		inferable: i,
+	}, nil
+}
+
+// Status fetches the current status of the job
+func (h *JobHandle) Status(ctx context.Context) (*JobStatus, error) {
+	responseData, err := h.inferable.FetchData(FetchDataOptions{
+		Path:    fmt.Sprintf("/clusters/%s/calls/%s", h.inferable.clusterID, h.ID),
+		Method:  "GET",
+		Context: ctx,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get status of call '%s': %w", h.ID, err)
+	}
+
+	status := &JobStatus{}
+	if err := json.Unmarshal(responseData, status); err != nil {
+		return nil, fmt.Errorf("failed to parse call status: %v", err)
+	}
+
+	return status, nil
+}
+
+// Wait polls the status of the job until it has completed or ctx is done.
+// The result can be decoded from the returned status with Decode.
+func (h *JobHandle) Wait(ctx context.Context) (*JobStatus, error) {
+	interval := h.PollInterval
+	if interval == 0 {
+		interval = DefaultJobPollInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		status, err := h.Status(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		if status.Done() {
+			return status, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("waiting for call '%s': %w", h.ID, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// Cancel requests cancellation of the job. Jobs which have already completed are not affected.
+func (h *JobHandle) Cancel(ctx context.Context) error {
+	_, err := h.inferable.FetchData(FetchDataOptions{
+		Path:    fmt.Sprintf("/clusters/%s/calls/%s/cancel", h.inferable.clusterID, h.ID),
+		Method:  "POST",
+		Context: ctx,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to cancel call '%s': %w", h.ID, err)
+	}
+
+	return nil
+}
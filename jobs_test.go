@@ -0,0 +1,66 @@
+package inferable
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCallAsync(t *testing.T) {
+	var polls atomic.Int32
+	var cancelled atomic.Bool
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/clusters/test-cluster/calls":
+			w.Write([]byte(`{"id": "call-1"}`))
+		case r.Method == "GET" && r.URL.Path == "/clusters/test-cluster/calls/call-1":
+			if polls.Add(1) < 3 {
+				w.Write([]byte(`{"id": "call-1", "status": "running"}`))
+				return
+			}
+			w.Write([]byte(`{"id": "call-1", "status": "success", "resultType": "resolution", "result": {"value": {"total": 42}}}`))
+		case r.Method == "POST" && r.URL.Path == "/clusters/test-cluster/calls/call-1/cancel":
+			cancelled.Store(true)
+			w.Write([]byte(`{}`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer server.Close()
+
+	i, err := New(InferableOptions{
+		APIEndpoint: server.URL,
+		APISecret:   "test-secret",
+		ClusterID:   "test-cluster",
+	})
+	require.NoError(t, err)
+
+	job, err := i.CallAsync(context.Background(), CallInput{Service: "default", Function: "sum"})
+	require.NoError(t, err)
+	assert.Equal(t, "call-1", job.ID)
+
+	status, err := job.Status(context.Background())
+	require.NoError(t, err)
+	assert.False(t, status.Done())
+
+	job.PollInterval = 10 * time.Millisecond
+	status, err = job.Wait(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, JobStatusSuccess, status.Status)
+
+	var out struct {
+		Total int `json:"total"`
+	}
+	require.NoError(t, status.Decode(&out))
+	assert.Equal(t, 42, out.Total)
+
+	require.NoError(t, job.Cancel(context.Background()))
+	assert.True(t, cancelled.Load())
+}
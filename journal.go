@@ -0,0 +1,117 @@
+package inferable
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+// InterruptedErrorCode is the code of the ToolError calls are rejected with when the machine handling
+// them stopped before they finished, see InferableOptions.JournalDir
+const InterruptedErrorCode = "MACHINE_INTERRUPTED"
+
+// journalDir returns the directory in which the acknowledged calls of the service are journaled,
+// or "" if journaling is disabled. It is keyed by the machine ID, so that machines sharing a journal
+// directory do not reject each other's calls.
+func (s *Service) journalDir() string {
+	if s.inferable.journalDir == "" {
+		return ""
+	}
+
+	return filepath.Join(s.inferable.journalDir, url.PathEscape(s.inferable.machineID), url.PathEscape(s.Name))
+}
+
+// journalCall records that a call was acknowledged and is being handled
+func (s *Service) journalCall(callID string) {
+	dir := s.journalDir()
+	if dir == "" {
+		return
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		s.inferable.logf(LogLevelError, "Failed to create journal directory %s: %v", dir, err)
+		return
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, url.PathEscape(callID)), nil, 0o644); err != nil {
+		s.inferable.logf(LogLevelError, "Failed to journal call '%s': %v", callID, err)
+	}
+}
+
+// finishJournaledCall removes a call from the journal once it has been handled
+func (s *Service) finishJournaledCall(callID string) {
+	dir := s.journalDir()
+	if dir == "" {
+		return
+	}
+
+	if err := os.Remove(filepath.Join(dir, url.PathEscape(callID))); err != nil && !os.IsNotExist(err) {
+		s.inferable.logf(LogLevelError, "Failed to remove call '%s' from the journal: %v", callID, err)
+	}
+}
+
+// recoverInterruptedCalls recovers the journaled calls when the service is first started by this process.
+// Calls journaled afterwards are being handled by this process, even once the service was restarted.
+func (s *Service) recoverInterruptedCalls(ctx context.Context) {
+	s.journalRecovery.Do(func() {
+		s.recoverJournaledCalls(ctx)
+	})
+}
+
+// recoverJournaledCalls rejects the calls which were acknowledged but not finished when the machine
+// last stopped, so that the cluster can retry them instead of waiting for them to stall
+func (s *Service) recoverJournaledCalls(ctx context.Context) {
+	dir := s.journalDir()
+	if dir == "" {
+		return
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			s.inferable.logf(LogLevelError, "Failed to read journal directory %s: %v", dir, err)
+		}
+		return
+	}
+
+	rejection, err := rejectionResult(&ToolError{
+		Code:      InterruptedErrorCode,
+		Message:   "the machine handling the call stopped before it finished",
+		Retryable: true,
+	})
+	if err != nil {
+		s.inferable.logf(LogLevelError, "Failed to prepare rejection of interrupted calls: %v", err)
+		return
+	}
+
+	for _, entry := range entries {
+		callID, err := url.PathUnescape(entry.Name())
+		if err != nil {
+			continue
+		}
+
+		meta := resultMetadata{MachineID: s.inferable.machineID, SDKVersion: Version}
+		if err := s.persistJobResult(ctx, callID, rejection, meta); err != nil {
+			// The call remains journaled, so that it is rejected when the machine starts again
+			s.inferable.logf(LogLevelError, "Failed to reject interrupted call '%s': %v", callID, err)
+			continue
+		}
+
+		s.inferable.logf(LogLevelInfo, "Rejected call '%s', which was interrupted when the machine last stopped", callID)
+		s.finishJournaledCall(callID)
+	}
+}
+
+func validateJournalDir(dir string) error {
+	if dir == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create journal directory %s: %v", dir, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package inferable
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJournal(t *testing.T) {
+	var mu sync.Mutex
+	persisted := map[string]persistedResult{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/jobs/"), "/result"); ok {
+			var result persistedResult
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&result))
+			mu.Lock()
+			persisted[id] = result
+			mu.Unlock()
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	i, err := New(InferableOptions{
+		APIEndpoint: server.URL,
+		APISecret:   "test-secret",
+		MachineID:   "machine-1",
+		JournalDir:  dir,
+	})
+	require.NoError(t, err)
+
+	journaled := false
+	type TestInput struct{}
+	require.NoError(t, i.Default.RegisterFunc(Function{
+		Name: "TestFunc",
+		Func: func(input TestInput) int {
+			_, err := os.Stat(filepath.Join(dir, "machine-1", "default", "call-1"))
+			journaled = err == nil
+			return 1
+		},
+	}))
+
+	// Calls are journaled while they are handled
+	body := `{"value": {"id": "call-1", "service": "default", "targetFn": "TestFunc", "targetArgs": "{\"value\": {}}"}}`
+	require.NoError(t, i.Default.handleMessage(&sqs.Message{Body: aws.String(body)}, time.Now()))
+	assert.True(t, journaled)
+	_, err = os.Stat(filepath.Join(dir, "machine-1", "default", "call-1"))
+	assert.True(t, os.IsNotExist(err))
+
+	// Calls left in the journal by a crash are rejected as retryable on the next start, while the calls
+	// of other machines sharing the journal directory are left alone
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "machine-1", "default", "call-2"), nil, 0o644))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "machine-2", "default"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "machine-2", "default", "call-3"), nil, 0o644))
+	i.Default.recoverInterruptedCalls(context.Background())
+
+	mu.Lock()
+	result, ok := persisted["call-2"]
+	mu.Unlock()
+	require.True(t, ok)
+	assert.Equal(t, "rejection", result.ResultType)
+	var rejection struct {
+		Value ToolError `json:"value"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(result.Result), &rejection))
+	assert.Equal(t, InterruptedErrorCode, rejection.Value.Code)
+	assert.True(t, rejection.Value.Retryable)
+
+	entries, err := os.ReadDir(filepath.Join(dir, "machine-1", "default"))
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+	_, ok = persisted["call-3"]
+	assert.False(t, ok)
+
+	// Calls journaled by this process are not rejected when the service is started again
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "machine-1", "default", "call-4"), nil, 0o644))
+	i.Default.recoverInterruptedCalls(context.Background())
+	mu.Lock()
+	_, ok = persisted["call-4"]
+	mu.Unlock()
+	assert.False(t, ok)
+}
+
+func TestJournalMachineID(t *testing.T) {
+	t.Setenv(MachineIDEnvVar, "")
+	dir := filepath.Join(t.TempDir(), "journal")
+
+	// Without a configured machine ID, restarts keep the ID persisted in the journal directory, so that
+	// the calls they journaled are recovered
+	first, err := New(InferableOptions{APIEndpoint: DefaultAPIEndpoint, APISecret: "test-secret", JournalDir: dir})
+	require.NoError(t, err)
+	second, err := New(InferableOptions{APIEndpoint: DefaultAPIEndpoint, APISecret: "test-secret", JournalDir: dir})
+	require.NoError(t, err)
+	assert.Equal(t, first.MachineID(), second.MachineID())
+	_, err = os.Stat(filepath.Join(dir, MachineIDFile))
+	assert.NoError(t, err)
+
+	configured, err := New(InferableOptions{APIEndpoint: DefaultAPIEndpoint, APISecret: "test-secret", JournalDir: dir, MachineID: "machine-1"})
+	require.NoError(t, err)
+	assert.Equal(t, "machine-1", configured.MachineID())
+}
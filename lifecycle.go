@@ -0,0 +1,231 @@
+package inferable
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// DefaultShutdownGracePeriod is how long Run waits for calls in flight to finish on shutdown.
+// It is shorter than the default termination grace period of Kubernetes pods (30 seconds).
+const DefaultShutdownGracePeriod = 25 * time.Second
+
+// drainPollInterval is how often Drain checks whether the calls in flight have finished
+const drainPollInterval = 50 * time.Millisecond
+
+// RunOptions configures Inferable.Run
+type RunOptions struct {
+	// GracePeriod is how long calls in flight may take to finish once shutdown was requested.
+	// Defaults to DefaultShutdownGracePeriod.
+	GracePeriod time.Duration
+	// Signals request a shutdown. Defaults to SIGTERM and SIGINT.
+	Signals []os.Signal
+}
+
+// Run starts every service with functions and blocks until ctx is done, a termination signal is received
+// or a service fails (see Service.Err). The services are then drained: they stop polling, and calls in flight are given the grace period to finish.
+// The returned error joins the errors of all services, and is nil after a clean shutdown.
+func (i *Inferable) Run(ctx context.Context) error {
+	return i.RunWithOptions(ctx, RunOptions{})
+}
+
+// RunWithOptions is Run with options
+func (i *Inferable) RunWithOptions(ctx context.Context, options RunOptions) error {
+	signals := options.Signals
+	if len(signals) == 0 {
+		signals = []os.Signal{syscall.SIGTERM, os.Interrupt}
+	}
+	gracePeriod := options.GracePeriod
+	if gracePeriod <= 0 {
+		gracePeriod = DefaultShutdownGracePeriod
+	}
+
+	services := i.runnableServices()
+	if len(services) == 0 {
+		return fmt.Errorf("no services with registered functions to run")
+	}
+
+	var started []*Service
+	for _, service := range services {
+		if err := service.Start(); err != nil {
+			for _, s := range started {
+				s.Stop()
+			}
+			return fmt.Errorf("failed to start service '%s': %w", service.Name, err)
+		}
+		started = append(started, service)
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, signals...)
+	defer stop()
+
+	// A service whose poll loop failed shuts down the others
+	failed := make(chan *Service, len(started))
+	for _, service := range started {
+		go func(service *Service) {
+			select {
+			case <-service.Done():
+				if service.Err() != nil {
+					failed <- service
+				}
+			case <-ctx.Done():
+			}
+		}(service)
+	}
+
+	select {
+	case <-ctx.Done():
+		i.logf(LogLevelInfo, "Shutting down, draining %d services within %s", len(started), gracePeriod)
+	case service := <-failed:
+		i.logf(LogLevelError, "Shutting down after service '%s' failed: %v", service.Name, service.Err())
+	}
+
+	drainCtx, cancel := context.WithTimeout(context.Background(), gracePeriod)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(started))
+	for idx, service := range started {
+		wg.Add(1)
+		go func(idx int, service *Service) {
+			defer wg.Done()
+			errs[idx] = service.Drain(drainCtx)
+		}(idx, service)
+	}
+	wg.Wait()
+
+	for _, service := range started {
+		errs = append(errs, service.Err())
+	}
+
+	return errors.Join(errs...)
+}
+
+// runnableServices returns the services with registered functions, ordered by name
+func (i *Inferable) runnableServices() []*Service {
+	services := []*Service{}
+	for _, service := range i.functionRegistry.list() {
+		if len(service.functions()) > 0 {
+			services = append(services, service)
+		}
+	}
+
+	return services
+}
+
+// Drain stops polling for new calls and waits for the calls in flight to finish and their results
+// to be persisted, until ctx is done. The service is then stopped, canceling any calls left.
+func (s *Service) Drain(ctx context.Context) error {
+	s.draining.Store(true)
+
+	s.consumerMu.Lock()
+	consumer, cancelConsumer, exited := s.consumer, s.consumerCancel, s.consumerExited
+	s.consumerMu.Unlock()
+	if stopper, ok := consumer.(pollStopper); ok {
+		// The poll loop keeps its context, so that the calls it received are handled, their visibility
+		// extended and their batched results submitted before it exits
+		stopper.StopPolling()
+	} else if cancelConsumer != nil {
+		cancelConsumer()
+	}
+
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+
+	var err error
+	for s.inFlight.Load() > 0 || s.PendingPersists() > 0 || (exited != nil && !isClosed(exited)) {
+		select {
+		case <-ctx.Done():
+			err = fmt.Errorf("service '%s' did not drain in time: %d calls in flight, %d results pending", s.Name, s.inFlight.Load(), s.PendingPersists())
+		case <-ticker.C:
+			continue
+		}
+		break
+	}
+
+	s.Stop()
+	return err
+}
+
+// Done returns a channel which is closed when the service stops, either because Stop was called or
+// because the poll loop failed. Err then reports the failure.
+func (s *Service) Done() <-chan struct{} {
+	s.lifecycleMu.Lock()
+	defer s.lifecycleMu.Unlock()
+
+	if s.done == nil {
+		s.done = make(chan struct{})
+	}
+
+	return s.done
+}
+
+// Err returns the error which stopped the service, or nil if it is running or was stopped with Stop
+func (s *Service) Err() error {
+	s.lifecycleMu.Lock()
+	defer s.lifecycleMu.Unlock()
+
+	return s.err
+}
+
+// resetDone prepares Done and Err for the service being started
+func (s *Service) resetDone() {
+	s.lifecycleMu.Lock()
+	defer s.lifecycleMu.Unlock()
+
+	if s.done == nil || isClosed(s.done) {
+		s.done = make(chan struct{})
+	}
+	s.err = nil
+}
+
+// closeDone marks the service as stopped because of err, which is nil if it was stopped intentionally.
+// Only the first call after the service was started has an effect.
+func (s *Service) closeDone(err error) {
+	s.lifecycleMu.Lock()
+	defer s.lifecycleMu.Unlock()
+
+	if s.done == nil {
+		s.done = make(chan struct{})
+	}
+	if isClosed(s.done) {
+		return
+	}
+
+	s.err = err
+	close(s.done)
+}
+
+func isClosed(ch chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+// Pause stops the service from polling for new calls, e.g. to quiesce the machine during a deploy or an
+// incident. The service stays registered, and calls already received are handled. See Resume.
+func (s *Service) Pause() {
+	if !s.paused.Swap(true) {
+		s.inferable.logf(LogLevelInfo, "Service '%s' paused", s.Name)
+	}
+}
+
+// Resume makes a paused service poll for new calls again
+func (s *Service) Resume() {
+	if s.paused.Swap(false) {
+		s.inferable.logf(LogLevelInfo, "Service '%s' resumed", s.Name)
+	}
+}
+
+// IsPaused reports whether the service was paused with Pause
+func (s *Service) IsPaused() bool {
+	return s.paused.Load()
+}
@@ -0,0 +1,258 @@
+package inferable
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunDrainsOnShutdown(t *testing.T) {
+	var received, persisted atomic.Int32
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Header.Get("X-Amz-Target") == "AmazonSQS.ReceiveMessage":
+			messages := []map[string]string{}
+			if received.Add(1) == 1 {
+				body := `{"value": {"id": "call-1", "service": "default", "targetFn": "Slow", "targetArgs": "{\"value\": {}}"}}`
+				sum := md5.Sum([]byte(body))
+				messages = append(messages, map[string]string{
+					"MessageId": "msg-1", "ReceiptHandle": "msg-1", "Body": body, "MD5OfBody": hex.EncodeToString(sum[:]),
+				})
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"Messages": messages})
+		case r.URL.Path == "/machines":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"queueUrl": server.URL + "/queue",
+				"region":   "us-east-1",
+				"credentials": map[string]string{
+					"accessKeyId": "key", "secretAccessKey": "secret", "sessionToken": "token",
+				},
+			})
+		case r.URL.Path == "/jobs/call-1/result":
+			persisted.Add(1)
+			w.Write([]byte(`{}`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer server.Close()
+
+	i, err := New(InferableOptions{
+		APIEndpoint:   server.URL,
+		APISecret:     "test-secret",
+		QueueEndpoint: server.URL,
+	})
+	require.NoError(t, err)
+
+	started := make(chan struct{})
+	type TestInput struct{}
+	require.NoError(t, i.Default.RegisterFunc(Function{
+		Name: "Slow",
+		Func: func(input TestInput) string {
+			close(started)
+			time.Sleep(200 * time.Millisecond)
+			return "done"
+		},
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() { errs <- i.RunWithOptions(ctx, RunOptions{GracePeriod: 5 * time.Second}) }()
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("call was not received")
+	}
+	cancel()
+
+	select {
+	case err := <-errs:
+		require.NoError(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("Run did not return")
+	}
+
+	// The call in flight finished and its result was persisted before Run returned
+	assert.Equal(t, int32(1), persisted.Load())
+	assert.False(t, i.Default.IsRegistered())
+}
+
+func TestDrainSubmitsBatchedResults(t *testing.T) {
+	var mu sync.Mutex
+	requests := map[string]int{}
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		action := r.Header.Get("X-Amz-Target")
+		mu.Lock()
+		requests[action+r.URL.Path]++
+		first := requests[action+r.URL.Path] == 1
+		mu.Unlock()
+
+		switch {
+		case action == "AmazonSQS.ReceiveMessage":
+			messages := []map[string]string{}
+			for idx := 1; first && idx <= 2; idx++ {
+				body := fmt.Sprintf(`{"value": {"id": "call-%d", "service": "drained", "targetFn": "Slow", "targetArgs": "{\"value\": {}}"}}`, idx)
+				sum := md5.Sum([]byte(body))
+				messages = append(messages, map[string]string{
+					"MessageId": fmt.Sprint(idx), "ReceiptHandle": fmt.Sprint(idx), "Body": body, "MD5OfBody": hex.EncodeToString(sum[:]),
+				})
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"Messages": messages})
+		case r.URL.Path == "/machines":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"queueUrl": server.URL + "/queue",
+				"region":   "us-east-1",
+				"credentials": map[string]string{
+					"accessKeyId": "key", "secretAccessKey": "secret", "sessionToken": "token",
+				},
+			})
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer server.Close()
+
+	i, err := New(InferableOptions{
+		APIEndpoint:   server.URL,
+		APISecret:     "test-secret",
+		QueueEndpoint: server.URL,
+	})
+	require.NoError(t, err)
+
+	service, err := i.RegisterServiceWithOptions("drained", ServiceOptions{BatchResults: true, WaitTime: -1})
+	require.NoError(t, err)
+
+	started := make(chan struct{}, 2)
+	type TestInput struct{}
+	require.NoError(t, service.RegisterFunc(Function{
+		Name: "Slow",
+		Func: func(input TestInput) string {
+			started <- struct{}{}
+			time.Sleep(200 * time.Millisecond)
+			return "done"
+		},
+	}))
+	require.NoError(t, service.Start())
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("call was not received")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, service.Drain(ctx))
+
+	// The calls received before the drain were handled, and their results submitted before their messages were deleted
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 1, requests["/jobs/results"])
+	assert.Equal(t, 2, requests["AmazonSQS.DeleteMessage/"])
+}
+
+func TestDrainTimeout(t *testing.T) {
+	i, err := New(InferableOptions{
+		APIEndpoint: DefaultAPIEndpoint,
+		APISecret:   "test-secret",
+	})
+	require.NoError(t, err)
+
+	i.Default.inFlight.Add(1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorContains(t, i.Default.Drain(ctx), "did not drain in time")
+}
+
+func TestServiceErr(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Amz-Target") == "AmazonSQS.ReceiveMessage" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type": "com.amazonaws.sqs#QueueDoesNotExist", "message": "queue does not exist"}`))
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	i, err := New(InferableOptions{
+		APIEndpoint:   server.URL,
+		APISecret:     "test-secret",
+		QueueEndpoint: server.URL,
+	})
+	require.NoError(t, err)
+
+	type TestInput struct{}
+	require.NoError(t, i.Default.RegisterFunc(Function{Name: "TestFunc", Func: func(input TestInput) int { return 1 }}))
+
+	service := i.Default
+	service.region = "us-east-1"
+	service.queueURL = server.URL + "/queue"
+	service.resetDone()
+	service.ctx, service.cancel = context.WithCancel(context.Background())
+
+	consumer, err := service.newConsumer()
+	require.NoError(t, err)
+	service.runConsumer(consumer)
+
+	select {
+	case <-service.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("service did not stop")
+	}
+	assert.ErrorContains(t, service.Err(), "poll loop of service 'default' failed")
+
+	// Stopping intentionally reports no error
+	service.resetDone()
+	service.Stop()
+	<-service.Done()
+	assert.NoError(t, service.Err())
+}
+
+func TestConcurrentRegistration(t *testing.T) {
+	i, err := New(InferableOptions{
+		APIEndpoint: DefaultAPIEndpoint,
+		APISecret:   "test-secret",
+	})
+	require.NoError(t, err)
+
+	type TestInput struct{}
+	var wg sync.WaitGroup
+	var registered atomic.Int32
+	for n := 0; n < 8; n++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			service, err := i.RegisterService(fmt.Sprintf("service-%d", n%4))
+			if err != nil {
+				service, _ = i.functionRegistry.get(fmt.Sprintf("service-%d", n%4))
+			}
+			if service.RegisterFunc(Function{Name: "TestFunc", Func: func(input TestInput) int { return 1 }}) == nil {
+				registered.Add(1)
+			}
+			service.EffectiveConfig()
+			service.Stop()
+			_, _ = i.ToJSONDefinition()
+		}(n)
+	}
+	wg.Wait()
+
+	// Each service registered its function exactly once
+	assert.Equal(t, int32(4), registered.Load())
+	assert.Len(t, i.runnableServices(), 4)
+}
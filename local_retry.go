@@ -0,0 +1,121 @@
+package inferable
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+const (
+	// DefaultLocalRetryAttempts is how often a function is executed when LocalRetryPolicy.MaxAttempts is not set
+	DefaultLocalRetryAttempts = 3
+	// DefaultLocalRetryBackoff is the delay before the first local retry when LocalRetryPolicy.Backoff is not set
+	DefaultLocalRetryBackoff = 100 * time.Millisecond
+	// DefaultLocalRetryMaxBackoff caps the delay between local retries when LocalRetryPolicy.MaxBackoff is not set
+	DefaultLocalRetryMaxBackoff = 5 * time.Second
+)
+
+// LocalRetryPolicy executes a function again on the same machine when it fails transiently, before the
+// error rejects the call (or, for errors marked Retryable, dispatches it again through the cluster).
+// It suits functions calling flaky downstream APIs.
+type LocalRetryPolicy struct {
+	// MaxAttempts is how often the function is executed, including the first attempt.
+	// Defaults to DefaultLocalRetryAttempts.
+	MaxAttempts int
+	// Backoff is the delay before the first retry, doubled for each following retry up to MaxBackoff.
+	// Defaults to DefaultLocalRetryBackoff and DefaultLocalRetryMaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// RetryOn reports whether an error returned by the function is transient. By default, errors marked
+	// Retryable, ToolErrors marked as retryable and expired contexts are retried.
+	RetryOn func(err error) bool
+}
+
+func (p *LocalRetryPolicy) validate() error {
+	if p.MaxAttempts < 0 || p.Backoff < 0 || p.MaxBackoff < 0 {
+		return fmt.Errorf("attempts and backoff must not be negative")
+	}
+
+	return nil
+}
+
+func (p *LocalRetryPolicy) maxAttempts() int {
+	if p.MaxAttempts == 0 {
+		return DefaultLocalRetryAttempts
+	}
+
+	return p.MaxAttempts
+}
+
+func (p *LocalRetryPolicy) backoff(retry int) time.Duration {
+	backoff, maxBackoff := p.Backoff, p.MaxBackoff
+	if backoff == 0 {
+		backoff = DefaultLocalRetryBackoff
+	}
+	if maxBackoff == 0 {
+		maxBackoff = DefaultLocalRetryMaxBackoff
+	}
+
+	for n := 1; n < retry && backoff < maxBackoff; n++ {
+		backoff *= 2
+	}
+	if backoff > maxBackoff {
+		return maxBackoff
+	}
+
+	return backoff
+}
+
+func (p *LocalRetryPolicy) retryOn(err error) bool {
+	if p.RetryOn != nil {
+		return p.RetryOn(err)
+	}
+
+	var toolErr *ToolError
+	if errors.As(err, &toolErr) {
+		return toolErr.Retryable
+	}
+
+	var retryable *RetryableError
+	return errors.As(err, &retryable) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// callError returns the error returned by a function, or nil if it succeeded
+func callError(returnValues []reflect.Value) error {
+	if len(returnValues) == 0 {
+		return nil
+	}
+
+	err, _ := returnValues[len(returnValues)-1].Interface().(error)
+	return err
+}
+
+// callWithLocalRetry calls the function, executing it again according to FunctionConfig.LocalRetry
+// while it fails transiently
+func (s *Service) callWithLocalRetry(ctx context.Context, call CallInfo, fn Function, compiled *compiledFunction, arg reflect.Value) []reflect.Value {
+	returnValues := compiled.call(ctx, arg)
+
+	policy := fn.Config.LocalRetry
+	if policy == nil {
+		return returnValues
+	}
+
+	for attempt := 1; attempt < policy.maxAttempts(); attempt++ {
+		err := callError(returnValues)
+		if err == nil || !policy.retryOn(err) {
+			break
+		}
+
+		delay := policy.backoff(attempt)
+		s.inferable.logf(LogLevelDebug, "Call '%s' to '%s' failed on local attempt %d, retrying in %s: %v", call.ID, fn.Name, attempt, delay, err)
+		if !sleepContext(ctx, delay) {
+			break
+		}
+
+		returnValues = compiled.call(ctx, arg)
+	}
+
+	return returnValues
+}
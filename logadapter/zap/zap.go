@@ -0,0 +1,55 @@
+// Package zap forwards the SDK logs to a zap logger, with their fields as structured context:
+//
+//	import inferablezap "github.com/inferablehq/inferable-go/logadapter/zap"
+//
+//	client, err := inferable.New(inferable.InferableOptions{
+//		APISecret: secret,
+//		Logger:    inferablezap.New(zapLogger.Sugar()),
+//	})
+//
+// The package depends on the methods of *zap.SugaredLogger only, so it does not add zap to the
+// dependencies of the SDK.
+package zap
+
+import (
+	"sort"
+
+	inferable "github.com/inferablehq/inferable-go"
+)
+
+// SugaredLogger is implemented by *zap.SugaredLogger
+type SugaredLogger interface {
+	Debugw(msg string, keysAndValues ...interface{})
+	Infow(msg string, keysAndValues ...interface{})
+	Errorw(msg string, keysAndValues ...interface{})
+}
+
+type logger struct {
+	sugared SugaredLogger
+}
+
+// New returns an inferable.Logger which writes to sugared
+func New(sugared SugaredLogger) inferable.Logger {
+	return logger{sugared: sugared}
+}
+
+func (l logger) Log(level inferable.LogLevel, msg string, fields map[string]interface{}) {
+	keysAndValues := make([]interface{}, 0, 2*len(fields))
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		keysAndValues = append(keysAndValues, key, fields[key])
+	}
+
+	switch level {
+	case inferable.LogLevelDebug:
+		l.sugared.Debugw(msg, keysAndValues...)
+	case inferable.LogLevelError:
+		l.sugared.Errorw(msg, keysAndValues...)
+	default:
+		l.sugared.Infow(msg, keysAndValues...)
+	}
+}
@@ -0,0 +1,45 @@
+package zap
+
+import (
+	"fmt"
+	"testing"
+
+	inferable "github.com/inferablehq/inferable-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSugaredLogger records logs like a *zap.SugaredLogger writing to an observer
+type fakeSugaredLogger struct {
+	logs []string
+}
+
+func (l *fakeSugaredLogger) record(level, msg string, keysAndValues []interface{}) {
+	l.logs = append(l.logs, fmt.Sprintf("%s %s %v", level, msg, keysAndValues))
+}
+
+func (l *fakeSugaredLogger) Debugw(msg string, keysAndValues ...interface{}) {
+	l.record("debug", msg, keysAndValues)
+}
+
+func (l *fakeSugaredLogger) Infow(msg string, keysAndValues ...interface{}) {
+	l.record("info", msg, keysAndValues)
+}
+
+func (l *fakeSugaredLogger) Errorw(msg string, keysAndValues ...interface{}) {
+	l.record("error", msg, keysAndValues)
+}
+
+func TestLogger(t *testing.T) {
+	sugared := &fakeSugaredLogger{}
+	logger := New(sugared)
+
+	logger.Log(inferable.LogLevelInfo, "Call finished", map[string]interface{}{"function": "Login", "duration": 12})
+	logger.Log(inferable.LogLevelDebug, "Polling", nil)
+	logger.Log(inferable.LogLevelError, "Failed", nil)
+
+	assert.Equal(t, []string{
+		"info Call finished [duration 12 function Login]",
+		"debug Polling []",
+		"error Failed []",
+	}, sugared.logs)
+}
@@ -0,0 +1,56 @@
+// Package zerolog forwards the SDK logs to a zerolog logger, with their fields as structured context:
+//
+//	import inferablezerolog "github.com/inferablehq/inferable-go/logadapter/zerolog"
+//
+//	logger := zerolog.New(os.Stderr).With().Timestamp().Logger()
+//	client, err := inferable.New(inferable.InferableOptions{
+//		APISecret: secret,
+//		Logger:    inferablezerolog.New(&logger),
+//	})
+//
+// The package depends on the methods of *zerolog.Logger and *zerolog.Event only, so it does not add
+// zerolog to the dependencies of the SDK.
+package zerolog
+
+import (
+	inferable "github.com/inferablehq/inferable-go"
+)
+
+// Event is implemented by *zerolog.Event
+type Event[E any] interface {
+	Fields(fields interface{}) E
+	Msg(msg string)
+}
+
+// LeveledLogger is implemented by *zerolog.Logger
+type LeveledLogger[E any] interface {
+	Debug() E
+	Info() E
+	Error() E
+}
+
+type logger[E Event[E]] struct {
+	leveled LeveledLogger[E]
+}
+
+// New returns an inferable.Logger which writes to leveled
+func New[E Event[E]](leveled LeveledLogger[E]) inferable.Logger {
+	return logger[E]{leveled: leveled}
+}
+
+func (l logger[E]) Log(level inferable.LogLevel, msg string, fields map[string]interface{}) {
+	var event E
+	switch level {
+	case inferable.LogLevelDebug:
+		event = l.leveled.Debug()
+	case inferable.LogLevelError:
+		event = l.leveled.Error()
+	default:
+		event = l.leveled.Info()
+	}
+
+	if len(fields) > 0 {
+		event = event.Fields(fields)
+	}
+	event.Msg(msg)
+}
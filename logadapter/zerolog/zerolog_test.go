@@ -0,0 +1,53 @@
+package zerolog
+
+import (
+	"testing"
+
+	inferable "github.com/inferablehq/inferable-go"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeLog struct {
+	level  string
+	msg    string
+	fields map[string]interface{}
+}
+
+// fakeEvent and fakeLogger mirror the chained API of *zerolog.Event and *zerolog.Logger
+type fakeEvent struct {
+	logger *fakeLogger
+	log    fakeLog
+}
+
+func (e *fakeEvent) Fields(fields interface{}) *fakeEvent {
+	e.log.fields = fields.(map[string]interface{})
+	return e
+}
+
+func (e *fakeEvent) Msg(msg string) {
+	e.log.msg = msg
+	e.logger.logs = append(e.logger.logs, e.log)
+}
+
+type fakeLogger struct {
+	logs []fakeLog
+}
+
+func (l *fakeLogger) Debug() *fakeEvent { return &fakeEvent{logger: l, log: fakeLog{level: "debug"}} }
+func (l *fakeLogger) Info() *fakeEvent  { return &fakeEvent{logger: l, log: fakeLog{level: "info"}} }
+func (l *fakeLogger) Error() *fakeEvent { return &fakeEvent{logger: l, log: fakeLog{level: "error"}} }
+
+func TestLogger(t *testing.T) {
+	leveled := &fakeLogger{}
+	logger := New(leveled)
+
+	logger.Log(inferable.LogLevelInfo, "Call finished", map[string]interface{}{"function": "Login"})
+	logger.Log(inferable.LogLevelDebug, "Polling", nil)
+	logger.Log(inferable.LogLevelError, "Failed", nil)
+
+	assert.Equal(t, []fakeLog{
+		{level: "info", msg: "Call finished", fields: map[string]interface{}{"function": "Login"}},
+		{level: "debug", msg: "Polling"},
+		{level: "error", msg: "Failed"},
+	}, leveled.logs)
+}
@@ -0,0 +1,108 @@
+package inferable
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// Logger receives the logs written by the SDK, e.g. to forward them to a structured logging library.
+// Fields carry the details of structured logs, such as the call lifecycle logs of ServiceOptions.CallLogging,
+// and are nil for other logs.
+type Logger interface {
+	Log(level LogLevel, msg string, fields map[string]interface{})
+}
+
+// stdLogger writes logs with the standard library logger, appending their fields as key=value pairs
+type stdLogger struct{}
+
+func (stdLogger) Log(level LogLevel, msg string, fields map[string]interface{}) {
+	if len(fields) == 0 {
+		log.Print(msg)
+		return
+	}
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(msg)
+	for _, key := range keys {
+		fmt.Fprintf(&b, " %s=%v", key, fields[key])
+	}
+	log.Print(b.String())
+}
+
+// leveledLogger forwards logs to the SDK logger, dropping those below the configured log level
+type leveledLogger struct {
+	inferable *Inferable
+}
+
+func (l leveledLogger) Log(level LogLevel, msg string, fields map[string]interface{}) {
+	l.inferable.logFields(level, msg, fields)
+}
+
+// LogLevel controls the verbosity of the logs written by the SDK
+type LogLevel string
+
+const (
+	LogLevelDebug LogLevel = "debug"
+	LogLevelInfo  LogLevel = "info"
+	LogLevelError LogLevel = "error"
+)
+
+func (l LogLevel) severity() (int32, error) {
+	switch l {
+	case LogLevelDebug:
+		return 0, nil
+	case LogLevelInfo, "":
+		return 1, nil
+	case LogLevelError:
+		return 2, nil
+	}
+
+	return 0, fmt.Errorf("unknown log level '%s'", l)
+}
+
+func (i *Inferable) setLogLevel(level LogLevel) error {
+	severity, err := level.severity()
+	if err != nil {
+		return err
+	}
+
+	i.logSeverity.Store(severity)
+	return nil
+}
+
+func (i *Inferable) logf(level LogLevel, format string, args ...interface{}) {
+	i.logFields(level, fmt.Sprintf(format, args...), nil)
+}
+
+// logFields writes a structured log to InferableOptions.Logger
+func (i *Inferable) logFields(level LogLevel, msg string, fields map[string]interface{}) {
+	severity, _ := level.severity()
+	if severity < i.logSeverity.Load() {
+		return
+	}
+
+	if i.logger == nil {
+		stdLogger{}.Log(level, msg, fields)
+		return
+	}
+
+	i.logger.Log(level, msg, fields)
+}
+
+func (i *Inferable) logLevel() LogLevel {
+	for _, level := range []LogLevel{LogLevelDebug, LogLevelInfo, LogLevelError} {
+		if severity, _ := level.severity(); severity == i.logSeverity.Load() {
+			return level
+		}
+	}
+
+	return LogLevelInfo
+}
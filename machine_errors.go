@@ -0,0 +1,110 @@
+package inferable
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// MachineErrorKind identifies an unrecoverable condition reported to the control plane,
+// so that the dashboard shows the machine as unhealthy
+type MachineErrorKind string
+
+const (
+	// MachineErrorRegistrationRejected is reported when the API rejected the functions of a service,
+	// e.g. because of an invalid schema
+	MachineErrorRegistrationRejected MachineErrorKind = "registration_rejected"
+	// MachineErrorPersistFailed is reported when a result could not be persisted after retrying
+	MachineErrorPersistFailed MachineErrorKind = "persist_failed"
+	// MachineErrorPanicStorm is reported when the functions of a service panicked panicStormThreshold
+	// times within panicStormWindow
+	MachineErrorPanicStorm MachineErrorKind = "panic_storm"
+	// MachineErrorMessageRejected is reported when a message was dropped because its body was far beyond the
+	// input limits, see ServiceOptions.MaxInputSize. The call it carried is not answered.
+	MachineErrorMessageRejected MachineErrorKind = "message_rejected"
+)
+
+const (
+	panicStormThreshold = 5
+	panicStormWindow    = time.Minute
+)
+
+// machineError is the payload of a machine error report
+type machineError struct {
+	Service string           `json:"service"`
+	Kind    MachineErrorKind `json:"kind"`
+	Message string           `json:"message"`
+	CallID  string           `json:"jobId,omitempty"`
+}
+
+// reportMachineError reports an unrecoverable condition of the service to the control plane. Reports are
+// best effort: failures are logged, and reports stop once the API turns out not to support them.
+func (s *Service) reportMachineError(kind MachineErrorKind, err error, callID string) {
+	if s.inferable.machineErrorsUnsupported.Load() {
+		return
+	}
+
+	body, marshalErr := json.Marshal(machineError{Service: s.Name, Kind: kind, Message: err.Error(), CallID: callID})
+	if marshalErr != nil {
+		s.inferable.logf(LogLevelError, "Failed to marshal machine error: %v", marshalErr)
+		return
+	}
+
+	headers := s.machineHeaders()
+	headers["Content-Type"] = "application/json"
+	_, fetchErr := s.inferable.FetchData(FetchDataOptions{
+		Path:    "/machines/errors",
+		Method:  "POST",
+		Headers: headers,
+		Body:    string(body),
+	})
+
+	var apiErr *APIError
+	switch {
+	case fetchErr == nil:
+		s.inferable.logf(LogLevelDebug, "Reported machine error '%s' of service '%s'", kind, s.Name)
+	case errors.As(fetchErr, &apiErr) && apiErr.StatusCode == http.StatusNotFound:
+		s.inferable.machineErrorsUnsupported.Store(true)
+		s.inferable.logf(LogLevelDebug, "Machine errors are not supported by the API")
+	default:
+		s.inferable.logf(LogLevelError, "Failed to report machine error '%s' of service '%s': %v", kind, s.Name, fetchErr)
+	}
+}
+
+// panicStorm detects bursts of panics, see MachineErrorPanicStorm
+type panicStorm struct {
+	mu     sync.Mutex
+	panics []time.Time
+}
+
+// record records a panic, reporting true once panicStormThreshold panics occurred within panicStormWindow.
+// The panics are then forgotten, so that a continuing storm is reported once per threshold.
+func (p *panicStorm) record(at time.Time) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	recent := p.panics[:0]
+	for _, panicked := range p.panics {
+		if at.Sub(panicked) < panicStormWindow {
+			recent = append(recent, panicked)
+		}
+	}
+	p.panics = append(recent, at)
+
+	if len(p.panics) < panicStormThreshold {
+		return false
+	}
+
+	p.panics = nil
+	return true
+}
+
+// recordPanic reports a panic storm to the control plane once the service panicked too often
+func (s *Service) recordPanic(err error, callID string) {
+	if s.panics.record(time.Now()) {
+		go s.reportMachineError(MachineErrorPanicStorm, fmt.Errorf("%d panics within %s, last: %v", panicStormThreshold, panicStormWindow, err), callID)
+	}
+}
@@ -0,0 +1,107 @@
+package inferable
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReportMachineErrors(t *testing.T) {
+	var mu sync.Mutex
+	reports := []machineError{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/machines":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "invalid schema for function 'TestFunc'"}`))
+			return
+		case "/machines/errors":
+			var report machineError
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&report))
+			assert.NotEmpty(t, r.Header.Get("X-Machine-ID"))
+			mu.Lock()
+			reports = append(reports, report)
+			mu.Unlock()
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	i, err := New(InferableOptions{
+		APIEndpoint: server.URL,
+		APISecret:   "test-secret",
+	})
+	require.NoError(t, err)
+
+	type TestInput struct{}
+	require.NoError(t, i.Default.RegisterFunc(Function{Name: "TestFunc", Func: func(input TestInput) int { return 1 }}))
+
+	// Rejected registrations are reported
+	require.Error(t, i.Default.Start())
+	mu.Lock()
+	require.Len(t, reports, 1)
+	assert.Equal(t, "default", reports[0].Service)
+	assert.Equal(t, MachineErrorRegistrationRejected, reports[0].Kind)
+	assert.Contains(t, reports[0].Message, "invalid schema")
+	mu.Unlock()
+
+	// Panic storms are reported once the threshold is reached
+	for n := 0; n < panicStormThreshold; n++ {
+		i.Default.recordPanic(assert.AnError, "call-1")
+	}
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(reports) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	mu.Lock()
+	assert.Equal(t, MachineErrorPanicStorm, reports[1].Kind)
+	assert.Equal(t, "call-1", reports[1].CallID)
+	mu.Unlock()
+}
+
+func TestPanicStorm(t *testing.T) {
+	storm := &panicStorm{}
+	start := time.Now()
+
+	// Panics spread beyond the window are not a storm
+	for n := 0; n < panicStormThreshold; n++ {
+		assert.False(t, storm.record(start.Add(time.Duration(n)*panicStormWindow)))
+	}
+
+	for n := 1; n < panicStormThreshold; n++ {
+		assert.False(t, storm.record(start.Add(10*panicStormWindow)))
+	}
+	assert.True(t, storm.record(start.Add(10*panicStormWindow)))
+	// The storm is reported again once the threshold is reached again
+	assert.False(t, storm.record(start.Add(10*panicStormWindow)))
+}
+
+func TestReportMachineErrorsUnsupported(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/machines/errors" {
+			requests++
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	i, err := New(InferableOptions{
+		APIEndpoint: server.URL,
+		APISecret:   "test-secret",
+	})
+	require.NoError(t, err)
+
+	i.Default.reportMachineError(MachineErrorPersistFailed, assert.AnError, "call-1")
+	i.Default.reportMachineError(MachineErrorPersistFailed, assert.AnError, "call-2")
+	assert.Equal(t, 1, requests)
+}
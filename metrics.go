@@ -0,0 +1,161 @@
+package inferable
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Names of the metrics served on /metrics (see RegisterHealthHandlers) and recorded to InferableOptions.MetricsSink
+const (
+	MetricCallsPendingAck = "inferable_calls_pending_ack"
+	MetricCallsInFlight   = "inferable_calls_in_flight"
+	MetricCallsCompleted  = "inferable_calls_completed_total"
+	MetricCallsFailed     = "inferable_calls_failed_total"
+	MetricCallDuration    = "inferable_call_duration_seconds"
+	MetricLastPoll        = "inferable_last_poll_timestamp_seconds"
+	MetricLastFailedPoll  = "inferable_last_failed_poll_timestamp_seconds"
+)
+
+// MetricsSink receives the metrics of the SDK as they are recorded, for metrics systems which do not
+// scrape the /metrics endpoint, such as DogStatsD (see NewDogStatsDSink). Metrics are tagged with the
+// service and, for calls, the function.
+type MetricsSink interface {
+	Count(name string, value int64, tags map[string]string)
+	Gauge(name string, value float64, tags map[string]string)
+	Histogram(name string, value float64, tags map[string]string)
+}
+
+// durationBuckets are the upper bounds (in seconds) of the buckets of call durations served on /metrics
+var durationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// durationHistogram counts durations by the first of durationBuckets they do not exceed
+type durationHistogram struct {
+	buckets [14]atomic.Int64 // one per bucket, and one for durations exceeding the last bucket
+	sum     atomic.Int64     // nanoseconds
+	count   atomic.Int64
+}
+
+func (h *durationHistogram) observe(d time.Duration) {
+	idx := len(durationBuckets)
+	for bucket, bound := range durationBuckets {
+		if d.Seconds() <= bound {
+			idx = bucket
+			break
+		}
+	}
+
+	h.buckets[idx].Add(1)
+	h.sum.Add(int64(d))
+	h.count.Add(1)
+}
+
+// recordGauges records the gauges of the calls of the service to the metrics sink
+func (s *Service) recordGauges() {
+	sink := s.inferable.metricsSink
+	if sink == nil {
+		return
+	}
+
+	tags := map[string]string{"service": s.Name}
+	sink.Gauge(MetricCallsInFlight, float64(s.inFlight.Load()), tags)
+	sink.Gauge(MetricCallsPendingAck, float64(s.stats.pendingAck.Load()), tags)
+}
+
+// recordCall records a handled call to the metrics sink
+func (s *Service) recordCall(call CallInfo, err error, duration time.Duration) {
+	sink := s.inferable.metricsSink
+	if sink == nil {
+		return
+	}
+
+	tags := map[string]string{"service": s.Name, "function": call.Function}
+	if err != nil {
+		sink.Count(MetricCallsFailed, 1, tags)
+	} else {
+		sink.Count(MetricCallsCompleted, 1, tags)
+	}
+	sink.Histogram(MetricCallDuration, duration.Seconds(), tags)
+}
+
+// recordPoll records the outcome of a poll to the metrics sink
+func (s *Service) recordPoll(err error, at time.Time) {
+	sink := s.inferable.metricsSink
+	if sink == nil {
+		return
+	}
+
+	name := MetricLastPoll
+	if err != nil {
+		name = MetricLastFailedPoll
+	}
+	sink.Gauge(name, unixSeconds(at), map[string]string{"service": s.Name})
+}
+
+// servicesWithFunctions returns every service which has functions, ordered by name
+func (i *Inferable) servicesWithFunctions() []*Service {
+	services := []*Service{}
+	for _, service := range i.functionRegistry.list() {
+		if len(service.functions()) > 0 {
+			services = append(services, service)
+		}
+	}
+
+	return services
+}
+
+// writeMetrics writes the stats of each service in the Prometheus text exposition format
+func writeMetrics(w http.ResponseWriter, services []*Service) {
+	stats := make([]ServiceStats, len(services))
+	for idx, service := range services {
+		stats[idx] = service.Stats()
+	}
+
+	metrics := []struct {
+		name  string
+		kind  string
+		help  string
+		value func(ServiceStats) float64
+	}{
+		{MetricCallsPendingAck, "gauge", "Calls received but not yet acknowledged.", func(s ServiceStats) float64 { return float64(s.PendingAck) }},
+		{MetricCallsInFlight, "gauge", "Calls being handled.", func(s ServiceStats) float64 { return float64(s.InFlight) }},
+		{MetricCallsCompleted, "counter", "Calls whose result was persisted.", func(s ServiceStats) float64 { return float64(s.Completed) }},
+		{MetricCallsFailed, "counter", "Calls which could not be handled.", func(s ServiceStats) float64 { return float64(s.Failed) }},
+		{MetricLastPoll, "gauge", "When the queue was last polled successfully.", func(s ServiceStats) float64 { return unixSeconds(s.LastPoll) }},
+		{MetricLastFailedPoll, "gauge", "When a poll of the queue last failed.", func(s ServiceStats) float64 { return unixSeconds(s.LastFailedPoll) }},
+	}
+
+	var b strings.Builder
+	for _, metric := range metrics {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", metric.name, metric.help, metric.name, metric.kind)
+		for _, service := range stats {
+			fmt.Fprintf(&b, "%s{service=%q} %v\n", metric.name, service.Service, metric.value(service))
+		}
+	}
+
+	fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s histogram\n", MetricCallDuration, "How long handling calls took.", MetricCallDuration)
+	for _, service := range services {
+		durations := &service.stats.durations
+		var cumulative int64
+		for bucket, bound := range durationBuckets {
+			cumulative += durations.buckets[bucket].Load()
+			fmt.Fprintf(&b, "%s_bucket{service=%q,le=\"%v\"} %d\n", MetricCallDuration, service.Name, bound, cumulative)
+		}
+		fmt.Fprintf(&b, "%s_bucket{service=%q,le=\"+Inf\"} %d\n", MetricCallDuration, service.Name, durations.count.Load())
+		fmt.Fprintf(&b, "%s_sum{service=%q} %v\n", MetricCallDuration, service.Name, time.Duration(durations.sum.Load()).Seconds())
+		fmt.Fprintf(&b, "%s_count{service=%q} %d\n", MetricCallDuration, service.Name, durations.count.Load())
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write([]byte(b.String()))
+}
+
+func unixSeconds(t time.Time) float64 {
+	if t.IsZero() {
+		return 0
+	}
+
+	return float64(t.UnixNano()) / float64(time.Second)
+}
@@ -0,0 +1,109 @@
+package inferable
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingSink struct {
+	mu      sync.Mutex
+	metrics []string
+}
+
+func (r *recordingSink) record(kind, name string, value interface{}, tags map[string]string) {
+	keys := []string{}
+	for key := range tags {
+		keys = append(keys, key+"="+tags[key])
+	}
+	sort.Strings(keys)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics = append(r.metrics, fmt.Sprintf("%s %s %v %v", kind, name, value, keys))
+}
+
+func (r *recordingSink) Count(name string, value int64, tags map[string]string) {
+	r.record("count", name, value, tags)
+}
+
+func (r *recordingSink) Gauge(name string, value float64, tags map[string]string) {
+	r.record("gauge", name, value, tags)
+}
+
+func (r *recordingSink) Histogram(name string, value float64, tags map[string]string) {
+	r.record("histogram", name, "_", tags)
+}
+
+func TestMetricsSink(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	sink := &recordingSink{}
+	i, err := New(InferableOptions{
+		APIEndpoint: server.URL,
+		APISecret:   "test-secret",
+		MetricsSink: sink,
+	})
+	require.NoError(t, err)
+
+	type TestInput struct{}
+	require.NoError(t, i.Default.RegisterFunc(Function{Name: "TestFunc", Func: func(input TestInput) int { return 1 }}))
+
+	body := `{"value": {"id": "call-1", "service": "default", "targetFn": "TestFunc", "targetArgs": "{\"value\": {}}"}}`
+	require.NoError(t, i.Default.handleMessage(&sqs.Message{Body: aws.String(body)}, time.Now()))
+
+	assert.Equal(t, []string{
+		"gauge inferable_calls_in_flight 1 [service=default]",
+		"gauge inferable_calls_pending_ack 1 [service=default]",
+		"count inferable_calls_completed_total 1 [function=TestFunc service=default]",
+		"histogram inferable_call_duration_seconds _ [function=TestFunc service=default]",
+		"gauge inferable_calls_in_flight 0 [service=default]",
+		"gauge inferable_calls_pending_ack 0 [service=default]",
+	}, sink.metrics)
+
+	// Call durations are served as a histogram by the metrics handler
+	recorder := httptest.NewRecorder()
+	i.MetricsHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(t, recorder.Body.String(), "# TYPE inferable_call_duration_seconds histogram\n")
+	assert.Contains(t, recorder.Body.String(), `inferable_call_duration_seconds_bucket{service="default",le="+Inf"} 1`+"\n")
+	assert.Contains(t, recorder.Body.String(), `inferable_call_duration_seconds_count{service="default"} 1`+"\n")
+}
+
+func TestDogStatsDSink(t *testing.T) {
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	sink, err := NewDogStatsDSink(listener.LocalAddr().String(), "env:test")
+	require.NoError(t, err)
+	defer sink.Close()
+
+	receive := func() string {
+		buf := make([]byte, 1024)
+		require.NoError(t, listener.SetReadDeadline(time.Now().Add(5*time.Second)))
+		n, _, err := listener.ReadFrom(buf)
+		require.NoError(t, err)
+		return string(buf[:n])
+	}
+
+	sink.Count(MetricCallsCompleted, 1, map[string]string{"service": "default", "function": "TestFunc"})
+	assert.Equal(t, "inferable_calls_completed_total:1|c|#env:test,function:TestFunc,service:default", receive())
+
+	sink.Gauge(MetricCallsInFlight, 2, map[string]string{"service": "default"})
+	assert.Equal(t, "inferable_calls_in_flight:2|g|#env:test,service:default", receive())
+
+	sink.Histogram(MetricCallDuration, 0.25, nil)
+	assert.Equal(t, "inferable_call_duration_seconds:0.25|h|#env:test", receive())
+}
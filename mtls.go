@@ -0,0 +1,90 @@
+package inferable
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// ClientCertificate authenticates the machine with mutual TLS, as an alternative or in addition to
+// the API secret. The certificate is reloaded when its files change, so that it can be rotated in place.
+type ClientCertificate struct {
+	// CertFile and KeyFile are PEM encoded
+	CertFile string
+	KeyFile  string
+}
+
+// certificateLoader holds the client certificate, reloading it when its files are modified
+type certificateLoader struct {
+	files ClientCertificate
+
+	mu       sync.Mutex
+	cert     *tls.Certificate
+	modified time.Time
+}
+
+func newCertificateLoader(files ClientCertificate) (*certificateLoader, error) {
+	if files.CertFile == "" || files.KeyFile == "" {
+		return nil, fmt.Errorf("client certificate requires both a certificate and a key file")
+	}
+
+	loader := &certificateLoader{files: files}
+	if _, err := loader.certificate(); err != nil {
+		return nil, err
+	}
+
+	return loader, nil
+}
+
+// certificate returns the client certificate, reloading it if either of its files was modified.
+// If reloading fails, e.g. because the files are being replaced, the previous certificate is kept.
+func (l *certificateLoader) certificate() (*tls.Certificate, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	modified, err := l.lastModified()
+	if err != nil {
+		if l.cert != nil {
+			return l.cert, nil
+		}
+		return nil, fmt.Errorf("failed to read client certificate: %v", err)
+	}
+
+	if l.cert != nil && modified.Equal(l.modified) {
+		return l.cert, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(l.files.CertFile, l.files.KeyFile)
+	if err != nil {
+		if l.cert != nil {
+			return l.cert, nil
+		}
+		return nil, fmt.Errorf("failed to load client certificate: %v", err)
+	}
+
+	l.cert = &cert
+	l.modified = modified
+	return l.cert, nil
+}
+
+// lastModified returns the latest modification time of the certificate and key files
+func (l *certificateLoader) lastModified() (time.Time, error) {
+	var latest time.Time
+	for _, path := range []string{l.files.CertFile, l.files.KeyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+
+	return latest, nil
+}
+
+func (l *certificateLoader) getClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return l.certificate()
+}
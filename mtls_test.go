@@ -0,0 +1,115 @@
+package inferable
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeClientCertificate issues a client certificate for commonName from a new CA, writes it to dir
+// and returns the CA
+func writeClientCertificate(t *testing.T, dir, commonName string) *x509.Certificate {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	ca, err := x509.ParseCertificate(caDER)
+	require.NoError(t, err)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "client.crt"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "client.key"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+
+	return ca
+}
+
+func TestClientCertificate(t *testing.T) {
+	dir := t.TempDir()
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(writeClientCertificate(t, dir, "machine-1"))
+
+	var mu sync.Mutex
+	var authenticated []string
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		authenticated = append(authenticated, r.TLS.PeerCertificates[0].Subject.CommonName)
+		mu.Unlock()
+		assert.Empty(t, r.Header.Get("Authorization"))
+		w.Write([]byte(`{}`))
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+
+	client, err := NewClient(ClientOptions{
+		Endpoint: server.URL,
+		Transport: TransportOptions{
+			TLSConfig:         &tls.Config{RootCAs: roots},
+			ClientCertificate: &ClientCertificate{CertFile: filepath.Join(dir, "client.crt"), KeyFile: filepath.Join(dir, "client.key")},
+			DisableKeepAlives: true,
+		},
+	})
+	require.NoError(t, err)
+
+	_, err = client.FetchData(FetchDataOptions{Path: "/live", Method: "GET"})
+	require.NoError(t, err)
+
+	// The rotated certificate is picked up by the next connection
+	clientCAs.AddCert(writeClientCertificate(t, dir, "machine-2"))
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "client.crt"), later, later))
+
+	_, err = client.FetchData(FetchDataOptions{Path: "/live", Method: "GET"})
+	require.NoError(t, err)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"machine-1", "machine-2"}, authenticated)
+
+	_, err = NewClient(ClientOptions{
+		Endpoint:  server.URL,
+		Transport: TransportOptions{ClientCertificate: &ClientCertificate{CertFile: filepath.Join(dir, "missing.crt"), KeyFile: filepath.Join(dir, "client.key")}},
+	})
+	assert.Error(t, err)
+}
@@ -0,0 +1,401 @@
+// Package openapi registers the operations of an OpenAPI 3 document as Inferable functions,
+// so that existing REST services can be used as tools without writing glue code.
+//
+// Each operation with an operationId becomes a function. Its input schema is derived from the
+// operation's parameters and JSON request body, and calls are proxied to the upstream API.
+//
+//	spec, _ := os.ReadFile("petstore.yaml")
+//	err := openapi.Register(i.Default, spec, openapi.Options{BaseURL: "https://petstore.internal"})
+package openapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	inferable "github.com/inferablehq/inferable-go"
+	"gopkg.in/yaml.v3"
+)
+
+// maxRefDepth bounds the resolution of nested $refs, guarding against cycles
+const maxRefDepth = 16
+
+var methods = []string{"get", "put", "post", "delete", "patch", "head", "options"}
+
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// Options configures how operations are registered and proxied
+type Options struct {
+	// BaseURL of the upstream API. Defaults to the first server of the document.
+	BaseURL string
+	// Operations restricts the registered operations to these operation IDs. All operations are registered if empty.
+	Operations []string
+	// Headers are added to every upstream request, e.g. for authentication
+	Headers map[string]string
+	// HTTPClient used for upstream requests. Defaults to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+type document struct {
+	Servers []struct {
+		URL string `json:"url"`
+	} `json:"servers"`
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components map[string]map[string]interface{}     `json:"components"`
+}
+
+type operation struct {
+	OperationID string      `json:"operationId"`
+	Summary     string      `json:"summary"`
+	Description string      `json:"description"`
+	Parameters  []parameter `json:"parameters"`
+	RequestBody *struct {
+		Required bool `json:"required"`
+		Content  map[string]struct {
+			Schema interface{} `json:"schema"`
+		} `json:"content"`
+	} `json:"requestBody"`
+}
+
+type parameter struct {
+	Name        string      `json:"name"`
+	In          string      `json:"in"`
+	Description string      `json:"description"`
+	Required    bool        `json:"required"`
+	Schema      interface{} `json:"schema"`
+}
+
+// Register parses an OpenAPI 3 document (JSON or YAML) and registers each of its operations as a function
+func Register(registrar inferable.FunctionRegistrar, spec []byte, options Options) error {
+	doc, err := parseDocument(spec)
+	if err != nil {
+		return err
+	}
+
+	baseURL := options.BaseURL
+	if baseURL == "" && len(doc.Servers) > 0 {
+		baseURL = doc.Servers[0].URL
+	}
+	if baseURL == "" {
+		return fmt.Errorf("base URL is required when the document declares no servers")
+	}
+
+	allowed := map[string]bool{}
+	for _, id := range options.Operations {
+		allowed[id] = true
+	}
+
+	client := options.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	paths := make([]string, 0, len(doc.Paths))
+	for path := range doc.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	registered := map[string]bool{}
+	for _, path := range paths {
+		item := doc.Paths[path]
+
+		// Parameters declared on the path apply to all of its operations
+		shared := []parameter{}
+		if raw, ok := item["parameters"]; ok {
+			if err := json.Unmarshal(raw, &shared); err != nil {
+				return fmt.Errorf("failed to parse parameters of path %s: %v", path, err)
+			}
+		}
+
+		for _, method := range methods {
+			raw, ok := item[method]
+			if !ok {
+				continue
+			}
+
+			op := operation{}
+			if err := json.Unmarshal(raw, &op); err != nil {
+				return fmt.Errorf("failed to parse operation %s %s: %v", strings.ToUpper(method), path, err)
+			}
+			if op.OperationID == "" || (len(allowed) > 0 && !allowed[op.OperationID]) {
+				continue
+			}
+			op.Parameters = append(append([]parameter{}, shared...), op.Parameters...)
+
+			fn, err := newFunction(doc, baseURL, method, path, op, options.Headers, client)
+			if err != nil {
+				return err
+			}
+
+			if err := registrar.RegisterFunc(fn); err != nil {
+				return fmt.Errorf("failed to register operation '%s': %v", op.OperationID, err)
+			}
+			registered[op.OperationID] = true
+		}
+	}
+
+	for _, id := range options.Operations {
+		if !registered[id] {
+			return fmt.Errorf("operation '%s' not found in document", id)
+		}
+	}
+
+	if len(registered) == 0 {
+		return fmt.Errorf("document has no operations with an operationId")
+	}
+
+	return nil
+}
+
+func parseDocument(spec []byte) (*document, error) {
+	// YAML is a superset of JSON, so both are parsed as YAML and normalized to JSON
+	var parsed interface{}
+	if err := yaml.Unmarshal(spec, &parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse OpenAPI document: %v", err)
+	}
+
+	normalized, err := json.Marshal(parsed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to normalize OpenAPI document: %v", err)
+	}
+
+	doc := &document{}
+	if err := json.Unmarshal(normalized, doc); err != nil {
+		return nil, fmt.Errorf("failed to parse OpenAPI document: %v", err)
+	}
+
+	return doc, nil
+}
+
+func newFunction(doc *document, baseURL, method, path string, op operation, headers map[string]string, client *http.Client) (inferable.Function, error) {
+	properties := map[string]interface{}{}
+	required := []string{}
+
+	for _, param := range op.Parameters {
+		if param.In == "cookie" {
+			continue
+		}
+
+		schema, err := resolveRefs(doc, param.Schema, 0)
+		if err != nil {
+			return inferable.Function{}, fmt.Errorf("operation '%s': %v", op.OperationID, err)
+		}
+		if schemaMap, ok := schema.(map[string]interface{}); ok && param.Description != "" {
+			schemaMap["description"] = param.Description
+		}
+		if schema == nil {
+			schema = map[string]interface{}{"type": "string"}
+		}
+
+		properties[param.Name] = schema
+		if param.Required || param.In == "path" {
+			required = append(required, param.Name)
+		}
+	}
+
+	hasBody := false
+	if op.RequestBody != nil {
+		if content, ok := op.RequestBody.Content["application/json"]; ok {
+			schema, err := resolveRefs(doc, content.Schema, 0)
+			if err != nil {
+				return inferable.Function{}, fmt.Errorf("operation '%s': %v", op.OperationID, err)
+			}
+
+			hasBody = true
+			properties["body"] = schema
+			if op.RequestBody.Required {
+				required = append(required, "body")
+			}
+		}
+	}
+
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+
+	schemaJSON, err := json.Marshal(schema)
+	if err != nil {
+		return inferable.Function{}, fmt.Errorf("failed to marshal schema of operation '%s': %v", op.OperationID, err)
+	}
+
+	description := op.Summary
+	if op.Description != "" {
+		description = strings.TrimSpace(strings.Join([]string{op.Summary, op.Description}, "\n"))
+	}
+
+	proxy := &proxy{
+		client:  client,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		method:  strings.ToUpper(method),
+		path:    path,
+		params:  op.Parameters,
+		hasBody: hasBody,
+		headers: headers,
+	}
+
+	return inferable.Function{
+		Name:        invalidNameChars.ReplaceAllString(op.OperationID, "_"),
+		Description: description,
+		InputSchema: schemaJSON,
+		Func:        proxy.call,
+	}, nil
+}
+
+// resolveRefs replaces local $refs (#/components/...) in schema with the referenced definitions
+func resolveRefs(doc *document, schema interface{}, depth int) (interface{}, error) {
+	if depth > maxRefDepth {
+		return nil, fmt.Errorf("$refs nested deeper than %d levels", maxRefDepth)
+	}
+
+	switch value := schema.(type) {
+	case map[string]interface{}:
+		if ref, ok := value["$ref"].(string); ok {
+			target, err := lookupRef(doc, ref)
+			if err != nil {
+				return nil, err
+			}
+			return resolveRefs(doc, target, depth+1)
+		}
+
+		resolved := make(map[string]interface{}, len(value))
+		for key, child := range value {
+			child, err := resolveRefs(doc, child, depth)
+			if err != nil {
+				return nil, err
+			}
+			resolved[key] = child
+		}
+		return resolved, nil
+	case []interface{}:
+		resolved := make([]interface{}, len(value))
+		for idx, child := range value {
+			child, err := resolveRefs(doc, child, depth)
+			if err != nil {
+				return nil, err
+			}
+			resolved[idx] = child
+		}
+		return resolved, nil
+	}
+
+	return schema, nil
+}
+
+func lookupRef(doc *document, ref string) (interface{}, error) {
+	parts := strings.Split(strings.TrimPrefix(ref, "#/"), "/")
+	if !strings.HasPrefix(ref, "#/components/") || len(parts) != 3 {
+		return nil, fmt.Errorf("unsupported $ref '%s'", ref)
+	}
+
+	target, ok := doc.Components[parts[1]][parts[2]]
+	if !ok {
+		return nil, fmt.Errorf("$ref '%s' not found", ref)
+	}
+
+	return target, nil
+}
+
+// proxy forwards calls of a function to its upstream operation
+type proxy struct {
+	client  *http.Client
+	baseURL string
+	method  string
+	path    string
+	params  []parameter
+	hasBody bool
+	headers map[string]string
+}
+
+func (p *proxy) call(ctx context.Context, input json.RawMessage) (interface{}, error) {
+	args := map[string]json.RawMessage{}
+	if err := json.Unmarshal(input, &args); err != nil {
+		return nil, fmt.Errorf("invalid input: %v", err)
+	}
+
+	path := p.path
+	query := url.Values{}
+	headers := http.Header{}
+
+	for _, param := range p.params {
+		raw, ok := args[param.Name]
+		if !ok {
+			continue
+		}
+		value := paramValue(raw)
+
+		switch param.In {
+		case "path":
+			path = strings.ReplaceAll(path, "{"+param.Name+"}", url.PathEscape(value))
+		case "query":
+			query.Set(param.Name, value)
+		case "header":
+			headers.Set(param.Name, value)
+		}
+	}
+
+	endpoint := p.baseURL + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	var body io.Reader
+	if raw, ok := args["body"]; ok && p.hasBody {
+		body = bytes.NewReader(raw)
+		headers.Set("Content-Type", "application/json")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, p.method, endpoint, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	for key, value := range p.headers {
+		req.Header.Set(key, value)
+	}
+	for key := range headers {
+		req.Header.Set(key, headers.Get(key))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request to %s %s failed: %v", p.method, p.path, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %v", err)
+	}
+
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("%s %s returned status %d: %s", p.method, p.path, resp.StatusCode, string(data))
+	}
+
+	var result interface{}
+	if err := json.Unmarshal(data, &result); err == nil {
+		return result, nil
+	}
+
+	return string(data), nil
+}
+
+// paramValue formats a JSON input value as a parameter, without quotes for strings
+func paramValue(raw json.RawMessage) string {
+	var value string
+	if err := json.Unmarshal(raw, &value); err == nil {
+		return value
+	}
+
+	return string(raw)
+}
@@ -0,0 +1,151 @@
+package openapi
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	inferable "github.com/inferablehq/inferable-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const petstore = `
+openapi: 3.0.0
+info:
+  title: Petstore
+  version: 1.0.0
+paths:
+  /pets/{petId}:
+    parameters:
+      - name: petId
+        in: path
+        required: true
+        schema:
+          type: string
+    get:
+      operationId: getPet
+      summary: Get a pet by ID
+      parameters:
+        - name: fields
+          in: query
+          schema:
+            type: string
+    delete:
+      summary: Operations without an ID are skipped
+  /pets:
+    post:
+      operationId: createPet
+      summary: Create a pet
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              $ref: '#/components/schemas/Pet'
+components:
+  schemas:
+    Pet:
+      type: object
+      properties:
+        name:
+          type: string
+      required: [name]
+`
+
+type registrar struct {
+	functions map[string]inferable.Function
+}
+
+func (r *registrar) RegisterFunc(fn inferable.Function) error {
+	r.functions[fn.Name] = fn
+	return nil
+}
+
+func TestRegister(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/pets/42":
+			assert.Equal(t, "name", r.URL.Query().Get("fields"))
+			w.Write([]byte(`{"id": "42", "name": "Rex"}`))
+		case r.Method == "POST" && r.URL.Path == "/pets":
+			body, _ := io.ReadAll(r.Body)
+			assert.JSONEq(t, `{"name": "Rex"}`, string(body))
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`created`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer upstream.Close()
+
+	r := &registrar{functions: map[string]inferable.Function{}}
+	err := Register(r, []byte(petstore), Options{
+		BaseURL: upstream.URL,
+		Headers: map[string]string{"Authorization": "Bearer token"},
+	})
+	require.NoError(t, err)
+	require.Len(t, r.functions, 2)
+
+	getPet := r.functions["getPet"]
+	assert.Equal(t, "Get a pet by ID", getPet.Description)
+	assert.JSONEq(t, `{
+		"type": "object",
+		"properties": {"petId": {"type": "string"}, "fields": {"type": "string"}},
+		"required": ["petId"]
+	}`, string(getPet.InputSchema))
+
+	createPet := r.functions["createPet"]
+	assert.JSONEq(t, `{
+		"type": "object",
+		"properties": {"body": {"type": "object", "properties": {"name": {"type": "string"}}, "required": ["name"]}},
+		"required": ["body"]
+	}`, string(createPet.InputSchema))
+
+	call := func(fn inferable.Function, input string) (interface{}, error) {
+		return fn.Func.(func(context.Context, json.RawMessage) (interface{}, error))(context.Background(), json.RawMessage(input))
+	}
+
+	result, err := call(getPet, `{"petId": "42", "fields": "name"}`)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"id": "42", "name": "Rex"}, result)
+
+	result, err = call(createPet, `{"body": {"name": "Rex"}}`)
+	require.NoError(t, err)
+	assert.Equal(t, "created", result)
+
+	_, err = call(getPet, `{"petId": "7"}`)
+	assert.ErrorContains(t, err, "returned status 404")
+}
+
+func TestRegisterOperationAllowlist(t *testing.T) {
+	r := &registrar{functions: map[string]inferable.Function{}}
+	err := Register(r, []byte(petstore), Options{BaseURL: "http://localhost", Operations: []string{"getPet"}})
+	require.NoError(t, err)
+	assert.Len(t, r.functions, 1)
+
+	err = Register(r, []byte(petstore), Options{BaseURL: "http://localhost", Operations: []string{"listPets"}})
+	assert.ErrorContains(t, err, "operation 'listPets' not found")
+
+	err = Register(r, []byte(petstore), Options{})
+	assert.ErrorContains(t, err, "base URL is required")
+}
+
+func TestRegisterWithService(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	i, err := inferable.New(inferable.InferableOptions{APIEndpoint: server.URL, APISecret: "test-secret"})
+	require.NoError(t, err)
+
+	require.NoError(t, Register(i.Default, []byte(petstore), Options{BaseURL: server.URL}))
+	assert.Contains(t, i.Default.Functions, "getPet")
+	assert.Contains(t, i.Default.Functions, "createPet")
+}
@@ -0,0 +1,149 @@
+package inferable
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	DefaultPersistTimeout = 10 * time.Second
+
+	// persistQueueSize bounds the results waiting to be persisted in the background
+	persistQueueSize      = 1000
+	maxPersistAttempts    = 10
+	initialPersistBackoff = 500 * time.Millisecond
+	maxPersistBackoff     = 30 * time.Second
+)
+
+// pendingPersist is a result waiting to be persisted by the background worker
+type pendingPersist struct {
+	ctx    context.Context
+	call   CallInfo
+	result CallResult
+	meta   resultMetadata
+}
+
+func (s *Service) persistTimeout() time.Duration {
+	if s.options.PersistTimeout <= 0 {
+		return DefaultPersistTimeout
+	}
+
+	return s.options.PersistTimeout
+}
+
+// PendingPersists returns the number of results waiting to be persisted by the background worker
+func (s *Service) PendingPersists() int {
+	return int(s.pendingPersist.Load())
+}
+
+// persistWithTimeout persists the result of a call. If the API does not respond within the persist timeout,
+// the result is queued for the background worker and the call is treated as handled.
+func (s *Service) persistWithTimeout(ctx context.Context, call CallInfo, result CallResult, meta resultMetadata) error {
+	persistCtx, cancel := context.WithTimeout(ctx, s.persistTimeout())
+	defer cancel()
+
+	err := s.persistJobResult(persistCtx, call.ID, result, meta)
+	if err == nil || !errors.Is(err, context.DeadlineExceeded) || ctx.Err() != nil {
+		return err
+	}
+
+	return s.enqueuePersist(pendingPersist{ctx: ctx, call: call, result: result, meta: meta}, err)
+}
+
+// enqueuePersist hands a result which could not be persisted in time, because of cause, to the background worker
+func (s *Service) enqueuePersist(pending pendingPersist, cause error) error {
+	s.persistMu.Lock()
+	defer s.persistMu.Unlock()
+
+	// The worker stops with the service, so a new one is started once the service was started again
+	ctx := s.baseContext()
+	if ctx.Err() != nil {
+		return fmt.Errorf("service stopped before the result could be persisted: %w", cause)
+	}
+	if s.persistQueue == nil || s.persistCtx != ctx {
+		s.persistQueue = make(chan pendingPersist, persistQueueSize)
+		s.persistCtx = ctx
+		go s.persistWorker(ctx, s.persistQueue)
+	}
+
+	select {
+	case s.persistQueue <- pending:
+		s.pendingPersist.Add(1)
+		s.inferable.logf(LogLevelInfo, "Persisting result of call '%s' timed out, retrying in the background (%d pending)", pending.call.ID, s.PendingPersists())
+		return nil
+	default:
+		return fmt.Errorf("background persist queue is full: %w", cause)
+	}
+}
+
+// persistWorker retries the results of queue with backoff until they are persisted, or ctx is done.
+// Results still queued then are failed, as they can no longer be persisted.
+func (s *Service) persistWorker(ctx context.Context, queue chan pendingPersist) {
+	for {
+		select {
+		case <-ctx.Done():
+			s.failQueuedPersists(queue, ctx.Err())
+			return
+		case pending := <-queue:
+			if err := s.retryPersist(ctx, pending); err != nil {
+				s.persistFailed(pending, err)
+			}
+			s.pendingPersist.Add(-1)
+		}
+	}
+}
+
+// failQueuedPersists fails the results left in queue once its worker stopped. No results are added to
+// the queue afterwards, as enqueuePersist checks that the service is running while holding persistMu.
+func (s *Service) failQueuedPersists(queue chan pendingPersist, cause error) {
+	s.persistMu.Lock()
+	left := []pendingPersist{}
+	for len(queue) > 0 {
+		left = append(left, <-queue)
+	}
+	s.persistMu.Unlock()
+
+	for _, pending := range left {
+		s.persistFailed(pending, fmt.Errorf("service stopped before the result could be persisted: %w", cause))
+		s.pendingPersist.Add(-1)
+	}
+}
+
+// persistFailed reports a result which could not be persisted in the background
+func (s *Service) persistFailed(pending pendingPersist, err error) {
+	s.inferable.logf(LogLevelError, "Failed to persist result of call '%s': %v", pending.call.ID, err)
+	s.options.Hooks.onError(pending.ctx, pending.call, err)
+	s.reportError(err, ErrorContext{Kind: ErrorKindPersist, Call: &pending.call})
+	s.reportMachineError(MachineErrorPersistFailed, err, pending.call.ID)
+}
+
+func (s *Service) retryPersist(ctx context.Context, pending pendingPersist) error {
+	delay := initialPersistBackoff
+
+	for attempt := 1; ; attempt++ {
+		persistCtx, cancel := context.WithTimeout(ctx, s.persistTimeout())
+		err := s.persistJobResult(persistCtx, pending.call.ID, pending.result, pending.meta)
+		cancel()
+
+		if err == nil {
+			return nil
+		}
+
+		if attempt >= maxPersistAttempts || (!errors.Is(err, context.DeadlineExceeded) && !isRetryableError(err)) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+		if delay > maxPersistBackoff {
+			delay = maxPersistBackoff
+		}
+	}
+}
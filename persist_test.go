@@ -0,0 +1,132 @@
+package inferable
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPersistTimeoutFallback(t *testing.T) {
+	var attempts atomic.Int32
+	var persisted atomic.Bool
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/jobs/call-1/result" {
+			if attempts.Add(1) == 1 {
+				// Respond after the persist timeout has passed
+				time.Sleep(200 * time.Millisecond)
+			} else {
+				persisted.Store(true)
+			}
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	i, err := New(InferableOptions{
+		APIEndpoint: server.URL,
+		APISecret:   "test-secret",
+	})
+	require.NoError(t, err)
+
+	service, err := i.RegisterServiceWithOptions("slow", ServiceOptions{PersistTimeout: 50 * time.Millisecond})
+	require.NoError(t, err)
+
+	type TestInput struct{}
+	require.NoError(t, service.RegisterFunc(Function{
+		Name: "Quick",
+		Func: func(input TestInput) string { return "done" },
+	}))
+
+	body := `{"value": {"id": "call-1", "service": "slow", "targetFn": "Quick", "targetArgs": "{\"value\": {}}"}}`
+
+	start := time.Now()
+	err = service.handleMessage(&sqs.Message{Body: aws.String(body)}, time.Now())
+	require.NoError(t, err)
+
+	// The message is handled without waiting for the slow API
+	assert.Less(t, time.Since(start), 200*time.Millisecond)
+	assert.Equal(t, 1, service.PendingPersists())
+
+	require.Eventually(t, persisted.Load, 5*time.Second, 10*time.Millisecond)
+	require.Eventually(t, func() bool { return service.PendingPersists() == 0 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, int32(2), attempts.Load())
+}
+
+func TestPersistWorkerRestartsWithService(t *testing.T) {
+	var slow atomic.Bool
+	slow.Store(true)
+	var persisted atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/result") {
+			if slow.Load() {
+				time.Sleep(200 * time.Millisecond)
+			} else {
+				persisted.Add(1)
+			}
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	i, err := New(InferableOptions{
+		APIEndpoint: server.URL,
+		APISecret:   "test-secret",
+	})
+	require.NoError(t, err)
+
+	var failed sync.Map
+	service, err := i.RegisterServiceWithOptions("restarted", ServiceOptions{
+		PersistTimeout: 50 * time.Millisecond,
+		Hooks: Hooks{
+			OnError: func(ctx context.Context, call CallInfo, err error) { failed.Store(call.ID, err) },
+		},
+	})
+	require.NoError(t, err)
+
+	type TestInput struct{}
+	require.NoError(t, service.RegisterFunc(Function{
+		Name: "Quick",
+		Func: func(input TestInput) string { return "done" },
+	}))
+
+	start := func() {
+		service.lifecycleMu.Lock()
+		service.ctx, service.cancel = context.WithCancel(context.Background())
+		service.lifecycleMu.Unlock()
+	}
+	handle := func(id string) {
+		body := `{"value": {"id": "` + id + `", "service": "restarted", "targetFn": "Quick", "targetArgs": "{\"value\": {}}"}}`
+		require.NoError(t, service.handleMessage(&sqs.Message{Body: aws.String(body)}, time.Now()))
+	}
+
+	// Results still queued when the service stops are failed rather than left pending
+	start()
+	handle("call-1")
+	assert.Equal(t, 1, service.PendingPersists())
+	service.Stop()
+	require.Eventually(t, func() bool { return service.PendingPersists() == 0 }, 5*time.Second, 10*time.Millisecond)
+	_, ok := failed.Load("call-1")
+	assert.True(t, ok)
+
+	// Once the service was started again, queued results are persisted by a new worker
+	start()
+	handle("call-2")
+	slow.Store(false)
+	require.Eventually(t, func() bool { return service.PendingPersists() == 0 }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, int32(1), persisted.Load())
+	_, ok = failed.Load("call-2")
+	assert.False(t, ok)
+	service.Stop()
+}
@@ -0,0 +1,385 @@
+package inferable
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"plugin"
+	"reflect"
+	"strings"
+)
+
+// PluginManifestExt is the extension of the manifest files picked up by LoadPlugins
+const PluginManifestExt = ".inferable.json"
+
+// PluginManifest describes functions to load from Go plugins or external handler processes.
+// External handlers are either commands started for each call, exchanging JSON over stdin and stdout,
+// or long running processes serving the functions over gRPC.
+type PluginManifest struct {
+	// Service to register the functions on. Defaults to the default service.
+	Service   string           `json:"service,omitempty"`
+	Functions []PluginFunction `json:"functions"`
+}
+
+// PluginFunction describes a single function in a PluginManifest.
+// Exactly one of Plugin, Command or GRPC must be set.
+type PluginFunction struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Plugin is the path of a Go plugin (relative to the manifest) which exports Symbol.
+	// The symbol must be a function accepted by Service.RegisterFunc.
+	Plugin string `json:"plugin,omitempty"`
+	Symbol string `json:"symbol,omitempty"`
+	// Command is an external process started for each call. It receives the call input as JSON
+	// on stdin and must write the result as JSON to stdout. A non-zero exit rejects the call.
+	Command []string `json:"command,omitempty"`
+	// GRPC is an RPC of an external gRPC handler process, called through PluginOptions.DialGRPC
+	GRPC *PluginGRPC `json:"grpc,omitempty"`
+	// Schema of the function input. Required for Command and GRPC functions.
+	Schema json.RawMessage `json:"schema,omitempty"`
+}
+
+// PluginGRPC describes a function served by an external gRPC handler process.
+// The RPC receives the call input as a JSON document and must reply with the result as a JSON document,
+// so the connection returned by PluginOptions.DialGRPC must use a JSON codec.
+type PluginGRPC struct {
+	// Target of the handler process, passed to PluginOptions.DialGRPC, e.g. "unix:///tmp/tools.sock"
+	Target string `json:"target"`
+	// Method is the full name of the RPC, e.g. "/tools.Tools/Echo"
+	Method string `json:"method"`
+	// Command optionally starts the handler process when the plugins are loaded. It runs until StopPlugins
+	// is called. Functions with the same target share the process, so their commands must be equal.
+	Command []string `json:"command,omitempty"`
+}
+
+// GRPCConn invokes unary RPCs. It matches the Invoke method of *grpc.ClientConn without call options,
+// like grpcconnector.Conn, so the SDK does not depend on gRPC.
+type GRPCConn interface {
+	Invoke(ctx context.Context, method string, args, reply interface{}) error
+}
+
+// PluginOptions configures LoadPluginsWithOptions
+type PluginOptions struct {
+	// DialGRPC connects to the target of GRPC functions. It is called once per target and required if
+	// any manifest contains GRPC functions. The args and reply of the RPCs are *json.RawMessage values,
+	// which a *grpc.ClientConn sends with a JSON codec:
+	//
+	//	type jsonCodec struct{}
+	//
+	//	func (jsonCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+	//	func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+	//	func (jsonCodec) Name() string                               { return "json" }
+	//
+	//	DialGRPC: func(target string) (inferable.GRPCConn, error) {
+	//		cc, err := grpc.NewClient(target,
+	//			grpc.WithTransportCredentials(insecure.NewCredentials()),
+	//			grpc.WithDefaultCallOptions(grpc.ForceCodec(jsonCodec{}), grpc.WaitForReady(true)))
+	//		if err != nil {
+	//			return nil, err
+	//		}
+	//		return conn{cc}, nil // see grpcconnector for the adapter
+	//	}
+	//
+	// Connections implementing io.Closer are closed by StopPlugins.
+	DialGRPC func(target string) (GRPCConn, error)
+}
+
+// grpcPlugin is a connection to an external gRPC handler process, see PluginGRPC
+type grpcPlugin struct {
+	conn    GRPCConn
+	command []string
+	// cmd is the handler process, if it was started by the SDK
+	cmd *exec.Cmd
+}
+
+// LoadPlugins scans dir for manifest files ending in PluginManifestExt and registers the functions they describe.
+// This allows a generic worker binary to pick up additional functions without being recompiled.
+func (i *Inferable) LoadPlugins(dir string) error {
+	return i.LoadPluginsWithOptions(dir, PluginOptions{})
+}
+
+// LoadPluginsWithOptions is LoadPlugins with options
+func (i *Inferable) LoadPluginsWithOptions(dir string, options PluginOptions) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read plugin directory: %v", err)
+	}
+
+	i.pluginsMu.Lock()
+	existing := make(map[string]bool, len(i.grpcPlugins))
+	for target := range i.grpcPlugins {
+		existing[target] = true
+	}
+	i.pluginsMu.Unlock()
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), PluginManifestExt) {
+			continue
+		}
+
+		if err := i.loadPluginManifest(filepath.Join(dir, entry.Name()), options); err != nil {
+			// The handler processes started by this load would otherwise keep running without a service
+			// to call them, while the caller has no reason to call StopPlugins
+			i.pluginsMu.Lock()
+			started := map[string]*grpcPlugin{}
+			for target, p := range i.grpcPlugins {
+				if !existing[target] {
+					started[target] = p
+					delete(i.grpcPlugins, target)
+				}
+			}
+			i.pluginsMu.Unlock()
+
+			stopGRPCPlugins(started)
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (i *Inferable) loadPluginManifest(path string, options PluginOptions) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read plugin manifest %s: %v", path, err)
+	}
+
+	var manifest PluginManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return fmt.Errorf("failed to parse plugin manifest %s: %v", path, err)
+	}
+
+	if manifest.Service == "" {
+		manifest.Service = "default"
+	}
+
+	service, exists := i.functionRegistry.get(manifest.Service)
+	if !exists {
+		service, err = i.RegisterService(manifest.Service)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, pluginFn := range manifest.Functions {
+		fn, err := i.pluginFunction(filepath.Dir(path), pluginFn, options)
+		if err != nil {
+			return fmt.Errorf("failed to load function '%s' from %s: %v", pluginFn.Name, path, err)
+		}
+
+		if err := service.RegisterFunc(fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (i *Inferable) pluginFunction(dir string, pluginFn PluginFunction, options PluginOptions) (Function, error) {
+	fn := Function{
+		Name:        pluginFn.Name,
+		Description: pluginFn.Description,
+		InputSchema: pluginFn.Schema,
+	}
+
+	kinds := 0
+	for _, set := range []bool{pluginFn.Plugin != "", len(pluginFn.Command) > 0, pluginFn.GRPC != nil} {
+		if set {
+			kinds++
+		}
+	}
+
+	switch {
+	case kinds > 1:
+		return fn, fmt.Errorf("only one of plugin, command or grpc can be set")
+	case pluginFn.Plugin != "":
+		pluginPath := pluginFn.Plugin
+		if !filepath.IsAbs(pluginPath) {
+			pluginPath = filepath.Join(dir, pluginPath)
+		}
+
+		p, err := plugin.Open(pluginPath)
+		if err != nil {
+			return fn, fmt.Errorf("failed to open plugin: %v", err)
+		}
+
+		symbol, err := p.Lookup(pluginFn.Symbol)
+		if err != nil {
+			return fn, fmt.Errorf("failed to find symbol '%s': %v", pluginFn.Symbol, err)
+		}
+
+		fn.Func, err = pluginSymbolFunc(symbol)
+		if err != nil {
+			return fn, fmt.Errorf("symbol '%s' %v", pluginFn.Symbol, err)
+		}
+	case len(pluginFn.Command) > 0:
+		if pluginFn.Schema == nil {
+			return fn, fmt.Errorf("schema is required for command functions")
+		}
+
+		fn.Func = commandHandler(dir, pluginFn.Command)
+	case pluginFn.GRPC != nil:
+		if pluginFn.Schema == nil {
+			return fn, fmt.Errorf("schema is required for grpc functions")
+		}
+		if !strings.HasPrefix(pluginFn.GRPC.Method, "/") || strings.Count(pluginFn.GRPC.Method, "/") != 2 {
+			return fn, fmt.Errorf("grpc method must be a full method name like /package.Service/Method, got '%s'", pluginFn.GRPC.Method)
+		}
+
+		conn, err := i.grpcPluginConn(dir, *pluginFn.GRPC, options)
+		if err != nil {
+			return fn, err
+		}
+
+		fn.Func = grpcHandler(conn, pluginFn.GRPC.Method)
+	default:
+		return fn, fmt.Errorf("one of plugin, command or grpc must be set")
+	}
+
+	return fn, nil
+}
+
+// pluginSymbolFunc returns the function a plugin symbol refers to. Exported functions are looked up as
+// functions, while exported variables holding functions are looked up as pointers to them.
+func pluginSymbolFunc(symbol plugin.Symbol) (interface{}, error) {
+	value := reflect.ValueOf(symbol)
+	if value.Kind() == reflect.Pointer && value.Elem().Kind() == reflect.Func {
+		value = value.Elem()
+	}
+
+	if value.Kind() != reflect.Func {
+		return nil, fmt.Errorf("is not a function, got %T", symbol)
+	}
+	if value.IsNil() {
+		return nil, fmt.Errorf("is a nil function")
+	}
+
+	return value.Interface(), nil
+}
+
+// commandHandler returns a function which executes command for each call, passing the input on stdin
+func commandHandler(dir string, command []string) func(context.Context, json.RawMessage) (json.RawMessage, error) {
+	return func(ctx context.Context, input json.RawMessage) (json.RawMessage, error) {
+		cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+		cmd.Dir = dir
+		cmd.Stdin = bytes.NewReader(input)
+
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+
+		if err := cmd.Run(); err != nil {
+			return nil, fmt.Errorf("command failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+		}
+
+		output := bytes.TrimSpace(stdout.Bytes())
+		if !json.Valid(output) {
+			return nil, fmt.Errorf("command did not write valid JSON to stdout")
+		}
+
+		return json.RawMessage(output), nil
+	}
+}
+
+// grpcPluginConn returns the connection to the handler process serving target, starting the process and
+// dialing it the first time the target is used
+func (i *Inferable) grpcPluginConn(dir string, grpc PluginGRPC, options PluginOptions) (GRPCConn, error) {
+	if grpc.Target == "" {
+		return nil, fmt.Errorf("grpc target is required")
+	}
+	if options.DialGRPC == nil {
+		return nil, fmt.Errorf("grpc functions require PluginOptions.DialGRPC")
+	}
+
+	i.pluginsMu.Lock()
+	defer i.pluginsMu.Unlock()
+
+	if existing, ok := i.grpcPlugins[grpc.Target]; ok {
+		if !reflect.DeepEqual(existing.command, grpc.Command) && len(grpc.Command) > 0 {
+			return nil, fmt.Errorf("grpc target '%s' is already served by a different command", grpc.Target)
+		}
+		return existing.conn, nil
+	}
+
+	p := &grpcPlugin{command: grpc.Command}
+	if len(grpc.Command) > 0 {
+		p.cmd = exec.Command(grpc.Command[0], grpc.Command[1:]...)
+		p.cmd.Dir = dir
+		p.cmd.Stdout = os.Stdout
+		p.cmd.Stderr = os.Stderr
+
+		if err := p.cmd.Start(); err != nil {
+			return nil, fmt.Errorf("failed to start grpc handler process: %v", err)
+		}
+	}
+
+	conn, err := options.DialGRPC(grpc.Target)
+	if err == nil && conn == nil {
+		err = fmt.Errorf("DialGRPC returned no connection")
+	}
+	if err != nil {
+		if p.cmd != nil {
+			p.cmd.Process.Kill()
+			p.cmd.Wait()
+		}
+		return nil, fmt.Errorf("failed to dial grpc target '%s': %v", grpc.Target, err)
+	}
+	p.conn = conn
+
+	if i.grpcPlugins == nil {
+		i.grpcPlugins = make(map[string]*grpcPlugin)
+	}
+	i.grpcPlugins[grpc.Target] = p
+
+	return conn, nil
+}
+
+// StopPlugins closes the connections to gRPC handler processes and stops the processes started for them.
+// Functions served by them fail until the plugins are loaded again.
+func (i *Inferable) StopPlugins() error {
+	i.pluginsMu.Lock()
+	plugins := i.grpcPlugins
+	i.grpcPlugins = nil
+	i.pluginsMu.Unlock()
+
+	return stopGRPCPlugins(plugins)
+}
+
+// stopGRPCPlugins closes the connections of plugins and stops the processes started for them
+func stopGRPCPlugins(plugins map[string]*grpcPlugin) error {
+	var firstErr error
+	for target, p := range plugins {
+		if closer, ok := p.conn.(io.Closer); ok {
+			if err := closer.Close(); err != nil && firstErr == nil {
+				firstErr = fmt.Errorf("failed to close grpc target '%s': %v", target, err)
+			}
+		}
+		if p.cmd != nil {
+			p.cmd.Process.Kill()
+			p.cmd.Wait()
+		}
+	}
+
+	return firstErr
+}
+
+// grpcHandler returns a function which invokes method on conn, passing the input as a JSON document
+func grpcHandler(conn GRPCConn, method string) func(context.Context, json.RawMessage) (json.RawMessage, error) {
+	return func(ctx context.Context, input json.RawMessage) (json.RawMessage, error) {
+		var reply json.RawMessage
+		if err := conn.Invoke(ctx, method, &input, &reply); err != nil {
+			return nil, fmt.Errorf("grpc handler failed: %v", err)
+		}
+
+		if !json.Valid(reply) {
+			return nil, fmt.Errorf("grpc handler did not reply with valid JSON")
+		}
+
+		return reply, nil
+	}
+}
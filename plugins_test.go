@@ -0,0 +1,279 @@
+package inferable
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadPlugins(t *testing.T) {
+	var persisted struct {
+		Result     string `json:"result"`
+		ResultType string `json:"resultType"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/jobs/call-1/result" {
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&persisted))
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	manifest := `{
+		"service": "tools",
+		"functions": [{
+			"name": "echo",
+			"description": "Echoes the input",
+			"command": ["cat"],
+			"schema": {"type": "object", "properties": {"message": {"type": "string"}}}
+		}]
+	}`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "echo"+PluginManifestExt), []byte(manifest), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ignored.json"), []byte(`{}`), 0o644))
+
+	i, err := New(InferableOptions{
+		APIEndpoint: server.URL,
+		APISecret:   "test-secret",
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, i.LoadPlugins(dir))
+
+	service := i.functionRegistry.services["tools"]
+	require.NotNil(t, service)
+
+	schema, err := service.GetSchema()
+	require.NoError(t, err)
+	schemaJSON, err := json.Marshal(schema["echo"].(map[string]interface{})["input"])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type": "object", "properties": {"message": {"type": "string"}}}`, string(schemaJSON))
+
+	body := `{"value": {"id": "call-1", "service": "tools", "targetFn": "echo", "targetArgs": "{\"value\": {\"message\": \"hello\"}}"}}`
+	require.NoError(t, service.handleMessage(&sqs.Message{Body: aws.String(body)}, time.Now()))
+
+	assert.Equal(t, "resolution", persisted.ResultType)
+	assert.JSONEq(t, `{"value": {"message": "hello"}}`, persisted.Result)
+}
+
+func TestLoadPluginsInvalidManifest(t *testing.T) {
+	dir := t.TempDir()
+	manifest := `{"functions": [{"name": "missing", "command": ["cat"]}]}`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "missing"+PluginManifestExt), []byte(manifest), 0o644))
+
+	i, err := New(InferableOptions{
+		APIEndpoint: DefaultAPIEndpoint,
+		APISecret:   "test-secret",
+	})
+	require.NoError(t, err)
+
+	assert.ErrorContains(t, i.LoadPlugins(dir), "schema is required")
+}
+
+// jsonConn emulates a gRPC connection using a JSON codec, echoing the args of the RPC
+type jsonConn struct {
+	methods []string
+	closed  bool
+}
+
+func (c *jsonConn) Invoke(ctx context.Context, method string, args, reply interface{}) error {
+	c.methods = append(c.methods, method)
+	if method == "/tools.Tools/Fail" {
+		return fmt.Errorf("rpc error: code = Unavailable")
+	}
+
+	data, err := json.Marshal(args)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, reply)
+}
+
+func (c *jsonConn) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestLoadPluginsGRPC(t *testing.T) {
+	dir := t.TempDir()
+	manifest := `{
+		"service": "grpctools",
+		"functions": [{
+			"name": "echo",
+			"grpc": {"target": "unix:///tmp/tools.sock", "method": "/tools.Tools/Echo", "command": ["sleep", "60"]},
+			"schema": {"type": "object"}
+		}, {
+			"name": "fail",
+			"grpc": {"target": "unix:///tmp/tools.sock", "method": "/tools.Tools/Fail"},
+			"schema": {"type": "object"}
+		}]
+	}`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "tools"+PluginManifestExt), []byte(manifest), 0o644))
+
+	i, err := New(InferableOptions{
+		APIEndpoint: DefaultAPIEndpoint,
+		APISecret:   "test-secret",
+	})
+	require.NoError(t, err)
+
+	assert.ErrorContains(t, i.LoadPlugins(dir), "require PluginOptions.DialGRPC")
+
+	conn := &jsonConn{}
+	var dialed []string
+	require.NoError(t, i.LoadPluginsWithOptions(dir, PluginOptions{
+		DialGRPC: func(target string) (GRPCConn, error) {
+			dialed = append(dialed, target)
+			return conn, nil
+		},
+	}))
+
+	// Functions with the same target share the connection and process
+	assert.Equal(t, []string{"unix:///tmp/tools.sock"}, dialed)
+	process := i.grpcPlugins["unix:///tmp/tools.sock"].cmd
+	require.NotNil(t, process)
+
+	service := i.functionRegistry.services["grpctools"]
+	require.NotNil(t, service)
+
+	echo := service.Functions["echo"].Func.(func(context.Context, json.RawMessage) (json.RawMessage, error))
+	result, err := echo(context.Background(), json.RawMessage(`{"message": "hello"}`))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"message": "hello"}`, string(result))
+
+	fail := service.Functions["fail"].Func.(func(context.Context, json.RawMessage) (json.RawMessage, error))
+	_, err = fail(context.Background(), json.RawMessage(`{}`))
+	assert.ErrorContains(t, err, "Unavailable")
+	assert.Equal(t, []string{"/tools.Tools/Echo", "/tools.Tools/Fail"}, conn.methods)
+
+	require.NoError(t, i.StopPlugins())
+	assert.True(t, conn.closed)
+	assert.NotNil(t, process.ProcessState, "handler process should have exited")
+}
+
+func TestLoadPluginsGRPCCleanup(t *testing.T) {
+	loaded := t.TempDir()
+	manifest := `{"functions": [{"name": "loaded", "grpc": {"target": "localhost:1", "method": "/a.A/A"}, "schema": {}}]}`
+	require.NoError(t, os.WriteFile(filepath.Join(loaded, "loaded"+PluginManifestExt), []byte(manifest), 0o644))
+
+	// The second manifest fails after the first one started a handler process
+	dir := t.TempDir()
+	pidFile := filepath.Join(dir, "pid")
+	manifest = `{"service": "started", "functions": [{"name": "started", "grpc": {"target": "localhost:2", "method": "/a.A/A", "command": ["sh", "-c", "echo $$ > pid; exec sleep 60"]}, "schema": {}}]}`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a"+PluginManifestExt), []byte(manifest), 0o644))
+	manifest = `{"functions": [{"name": "invalid", "grpc": {"target": "localhost:3", "method": "/a.A/A"}}]}`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b"+PluginManifestExt), []byte(manifest), 0o644))
+
+	i, err := New(InferableOptions{
+		APIEndpoint: DefaultAPIEndpoint,
+		APISecret:   "test-secret",
+	})
+	require.NoError(t, err)
+
+	conns := map[string]*jsonConn{}
+	options := PluginOptions{
+		DialGRPC: func(target string) (GRPCConn, error) {
+			// Wait for the handler process to be up, like a connection waiting for the server to be ready
+			for deadline := time.Now().Add(5 * time.Second); target == "localhost:2" && time.Now().Before(deadline); {
+				if _, err := os.Stat(pidFile); err == nil {
+					break
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			conns[target] = &jsonConn{}
+			return conns[target], nil
+		},
+	}
+
+	require.NoError(t, i.LoadPluginsWithOptions(loaded, options))
+	assert.ErrorContains(t, i.LoadPluginsWithOptions(dir, options), "schema is required")
+
+	// Only the plugins of the failed load are stopped
+	assert.True(t, conns["localhost:2"].closed)
+	assert.False(t, conns["localhost:1"].closed)
+	assert.Contains(t, i.grpcPlugins, "localhost:1")
+	assert.NotContains(t, i.grpcPlugins, "localhost:2")
+
+	data, err := os.ReadFile(pidFile)
+	require.NoError(t, err)
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	require.NoError(t, err)
+	process, err := os.FindProcess(pid)
+	require.NoError(t, err)
+	assert.ErrorIs(t, process.Signal(syscall.Signal(0)), os.ErrProcessDone, "handler process should have been stopped")
+}
+
+func TestLoadPluginsGRPCInvalid(t *testing.T) {
+	dial := PluginOptions{DialGRPC: func(target string) (GRPCConn, error) { return &jsonConn{}, nil }}
+
+	for name, function := range map[string]string{
+		"schema is required":          `{"name": "a", "grpc": {"target": "localhost:1", "method": "/a.A/A"}}`,
+		"full method name":            `{"name": "a", "grpc": {"target": "localhost:1", "method": "A"}, "schema": {}}`,
+		"grpc target is required":     `{"name": "a", "grpc": {"method": "/a.A/A"}, "schema": {}}`,
+		"only one of plugin, command": `{"name": "a", "command": ["cat"], "grpc": {"target": "localhost:1", "method": "/a.A/A"}, "schema": {}}`,
+	} {
+		dir := t.TempDir()
+		manifest := `{"functions": [` + function + `]}`
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "invalid"+PluginManifestExt), []byte(manifest), 0o644))
+
+		i, err := New(InferableOptions{
+			APIEndpoint: DefaultAPIEndpoint,
+			APISecret:   "test-secret",
+		})
+		require.NoError(t, err)
+
+		assert.ErrorContains(t, i.LoadPluginsWithOptions(dir, dial), name)
+	}
+}
+
+func TestPluginSymbolFunc(t *testing.T) {
+	type TestInput struct{}
+	fn := func(input TestInput) string { return "done" }
+
+	// Exported functions are looked up as functions, and exported variables as pointers
+	resolved, err := pluginSymbolFunc(fn)
+	require.NoError(t, err)
+	assert.IsType(t, fn, resolved)
+
+	resolved, err = pluginSymbolFunc(&fn)
+	require.NoError(t, err)
+	assert.IsType(t, fn, resolved)
+
+	count := 3
+	_, err = pluginSymbolFunc(&count)
+	assert.ErrorContains(t, err, "is not a function")
+
+	var missing func(input TestInput) string
+	_, err = pluginSymbolFunc(&missing)
+	assert.ErrorContains(t, err, "nil function")
+}
+
+func TestRegisterFuncRequiresFunction(t *testing.T) {
+	i, err := New(InferableOptions{
+		APIEndpoint: DefaultAPIEndpoint,
+		APISecret:   "test-secret",
+	})
+	require.NoError(t, err)
+
+	service, err := i.RegisterService("plugins")
+	require.NoError(t, err)
+
+	type TestInput struct{}
+	var missing func(input TestInput) string
+	for _, value := range []interface{}{nil, 3, "fn", missing} {
+		assert.ErrorContains(t, service.RegisterFunc(Function{Name: "invalid", Func: value}), "must be a non-nil func")
+	}
+}
@@ -0,0 +1,350 @@
+// Package postgres registers functions which give agents structured access to an allowlist of Postgres tables.
+//
+// The functions getSchema, query and execute are registered on the given registrar. Use a Scope to
+// prefix their names when connecting more than one database:
+//
+//	db, _ := sql.Open("postgres", dsn)
+//	err := postgres.Register(i.Default.Scope("crm"), db, postgres.Options{Tables: []string{"customers"}})
+//
+// Statements are built from the structured input with quoted identifiers and bound parameters,
+// so agents never write raw SQL.
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	inferable "github.com/inferablehq/inferable-go"
+)
+
+// DefaultMaxRows is the number of rows returned by query when Options.MaxRows is not set
+const DefaultMaxRows = 100
+
+// Options configures the registered functions
+type Options struct {
+	// Tables the functions may access. Required.
+	Tables []string
+	// Schema of the tables. Defaults to "public".
+	Schema string
+	// MaxRows caps the rows returned by a query. Defaults to DefaultMaxRows.
+	MaxRows int
+	// ReadOnly skips the registration of the execute function
+	ReadOnly bool
+}
+
+// GetSchemaInput is the input of the getSchema function
+type GetSchemaInput struct{}
+
+// Column describes a column of an allowed table
+type Column struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Nullable bool   `json:"nullable"`
+}
+
+// QueryInput is the input of the query function
+type QueryInput struct {
+	Table string `json:"table" jsonschema:"description=Table to query"`
+	// Columns to return. All columns are returned if empty.
+	Columns []string `json:"columns,omitempty" jsonschema:"description=Columns to return, all columns if empty"`
+	// Where filters rows by column equality
+	Where   map[string]interface{} `json:"where,omitempty" jsonschema:"description=Filters rows by column equality"`
+	OrderBy string                 `json:"orderBy,omitempty" jsonschema:"description=Column to order the rows by"`
+	Desc    bool                   `json:"desc,omitempty" jsonschema:"description=Order the rows in descending order"`
+	Limit   int                    `json:"limit,omitempty" jsonschema:"description=Maximum number of rows to return"`
+}
+
+// ExecuteInput is the input of the execute function
+type ExecuteInput struct {
+	Table  string `json:"table" jsonschema:"description=Table to modify"`
+	Action string `json:"action" jsonschema:"enum=insert,enum=update,enum=delete"`
+	// Values to insert or set
+	Values map[string]interface{} `json:"values,omitempty" jsonschema:"description=Column values to insert or set"`
+	// Where selects the rows to update or delete by column equality. Required for updates and deletes.
+	Where map[string]interface{} `json:"where,omitempty" jsonschema:"description=Selects the rows to update or delete by column equality"`
+}
+
+// ExecuteResult is the result of the execute function
+type ExecuteResult struct {
+	RowsAffected int64 `json:"rowsAffected"`
+}
+
+type connector struct {
+	db      *sql.DB
+	schema  string
+	tables  map[string]bool
+	names   []string
+	maxRows int
+}
+
+// Register registers the getSchema, query and (unless Options.ReadOnly is set) execute functions
+func Register(registrar inferable.FunctionRegistrar, db *sql.DB, options Options) error {
+	if db == nil {
+		return fmt.Errorf("db is required")
+	}
+	if len(options.Tables) == 0 {
+		return fmt.Errorf("at least one table must be allowed")
+	}
+	if options.MaxRows < 0 {
+		return fmt.Errorf("max rows must not be negative")
+	}
+
+	c := &connector{
+		db:      db,
+		schema:  options.Schema,
+		tables:  map[string]bool{},
+		maxRows: options.MaxRows,
+	}
+	if c.schema == "" {
+		c.schema = "public"
+	}
+	if c.maxRows == 0 {
+		c.maxRows = DefaultMaxRows
+	}
+	for _, table := range options.Tables {
+		c.tables[table] = true
+	}
+	c.names = append(c.names, options.Tables...)
+	sort.Strings(c.names)
+
+	functions := []inferable.Function{
+		{
+			Name:        "getSchema",
+			Description: fmt.Sprintf("Lists the columns of the tables %s", strings.Join(c.names, ", ")),
+			Func:        c.getSchema,
+		},
+		{
+			Name:        "query",
+			Description: fmt.Sprintf("Queries rows of a table, returning at most %d rows", c.maxRows),
+			Func:        c.query,
+		},
+	}
+	if !options.ReadOnly {
+		functions = append(functions, inferable.Function{
+			Name:        "execute",
+			Description: "Inserts, updates or deletes rows of a table",
+			Func:        c.execute,
+		})
+	}
+
+	for _, fn := range functions {
+		if err := registrar.RegisterFunc(fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *connector) getSchema(ctx context.Context, input GetSchemaInput) (map[string][]Column, error) {
+	schema := map[string][]Column{}
+
+	for _, table := range c.names {
+		rows, err := c.db.QueryContext(ctx,
+			"SELECT column_name, data_type, is_nullable FROM information_schema.columns WHERE table_schema = $1 AND table_name = $2 ORDER BY ordinal_position",
+			c.schema, table)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read columns of table '%s': %v", table, err)
+		}
+
+		columns := []Column{}
+		for rows.Next() {
+			var column Column
+			var nullable string
+			if err := rows.Scan(&column.Name, &column.Type, &nullable); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to read columns of table '%s': %v", table, err)
+			}
+			column.Nullable = nullable == "YES"
+			columns = append(columns, column)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read columns of table '%s': %v", table, err)
+		}
+
+		schema[table] = columns
+	}
+
+	return schema, nil
+}
+
+func (c *connector) query(ctx context.Context, input QueryInput) ([]map[string]interface{}, error) {
+	table, err := c.table(input.Table)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := "*"
+	if len(input.Columns) > 0 {
+		quoted := make([]string, len(input.Columns))
+		for idx, column := range input.Columns {
+			quoted[idx] = quoteIdentifier(column)
+		}
+		columns = strings.Join(quoted, ", ")
+	}
+
+	limit := input.Limit
+	if limit <= 0 || limit > c.maxRows {
+		limit = c.maxRows
+	}
+
+	where, args := whereClause(input.Where, 1)
+	statement := fmt.Sprintf("SELECT %s FROM %s%s", columns, table, where)
+	if input.OrderBy != "" {
+		statement += " ORDER BY " + quoteIdentifier(input.OrderBy)
+		if input.Desc {
+			statement += " DESC"
+		}
+	}
+	statement += fmt.Sprintf(" LIMIT %d", limit)
+
+	rows, err := c.db.QueryContext(ctx, statement, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
+
+	names, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+
+	results := []map[string]interface{}{}
+	for rows.Next() {
+		values := make([]interface{}, len(names))
+		pointers := make([]interface{}, len(names))
+		for idx := range values {
+			pointers[idx] = &values[idx]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, fmt.Errorf("failed to read row: %v", err)
+		}
+
+		row := make(map[string]interface{}, len(names))
+		for idx, name := range names {
+			// Text columns are scanned as bytes by most drivers
+			if value, ok := values[idx].([]byte); ok {
+				row[name] = string(value)
+			} else {
+				row[name] = values[idx]
+			}
+		}
+		results = append(results, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+
+	return results, nil
+}
+
+func (c *connector) execute(ctx context.Context, input ExecuteInput) (ExecuteResult, error) {
+	table, err := c.table(input.Table)
+	if err != nil {
+		return ExecuteResult{}, err
+	}
+
+	var statement string
+	var args []interface{}
+
+	switch input.Action {
+	case "insert":
+		if len(input.Values) == 0 {
+			return ExecuteResult{}, fmt.Errorf("values are required to insert rows")
+		}
+		columns, values := sortedColumns(input.Values)
+		quoted := make([]string, len(columns))
+		placeholders := make([]string, len(columns))
+		for idx, column := range columns {
+			quoted[idx] = quoteIdentifier(column)
+			placeholders[idx] = fmt.Sprintf("$%d", idx+1)
+		}
+		statement = fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", table, strings.Join(quoted, ", "), strings.Join(placeholders, ", "))
+		args = values
+	case "update":
+		if len(input.Values) == 0 {
+			return ExecuteResult{}, fmt.Errorf("values are required to update rows")
+		}
+		if len(input.Where) == 0 {
+			return ExecuteResult{}, fmt.Errorf("where is required to update rows")
+		}
+		columns, values := sortedColumns(input.Values)
+		assignments := make([]string, len(columns))
+		for idx, column := range columns {
+			assignments[idx] = fmt.Sprintf("%s = $%d", quoteIdentifier(column), idx+1)
+		}
+		where, whereArgs := whereClause(input.Where, len(values)+1)
+		statement = fmt.Sprintf("UPDATE %s SET %s%s", table, strings.Join(assignments, ", "), where)
+		args = append(values, whereArgs...)
+	case "delete":
+		if len(input.Where) == 0 {
+			return ExecuteResult{}, fmt.Errorf("where is required to delete rows")
+		}
+		where, whereArgs := whereClause(input.Where, 1)
+		statement = fmt.Sprintf("DELETE FROM %s%s", table, where)
+		args = whereArgs
+	default:
+		return ExecuteResult{}, fmt.Errorf("unknown action '%s', expected insert, update or delete", input.Action)
+	}
+
+	result, err := c.db.ExecContext(ctx, statement, args...)
+	if err != nil {
+		return ExecuteResult{}, fmt.Errorf("%s failed: %v", input.Action, err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return ExecuteResult{}, fmt.Errorf("failed to read affected rows: %v", err)
+	}
+
+	return ExecuteResult{RowsAffected: affected}, nil
+}
+
+// table returns the qualified and quoted name of an allowed table
+func (c *connector) table(name string) (string, error) {
+	if !c.tables[name] {
+		return "", fmt.Errorf("table '%s' is not allowed, expected one of %s", name, strings.Join(c.names, ", "))
+	}
+
+	return quoteIdentifier(c.schema) + "." + quoteIdentifier(name), nil
+}
+
+// whereClause builds an equality filter with placeholders numbered from first
+func whereClause(where map[string]interface{}, first int) (string, []interface{}) {
+	if len(where) == 0 {
+		return "", nil
+	}
+
+	columns, values := sortedColumns(where)
+	conditions := make([]string, len(columns))
+	for idx, column := range columns {
+		conditions[idx] = fmt.Sprintf("%s = $%d", quoteIdentifier(column), first+idx)
+	}
+
+	return " WHERE " + strings.Join(conditions, " AND "), values
+}
+
+// sortedColumns returns the columns and values of a map in a stable order
+func sortedColumns(values map[string]interface{}) ([]string, []interface{}) {
+	columns := make([]string, 0, len(values))
+	for column := range values {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	args := make([]interface{}, len(columns))
+	for idx, column := range columns {
+		args[idx] = values[column]
+	}
+
+	return columns, args
+}
+
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
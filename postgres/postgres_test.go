@@ -0,0 +1,155 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"testing"
+
+	inferable "github.com/inferablehq/inferable-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDriver records statements and answers queries with canned rows
+type fakeDriver struct {
+	statements []string
+	args       [][]driver.Value
+	columns    []string
+	rows       [][]driver.Value
+}
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) { return &fakeConn{d}, nil }
+
+type fakeConn struct{ d *fakeDriver }
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) { return &fakeStmt{c.d, query}, nil }
+func (c *fakeConn) Close() error                              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error)                 { return nil, driver.ErrSkip }
+
+type fakeStmt struct {
+	d     *fakeDriver
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.d.statements = append(s.d.statements, s.query)
+	s.d.args = append(s.d.args, args)
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.d.statements = append(s.d.statements, s.query)
+	s.d.args = append(s.d.args, args)
+	return &fakeRows{columns: s.d.columns, rows: s.d.rows}, nil
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+type registrar struct {
+	functions map[string]inferable.Function
+}
+
+func (r *registrar) RegisterFunc(fn inferable.Function) error {
+	r.functions[fn.Name] = fn
+	return nil
+}
+
+func openFake(t *testing.T, name string) (*sql.DB, *fakeDriver) {
+	d := &fakeDriver{}
+	sql.Register(name, d)
+	db, err := sql.Open(name, "")
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db, d
+}
+
+func TestRegister(t *testing.T) {
+	db, _ := openFake(t, "fake-register")
+
+	r := &registrar{functions: map[string]inferable.Function{}}
+	require.NoError(t, Register(r, db, Options{Tables: []string{"customers"}}))
+	assert.Len(t, r.functions, 3)
+
+	r = &registrar{functions: map[string]inferable.Function{}}
+	require.NoError(t, Register(r, db, Options{Tables: []string{"customers"}, ReadOnly: true}))
+	assert.NotContains(t, r.functions, "execute")
+
+	assert.ErrorContains(t, Register(r, db, Options{}), "at least one table")
+}
+
+func TestQuery(t *testing.T) {
+	db, d := openFake(t, "fake-query")
+	d.columns = []string{"id", "name"}
+	d.rows = [][]driver.Value{{int64(1), []byte("Ada")}}
+
+	c := &connector{db: db, schema: "public", tables: map[string]bool{"customers": true}, names: []string{"customers"}, maxRows: 10}
+
+	rows, err := c.query(context.Background(), QueryInput{
+		Table:   "customers",
+		Columns: []string{"id", "name"},
+		Where:   map[string]interface{}{"name": "Ada", "active": true},
+		OrderBy: "id",
+		Desc:    true,
+		Limit:   1000,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []map[string]interface{}{{"id": int64(1), "name": "Ada"}}, rows)
+	assert.Equal(t, `SELECT "id", "name" FROM "public"."customers" WHERE "active" = $1 AND "name" = $2 ORDER BY "id" DESC LIMIT 10`, d.statements[0])
+	assert.Equal(t, []driver.Value{true, "Ada"}, d.args[0])
+
+	_, err = c.query(context.Background(), QueryInput{Table: "users"})
+	assert.ErrorContains(t, err, "table 'users' is not allowed")
+
+	_, err = c.query(context.Background(), QueryInput{Table: "customers", Columns: []string{`name"; DROP TABLE customers; --`}})
+	require.NoError(t, err)
+	assert.Equal(t, `SELECT "name""; DROP TABLE customers; --" FROM "public"."customers" LIMIT 10`, d.statements[1])
+}
+
+func TestExecute(t *testing.T) {
+	db, d := openFake(t, "fake-execute")
+	c := &connector{db: db, schema: "public", tables: map[string]bool{"customers": true}, names: []string{"customers"}, maxRows: 10}
+	ctx := context.Background()
+
+	result, err := c.execute(ctx, ExecuteInput{Table: "customers", Action: "insert", Values: map[string]interface{}{"name": "Ada", "id": 1}})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), result.RowsAffected)
+
+	_, err = c.execute(ctx, ExecuteInput{Table: "customers", Action: "update", Values: map[string]interface{}{"name": "Grace"}, Where: map[string]interface{}{"id": 1}})
+	require.NoError(t, err)
+
+	_, err = c.execute(ctx, ExecuteInput{Table: "customers", Action: "delete", Where: map[string]interface{}{"id": 1}})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		`INSERT INTO "public"."customers" ("id", "name") VALUES ($1, $2)`,
+		`UPDATE "public"."customers" SET "name" = $1 WHERE "id" = $2`,
+		`DELETE FROM "public"."customers" WHERE "id" = $1`,
+	}, d.statements)
+	assert.Equal(t, []driver.Value{"Grace", int64(1)}, d.args[1])
+
+	_, err = c.execute(ctx, ExecuteInput{Table: "customers", Action: "delete"})
+	assert.ErrorContains(t, err, "where is required")
+
+	_, err = c.execute(ctx, ExecuteInput{Table: "customers", Action: "truncate"})
+	assert.ErrorContains(t, err, "unknown action")
+}
@@ -0,0 +1,75 @@
+package inferable
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// tokenBucket limits the rate of requests, see ClientOptions.RequestsPerSecond. Requests take a token each,
+// which are replenished at rate per second up to burst. Rate limited responses pause all requests until
+// their Retry-After has passed.
+type tokenBucket struct {
+	rate  float64
+	burst float64
+
+	mu          sync.Mutex
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// reserve takes a token, returning 0, or returns how long to wait until one is available
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if now.Before(b.pausedUntil) {
+		return b.pausedUntil.Sub(now)
+	}
+
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+// wait blocks until a request may be sent, or ctx is done
+func (b *tokenBucket) wait(ctx context.Context) error {
+	for {
+		delay := b.reserve(time.Now())
+		if delay == 0 {
+			return nil
+		}
+
+		if !sleepContext(ctx, delay) {
+			return ctx.Err()
+		}
+	}
+}
+
+// pause holds back all requests for d, e.g. when the API asked us to slow down
+func (b *tokenBucket) pause(d time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if until := time.Now().Add(d); until.After(b.pausedUntil) {
+		b.pausedUntil = until
+	}
+}
@@ -0,0 +1,73 @@
+package inferable
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenBucket(t *testing.T) {
+	bucket := newTokenBucket(10, 2)
+	now := bucket.last
+
+	// The burst is available at once, further requests wait for tokens to be replenished
+	assert.Zero(t, bucket.reserve(now))
+	assert.Zero(t, bucket.reserve(now))
+	assert.Equal(t, 100*time.Millisecond, bucket.reserve(now))
+	assert.Zero(t, bucket.reserve(now.Add(100*time.Millisecond)))
+
+	// Tokens are not replenished past the burst
+	assert.Zero(t, bucket.reserve(now.Add(time.Hour)))
+	assert.Zero(t, bucket.reserve(now.Add(time.Hour)))
+	assert.NotZero(t, bucket.reserve(now.Add(time.Hour)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	bucket.pause(time.Minute)
+	assert.ErrorIs(t, bucket.wait(ctx), context.Canceled)
+}
+
+func TestClientRateLimit(t *testing.T) {
+	var requests atomic.Int32
+	var rateLimited atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if rateLimited.CompareAndSwap(true, false) {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	_, err := NewClient(ClientOptions{Endpoint: server.URL, RequestsPerSecond: -1})
+	assert.Error(t, err)
+
+	client, err := NewClient(ClientOptions{Endpoint: server.URL, Secret: "test-secret", RequestsPerSecond: 20})
+	require.NoError(t, err)
+
+	start := time.Now()
+	for range 3 {
+		_, err := client.FetchData(FetchDataOptions{Path: "/live", Method: "GET"})
+		require.NoError(t, err)
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+
+	// A rate limited response holds back all requests until its Retry-After has passed
+	rateLimited.Store(true)
+	_, err = client.FetchData(FetchDataOptions{Path: "/live", Method: "GET"})
+	require.Error(t, err)
+
+	start = time.Now()
+	_, err = client.FetchData(FetchDataOptions{Path: "/live", Method: "GET"})
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 900*time.Millisecond)
+	assert.Equal(t, int32(5), requests.Load())
+}
@@ -0,0 +1,159 @@
+package inferable
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// redactedValue replaces the values of redacted fields
+const redactedValue = `"[REDACTED]"`
+
+// redaction describes the fields of a JSON value to redact. A field mapped to nil is redacted,
+// other fields are descended into. It applies to each element of arrays.
+type redaction struct {
+	fields map[string]*redaction
+}
+
+// redactionCache holds the redaction of each type, which is nil for types without redacted fields
+var redactionCache sync.Map // reflect.Type -> *redaction
+
+// isRedacted reports whether a struct field is tagged `redact:"true"` or `inferable:"secret"`
+func isRedacted(field reflect.StructField) bool {
+	return field.Tag.Get("redact") == "true" || hasInferableOption(field, "secret")
+}
+
+// redactionFor returns the redaction of the fields of t which are tagged as secret, or nil if there are none
+func redactionFor(t reflect.Type) *redaction {
+	if cached, ok := redactionCache.Load(t); ok {
+		return cached.(*redaction)
+	}
+
+	r := buildRedaction(t, map[reflect.Type]bool{})
+	redactionCache.Store(t, r)
+	return r
+}
+
+func buildRedaction(t reflect.Type, visiting map[reflect.Type]bool) *redaction {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || visiting[t] {
+		return nil
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	fields := map[string]*redaction{}
+	for idx := 0; idx < t.NumField(); idx++ {
+		field := t.Field(idx)
+		if !field.IsExported() {
+			continue
+		}
+
+		name := field.Name
+		if tag, _, _ := strings.Cut(field.Tag.Get("json"), ","); tag == "-" {
+			continue
+		} else if tag != "" {
+			name = tag
+		}
+
+		if isRedacted(field) {
+			fields[name] = nil
+		} else if nested := buildRedaction(field.Type, visiting); nested != nil {
+			fields[name] = nested
+		}
+	}
+
+	if len(fields) == 0 {
+		return nil
+	}
+
+	return &redaction{fields: fields}
+}
+
+// apply returns data with the redacted fields replaced. Values which do not match the redaction are returned unchanged.
+func (r *redaction) apply(data json.RawMessage) json.RawMessage {
+	if r == nil {
+		return data
+	}
+
+	var elements []json.RawMessage
+	if err := json.Unmarshal(data, &elements); err == nil {
+		for idx, element := range elements {
+			elements[idx] = r.apply(element)
+		}
+		redacted, err := json.Marshal(elements)
+		if err != nil {
+			return data
+		}
+		return redacted
+	}
+
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(data, &object); err != nil {
+		return data
+	}
+
+	for name, nested := range r.fields {
+		value, ok := object[name]
+		if !ok {
+			continue
+		}
+		if nested == nil {
+			object[name] = json.RawMessage(redactedValue)
+		} else {
+			object[name] = nested.apply(value)
+		}
+	}
+
+	redacted, err := json.Marshal(object)
+	if err != nil {
+		return data
+	}
+	return redacted
+}
+
+// redactedCallInput returns the input of a call ({"value": ...}) with the secret fields of argType redacted,
+// or false if argType has no secret fields
+func redactedCallInput(argType reflect.Type, targetArgs []byte) ([]byte, bool) {
+	r := redactionFor(argType)
+	if r == nil {
+		return nil, false
+	}
+
+	return (&redaction{fields: map[string]*redaction{"value": r}}).apply(targetArgs), true
+}
+
+// resultMarshaler returns the marshaler of the results of fn, which redacts the secret fields of results
+// if FunctionConfig.RedactResults is set. Results are marshaled with codec unless the function has a marshaler.
+func resultMarshaler(fn Function, codec JSONCodec) func(interface{}) ([]byte, error) {
+	if !fn.Config.RedactResults {
+		return fn.Config.ResultMarshaler
+	}
+
+	return func(value interface{}) ([]byte, error) {
+		var resultJSON []byte
+		var err error
+		if fn.Config.ResultMarshaler != nil {
+			resultJSON, err = fn.Config.ResultMarshaler(value)
+		} else {
+			resultJSON, err = codec.Marshal(value)
+		}
+		if err != nil || value == nil {
+			return resultJSON, err
+		}
+
+		return redactionFor(reflect.TypeOf(value)).apply(resultJSON), nil
+	}
+}
+
+// redactedInput returns the input of a call to fn with its secret fields redacted, or false if it has none
+func redactedInput(fn Function, registered bool, targetArgs []byte) ([]byte, bool) {
+	if !registered || fn.Func == nil {
+		return nil, false
+	}
+
+	return redactedCallInput(fn.compiled().argType, targetArgs)
+}
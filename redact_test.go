@@ -0,0 +1,89 @@
+package inferable
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type redactedContact struct {
+	Email string `json:"email" redact:"true"`
+	Phone string `json:"phone" inferable:"secret"`
+	City  string `json:"city"`
+}
+
+type redactedCustomer struct {
+	Name     string            `json:"name"`
+	Token    string            `redact:"true"`
+	Contacts []redactedContact `json:"contacts"`
+	Primary  *redactedContact  `json:"primary,omitempty"`
+}
+
+func TestRedaction(t *testing.T) {
+	assert.Nil(t, redactionFor(reflect.TypeOf(struct{ Name string }{})))
+
+	data, err := json.Marshal(redactedCustomer{
+		Name:     "Ada",
+		Token:    "tok",
+		Contacts: []redactedContact{{Email: "ada@example.com", Phone: "555", City: "London"}},
+		Primary:  &redactedContact{Email: "ada@example.com"},
+	})
+	require.NoError(t, err)
+
+	redacted := redactionFor(reflect.TypeOf(redactedCustomer{})).apply(data)
+	assert.JSONEq(t, `{
+		"name": "Ada",
+		"Token": "[REDACTED]",
+		"contacts": [{"email": "[REDACTED]", "phone": "[REDACTED]", "city": "London"}],
+		"primary": {"email": "[REDACTED]", "phone": "[REDACTED]", "city": ""}
+	}`, string(redacted))
+}
+
+func TestRedactedFields(t *testing.T) {
+	var persisted persistedResult
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/jobs/call-1/result" {
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&persisted))
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	i, err := New(InferableOptions{
+		APIEndpoint: server.URL,
+		APISecret:   "test-secret",
+		LogLevel:    LogLevelDebug,
+	})
+	require.NoError(t, err)
+
+	err = i.Default.RegisterFunc(Function{
+		Name:   "Echo",
+		Func:   func(input redactedContact) redactedContact { return input },
+		Config: FunctionConfig{RedactResults: true},
+	})
+	require.NoError(t, err)
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	body := `{"value": {"id": "call-1", "service": "default", "targetFn": "Echo", "targetArgs": "{\"value\": {\"email\": \"ada@example.com\", \"city\": \"London\"}}"}}`
+	require.NoError(t, i.Default.handleMessage(&sqs.Message{Body: aws.String(body)}, time.Now()))
+
+	assert.NotContains(t, logs.String(), "ada@example.com")
+	assert.Contains(t, logs.String(), "London")
+	assert.NotContains(t, persisted.Result, "ada@example.com")
+	assert.Contains(t, persisted.Result, "[REDACTED]")
+	assert.Contains(t, persisted.Result, "London")
+}
@@ -0,0 +1,72 @@
+package inferable
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// FunctionDescriber can be implemented by receivers passed to RegisterStruct
+// to describe their functions, keyed by method name
+type FunctionDescriber interface {
+	Description() map[string]string
+}
+
+// RegisterStruct registers every exported method of receiver which takes a single struct input
+// (optionally preceded by a context.Context) as a function named after the method.
+// Descriptions are taken from the Description method of the receiver, if it implements FunctionDescriber.
+// Other methods are skipped.
+func (s *Service) RegisterStruct(receiver interface{}) error {
+	return registerStruct(s, receiver)
+}
+
+// RegisterStruct registers the methods of receiver in the scope, see Service.RegisterStruct
+func (sc *Scope) RegisterStruct(receiver interface{}) error {
+	return registerStruct(sc, receiver)
+}
+
+func registerStruct(registrar FunctionRegistrar, receiver interface{}) error {
+	if receiver == nil {
+		return fmt.Errorf("receiver must not be nil")
+	}
+
+	value := reflect.ValueOf(receiver)
+	descriptions := map[string]string{}
+	if describer, ok := receiver.(FunctionDescriber); ok {
+		descriptions = describer.Description()
+	}
+
+	registered := 0
+	for idx := 0; idx < value.NumMethod(); idx++ {
+		method := value.Type().Method(idx)
+		fn := value.Method(idx)
+
+		if !isFunctionSignature(fn.Type()) {
+			continue
+		}
+
+		err := registrar.RegisterFunc(Function{
+			Name:        method.Name,
+			Description: descriptions[method.Name],
+			Func:        fn.Interface(),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to register method '%s' of %s: %v", method.Name, value.Type(), err)
+		}
+		registered++
+	}
+
+	if registered == 0 {
+		return fmt.Errorf("%s has no exported methods taking a single struct input", value.Type())
+	}
+
+	return nil
+}
+
+// isFunctionSignature reports whether fnType takes a single struct input, optionally preceded by a context
+func isFunctionSignature(fnType reflect.Type) bool {
+	if fnType.NumIn() != 1 && !acceptsContext(fnType) {
+		return false
+	}
+
+	return inputType(fnType).Kind() == reflect.Struct
+}
@@ -0,0 +1,57 @@
+package inferable
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type OrderInput struct {
+	ID string `json:"id"`
+}
+
+type orderAPI struct {
+	prefix string
+}
+
+func (o *orderAPI) GetOrder(input OrderInput) string {
+	return o.prefix + input.ID
+}
+
+func (o *orderAPI) CancelOrder(ctx context.Context, input OrderInput) (string, error) {
+	return "cancelled " + input.ID, nil
+}
+
+func (o *orderAPI) Helper(id string) string {
+	return id
+}
+
+func (o *orderAPI) Description() map[string]string {
+	return map[string]string{"GetOrder": "Get an order by ID"}
+}
+
+func TestRegisterStruct(t *testing.T) {
+	i, err := New(InferableOptions{
+		APIEndpoint: DefaultAPIEndpoint,
+		APISecret:   "test-secret",
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, i.Default.RegisterStruct(&orderAPI{prefix: "order-"}))
+
+	assert.Len(t, i.Default.Functions, 2)
+	assert.Equal(t, "Get an order by ID", i.Default.Functions["GetOrder"].Description)
+	assert.Contains(t, i.Default.Functions, "CancelOrder")
+
+	// Methods are bound to the receiver
+	result, err := i.CallFunc("default", "GetOrder", OrderInput{ID: "42"})
+	require.NoError(t, err)
+	assert.Equal(t, "order-42", result[0].String())
+
+	require.NoError(t, i.Default.Scope("orders").RegisterStruct(&orderAPI{}))
+	assert.Contains(t, i.Default.Functions, "orders_GetOrder")
+
+	assert.Error(t, i.Default.RegisterStruct(struct{}{}))
+}
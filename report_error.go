@@ -0,0 +1,45 @@
+package inferable
+
+import "fmt"
+
+// ErrorKind tells where an error reported to InferableOptions.OnError occurred
+type ErrorKind string
+
+const (
+	// ErrorKindPoll is reported when polling the queue for calls failed
+	ErrorKindPoll ErrorKind = "poll"
+	// ErrorKindPanic is reported when a function or the poll loop panicked
+	ErrorKindPanic ErrorKind = "panic"
+	// ErrorKindPersist is reported when the result of a call could not be persisted
+	ErrorKindPersist ErrorKind = "persist"
+)
+
+// ErrorContext describes an error reported to InferableOptions.OnError
+type ErrorContext struct {
+	Kind    ErrorKind
+	Service string
+	// Call is the call the error occurred in, nil for errors which are not specific to a call
+	Call *CallInfo
+	// Stack is the stack trace of panics
+	Stack string
+}
+
+// reportError passes an error to InferableOptions.OnError, if set
+func (s *Service) reportError(err error, errCtx ErrorContext) {
+	if s.inferable.onError == nil {
+		return
+	}
+
+	errCtx.Service = s.Name
+	s.inferable.onError(err, errCtx)
+}
+
+// callPanic carries a panic of a function out of handleMessage once it has been reported,
+// so that it is not reported again by the poll loop
+type callPanic struct {
+	value interface{}
+}
+
+func (p callPanic) String() string {
+	return fmt.Sprint(p.value)
+}
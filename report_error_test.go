@@ -0,0 +1,109 @@
+package inferable
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOnError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/result") {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "invalid result"}`))
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	type reported struct {
+		err    error
+		errCtx ErrorContext
+	}
+	var errs []reported
+	i, err := New(InferableOptions{
+		APIEndpoint: server.URL,
+		APISecret:   "test-secret",
+		OnError:     func(err error, errCtx ErrorContext) { errs = append(errs, reported{err, errCtx}) },
+	})
+	require.NoError(t, err)
+
+	type TestInput struct{}
+	require.NoError(t, i.Default.RegisterFunc(Function{Name: "Quick", Func: func(input TestInput) int { return 1 }}))
+	require.NoError(t, i.Default.RegisterFunc(Function{Name: "Boom", Func: func(input TestInput) int { panic("boom") }}))
+
+	// Failed polls
+	i.Default.observePoll(errors.New("poll failed"))
+	require.Len(t, errs, 1)
+	assert.EqualError(t, errs[0].err, "poll failed")
+	assert.Equal(t, ErrorContext{Kind: ErrorKindPoll, Service: "default"}, errs[0].errCtx)
+
+	// Results which could not be persisted
+	body := `{"value": {"id": "call-1", "service": "default", "targetFn": "Quick", "targetArgs": "{\"value\": {}}"}}`
+	require.Error(t, i.Default.handleMessage(&sqs.Message{Body: aws.String(body)}, time.Now()))
+	require.Len(t, errs, 2)
+	assert.Equal(t, ErrorKindPersist, errs[1].errCtx.Kind)
+	require.NotNil(t, errs[1].errCtx.Call)
+	assert.Equal(t, "call-1", errs[1].errCtx.Call.ID)
+
+	// Panics are reported with their call, and still reach the poll loop so that it is restarted
+	body = `{"value": {"id": "call-2", "service": "default", "targetFn": "Boom", "targetArgs": "{\"value\": {}}"}}`
+	assert.PanicsWithValue(t, callPanic{value: "boom"}, func() {
+		i.Default.handleMessage(&sqs.Message{Body: aws.String(body)}, time.Now())
+	})
+	require.Len(t, errs, 3)
+	assert.EqualError(t, errs[2].err, "call 'call-2' to 'Boom' panicked: boom")
+	assert.Equal(t, ErrorKindPanic, errs[2].errCtx.Kind)
+	assert.Equal(t, "call-2", errs[2].errCtx.Call.ID)
+	assert.Contains(t, errs[2].errCtx.Stack, "goroutine")
+	assert.Equal(t, int64(0), i.Default.Stats().PendingAck)
+	assert.Equal(t, int64(2), i.Default.Stats().Failed)
+}
+
+func TestOnErrorWorkerPanic(t *testing.T) {
+	var mu sync.Mutex
+	var errs []error
+	i, err := New(InferableOptions{
+		APIEndpoint: "http://localhost",
+		APISecret:   "test-secret",
+		OnError: func(err error, errCtx ErrorContext) {
+			mu.Lock()
+			defer mu.Unlock()
+			assert.Equal(t, ErrorKindPanic, errCtx.Kind)
+			errs = append(errs, err)
+		},
+	})
+	require.NoError(t, err)
+
+	handler := func(msg *sqs.Message, receivedAt time.Time) error {
+		if *msg.Body == "reported" {
+			panic(callPanic{value: "boom"})
+		}
+		panic("boom")
+	}
+	consumer, err := newSQSConsumer("http://localhost", "us-east-1", "http://localhost/queue", handler, "key", "secret", "token")
+	require.NoError(t, err)
+	consumer.SetConcurrency(2)
+	consumer.SetPanicHandler(func(r interface{}) { i.Default.reportPanic("worker", r) })
+
+	// Panics of workers are reported, unless they are panics of calls which were reported already
+	consumer.dispatch(context.Background(), &sqs.Message{Body: aws.String("reported")}, time.Now())
+	consumer.dispatch(context.Background(), &sqs.Message{Body: aws.String("unreported")}, time.Now())
+	consumer.workers.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, errs, 1)
+	assert.EqualError(t, errs[0], "worker panicked: boom")
+}
@@ -0,0 +1,80 @@
+package inferable
+
+import (
+	"context"
+	"sync"
+)
+
+type resultTagsKey struct{}
+
+// resultTags holds the tags set on the result of a call, see SetResultMeta
+type resultTags struct {
+	mu   sync.Mutex
+	tags map[string]string
+}
+
+// SetResultMeta tags the result of the call being handled with a key/value pair, which is persisted in its
+// metadata to help debug run timelines. It has no effect if ctx was not passed to a function by the service.
+func SetResultMeta(ctx context.Context, key, value string) {
+	tags, ok := ctx.Value(resultTagsKey{}).(*resultTags)
+	if !ok {
+		return
+	}
+
+	tags.mu.Lock()
+	defer tags.mu.Unlock()
+	if tags.tags == nil {
+		tags.tags = map[string]string{}
+	}
+	tags.tags[key] = value
+}
+
+func withResultTags(ctx context.Context) (context.Context, *resultTags) {
+	tags := &resultTags{}
+	return context.WithValue(ctx, resultTagsKey{}, tags), tags
+}
+
+// get returns a copy of the tags, or nil if none were set
+func (t *resultTags) get() map[string]string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.tags) == 0 {
+		return nil
+	}
+
+	tags := make(map[string]string, len(t.tags))
+	for key, value := range t.tags {
+		tags[key] = value
+	}
+
+	return tags
+}
+
+// workerPool assigns the calls handled at once to numbered workers, starting at 1,
+// so that results can tell which worker of a machine handled them
+type workerPool struct {
+	mu   sync.Mutex
+	busy []bool
+}
+
+// acquire returns the lowest numbered free worker
+func (p *workerPool) acquire() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for idx, busy := range p.busy {
+		if !busy {
+			p.busy[idx] = true
+			return idx + 1
+		}
+	}
+
+	p.busy = append(p.busy, true)
+	return len(p.busy)
+}
+
+func (p *workerPool) release(worker int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.busy[worker-1] = false
+}
@@ -0,0 +1,94 @@
+package inferable
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultMaxResultSize is the size in bytes above which results are handled according to
+// ServiceOptions.OversizedResults when ServiceOptions.MaxResultSize is not set
+const DefaultMaxResultSize = 4 << 20
+
+// OversizedResultPolicy chooses how results larger than ServiceOptions.MaxResultSize are persisted,
+// rather than being rejected by the API
+type OversizedResultPolicy string
+
+const (
+	// OversizedResultUpload uploads oversized results in chunks, like results streamed by functions
+	OversizedResultUpload OversizedResultPolicy = "upload"
+	// OversizedResultTruncate replaces oversized results with a marker holding the start of the result,
+	// e.g. {"truncated": true, "size": 5000000, "preview": "..."}
+	OversizedResultTruncate OversizedResultPolicy = "truncate"
+)
+
+// truncatedResult replaces a result which was too large to persist
+type truncatedResult struct {
+	Truncated bool   `json:"truncated"`
+	Size      int    `json:"size"`
+	Preview   string `json:"preview"`
+}
+
+func (s *Service) maxResultSize() int {
+	if s.options.MaxResultSize <= 0 {
+		return DefaultMaxResultSize
+	}
+
+	return s.options.MaxResultSize
+}
+
+// guardResultSize returns the result unchanged if it is within the maximum size, or else uploads or
+// truncates it according to ServiceOptions.OversizedResults
+func (s *Service) guardResultSize(ctx context.Context, call CallInfo, result CallResult, meta *resultMetadata) (CallResult, error) {
+	size := len(result.Value)
+	if meta.Chunked || size <= s.maxResultSize() {
+		return result, nil
+	}
+
+	if s.options.OversizedResults == OversizedResultTruncate {
+		s.inferable.logf(LogLevelError, "Result of call '%s' is %d bytes, exceeding the maximum of %d bytes, and was truncated", call.ID, size, s.maxResultSize())
+		meta.Truncated = true
+		return truncateResult(result, s.maxResultSize())
+	}
+
+	s.inferable.logf(LogLevelInfo, "Result of call '%s' is %d bytes, exceeding the maximum of %d bytes, and is uploaded in chunks", call.ID, size, s.maxResultSize())
+	meta.Chunked = true
+	uploaded, err := s.uploadResultStream(ctx, call.ID, strings.NewReader(result.Value))
+	if err != nil {
+		return CallResult{}, err
+	}
+	uploaded.Type = result.Type
+
+	return uploaded, nil
+}
+
+// truncateResult replaces a result with a marker whose preview keeps the result within maxSize
+func truncateResult(result CallResult, maxSize int) (CallResult, error) {
+	marker := truncatedResult{Truncated: true, Size: len(result.Value)}
+
+	overhead, err := json.Marshal(marker)
+	if err != nil {
+		return CallResult{}, fmt.Errorf("failed to marshal truncated result: %v", err)
+	}
+
+	// The preview is escaped when marshaled, so it is cut until the marker fits
+	preview := result.Value[:max(0, min(len(result.Value), maxSize-len(overhead)))]
+	for {
+		for len(preview) > 0 && !utf8.ValidString(preview) {
+			preview = preview[:len(preview)-1]
+		}
+		marker.Preview = preview
+
+		truncated, err := json.Marshal(marker)
+		if err != nil {
+			return CallResult{}, fmt.Errorf("failed to marshal truncated result: %v", err)
+		}
+		if len(truncated) <= maxSize || preview == "" {
+			return CallResult{Value: string(truncated), Type: result.Type}, nil
+		}
+
+		preview = preview[:max(0, len(preview)-(len(truncated)-maxSize))]
+	}
+}
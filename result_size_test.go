@@ -0,0 +1,110 @@
+package inferable
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOversizedResults(t *testing.T) {
+	var mu sync.Mutex
+	chunks := map[string][]string{}
+	persisted := map[string]persistedResult{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/result/chunks"):
+			body, _ := io.ReadAll(r.Body)
+			chunks[r.URL.Path] = append(chunks[r.URL.Path], string(body))
+		case strings.HasSuffix(r.URL.Path, "/result"):
+			var result persistedResult
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&result))
+			persisted[r.URL.Path] = result
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	i, err := New(InferableOptions{
+		APIEndpoint: server.URL,
+		APISecret:   "test-secret",
+	})
+	require.NoError(t, err)
+
+	_, err = i.RegisterServiceWithOptions("invalid", ServiceOptions{OversizedResults: "drop"})
+	assert.Error(t, err)
+
+	type TestInput struct {
+		Size int `json:"size"`
+	}
+	fn := Function{
+		Name: "Large",
+		Func: func(input TestInput) string { return strings.Repeat("é", input.Size) },
+	}
+
+	upload, err := i.RegisterServiceWithOptions("upload", ServiceOptions{MaxResultSize: 64, ResultChunkSize: 50})
+	require.NoError(t, err)
+	require.NoError(t, upload.RegisterFunc(fn))
+
+	truncate, err := i.RegisterServiceWithOptions("truncate", ServiceOptions{MaxResultSize: 64, OversizedResults: OversizedResultTruncate})
+	require.NoError(t, err)
+	require.NoError(t, truncate.RegisterFunc(fn))
+
+	call := func(service *Service, id string, size int) {
+		body := `{"value": {"id": "` + id + `", "service": "` + service.Name + `", "targetFn": "Large", "targetArgs": "{\"value\": {\"size\": ` + strconv.Itoa(size) + `}}"}}`
+		require.NoError(t, service.handleMessage(&sqs.Message{Body: aws.String(body)}, time.Now()))
+	}
+	call(upload, "small", 10)
+	call(upload, "uploaded", 40)
+	call(truncate, "truncated", 40)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	// Results within the maximum size are persisted as is
+	assert.JSONEq(t, `{"value": "`+strings.Repeat("é", 10)+`"}`, persisted["/jobs/small/result"].Result)
+	assert.False(t, persisted["/jobs/small/result"].Meta.Chunked)
+
+	uploaded := persisted["/jobs/uploaded/result"]
+	assert.True(t, uploaded.Meta.Chunked)
+	assert.Equal(t, "resolution", uploaded.ResultType)
+	assert.JSONEq(t, `{"value": {"chunks": 2, "size": 82}}`, uploaded.Result)
+	assert.Equal(t, `"`+strings.Repeat("é", 40)+`"`, strings.Join(chunks["/jobs/uploaded/result/chunks"], ""))
+
+	truncated := persisted["/jobs/truncated/result"]
+	assert.True(t, truncated.Meta.Truncated)
+	var value struct {
+		Value truncatedResult `json:"value"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(truncated.Result), &value))
+	assert.True(t, value.Value.Truncated)
+	assert.Equal(t, 82, value.Value.Size)
+	assert.True(t, strings.HasPrefix(`"`+strings.Repeat("é", 40), value.Value.Preview))
+	assert.NotEmpty(t, value.Value.Preview)
+}
+
+func TestTruncateResult(t *testing.T) {
+	for _, maxSize := range []int{1, 48, 64, 100} {
+		result, err := truncateResult(CallResult{Value: `"` + strings.Repeat(`"é`, 100) + `"`, Type: "rejection"}, maxSize)
+		require.NoError(t, err)
+		assert.Equal(t, "rejection", result.Type)
+		assert.True(t, json.Valid([]byte(result.Value)))
+		if maxSize > 48 {
+			assert.LessOrEqual(t, len(result.Value), maxSize)
+		}
+	}
+}
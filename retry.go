@@ -0,0 +1,97 @@
+package inferable
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+const (
+	// DefaultMaxCallAttempts is how often a call failing with a retryable error is attempted
+	// when ServiceOptions.MaxCallAttempts is not set
+	DefaultMaxCallAttempts = 3
+	// DefaultRetryDelay is how long a call failing with a retryable error waits to be dispatched again
+	// when ServiceOptions.RetryDelay is not set
+	DefaultRetryDelay = 5 * time.Second
+)
+
+// RetryableError signals that a call failed transiently and should be dispatched again later,
+// instead of being rejected. See Retryable.
+type RetryableError struct {
+	Err error
+}
+
+func (e *RetryableError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RetryableError) Unwrap() error {
+	return e.Err
+}
+
+// Retryable marks an error returned by a function as transient, so that the call is dispatched again
+// after ServiceOptions.RetryDelay rather than rejected. Once the call has been attempted
+// ServiceOptions.MaxCallAttempts times, the error rejects it. Returns nil if err is nil.
+//
+// Errors caused by an expired or canceled context are retryable without being marked.
+func Retryable(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &RetryableError{Err: err}
+}
+
+// retryableCallError returns the error returned by a function if it is retryable, or nil otherwise
+func retryableCallError(returnValues []reflect.Value) *RetryableError {
+	if len(returnValues) == 0 {
+		return nil
+	}
+
+	err, ok := returnValues[len(returnValues)-1].Interface().(error)
+	if !ok || err == nil {
+		return nil
+	}
+
+	var retryable *RetryableError
+	if errors.As(err, &retryable) {
+		return retryable
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return &RetryableError{Err: err}
+	}
+
+	return nil
+}
+
+func (s *Service) maxCallAttempts() int {
+	if s.options.MaxCallAttempts <= 0 {
+		return DefaultMaxCallAttempts
+	}
+
+	return s.options.MaxCallAttempts
+}
+
+func (s *Service) retryDelay() time.Duration {
+	if s.options.RetryDelay <= 0 {
+		return DefaultRetryDelay
+	}
+
+	return s.options.RetryDelay
+}
+
+// callAttempt returns how often the message of a call has been received, including this time
+func callAttempt(msg *sqs.Message) int {
+	if count, ok := msg.Attributes[sqs.MessageSystemAttributeNameApproximateReceiveCount]; ok && count != nil {
+		if attempt, err := strconv.Atoi(*count); err == nil && attempt > 0 {
+			return attempt
+		}
+	}
+
+	return 1
+}
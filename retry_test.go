@@ -0,0 +1,221 @@
+package inferable
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryableCall(t *testing.T) {
+	var mu sync.Mutex
+	persisted := map[string]persistedResult{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/result") {
+			var result persistedResult
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&result))
+			mu.Lock()
+			persisted[r.URL.Path] = result
+			mu.Unlock()
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	i, err := New(InferableOptions{
+		APIEndpoint: server.URL,
+		APISecret:   "test-secret",
+	})
+	require.NoError(t, err)
+
+	type TestInput struct{}
+
+	attempts := []int{}
+	require.NoError(t, i.Default.RegisterFunc(Function{
+		Name: "Flaky",
+		Func: func(ctx context.Context, input TestInput) (string, error) {
+			call, _ := CallInfoFromContext(ctx)
+			attempts = append(attempts, call.Attempt)
+			return "", Retryable(errors.New("upstream unavailable"))
+		},
+	}))
+	require.NoError(t, i.Default.RegisterFunc(Function{
+		Name: "Timeout",
+		Func: func(input TestInput) (string, error) {
+			return "", fmt.Errorf("query failed: %w", context.DeadlineExceeded)
+		},
+	}))
+
+	message := func(id, fn string, receiveCount string) *sqs.Message {
+		body := `{"value": {"id": "` + id + `", "service": "default", "targetFn": "` + fn + `", "targetArgs": "{\"value\": {}}"}}`
+		return &sqs.Message{
+			Body:       aws.String(body),
+			Attributes: map[string]*string{sqs.MessageSystemAttributeNameApproximateReceiveCount: aws.String(receiveCount)},
+		}
+	}
+
+	var retryable *RetryableError
+	err = i.Default.handleMessage(message("call-1", "Flaky", "1"), time.Now())
+	require.ErrorAs(t, err, &retryable)
+	err = i.Default.handleMessage(message("call-2", "Timeout", "2"), time.Now())
+	require.ErrorAs(t, err, &retryable)
+
+	// The last attempt rejects the call
+	require.NoError(t, i.Default.handleMessage(message("call-1", "Flaky", "3"), time.Now()))
+	assert.Equal(t, []int{1, 3}, attempts)
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, persisted, 1)
+	assert.Equal(t, "rejection", persisted["/jobs/call-1/result"].ResultType)
+	assert.JSONEq(t, `{"value": "upstream unavailable"}`, persisted["/jobs/call-1/result"].Result)
+}
+
+func TestSQSConsumerRetryDelay(t *testing.T) {
+	var mu sync.Mutex
+	actions := map[string]int{}
+	var visibility float64
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		action := r.Header.Get("X-Amz-Target")
+		mu.Lock()
+		defer mu.Unlock()
+		actions[action]++
+
+		switch action {
+		case "AmazonSQS.ReceiveMessage":
+			body := "{}"
+			sum := md5.Sum([]byte(body))
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"Messages": []map[string]string{
+					{"MessageId": "msg-1", "ReceiptHandle": "msg-1", "Body": body, "MD5OfBody": hex.EncodeToString(sum[:])},
+				},
+			})
+			return
+		case "AmazonSQS.ChangeMessageVisibility":
+			var input map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&input)
+			visibility, _ = input["VisibilityTimeout"].(float64)
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	handler := func(msg *sqs.Message, receivedAt time.Time) error {
+		return Retryable(errors.New("try again"))
+	}
+
+	consumer, err := newSQSConsumer(server.URL, "us-east-1", server.URL+"/queue", handler, "key", "secret", "token")
+	require.NoError(t, err)
+	consumer.SetRetryDelay(5 * time.Second)
+
+	require.NoError(t, consumer.poll(context.Background()))
+
+	mu.Lock()
+	assert.Equal(t, 0, actions["AmazonSQS.DeleteMessage"])
+	assert.Equal(t, 1, actions["AmazonSQS.ChangeMessageVisibility"])
+	assert.Equal(t, float64(5), visibility)
+	mu.Unlock()
+
+	// Sub-second delays are rounded up rather than making the message visible again immediately
+	consumer.SetRetryDelay(500 * time.Millisecond)
+	require.NoError(t, consumer.poll(context.Background()))
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, float64(1), visibility)
+}
+
+func TestLocalRetry(t *testing.T) {
+	var mu sync.Mutex
+	persisted := map[string]persistedResult{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/result") {
+			var result persistedResult
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&result))
+			mu.Lock()
+			persisted[r.URL.Path] = result
+			mu.Unlock()
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	i, err := New(InferableOptions{
+		APIEndpoint: server.URL,
+		APISecret:   "test-secret",
+	})
+	require.NoError(t, err)
+
+	type TestInput struct{}
+
+	flakyAttempts := 0
+	require.NoError(t, i.Default.RegisterFunc(Function{
+		Name: "Flaky",
+		Func: func(input TestInput) (string, error) {
+			flakyAttempts++
+			if flakyAttempts < 3 {
+				return "", &ToolError{Code: "UPSTREAM_UNAVAILABLE", Message: "try again", Retryable: true}
+			}
+			return "done", nil
+		},
+		Config: FunctionConfig{LocalRetry: &LocalRetryPolicy{Backoff: time.Millisecond}},
+	}))
+
+	brokenAttempts := 0
+	require.NoError(t, i.Default.RegisterFunc(Function{
+		Name: "Broken",
+		Func: func(input TestInput) (string, error) {
+			brokenAttempts++
+			return "", errors.New("invalid request")
+		},
+		Config: FunctionConfig{LocalRetry: &LocalRetryPolicy{
+			MaxAttempts: 2,
+			Backoff:     time.Millisecond,
+			RetryOn:     func(err error) bool { return strings.Contains(err.Error(), "invalid") },
+		}},
+	}))
+
+	assert.Error(t, i.Default.RegisterFunc(Function{
+		Name:   "Invalid",
+		Func:   func(input TestInput) string { return "" },
+		Config: FunctionConfig{LocalRetry: &LocalRetryPolicy{MaxAttempts: -1}},
+	}))
+
+	for id, fn := range map[string]string{"call-1": "Flaky", "call-2": "Broken"} {
+		body := `{"value": {"id": "` + id + `", "service": "default", "targetFn": "` + fn + `", "targetArgs": "{\"value\": {}}"}}`
+		require.NoError(t, i.Default.handleMessage(&sqs.Message{Body: aws.String(body)}, time.Now()))
+	}
+
+	// Transient failures are retried locally until the function succeeds
+	assert.Equal(t, 3, flakyAttempts)
+	assert.Equal(t, "resolution", persisted["/jobs/call-1/result"].ResultType)
+
+	// Failures are persisted once the attempts are exhausted
+	assert.Equal(t, 2, brokenAttempts)
+	assert.Equal(t, "rejection", persisted["/jobs/call-2/result"].ResultType)
+}
+
+func TestLocalRetryBackoff(t *testing.T) {
+	policy := &LocalRetryPolicy{Backoff: time.Second, MaxBackoff: 3 * time.Second}
+	assert.Equal(t, time.Second, policy.backoff(1))
+	assert.Equal(t, 2*time.Second, policy.backoff(2))
+	assert.Equal(t, 3*time.Second, policy.backoff(3))
+	assert.Equal(t, DefaultLocalRetryBackoff, (&LocalRetryPolicy{}).backoff(1))
+
+	assert.False(t, (&LocalRetryPolicy{}).retryOn(errors.New("invalid request")))
+	assert.True(t, (&LocalRetryPolicy{}).retryOn(Retryable(errors.New("upstream unavailable"))))
+}
@@ -0,0 +1,329 @@
+package inferable
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/invopop/jsonschema"
+)
+
+// Run represents an agent run created in the cluster
+type Run struct {
+	ID        string `json:"id"`
+	Status    string `json:"status,omitempty"`
+	inferable *Inferable
+}
+
+// Attachment represents a document uploaded to the cluster which can be referenced by runs
+type Attachment struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	ContentType string `json:"contentType"`
+}
+
+type UploadAttachmentInput struct {
+	Name string
+	// ContentType of the document (e.g. application/pdf, text/csv, text/plain).
+	// Detected from the name and data if empty.
+	ContentType string
+	Data        []byte
+}
+
+type CreateRunInput struct {
+	Message string `json:"initialPrompt,omitempty"`
+	// IDs of attachments previously uploaded with UploadAttachment
+	Attachments []string          `json:"attachments,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	// Context snippets used to ground the agent with application state
+	Context []ContextSnippet `json:"context,omitempty"`
+	// ResultType is a value of the struct type the run should produce (e.g. MyResult{}).
+	// Its schema is sent with the run, and the structured output can be read back with Run.Result.
+	ResultType interface{} `json:"-"`
+}
+
+// ContextSnippet is a piece of application state made available to the agent during a run.
+// Either Key and Value, or Markdown should be set.
+type ContextSnippet struct {
+	Key      string      `json:"key,omitempty"`
+	Value    interface{} `json:"value,omitempty"`
+	Markdown string      `json:"markdown,omitempty"`
+}
+
+// KeyValueContext creates a context snippet from a key and a JSON serializable value
+func KeyValueContext(key string, value interface{}) ContextSnippet {
+	return ContextSnippet{Key: key, Value: value}
+}
+
+// MarkdownContext creates a context snippet from free-form markdown
+func MarkdownContext(markdown string) ContextSnippet {
+	return ContextSnippet{Markdown: markdown}
+}
+
+func validateContextSnippets(snippets []ContextSnippet) error {
+	for idx, snippet := range snippets {
+		if snippet.Key == "" && snippet.Markdown == "" {
+			return fmt.Errorf("context snippet %d must have either a key or markdown", idx)
+		}
+		if snippet.Key != "" && snippet.Markdown != "" {
+			return fmt.Errorf("context snippet '%s' cannot have both a key and markdown", snippet.Key)
+		}
+	}
+
+	return nil
+}
+
+// UploadAttachment uploads a document to the cluster so that it can be attached to runs
+func (i *Inferable) UploadAttachment(input UploadAttachmentInput) (*Attachment, error) {
+	if i.clusterID == "" {
+		return nil, fmt.Errorf("cluster ID must be provided to upload attachments")
+	}
+
+	if input.Name == "" {
+		return nil, fmt.Errorf("attachment name is required")
+	}
+
+	if len(input.Data) == 0 {
+		return nil, fmt.Errorf("attachment '%s' is empty", input.Name)
+	}
+
+	contentType := input.ContentType
+	if contentType == "" {
+		contentType = detectContentType(input.Name, input.Data)
+	}
+
+	payload := struct {
+		Name        string `json:"name"`
+		ContentType string `json:"contentType"`
+		Data        string `json:"data"`
+	}{
+		Name:        input.Name,
+		ContentType: contentType,
+		Data:        base64.StdEncoding.EncodeToString(input.Data),
+	}
+
+	jsonPayload, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal attachment payload: %v", err)
+	}
+
+	responseData, err := i.FetchData(FetchDataOptions{
+		Path:   fmt.Sprintf("/clusters/%s/attachments", i.clusterID),
+		Method: "POST",
+		Body:   string(jsonPayload),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload attachment: %w", err)
+	}
+
+	attachment := &Attachment{}
+	if err := json.Unmarshal(responseData, attachment); err != nil {
+		return nil, fmt.Errorf("failed to parse attachment response: %v", err)
+	}
+
+	if attachment.Name == "" {
+		attachment.Name = input.Name
+	}
+	if attachment.ContentType == "" {
+		attachment.ContentType = contentType
+	}
+
+	return attachment, nil
+}
+
+// UploadAttachmentFile reads the document at path and uploads it to the cluster
+func (i *Inferable) UploadAttachmentFile(path string) (*Attachment, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read attachment: %v", err)
+	}
+
+	return i.UploadAttachment(UploadAttachmentInput{
+		Name: filepath.Base(path),
+		Data: data,
+	})
+}
+
+// CreateRun creates a new agent run in the cluster
+func (i *Inferable) CreateRun(input CreateRunInput) (*Run, error) {
+	if i.clusterID == "" {
+		return nil, fmt.Errorf("cluster ID must be provided to create runs")
+	}
+
+	if err := validateContextSnippets(input.Context); err != nil {
+		return nil, err
+	}
+
+	payload := struct {
+		CreateRunInput
+		ResultSchema *jsonschema.Schema `json:"resultSchema,omitempty"`
+	}{
+		CreateRunInput: input,
+	}
+
+	if input.ResultType != nil {
+		resultType := reflect.TypeOf(input.ResultType)
+		if resultType.Kind() == reflect.Ptr {
+			resultType = resultType.Elem()
+		}
+
+		if resultType.Kind() != reflect.Struct {
+			return nil, fmt.Errorf("run result type must be a struct, got %s", resultType.Kind())
+		}
+
+		schema, err := reflectSchema("run result", resultType)
+		if err != nil {
+			return nil, err
+		}
+		payload.ResultSchema = schema
+	}
+
+	jsonPayload, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal run payload: %v", err)
+	}
+
+	responseData, err := i.FetchData(FetchDataOptions{
+		Path:   fmt.Sprintf("/clusters/%s/runs", i.clusterID),
+		Method: "POST",
+		Body:   string(jsonPayload),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create run: %w", err)
+	}
+
+	run := &Run{inferable: i}
+	if err := json.Unmarshal(responseData, run); err != nil {
+		return nil, fmt.Errorf("failed to parse run response: %v", err)
+	}
+
+	return run, nil
+}
+
+// AddContext attaches context snippets to a run which is already in progress, see Inferable.AddRunContext
+func (r *Run) AddContext(ctx context.Context, snippets ...ContextSnippet) error {
+	if r.inferable == nil {
+		return fmt.Errorf("run '%s' was not created by a client, use Inferable.AddRunContext", r.ID)
+	}
+
+	return r.inferable.AddRunContext(ctx, r.ID, snippets...)
+}
+
+// Result fetches the structured output of the run and unmarshals it into out, updating the status of
+// the run. See Inferable.RunResult.
+func (r *Run) Result(ctx context.Context, out interface{}) error {
+	if r.inferable == nil {
+		return fmt.Errorf("run '%s' was not created by a client, use Inferable.RunResult", r.ID)
+	}
+
+	status, err := r.inferable.runResult(ctx, r.ID, out)
+	if status != "" {
+		r.Status = status
+	}
+
+	return err
+}
+
+// AddRunContext attaches context snippets to a run which is already in progress
+func (i *Inferable) AddRunContext(ctx context.Context, runID string, snippets ...ContextSnippet) error {
+	if i.clusterID == "" {
+		return fmt.Errorf("cluster ID must be provided to add context to runs")
+	}
+
+	if len(snippets) == 0 {
+		return nil
+	}
+
+	if err := validateContextSnippets(snippets); err != nil {
+		return err
+	}
+
+	jsonPayload, err := json.Marshal(struct {
+		Context []ContextSnippet `json:"context"`
+	}{
+		Context: snippets,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal run context: %v", err)
+	}
+
+	_, err = i.FetchData(FetchDataOptions{
+		Path:    fmt.Sprintf("/clusters/%s/runs/%s/context", i.clusterID, runID),
+		Method:  "POST",
+		Body:    string(jsonPayload),
+		Context: ctx,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add context to run '%s': %w", runID, err)
+	}
+
+	return nil
+}
+
+// RunResult fetches the structured output of the run and unmarshals it into out.
+// It returns an error if the run has not produced a result yet.
+func (i *Inferable) RunResult(ctx context.Context, runID string, out interface{}) error {
+	_, err := i.runResult(ctx, runID, out)
+	return err
+}
+
+// runResult is RunResult, also returning the status of the run if the API reported it
+func (i *Inferable) runResult(ctx context.Context, runID string, out interface{}) (string, error) {
+	if i.clusterID == "" {
+		return "", fmt.Errorf("cluster ID must be provided to get run results")
+	}
+
+	responseData, err := i.FetchData(FetchDataOptions{
+		Path:    fmt.Sprintf("/clusters/%s/runs/%s", i.clusterID, runID),
+		Method:  "GET",
+		Context: ctx,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to get run '%s': %w", runID, err)
+	}
+
+	var response struct {
+		Status string          `json:"status"`
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.Unmarshal(responseData, &response); err != nil {
+		return "", fmt.Errorf("failed to parse run response: %v", err)
+	}
+
+	if len(response.Result) == 0 || string(response.Result) == "null" {
+		return response.Status, fmt.Errorf("run '%s' has no result (status: %s)", runID, response.Status)
+	}
+
+	if err := json.Unmarshal(response.Result, out); err != nil {
+		return response.Status, fmt.Errorf("failed to unmarshal result of run '%s': %v", runID, err)
+	}
+
+	return response.Status, nil
+}
+
+// documentContentTypes covers document extensions missing from the builtin mime table
+var documentContentTypes = map[string]string{
+	".csv": "text/csv",
+	".txt": "text/plain",
+	".md":  "text/markdown",
+}
+
+func detectContentType(name string, data []byte) string {
+	ext := strings.ToLower(filepath.Ext(name))
+	if contentType, ok := documentContentTypes[ext]; ok {
+		return contentType
+	}
+
+	if contentType := mime.TypeByExtension(ext); contentType != "" {
+		return contentType
+	}
+
+	return http.DetectContentType(data)
+}
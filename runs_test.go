@@ -0,0 +1,173 @@
+package inferable
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateRunWithAttachments(t *testing.T) {
+	var uploaded map[string]string
+	var created map[string]interface{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/clusters/test-cluster/attachments":
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&uploaded))
+			w.Write([]byte(`{"id": "att-1"}`))
+		case "/clusters/test-cluster/runs":
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&created))
+			w.Write([]byte(`{"id": "run-1", "status": "pending"}`))
+		}
+	}))
+	defer server.Close()
+
+	i, err := New(InferableOptions{
+		APIEndpoint: server.URL,
+		APISecret:   "test-secret",
+		ClusterID:   "test-cluster",
+	})
+	require.NoError(t, err)
+
+	attachment, err := i.UploadAttachment(UploadAttachmentInput{
+		Name: "report.csv",
+		Data: []byte("a,b\n1,2\n"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "att-1", attachment.ID)
+	assert.Equal(t, "text/csv", attachment.ContentType)
+
+	assert.Equal(t, "report.csv", uploaded["name"])
+	assert.Equal(t, "text/csv", uploaded["contentType"])
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("a,b\n1,2\n")), uploaded["data"])
+
+	run, err := i.CreateRun(CreateRunInput{
+		Message:     "Summarize the report",
+		Attachments: []string{attachment.ID},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "run-1", run.ID)
+	assert.Equal(t, "Summarize the report", created["initialPrompt"])
+	assert.Equal(t, []interface{}{"att-1"}, created["attachments"])
+}
+
+func TestCreateRunWithoutClusterID(t *testing.T) {
+	i, _ := New(InferableOptions{
+		APIEndpoint: DefaultAPIEndpoint,
+		APISecret:   "test-secret",
+	})
+
+	_, err := i.CreateRun(CreateRunInput{Message: "Hello"})
+	assert.Error(t, err)
+
+	_, err = i.UploadAttachment(UploadAttachmentInput{Name: "a.txt", Data: []byte("a")})
+	assert.Error(t, err)
+}
+
+func TestRunContext(t *testing.T) {
+	var created map[string]interface{}
+	var added map[string]interface{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/clusters/test-cluster/runs":
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&created))
+			w.Write([]byte(`{"id": "run-1"}`))
+		case "/clusters/test-cluster/runs/run-1/context":
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&added))
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer server.Close()
+
+	i, err := New(InferableOptions{
+		APIEndpoint: server.URL,
+		APISecret:   "test-secret",
+		ClusterID:   "test-cluster",
+	})
+	require.NoError(t, err)
+
+	run, err := i.CreateRun(CreateRunInput{
+		Message: "Check the order",
+		Context: []ContextSnippet{KeyValueContext("orderId", "order-1")},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{map[string]interface{}{"key": "orderId", "value": "order-1"}}, created["context"])
+
+	err = run.AddContext(context.Background(), MarkdownContext("# Order\nShipped"))
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{map[string]interface{}{"markdown": "# Order\nShipped"}}, added["context"])
+
+	err = run.AddContext(context.Background(), ContextSnippet{})
+	assert.Error(t, err)
+
+	// Runs which were not created by a client, e.g. loaded from storage, are addressed by their ID
+	stored := &Run{ID: "run-1"}
+	assert.ErrorContains(t, stored.AddContext(context.Background(), MarkdownContext("# Order")), "Inferable.AddRunContext")
+	require.NoError(t, i.AddRunContext(context.Background(), stored.ID, KeyValueContext("orderId", "order-2")))
+	assert.Equal(t, []interface{}{map[string]interface{}{"key": "orderId", "value": "order-2"}}, added["context"])
+}
+
+func TestRunResult(t *testing.T) {
+	var created struct {
+		ResultSchema map[string]interface{} `json:"resultSchema"`
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/clusters/test-cluster/runs":
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&created))
+			w.Write([]byte(`{"id": "run-1", "status": "pending"}`))
+		case r.Method == "GET" && r.URL.Path == "/clusters/test-cluster/runs/run-1":
+			w.Write([]byte(`{"id": "run-1", "status": "done", "result": {"summary": "all good", "score": 7}}`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer server.Close()
+
+	i, err := New(InferableOptions{
+		APIEndpoint: server.URL,
+		APISecret:   "test-secret",
+		ClusterID:   "test-cluster",
+	})
+	require.NoError(t, err)
+
+	type Review struct {
+		Summary string `json:"summary"`
+		Score   int    `json:"score"`
+	}
+
+	run, err := i.CreateRun(CreateRunInput{Message: "Review the PR", ResultType: Review{}})
+	require.NoError(t, err)
+
+	properties, ok := created.ResultSchema["properties"].(map[string]interface{})
+	require.True(t, ok)
+	assert.Contains(t, properties, "summary")
+	assert.Contains(t, properties, "score")
+
+	var review Review
+	require.NoError(t, run.Result(context.Background(), &review))
+	assert.Equal(t, Review{Summary: "all good", Score: 7}, review)
+	assert.Equal(t, "done", run.Status)
+
+	stored := &Run{ID: "run-1"}
+	assert.ErrorContains(t, stored.Result(context.Background(), &review), "Inferable.RunResult")
+	review = Review{}
+	require.NoError(t, i.RunResult(context.Background(), stored.ID, &review))
+	assert.Equal(t, Review{Summary: "all good", Score: 7}, review)
+
+	// Fetching the result can be canceled
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, i.RunResult(ctx, stored.ID, &review), context.Canceled)
+
+	_, err = i.CreateRun(CreateRunInput{Message: "Review the PR", ResultType: "not a struct"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,159 @@
+package inferable
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// RuntimeConfig holds tunables which can be changed at runtime without restarting or re-registering the machine.
+// Tunables which are not set fall back to the options the machine was configured with.
+type RuntimeConfig struct {
+	LogLevel LogLevel `json:"logLevel,omitempty"`
+	// DisabledFunctions are rejected instead of executed, in the form "service.function"
+	DisabledFunctions []string `json:"disabledFunctions,omitempty"`
+	// RequestsPerSecond and RequestBurst override InferableOptions.RequestsPerSecond and RequestBurst
+	// while RequestsPerSecond is set
+	RequestsPerSecond float64 `json:"requestsPerSecond,omitempty"`
+	RequestBurst      int     `json:"requestBurst,omitempty"`
+	// MaxConcurrentCalls overrides ServiceOptions.MaxConcurrentCalls of every service while it is set.
+	// Services with ServiceOptions.BatchResults keep handling their calls one at a time.
+	MaxConcurrentCalls int `json:"maxConcurrentCalls,omitempty"`
+}
+
+// LoadRuntimeConfig reads a RuntimeConfig from a JSON file
+func LoadRuntimeConfig(path string) (RuntimeConfig, error) {
+	var config RuntimeConfig
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, fmt.Errorf("failed to read runtime config: %v", err)
+	}
+
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("failed to parse runtime config %s: %v", path, err)
+	}
+
+	return config, nil
+}
+
+// ApplyRuntimeConfig applies config to the running machine
+func (i *Inferable) ApplyRuntimeConfig(config RuntimeConfig) error {
+	if config.RequestsPerSecond < 0 || config.RequestBurst < 0 {
+		return fmt.Errorf("request rate and burst must not be negative")
+	}
+
+	if config.MaxConcurrentCalls < 0 {
+		return fmt.Errorf("max concurrent calls must not be negative, got %d", config.MaxConcurrentCalls)
+	}
+
+	logLevel := config.LogLevel
+	if logLevel == "" {
+		logLevel = i.configuredLogLevel
+	}
+	if err := i.setLogLevel(logLevel); err != nil {
+		return err
+	}
+
+	disabled := make(map[string]bool, len(config.DisabledFunctions))
+	for _, name := range config.DisabledFunctions {
+		disabled[name] = true
+	}
+	i.disabledFunctions.Store(disabled)
+
+	if config.RequestsPerSecond > 0 {
+		i.client.setRateLimit(config.RequestsPerSecond, config.RequestBurst)
+	} else {
+		i.client.setRateLimit(i.client.requestsPerSecond, i.client.requestBurst)
+	}
+
+	i.maxConcurrentCalls.Store(int64(config.MaxConcurrentCalls))
+	for _, service := range i.functionRegistry.list() {
+		service.applyConcurrency()
+	}
+
+	return nil
+}
+
+// WatchRuntimeConfig applies the runtime config at path, and reloads it whenever the file changes
+// (checked every interval) or the process receives SIGHUP, until ctx is canceled.
+// Errors while reloading are logged and the previous config is kept.
+func (i *Inferable) WatchRuntimeConfig(ctx context.Context, path string, interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("runtime config watch interval must be positive, got %s", interval)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to read runtime config: %v", err)
+	}
+
+	if err := i.reloadRuntimeConfig(path); err != nil {
+		return err
+	}
+
+	modTime := info.ModTime()
+
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+
+	go func() {
+		defer signal.Stop(hangup)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hangup:
+			case <-ticker.C:
+				info, err := os.Stat(path)
+				if err != nil || info.ModTime().Equal(modTime) {
+					continue
+				}
+				modTime = info.ModTime()
+			}
+
+			if err := i.reloadRuntimeConfig(path); err != nil {
+				i.logf(LogLevelError, "Error reloading runtime config. Keeping previous config: %v", err)
+				continue
+			}
+
+			i.logf(LogLevelInfo, "Reloaded runtime config from %s", path)
+		}
+	}()
+
+	return nil
+}
+
+func (i *Inferable) reloadRuntimeConfig(path string) error {
+	config, err := LoadRuntimeConfig(path)
+	if err != nil {
+		return err
+	}
+
+	return i.ApplyRuntimeConfig(config)
+}
+
+// applyConcurrency resizes the workers of the running transport to the current concurrency,
+// see RuntimeConfig.MaxConcurrentCalls
+func (s *Service) applyConcurrency() {
+	s.consumerMu.Lock()
+	consumer := s.consumer
+	s.consumerMu.Unlock()
+
+	if setter, ok := consumer.(concurrencySetter); ok {
+		setter.SetConcurrency(s.maxConcurrentCalls())
+	}
+}
+
+func (i *Inferable) isFunctionDisabled(serviceName, functionName string) bool {
+	disabled, _ := i.disabledFunctions.Load().(map[string]bool)
+	return disabled[serviceName+"."+functionName]
+}
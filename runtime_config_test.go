@@ -0,0 +1,155 @@
+package inferable
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchRuntimeConfig(t *testing.T) {
+	i, err := New(InferableOptions{
+		APIEndpoint: DefaultAPIEndpoint,
+		APISecret:   "test-secret",
+	})
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"logLevel": "debug", "disabledFunctions": ["default.echo"]}`), 0o644))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	require.NoError(t, i.WatchRuntimeConfig(ctx, path, 10*time.Millisecond))
+	assert.True(t, i.isFunctionDisabled("default", "echo"))
+	assert.Equal(t, int32(0), i.logSeverity.Load())
+
+	// Changes to the file are picked up
+	require.NoError(t, os.WriteFile(path, []byte(`{"logLevel": "error"}`), 0o644))
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Second)))
+	assert.Eventually(t, func() bool {
+		return !i.isFunctionDisabled("default", "echo")
+	}, time.Second, 10*time.Millisecond)
+
+	// Invalid configs are ignored
+	require.NoError(t, os.WriteFile(path, []byte(`{"logLevel": "verbose"}`), 0o644))
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(2*time.Second)))
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(2), i.logSeverity.Load())
+
+	// SIGHUP reloads the config even if the file did not change
+	require.NoError(t, os.WriteFile(path, []byte(`{"disabledFunctions": ["default.reverse"]}`), 0o644))
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(2*time.Second)))
+	process, err := os.FindProcess(os.Getpid())
+	require.NoError(t, err)
+	require.NoError(t, process.Signal(syscall.SIGHUP))
+	assert.Eventually(t, func() bool {
+		return i.isFunctionDisabled("default", "reverse")
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestWatchRuntimeConfigInterval(t *testing.T) {
+	i, err := New(InferableOptions{
+		APIEndpoint: DefaultAPIEndpoint,
+		APISecret:   "test-secret",
+	})
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{}`), 0o644))
+
+	for _, interval := range []time.Duration{0, -time.Second} {
+		assert.ErrorContains(t, i.WatchRuntimeConfig(context.Background(), path, interval), "must be positive")
+	}
+}
+
+func TestRuntimeConfigRateLimit(t *testing.T) {
+	i, err := New(InferableOptions{
+		APIEndpoint:       DefaultAPIEndpoint,
+		APISecret:         "test-secret",
+		RequestsPerSecond: 5,
+	})
+	require.NoError(t, err)
+	configured := i.client.limiter.Load()
+	require.NotNil(t, configured)
+
+	// The rate limit of the runtime config overrides the configured one while it is set
+	require.NoError(t, i.ApplyRuntimeConfig(RuntimeConfig{RequestsPerSecond: 50, RequestBurst: 10}))
+	assert.Equal(t, 50.0, i.client.limiter.Load().rate)
+	assert.Equal(t, 10.0, i.client.limiter.Load().burst)
+
+	require.NoError(t, i.ApplyRuntimeConfig(RuntimeConfig{}))
+	assert.Equal(t, 5.0, i.client.limiter.Load().rate)
+
+	// Reapplying an unchanged limit keeps the limiter and its tokens
+	limiter := i.client.limiter.Load()
+	require.NoError(t, i.ApplyRuntimeConfig(RuntimeConfig{}))
+	assert.Same(t, limiter, i.client.limiter.Load())
+
+	assert.Error(t, i.ApplyRuntimeConfig(RuntimeConfig{RequestsPerSecond: -1}))
+	assert.Equal(t, 5.0, i.client.limiter.Load().rate)
+
+	// Polls wait for the current limit
+	require.NoError(t, i.client.waitRateLimit(context.Background()))
+}
+
+func TestRuntimeConfigLogLevel(t *testing.T) {
+	i, err := New(InferableOptions{
+		APIEndpoint: DefaultAPIEndpoint,
+		APISecret:   "test-secret",
+		LogLevel:    LogLevelError,
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, i.ApplyRuntimeConfig(RuntimeConfig{LogLevel: LogLevelDebug}))
+	assert.Equal(t, LogLevelDebug, i.logLevel())
+
+	// A config without a log level restores the configured one
+	require.NoError(t, i.ApplyRuntimeConfig(RuntimeConfig{}))
+	assert.Equal(t, LogLevelError, i.logLevel())
+}
+
+func TestRuntimeConfigConcurrency(t *testing.T) {
+	i, err := New(InferableOptions{
+		APIEndpoint: DefaultAPIEndpoint,
+		APISecret:   "test-secret",
+	})
+	require.NoError(t, err)
+
+	service, err := i.RegisterServiceWithOptions("concurrent", ServiceOptions{MaxConcurrentCalls: 2})
+	require.NoError(t, err)
+	batched, err := i.RegisterServiceWithOptions("batched", ServiceOptions{BatchResults: true})
+	require.NoError(t, err)
+
+	consumer := &SQSConsumer{}
+	consumer.SetConcurrency(service.maxConcurrentCalls())
+	service.consumer = consumer
+	assert.Equal(t, 2, consumer.freeSlots())
+
+	// The workers of running services are resized, except for services with batched results
+	require.NoError(t, i.ApplyRuntimeConfig(RuntimeConfig{MaxConcurrentCalls: 5}))
+	assert.Equal(t, 5, consumer.freeSlots())
+	assert.Equal(t, 1, batched.maxConcurrentCalls())
+
+	// Lowering the limit below the busy workers holds back new calls until enough of them have finished
+	for idx := 0; idx < 3; idx++ {
+		consumer.slots.acquire()
+	}
+	require.NoError(t, i.ApplyRuntimeConfig(RuntimeConfig{MaxConcurrentCalls: 1}))
+	assert.Equal(t, 0, consumer.freeSlots())
+	for idx := 0; idx < 3; idx++ {
+		consumer.slots.release()
+	}
+	assert.Equal(t, -1, consumer.freeSlots())
+
+	// Without an override the configured concurrency applies again
+	require.NoError(t, i.ApplyRuntimeConfig(RuntimeConfig{}))
+	assert.Equal(t, 2, consumer.freeSlots())
+
+	assert.Error(t, i.ApplyRuntimeConfig(RuntimeConfig{MaxConcurrentCalls: -1}))
+}
@@ -0,0 +1,360 @@
+package inferable
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/invopop/jsonschema"
+)
+
+// customSchema is implemented by types which provide their own invopop/jsonschema schema
+type customSchema interface {
+	JSONSchema() *jsonschema.Schema
+}
+
+// newReflector returns a reflector which inlines the schemas of types implementing SchemaProvider or
+// JSONSchema, and of time, duration and UUID types, instead of reflecting them. Invalid provided schemas
+// are appended to errs.
+func newReflector(errs *[]error) *jsonschema.Reflector {
+	return &jsonschema.Reflector{
+		Mapper: func(t reflect.Type) *jsonschema.Schema {
+			schema, err := overriddenSchema(t)
+			if err != nil {
+				*errs = append(*errs, err)
+			}
+			if schema == nil {
+				schema = builtinSchema(t)
+			}
+			return schema
+		},
+	}
+}
+
+// overriddenSchema returns the schema a type provides itself, or nil if it has none.
+// SchemaProvider takes precedence over JSONSchema. Unions are described by their registered variants.
+func overriddenSchema(t reflect.Type) (*jsonschema.Schema, error) {
+	value := reflect.New(t).Interface()
+
+	if union, ok := value.(unionType); ok {
+		return union.unionSchema()
+	}
+
+	if provider, ok := value.(SchemaProvider); ok {
+		schema := &jsonschema.Schema{}
+		if err := json.Unmarshal(provider.InferableSchema(), schema); err != nil {
+			return nil, fmt.Errorf("invalid schema provided by %s: %v", t, err)
+		}
+		return schema, nil
+	}
+
+	if custom, ok := value.(customSchema); ok {
+		return custom.JSONSchema(), nil
+	}
+
+	return nil, nil
+}
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+)
+
+// builtinSchema returns the schema of common types which reflect poorly, or nil for other types
+func builtinSchema(t reflect.Type) *jsonschema.Schema {
+	switch {
+	case t == timeType:
+		return &jsonschema.Schema{Type: "string", Format: "date-time"}
+	case t == durationType:
+		return &jsonschema.Schema{Type: "string", Format: "duration"}
+	case isUUID(t):
+		return &jsonschema.Schema{Type: "string", Format: "uuid"}
+	case isFreeForm(t):
+		return &jsonschema.Schema{Type: "object", AdditionalProperties: jsonschema.TrueSchema}
+	}
+
+	return nil
+}
+
+// isUUID reports whether t looks like a UUID type, e.g. github.com/google/uuid.UUID:
+// a 16 byte array which unmarshals from text
+func isUUID(t reflect.Type) bool {
+	return t.Kind() == reflect.Array && t.Len() == 16 && t.Elem().Kind() == reflect.Uint8 &&
+		reflect.PointerTo(t).Implements(textUnmarshalerType)
+}
+
+// isFreeForm reports whether t holds free-form parameters, i.e. it is a json.RawMessage or a map[string]any
+func isFreeForm(t reflect.Type) bool {
+	if t == rawMessageType {
+		return true
+	}
+
+	return t.Kind() == reflect.Map && t.Key().Kind() == reflect.String &&
+		t.Elem().Kind() == reflect.Interface && t.Elem().NumMethod() == 0
+}
+
+// withFieldSchemas returns a copy of a reflected schema with the schemas of the properties at the
+// given dotted JSON paths replaced
+func withFieldSchemas(schema *jsonschema.Schema, fieldSchemas map[string]json.RawMessage) (*jsonschema.Schema, error) {
+	// Reflected schemas are cached and shared, so they are copied before they are modified
+	data, err := json.Marshal(schema)
+	if err != nil {
+		return nil, err
+	}
+	copied := &jsonschema.Schema{}
+	if err := json.Unmarshal(data, copied); err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(fieldSchemas))
+	for path := range fieldSchemas {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		fieldSchema := &jsonschema.Schema{}
+		if err := json.Unmarshal(fieldSchemas[path], fieldSchema); err != nil {
+			return nil, fmt.Errorf("invalid schema for field '%s': %v", path, err)
+		}
+
+		parent := copied
+		keys := strings.Split(path, ".")
+		for _, key := range keys[:len(keys)-1] {
+			if parent.Properties == nil {
+				return nil, fmt.Errorf("field '%s' not found", path)
+			}
+			if parent, _ = parent.Properties.Get(key); parent == nil {
+				return nil, fmt.Errorf("field '%s' not found", path)
+			}
+		}
+
+		key := keys[len(keys)-1]
+		if parent.Properties == nil {
+			return nil, fmt.Errorf("field '%s' not found", path)
+		}
+		if _, ok := parent.Properties.Get(key); !ok {
+			return nil, fmt.Errorf("field '%s' not found", path)
+		}
+		parent.Properties.Set(key, fieldSchema)
+	}
+
+	return copied, nil
+}
+
+// hasInferableOption reports whether the `inferable` tag of a field has an option, e.g. `inferable:"secret,optional"`
+func hasInferableOption(field reflect.StructField, option string) bool {
+	for _, value := range strings.Split(field.Tag.Get("inferable"), ",") {
+		if strings.TrimSpace(value) == option {
+			return true
+		}
+	}
+	return false
+}
+
+// applyOptionalFields removes the fields of structType which are pointers or tagged `inferable:"optional"`
+// from the required properties of its schema, unless a jsonschema tag requires them.
+// Fields tagged `inferable:"required"` are required even if they are omitted when empty.
+func applyOptionalFields(schema *jsonschema.Schema, structType reflect.Type) {
+	if schema.Properties == nil {
+		return
+	}
+
+	for idx := 0; idx < structType.NumField(); idx++ {
+		field := structType.Field(idx)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if property, ok := schema.Properties.Get(name); ok && property != nil && property.Type == "object" && fieldType.Kind() == reflect.Struct {
+			applyOptionalFields(property, fieldType)
+		}
+
+		required := contains(schema.Required, name)
+		switch {
+		case hasInferableOption(field, "required"):
+			if !required {
+				schema.Required = append(schema.Required, name)
+			}
+		case hasInferableOption(field, "optional"), field.Type.Kind() == reflect.Ptr:
+			if required && !contains(strings.Split(field.Tag.Get("jsonschema"), ","), "required") {
+				schema.Required = remove(schema.Required, name)
+			}
+		}
+	}
+}
+
+// remove returns values without value
+func remove(values []string, value string) []string {
+	kept := []string{}
+	for _, v := range values {
+		if v != value {
+			kept = append(kept, v)
+		}
+	}
+	return kept
+}
+
+// containsDuration reports whether values of t can contain a time.Duration which is decoded by encoding/json
+func containsDuration(t reflect.Type, visited map[reflect.Type]bool) bool {
+	if visited[t] {
+		return false
+	}
+	visited[t] = true
+
+	if t == durationType {
+		return true
+	}
+	if reflect.PointerTo(t).Implements(jsonUnmarshalerType) {
+		return false
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		return containsDuration(t.Elem(), visited)
+	case reflect.Struct:
+		for idx := 0; idx < t.NumField(); idx++ {
+			if field := t.Field(idx); field.IsExported() && containsDuration(field.Type, visited) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// normalizeDurations replaces the duration strings in a decoded JSON value of type t with their
+// number of nanoseconds, which is how encoding/json decodes a time.Duration
+func normalizeDurations(value interface{}, t reflect.Type) (interface{}, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == durationType {
+		text, ok := value.(string)
+		if !ok {
+			return value, nil
+		}
+		duration, err := parseDuration(text)
+		if err != nil {
+			return nil, err
+		}
+		return json.Number(strconv.FormatInt(int64(duration), 10)), nil
+	}
+	if reflect.PointerTo(t).Implements(jsonUnmarshalerType) {
+		return value, nil
+	}
+
+	var err error
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		if items, ok := value.([]interface{}); ok {
+			for idx := range items {
+				if items[idx], err = normalizeDurations(items[idx], t.Elem()); err != nil {
+					return nil, err
+				}
+			}
+		}
+	case reflect.Map:
+		if entries, ok := value.(map[string]interface{}); ok {
+			for key := range entries {
+				if entries[key], err = normalizeDurations(entries[key], t.Elem()); err != nil {
+					return nil, err
+				}
+			}
+		}
+	case reflect.Struct:
+		if fields, ok := value.(map[string]interface{}); ok {
+			if err := normalizeFields(fields, t); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return value, nil
+}
+
+// normalizeFields normalizes the durations of the JSON fields of a struct, including those of embedded structs
+func normalizeFields(fields map[string]interface{}, t reflect.Type) error {
+	for idx := 0; idx < t.NumField(); idx++ {
+		field := t.Field(idx)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || (!field.IsExported() && !field.Anonymous) {
+			continue
+		}
+
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if field.Anonymous && name == "" && fieldType.Kind() == reflect.Struct {
+			if err := normalizeFields(fields, fieldType); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		for key, value := range fields {
+			// encoding/json matches field names case-insensitively
+			if !strings.EqualFold(key, name) {
+				continue
+			}
+			normalized, err := normalizeDurations(value, field.Type)
+			if err != nil {
+				return fmt.Errorf("invalid field %s: %v", key, err)
+			}
+			fields[key] = normalized
+		}
+	}
+
+	return nil
+}
+
+var isoDurationPattern = regexp.MustCompile(`^P(?:(\d+(?:\.\d+)?)W)?(?:(\d+(?:\.\d+)?)D)?(?:T(?:(\d+(?:\.\d+)?)H)?(?:(\d+(?:\.\d+)?)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+
+// parseDuration parses a Go duration such as "1h30m", or an ISO 8601 duration such as "PT1H30M".
+// ISO 8601 years and months are rejected, as their length varies.
+func parseDuration(text string) (time.Duration, error) {
+	if duration, err := time.ParseDuration(text); err == nil {
+		return duration, nil
+	}
+
+	match := isoDurationPattern.FindStringSubmatch(strings.ToUpper(text))
+	if match == nil || text == "P" || strings.HasSuffix(strings.ToUpper(text), "T") {
+		return 0, fmt.Errorf("invalid duration '%s'", text)
+	}
+
+	units := []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+	var duration float64
+	for idx, unit := range units {
+		if match[idx+1] == "" {
+			continue
+		}
+		amount, err := strconv.ParseFloat(match[idx+1], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration '%s'", text)
+		}
+		duration += amount * float64(unit)
+	}
+
+	return time.Duration(duration), nil
+}
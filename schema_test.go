@@ -0,0 +1,260 @@
+package inferable
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/invopop/jsonschema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testUUID [16]byte
+
+func (testUUID) InferableSchema() json.RawMessage {
+	return json.RawMessage(`{"type":"string","format":"uuid"}`)
+}
+
+type testAmount struct {
+	Cents int64
+}
+
+func (*testAmount) JSONSchema() *jsonschema.Schema {
+	return &jsonschema.Schema{Type: "string", Pattern: `^\d+\.\d{2}$`}
+}
+
+type testInvalidSchema struct{}
+
+func (testInvalidSchema) InferableSchema() json.RawMessage {
+	return json.RawMessage(`[]`)
+}
+
+type testCustomInput struct {
+	Query string
+}
+
+func (testCustomInput) JSONSchema() *jsonschema.Schema {
+	return &jsonschema.Schema{Type: "object", Description: "Free text search"}
+}
+
+func TestSchemaOverrides(t *testing.T) {
+	i, err := New(InferableOptions{
+		APIEndpoint: DefaultAPIEndpoint,
+		APISecret:   "test-secret",
+	})
+	require.NoError(t, err)
+
+	type PaymentInput struct {
+		ID     testUUID    `json:"id"`
+		Amount testAmount  `json:"amount"`
+		Refund *testAmount `json:"refund,omitempty"`
+	}
+
+	require.NoError(t, i.Default.RegisterFunc(Function{
+		Name: "pay",
+		Func: func(input PaymentInput) string { return "ok" },
+	}))
+
+	schema, err := json.Marshal(i.Default.Functions["pay"].schema)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"type": "object",
+		"properties": {
+			"id": {"type": "string", "format": "uuid"},
+			"amount": {"type": "string", "pattern": "^\\d+\\.\\d{2}$"},
+			"refund": {"type": "string", "pattern": "^\\d+\\.\\d{2}$"}
+		},
+		"required": ["id", "amount"]
+	}`, string(schema))
+
+	require.NoError(t, i.Default.RegisterFunc(Function{
+		Name: "search",
+		Func: func(input testCustomInput) string { return "ok" },
+	}))
+
+	schema, err = json.Marshal(i.Default.Functions["search"].schema)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type": "object", "description": "Free text search"}`, string(schema))
+
+	type InvalidInput struct {
+		Value testInvalidSchema `json:"value"`
+	}
+
+	err = i.Default.RegisterFunc(Function{
+		Name: "invalid",
+		Func: func(input InvalidInput) string { return "ok" },
+	})
+	assert.ErrorContains(t, err, "invalid schema provided by inferable.testInvalidSchema")
+}
+
+type testUUIDValue [16]byte
+
+func (u *testUUIDValue) UnmarshalText(text []byte) error {
+	copy(u[:], text)
+	return nil
+}
+
+func TestCommonTypeSchemas(t *testing.T) {
+	i, err := New(InferableOptions{
+		APIEndpoint: DefaultAPIEndpoint,
+		APISecret:   "test-secret",
+	})
+	require.NoError(t, err)
+
+	type ScheduleInput struct {
+		ID        testUUIDValue             `json:"id"`
+		At        time.Time                 `json:"at"`
+		Timeout   time.Duration             `json:"timeout"`
+		Reminders []time.Duration           `json:"reminders,omitempty"`
+		Windows   map[string]*time.Duration `json:"windows,omitempty"`
+	}
+
+	var received ScheduleInput
+	require.NoError(t, i.Default.RegisterFunc(Function{
+		Name: "schedule",
+		Func: func(input ScheduleInput) string {
+			received = input
+			return "ok"
+		},
+	}))
+
+	schema, err := json.Marshal(i.Default.Functions["schedule"].schema)
+	require.NoError(t, err)
+
+	var properties struct {
+		Properties map[string]json.RawMessage `json:"properties"`
+	}
+	require.NoError(t, json.Unmarshal(schema, &properties))
+	assert.JSONEq(t, `{"type": "string", "format": "uuid"}`, string(properties.Properties["id"]))
+	assert.JSONEq(t, `{"type": "string", "format": "date-time"}`, string(properties.Properties["at"]))
+	assert.JSONEq(t, `{"type": "string", "format": "duration"}`, string(properties.Properties["timeout"]))
+
+	compiled := i.Default.Functions["schedule"].compiled()
+	arg, err := compiled.decodeArgs([]byte(`{"value": {
+		"id": "0123456789abcdef",
+		"at": "2024-03-01T10:00:00+01:00",
+		"timeout": "PT1H30M",
+		"reminders": ["15m", 60000000000],
+		"windows": {"night": "P1DT2H"}
+	}}`))
+	require.NoError(t, err)
+
+	compiled.call(context.Background(), arg.Elem())
+	assert.Equal(t, "0123456789abcdef", string(received.ID[:]))
+	assert.True(t, received.At.Equal(time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)))
+	assert.Equal(t, 90*time.Minute, received.Timeout)
+	assert.Equal(t, []time.Duration{15 * time.Minute, time.Minute}, received.Reminders)
+	assert.Equal(t, 26*time.Hour, *received.Windows["night"])
+
+	_, err = compiled.decodeArgs([]byte(`{"value": {"timeout": "P1M"}}`))
+	assert.ErrorContains(t, err, "invalid duration 'P1M'")
+}
+
+func TestFreeFormFields(t *testing.T) {
+	i, err := New(InferableOptions{
+		APIEndpoint: DefaultAPIEndpoint,
+		APISecret:   "test-secret",
+	})
+	require.NoError(t, err)
+
+	type QueryInput struct {
+		Table   string                 `json:"table"`
+		Filters map[string]interface{} `json:"filters"`
+		Options json.RawMessage        `json:"options"`
+		Extra   map[string]any         `json:"extra"`
+	}
+
+	var received QueryInput
+	query := func(input QueryInput) string {
+		received = input
+		return "ok"
+	}
+
+	require.NoError(t, i.Default.RegisterFunc(Function{
+		Name: "query",
+		Func: query,
+		FieldSchemas: map[string]json.RawMessage{
+			"extra": json.RawMessage(`{"type": "object", "additionalProperties": {"type": "string"}}`),
+		},
+	}))
+
+	schema, err := json.Marshal(i.Default.Functions["query"].schema)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"type": "object",
+		"properties": {
+			"table": {"type": "string"},
+			"filters": {"type": "object", "additionalProperties": true},
+			"options": {"type": "object", "additionalProperties": true},
+			"extra": {"type": "object", "additionalProperties": {"type": "string"}}
+		},
+		"required": ["table", "filters", "options", "extra"]
+	}`, string(schema))
+
+	compiled := i.Default.Functions["query"].compiled()
+	arg, err := compiled.decodeArgs([]byte(`{"value": {"table": "users", "filters": {"age": 30}, "options": {"limit": 5}}}`))
+	require.NoError(t, err)
+	compiled.call(context.Background(), arg.Elem())
+	assert.Equal(t, map[string]interface{}{"age": float64(30)}, received.Filters)
+	assert.JSONEq(t, `{"limit": 5}`, string(received.Options))
+
+	// Field schemas apply to a copy, so the shared reflected schema is unchanged
+	require.NoError(t, i.Default.RegisterFunc(Function{Name: "query2", Func: query}))
+	schema, err = json.Marshal(i.Default.Functions["query2"].schema)
+	require.NoError(t, err)
+	assert.Contains(t, string(schema), `"extra":{"additionalProperties":true,"type":"object"}`)
+
+	err = i.Default.RegisterFunc(Function{
+		Name:         "query3",
+		Func:         query,
+		FieldSchemas: map[string]json.RawMessage{"missing.field": json.RawMessage(`{}`)},
+	})
+	assert.ErrorContains(t, err, "field 'missing.field' not found")
+}
+
+func TestOptionalFields(t *testing.T) {
+	i, err := New(InferableOptions{
+		APIEndpoint: DefaultAPIEndpoint,
+		APISecret:   "test-secret",
+	})
+	require.NoError(t, err)
+
+	type SearchInput struct {
+		Query    string  `json:"query"`
+		Limit    *int    `json:"limit"`
+		Language string  `json:"language" inferable:"optional"`
+		Cursor   *string `json:"cursor" jsonschema:"required"`
+		Page     int     `json:"page,omitempty" inferable:"required"`
+		Token    string  `json:"token" inferable:"secret,optional"`
+		Filter   struct {
+			Field string  `json:"field"`
+			Value *string `json:"value"`
+		} `json:"filter"`
+	}
+
+	require.NoError(t, i.Default.RegisterFunc(Function{
+		Name: "search",
+		Func: func(input SearchInput) string { return "ok" },
+	}))
+
+	schema, err := json.Marshal(i.Default.Functions["search"].schema)
+	require.NoError(t, err)
+
+	var parsed struct {
+		Required   []string `json:"required"`
+		Properties struct {
+			Filter struct {
+				Required []string `json:"required"`
+			} `json:"filter"`
+		} `json:"properties"`
+	}
+	require.NoError(t, json.Unmarshal(schema, &parsed))
+	assert.Equal(t, []string{"query", "cursor", "filter", "page"}, parsed.Required)
+	assert.Equal(t, []string{"field"}, parsed.Properties.Filter.Required)
+
+	// Secret fields remain redacted when they are optional
+	assert.True(t, isRedacted(reflect.TypeOf(SearchInput{}).Field(5)))
+}
@@ -0,0 +1,102 @@
+package inferable
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// scopeSeparator joins the names of nested scopes and functions
+const scopeSeparator = "_"
+
+// Scope registers functions on a parent service under a name prefix, so that packages can contribute
+// functions to a service without coordinating their names or hooks.
+//
+//	billing := i.Default.Scope("billing")
+//	billing.Use(inferable.Hooks{OnCall: requireBillingRole})
+//	billing.RegisterFunc(inferable.Function{Name: "refund", Func: refund}) // registered as "billing_refund"
+type Scope struct {
+	service *Service
+	parent  *Scope
+	name    string
+	hooks   []Hooks
+}
+
+// Scope creates a registrar which prefixes the names of the functions it registers with name
+func (s *Service) Scope(name string) *Scope {
+	return &Scope{service: s, name: name}
+}
+
+// Scope creates a nested registrar, whose functions are prefixed with the names of both scopes
+// and are subject to the hooks of both scopes
+func (sc *Scope) Scope(name string) *Scope {
+	return &Scope{service: sc.service, parent: sc, name: name}
+}
+
+// Prefix returns the prefix added to the names of functions registered in the scope
+func (sc *Scope) Prefix() string {
+	names := []string{}
+	for scope := sc; scope != nil; scope = scope.parent {
+		names = append([]string{scope.name}, names...)
+	}
+
+	return strings.Join(names, scopeSeparator) + scopeSeparator
+}
+
+// Use adds hooks which apply only to the functions registered in the scope and its nested scopes.
+// Only OnCall and OnResult are invoked for scoped hooks; lifecycle hooks belong on the service.
+func (sc *Scope) Use(hooks Hooks) {
+	sc.hooks = append(sc.hooks, hooks)
+}
+
+// RegisterFunc registers fn on the parent service, with its name prefixed by the scope
+func (sc *Scope) RegisterFunc(fn Function) error {
+	for scope := sc; scope != nil; scope = scope.parent {
+		if scope.name == "" {
+			return fmt.Errorf("cannot register function '%s': scope name must not be empty", fn.Name)
+		}
+	}
+
+	fn.Name = sc.Prefix() + fn.Name
+	fn.scope = sc
+
+	return sc.service.RegisterFunc(fn)
+}
+
+// scopedHooks returns the hooks of the scope and its parents, outermost first
+func (sc *Scope) scopedHooks() []Hooks {
+	hooks := []Hooks{}
+	for scope := sc; scope != nil; scope = scope.parent {
+		hooks = append(append([]Hooks{}, scope.hooks...), hooks...)
+	}
+
+	return hooks
+}
+
+// callHooks returns the hooks applying to calls of fn: the service hooks, followed by those of its scopes
+func (s *Service) callHooks(fn Function) []Hooks {
+	hooks := []Hooks{s.options.Hooks}
+	if fn.scope != nil {
+		hooks = append(hooks, fn.scope.scopedHooks()...)
+	}
+
+	return hooks
+}
+
+func runOnCall(ctx context.Context, hooks []Hooks, call CallInfo, input interface{}) error {
+	for _, h := range hooks {
+		if err := h.onCall(ctx, call, input); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// runOnResult invokes the hooks innermost first, mirroring runOnCall
+func runOnResult(ctx context.Context, hooks []Hooks, call CallInfo, result CallResult, duration time.Duration) {
+	for idx := len(hooks) - 1; idx >= 0; idx-- {
+		hooks[idx].onResult(ctx, call, result, duration)
+	}
+}
@@ -0,0 +1,77 @@
+package inferable
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScope(t *testing.T) {
+	results := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var persisted struct {
+			ResultType string `json:"resultType"`
+		}
+		json.NewDecoder(r.Body).Decode(&persisted)
+		results[r.URL.Path] = persisted.ResultType
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	i, err := New(InferableOptions{
+		APIEndpoint: server.URL,
+		APISecret:   "test-secret",
+	})
+	require.NoError(t, err)
+
+	type TestInput struct{}
+	noop := func(input TestInput) string { return "ok" }
+
+	billing := i.Default.Scope("billing")
+	invoices := billing.Scope("invoices")
+
+	var order []string
+	billing.Use(Hooks{
+		OnCall: func(ctx context.Context, call CallInfo, input interface{}) error {
+			order = append(order, "billing:"+call.Function)
+			return nil
+		},
+	})
+	invoices.Use(Hooks{
+		OnCall: func(ctx context.Context, call CallInfo, input interface{}) error {
+			order = append(order, "invoices:"+call.Function)
+			return fmt.Errorf("invoices are read only")
+		},
+	})
+
+	require.NoError(t, billing.RegisterFunc(Function{Name: "refund", Func: noop}))
+	require.NoError(t, invoices.RegisterFunc(Function{Name: "void", Func: noop}))
+	require.NoError(t, i.Default.RegisterFunc(Function{Name: "refund", Func: noop}))
+
+	assert.Contains(t, i.Default.Functions, "billing_refund")
+	assert.Contains(t, i.Default.Functions, "billing_invoices_void")
+	assert.Equal(t, "billing_invoices_", invoices.Prefix())
+
+	assert.Error(t, billing.RegisterFunc(Function{Name: "refund", Func: noop}))
+	assert.Error(t, i.Default.Scope("").RegisterFunc(Function{Name: "other", Func: noop}))
+
+	for idx, fn := range []string{"refund", "billing_refund", "billing_invoices_void"} {
+		body := fmt.Sprintf(`{"value": {"id": "call-%d", "service": "default", "targetFn": "%s", "targetArgs": "{\"value\": {}}"}}`, idx, fn)
+		require.NoError(t, i.Default.handleMessage(&sqs.Message{Body: aws.String(body)}, time.Now()))
+	}
+
+	// Scoped hooks only apply to functions in the scope, outermost first
+	assert.Equal(t, []string{"billing:billing_refund", "billing:billing_invoices_void", "invoices:billing_invoices_void"}, order)
+	assert.Equal(t, "resolution", results["/jobs/call-0/result"])
+	assert.Equal(t, "resolution", results["/jobs/call-1/result"])
+	assert.Equal(t, "rejection", results["/jobs/call-2/result"])
+}
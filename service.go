@@ -2,11 +2,19 @@ package inferable
 
 import (
 	"context"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"net/http"
 	"reflect"
+	"runtime/debug"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/aws/aws-sdk-go/service/sqs"
@@ -14,113 +22,583 @@ import (
 )
 
 type Service struct {
-	Name      string
-	Functions map[string]Function
-	inferable *Inferable
-	// Add new fields to store registration details
-	queueURL    string
-	region      string
-	enabled     bool
-	expiration  time.Time
+	Name string
+	// Functions registered with the service. It is guarded by functionsMu, so it must only be modified
+	// through RegisterFunc, and only read directly while no functions are being registered.
+	Functions   map[string]Function
+	functionsMu sync.RWMutex
+	inferable   *Inferable
+	// Add new fields to store registration details, which are guarded by credentialsMu
+	queueURL   string
+	region     string
+	enabled    bool
+	expiration time.Time
+	// clusterID is the cluster the machine registered with, if reported by the API
+	clusterID string
+	// registered is set once the machine has registered, and cleared when the service is stopped
+	registered atomic.Bool
+	// stopped is set when the service is stopped or deregistered, leaving it out of cluster pings until it
+	// registers again
+	stopped atomic.Bool
+	// health of the poll loop and calls being handled, see Health
+	lastPoll     atomic.Int64
+	pollFailures atomic.Int64
+	inFlight     atomic.Int64
+	// stats counts the calls handled by the service, see Stats
+	stats callStats
+	// workers numbers the calls handled at once, see resultMetadata.WorkerID
+	workers workerPool
+	// panics detects panic storms, see MachineErrorPanicStorm
+	panics panicStorm
+	// draining stops the watchdog from restarting the poll loop, see Drain
+	draining atomic.Bool
+	// paused stops the service from polling for new calls, see Pause
+	paused atomic.Bool
+	// journalRecovery rejects the calls left in the journal by the previous process once, see recoverInterruptedCalls
+	journalRecovery sync.Once
+	// resultCache holds results of functions with CacheConfig.Local
+	resultCache resultCache
 	credentials struct {
 		AccessKeyID     string
 		SecretAccessKey string
 		SessionToken    string
 	}
-	consumer *SQSConsumer
-	ctx      context.Context
-	cancel   context.CancelFunc
+	// credentialsMu guards credentials, expiration and the other registration details, which are replaced
+	// whenever the machine registers again, e.g. to refresh its credentials
+	credentialsMu sync.RWMutex
+	// refreshMu makes concurrent refreshes of the credentials register the machine only once, see refreshCredentials
+	refreshMu sync.Mutex
+	// resultKey encrypts result fields tagged `inferable:"sensitive"`, if provided by the cluster at registration
+	resultKey *rsa.PublicKey
+	consumer  Transport
+	// consumerMu guards the consumer as it is replaced by the watchdog
+	consumerMu     sync.Mutex
+	consumerCancel context.CancelFunc
+	consumerExited chan struct{}
+	// Results which could not be persisted in time are retried in the background by a worker which runs
+	// until the service is stopped, see persistWithTimeout. persistMu guards persistQueue and persistCtx,
+	// the queue of the running worker and the context it runs with.
+	persistQueue   chan pendingPersist
+	persistCtx     context.Context
+	persistMu      sync.Mutex
+	pendingPersist atomic.Int64
+	// batch collects results while the calls of a poll are handled with ServiceOptions.BatchResults
+	batchMu sync.Mutex
+	batch   []pendingPersist
+	// lifecycleMu guards ctx and cancel, as well as done and err which report why the service
+	// stopped, see Done and Err
+	lifecycleMu sync.Mutex
+	done        chan struct{}
+	err         error
+	ctx         context.Context
+	cancel      context.CancelFunc
+	options     ServiceOptions
 }
 
+type ServiceOptions struct {
+	// DisableAutoAcknowledge stops the service from acknowledging calls as they are received.
+	// Calls must then be acknowledged with Service.Ack, trading latency for stronger delivery guarantees.
+	DisableAutoAcknowledge bool
+	// Hooks are invoked around the lifecycle of the service and every call it handles
+	Hooks Hooks
+	// RegistrationRetryWindow is how long Start keeps retrying registration after transient failures
+	// before giving up. Defaults to DefaultRegistrationRetryWindow. A negative value disables retries.
+	RegistrationRetryWindow time.Duration
+	// StartTrace is invoked before each call is handled, to link calls to distributed traces.
+	// When set, the returned trace ID is persisted in the result metadata and set on CallInfo.
+	StartTrace StartTraceFunc
+	// WatchdogInterval is how often the watchdog checks that the poll loop is alive. A poll loop which
+	// has exited, or made no progress for WatchdogMissedIntervals intervals, is restarted.
+	// Defaults to DefaultWatchdogInterval. A negative value disables the watchdog.
+	WatchdogInterval time.Duration
+	// PollLimit is the maximum number of calls received in one poll, up to 10. Defaults to DefaultMaxMessages.
+	PollLimit int
+	// WaitTime is how long a poll waits for calls to arrive, up to 20 seconds. Defaults to DefaultPollWaitTime.
+	// A negative value disables long polling.
+	WaitTime time.Duration
+	// Interval is the delay between polls. Defaults to DefaultPollInterval.
+	Interval time.Duration
+	// MaxPollInterval enables adaptive polling: while polls return no calls, the poll interval is
+	// gradually lengthened up to MaxPollInterval, and reset as soon as calls arrive. Disabled by default.
+	MaxPollInterval time.Duration
+	// ReportRegistrationChanges sends the changes to the functions of the service since its previous
+	// registration along with the registration. Requires InferableOptions.RegistrationHistoryDir.
+	ReportRegistrationChanges bool
+	// BatchResults submits the results of the calls received in one poll in a single request,
+	// reducing round-trips for services handling many quick calls
+	BatchResults bool
+	// PersistTimeout is how long persisting a result may take before it is handed to a background
+	// worker, so that slow API responses do not hold up new calls. Defaults to DefaultPersistTimeout.
+	PersistTimeout time.Duration
+	// WatchdogMissedIntervals defaults to DefaultWatchdogMissedIntervals
+	WatchdogMissedIntervals int
+	// MaxCallAttempts is how often a call failing with a retryable error (see Retryable) is attempted
+	// before the error rejects it. Defaults to DefaultMaxCallAttempts.
+	MaxCallAttempts int
+	// RetryDelay is how long a call failing with a retryable error waits to be dispatched again.
+	// It has a resolution of one second, shorter delays are rounded up. Defaults to DefaultRetryDelay.
+	RetryDelay time.Duration
+	// ResultChunkSize is the size of the chunks in which results streamed by functions (returned as an
+	// io.Reader or <-chan []byte) are uploaded. Defaults to DefaultResultChunkSize.
+	ResultChunkSize int
+	// MaxResultSize is the size in bytes of the largest result persisted as is. Larger results are
+	// handled according to OversizedResults. Defaults to DefaultMaxResultSize.
+	MaxResultSize int
+	// OversizedResults chooses how results larger than MaxResultSize are persisted.
+	// Defaults to OversizedResultUpload.
+	OversizedResults OversizedResultPolicy
+	// MaxInputSize is the size in bytes of the largest input accepted. Calls with larger inputs are
+	// rejected before their input is decoded. Defaults to DefaultMaxInputSize.
+	MaxInputSize int
+	// MaxInputDepth is how deeply arrays and objects of inputs may be nested. Calls with deeper inputs
+	// are rejected before their input is decoded. Defaults to DefaultMaxInputDepth.
+	MaxInputDepth int
+	// JSONCodec decodes the inputs and encodes the results of calls, e.g. a faster codec compatible with
+	// encoding/json. Defaults to StdJSONCodec.
+	JSONCodec JSONCodec
+	// PollTimeout is how long a poll may take before it is abandoned and retried, so that a stalled
+	// connection cannot block the poll loop. Must exceed WaitTime. Defaults to WaitTime plus 10 seconds.
+	PollTimeout time.Duration
+	// InputValidator validates the input of each call before the function is called, e.g. with
+	// go-playground/validator. Constraints of `validate` tags are included in the schemas either way.
+	InputValidator InputValidator
+	// CallLogging enables structured logs of the lifecycle of a sample of calls
+	CallLogging CallLogging
+	// MaxConcurrentCalls is how many calls are handled at once. While all of them are busy, no further
+	// calls are received, leaving them to other machines of the cluster. Calls are handled one at a time by default.
+	// It can be changed while the service runs, see RuntimeConfig.MaxConcurrentCalls.
+	MaxConcurrentCalls int
+	// UtilizationReporter receives the utilization of the service every UtilizationInterval while it is started,
+	// e.g. to scale the machines running it
+	UtilizationReporter UtilizationReporter
+	// UtilizationInterval defaults to DefaultUtilizationInterval
+	UtilizationInterval time.Duration
+}
+
+// StartTraceFunc starts a span for a call. It returns a context carrying the span, the ID of its trace,
+// and a function which ends the span once the call has been handled.
+type StartTraceFunc func(ctx context.Context, call CallInfo) (context.Context, string, func())
+
+const (
+	DefaultRegistrationRetryWindow = 30 * time.Second
+
+	initialRegistrationBackoff = 500 * time.Millisecond
+	maxRegistrationBackoff     = 10 * time.Second
+)
+
 type Function struct {
 	Name        string
 	Description string
-	schema      interface{}
-	Config      interface{}
-	Func        interface{}
+	// Version of the function, e.g. the release of the binary, reported at registration and in the
+	// metadata of results so that fleets running mixed versions can be observed
+	Version string
+	schema  interface{}
+	Config  FunctionConfig
+	Func    interface{}
+	// InputSchema overrides the JSON schema reflected from the input struct.
+	// It is required for functions which take their input as a json.RawMessage.
+	InputSchema json.RawMessage
+	// FieldSchemas replace the schemas of properties of the reflected input schema, keyed by their dotted
+	// JSON path, e.g. "filter.options". Useful for free-form fields such as map[string]any, which otherwise
+	// accept any object.
+	FieldSchemas map[string]json.RawMessage
+	// scope is set for functions registered through a Scope
+	scope *Scope
+	// compiledFn is set on registration
+	compiledFn *compiledFunction
+}
+
+// FunctionConfig holds optional settings for a function which are sent to the control plane at registration
+type FunctionConfig struct {
+	// ErrorCodes are the known failure modes of the function, included in its definition
+	// so that agents can be prompted with them
+	ErrorCodes []ErrorCode
+	// ResultContentType declares the semantic content type of the result, so that renderers and agents
+	// treat it correctly. Defaults to ContentTypeJSON. Other content types require the function to return a string.
+	ResultContentType ContentType
+	// RequiredLabels restricts the function to machines advertising all of these labels
+	// (see InferableOptions.MachineLabels), e.g. "gpu", "vpn" or "region:eu".
+	RequiredLabels []string
+	// Sensitive stops the raw input of calls from being written to local logs.
+	// The size and SHA-256 hash of the input are logged and persisted with the result instead.
+	Sensitive bool
+	// ResultMarshaler serializes the results of the function in place of encoding/json, e.g. to render
+	// protobuf messages with protojson, format decimals or redact fields. It must produce valid JSON.
+	ResultMarshaler func(interface{}) ([]byte, error)
+	// RedactResults replaces the fields of results tagged `redact:"true"` or `inferable:"secret"` before they
+	// are persisted, keeping them out of run transcripts. Such fields of the input are always redacted in logs.
+	RedactResults bool
+	// Private functions can only be called directly through the API, and are never offered to agents as tools
+	Private bool
+	// Cache lets the cluster cache the results of calls with the same key, and optionally the machine
+	Cache *CacheConfig
+	// TimeoutSeconds is how long a call may take before the cluster considers it stalled. Defaults to the cluster's setting.
+	TimeoutSeconds int
+	// RetryCountOnStall is how often the cluster dispatches a stalled call again before failing it
+	RetryCountOnStall int
+	// LocalRetry executes the function again on this machine when it fails transiently, before the error is persisted
+	LocalRetry *LocalRetryPolicy
+	// Execution chooses when calls are acknowledged. Defaults to ExecutionAtMostOnce.
+	// It has no effect with ServiceOptions.DisableAutoAcknowledge.
+	Execution ExecutionPolicy
+}
+
+// ExecutionPolicy chooses between losing and duplicating calls when a machine crashes while handling them
+type ExecutionPolicy string
+
+const (
+	// ExecutionAtMostOnce acknowledges calls before the function executes. Calls handled by a machine
+	// which crashes are lost rather than executed again.
+	ExecutionAtMostOnce ExecutionPolicy = "at-most-once"
+	// ExecutionAtLeastOnce acknowledges calls once the function has executed. Calls handled by a machine
+	// which crashes are dispatched again, so side-effecting functions should be idempotent.
+	ExecutionAtLeastOnce ExecutionPolicy = "at-least-once"
+)
+
+// ContentType is the semantic content type of a function result
+type ContentType string
+
+const (
+	ContentTypeJSON     ContentType = "json"
+	ContentTypeMarkdown ContentType = "markdown"
+	ContentTypeHTML     ContentType = "html"
+	ContentTypeCSV      ContentType = "csv"
+)
+
+// resultMetadata is persisted alongside the result of a call. Durations are in milliseconds.
+type resultMetadata struct {
+	FunctionExecutionTime int64 `json:"functionExecutionTime"`
+	// QueueWaitTime is the time between the call being received and the function starting to execute
+	QueueWaitTime int64       `json:"queueWaitTime"`
+	ContentType   ContentType `json:"contentType,omitempty"`
+	TraceID       string      `json:"traceId,omitempty"`
+	// InputSize and InputHash (SHA-256) identify the input of calls to sensitive functions
+	InputSize int    `json:"inputSize,omitempty"`
+	InputHash string `json:"inputHash,omitempty"`
+	// Chunked is set if the result was streamed, see ServiceOptions.ResultChunkSize
+	Chunked bool `json:"chunked,omitempty"`
+	// Truncated is set if the result was too large and replaced, see ServiceOptions.OversizedResults
+	Truncated bool `json:"truncated,omitempty"`
+	// FunctionVersion is the Function.Version which handled the call
+	FunctionVersion string `json:"functionVersion,omitempty"`
+	// Cached is set if the result was served from the local cache, see CacheConfig.Local
+	Cached bool `json:"cached,omitempty"`
+	// MachineID and SDKVersion identify the machine which handled the call
+	MachineID  string `json:"machineId,omitempty"`
+	SDKVersion string `json:"sdkVersion,omitempty"`
+	// Attempt is the attempt of the call which produced the result, see CallInfo.Attempt
+	Attempt int `json:"attempt,omitempty"`
+	// WorkerID is the worker of the machine which handled the call, see ServiceOptions.MaxConcurrentCalls
+	WorkerID int `json:"workerId,omitempty"`
+	// Tags are set by the function with SetResultMeta
+	Tags map[string]string `json:"tags,omitempty"`
+}
+
+// inputDigest returns the size and hex encoded SHA-256 hash of a call input
+func inputDigest(input []byte) (int, string) {
+	sum := sha256.Sum256(input)
+	return len(input), hex.EncodeToString(sum[:])
+}
+
+// callInput returns the JSON of the call input, which the API sends either as an object or encoded in a string
+func callInput(targetArgs json.RawMessage) ([]byte, error) {
+	if len(targetArgs) == 0 || targetArgs[0] != '"' {
+		return targetArgs, nil
+	}
+
+	var encoded string
+	if err := json.Unmarshal(targetArgs, &encoded); err != nil {
+		return nil, err
+	}
+
+	return []byte(encoded), nil
+}
+
+// ErrorCode describes a structured error which a function may produce
+type ErrorCode struct {
+	Code        string `json:"code"`
+	Description string `json:"description,omitempty"`
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+type functionRegistration struct {
+	Name              string      `json:"name"`
+	Description       string      `json:"description,omitempty"`
+	Schema            string      `json:"schema,omitempty"`
+	CanReturnError    bool        `json:"canReturnError"`
+	ErrorCodes        []ErrorCode `json:"errorCodes,omitempty"`
+	RequiredLabels    []string    `json:"requiredLabels,omitempty"`
+	ResultContentType ContentType `json:"resultContentType,omitempty"`
+	Version           string      `json:"version,omitempty"`
+	// Config holds settings which are sent under "config", as by the other Inferable SDKs
+	Config *functionRegistrationConfig `json:"config,omitempty"`
+}
+
+// functionRegistrationConfig is the configuration of a function, as sent at registration
+type functionRegistrationConfig struct {
+	Private           bool                       `json:"private,omitempty"`
+	Cache             *functionRegistrationCache `json:"cache,omitempty"`
+	RetryCountOnStall int                        `json:"retryCountOnStall,omitempty"`
+	TimeoutSeconds    int                        `json:"timeoutSeconds,omitempty"`
+}
+
+// registrationConfig returns the configuration of the function sent at registration, or nil if it has none
+func (c FunctionConfig) registrationConfig() *functionRegistrationConfig {
+	config := functionRegistrationConfig{
+		Private:           c.Private,
+		Cache:             c.Cache.registrationConfig(),
+		RetryCountOnStall: c.RetryCountOnStall,
+		TimeoutSeconds:    c.TimeoutSeconds,
+	}
+	if config == (functionRegistrationConfig{}) {
+		return nil
+	}
+
+	return &config
 }
 
 func (s *Service) RegisterFunc(fn Function) error {
-	if _, exists := s.Functions[fn.Name]; exists {
+	if _, exists := s.function(fn.Name); exists {
 		return fmt.Errorf("function with name '%s' already registered for service '%s'", fn.Name, s.Name)
 	}
 
-	// Validate that the function has exactly one argument and it's a struct
+	// Validate that the function has exactly one argument (optionally preceded by a context.Context) and it's a struct
 	fnType := reflect.TypeOf(fn.Func)
-	if fnType.NumIn() != 1 {
+	if fnType == nil || fnType.Kind() != reflect.Func || reflect.ValueOf(fn.Func).IsNil() {
+		return fmt.Errorf("function '%s' must be a non-nil func, got %T", fn.Name, fn.Func)
+	}
+	if fnType.NumIn() != 1 && !acceptsContext(fnType) {
 		return fmt.Errorf("function '%s' must have exactly one argument", fn.Name)
 	}
-	argType := fnType.In(0)
-	if argType.Kind() != reflect.Struct {
-		return fmt.Errorf("function '%s' argument must be a struct", fn.Name)
+	argType := inputType(fnType)
+
+	if fn.InputSchema != nil {
+		if argType.Kind() != reflect.Struct && argType != rawMessageType {
+			return fmt.Errorf("function '%s' argument must be a struct or json.RawMessage", fn.Name)
+		}
+
+		var schema map[string]interface{}
+		if err := json.Unmarshal(fn.InputSchema, &schema); err != nil {
+			return fmt.Errorf("input schema for function '%s' must be a JSON object: %v", fn.Name, err)
+		}
+		fn.schema = fn.InputSchema
+	} else {
+		if argType.Kind() != reflect.Struct {
+			return fmt.Errorf("function '%s' argument must be a struct", fn.Name)
+		}
+
+		if schema, ok, err := providedSchema(fn.Name, argType); ok {
+			if err != nil {
+				return err
+			}
+			fn.schema = schema
+		} else {
+			schema, err := reflectSchema(fn.Name, argType)
+			if err != nil {
+				return err
+			}
+			if len(fn.FieldSchemas) > 0 {
+				if schema, err = withFieldSchemas(schema, fn.FieldSchemas); err != nil {
+					return fmt.Errorf("failed to apply field schemas for function '%s': %v", fn.Name, err)
+				}
+			}
+			fn.schema = schema
+		}
+	}
+
+	if len(fn.FieldSchemas) > 0 {
+		if _, reflected := fn.schema.(*jsonschema.Schema); !reflected {
+			return fmt.Errorf("field schemas of function '%s' require a reflected input schema", fn.Name)
+		}
+	}
+
+	if provider, ok := fn.Func.(DescriptionProvider); ok && fn.Description == "" {
+		fn.Description = provider.InferableDescription()
+	}
+
+	if err := validateLabels(fn.Config.RequiredLabels); err != nil {
+		return fmt.Errorf("invalid required labels for function '%s': %v", fn.Name, err)
+	}
+
+	for _, errorCode := range fn.Config.ErrorCodes {
+		if errorCode.Code == "" {
+			return fmt.Errorf("error codes for function '%s' must not be empty", fn.Name)
+		}
+	}
+
+	if fn.Config.TimeoutSeconds < 0 || fn.Config.RetryCountOnStall < 0 {
+		return fmt.Errorf("timeout and stall retries of function '%s' must not be negative", fn.Name)
+	}
+
+	if fn.Config.LocalRetry != nil {
+		if err := fn.Config.LocalRetry.validate(); err != nil {
+			return fmt.Errorf("invalid local retry policy for function '%s': %v", fn.Name, err)
+		}
+	}
+
+	switch fn.Config.Execution {
+	case "", ExecutionAtMostOnce, ExecutionAtLeastOnce:
+	default:
+		return fmt.Errorf("unknown execution policy '%s' of function '%s'", fn.Config.Execution, fn.Name)
+	}
+
+	if fn.Config.Cache != nil {
+		if err := fn.Config.Cache.validate(); err != nil {
+			return fmt.Errorf("invalid cache configuration for function '%s': %v", fn.Name, err)
+		}
+	}
+
+	if len(fn.Config.ErrorCodes) > 0 && !returnsError(fnType) {
+		return fmt.Errorf("function '%s' declares error codes but does not return an error", fn.Name)
+	}
+
+	switch fn.Config.ResultContentType {
+	case "", ContentTypeJSON:
+	case ContentTypeMarkdown, ContentTypeHTML, ContentTypeCSV:
+		if fnType.NumOut() == 0 || fnType.Out(0).Kind() != reflect.String {
+			return fmt.Errorf("function '%s' must return a string to declare result content type '%s'", fn.Name, fn.Config.ResultContentType)
+		}
+	default:
+		return fmt.Errorf("unknown result content type '%s' for function '%s'", fn.Config.ResultContentType, fn.Name)
 	}
 
-	// Get the schema for the input struct
-	reflector := jsonschema.Reflector{}
-	schema := reflector.Reflect(reflect.New(argType).Interface())
+	fn.compiledFn = compileFunction(fn.Func)
+
+	s.functionsMu.Lock()
+	defer s.functionsMu.Unlock()
+
+	// The function may have been registered concurrently
+	if _, exists := s.Functions[fn.Name]; exists {
+		return fmt.Errorf("function with name '%s' already registered for service '%s'", fn.Name, s.Name)
+	}
+	s.Functions[fn.Name] = fn
+	return nil
+}
+
+// function returns the registered function with the given name
+func (s *Service) function(name string) (Function, bool) {
+	s.functionsMu.RLock()
+	defer s.functionsMu.RUnlock()
+
+	fn, ok := s.Functions[name]
+	return fn, ok
+}
+
+// functions returns a snapshot of the registered functions, ordered by name
+func (s *Service) functions() []Function {
+	s.functionsMu.RLock()
+	defer s.functionsMu.RUnlock()
+
+	functions := make([]Function, 0, len(s.Functions))
+	for _, fn := range s.Functions {
+		functions = append(functions, fn)
+	}
+
+	sort.Slice(functions, func(a, b int) bool {
+		return functions[a].Name < functions[b].Name
+	})
+
+	return functions
+}
+
+var rawMessageType = reflect.TypeOf(json.RawMessage{})
+
+// reflectSchema derives the JSON schema of a function's input struct
+func reflectSchema(fnName string, argType reflect.Type) (*jsonschema.Schema, error) {
+	if schema, ok := cachedSchema(argType); ok {
+		return schema, nil
+	}
+
+	// Input types implementing JSONSchema are not reflected at all
+	if value, ok := reflect.New(argType).Interface().(customSchema); ok {
+		schema := value.JSONSchema()
+		if schema == nil {
+			return nil, fmt.Errorf("failed to get schema for function '%s'", fnName)
+		}
+		schemaCache.Store(argType, schema)
+		return schema, nil
+	}
+
+	errs := []error{}
+	schema := newReflector(&errs).Reflect(reflect.New(argType).Interface())
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("failed to get schema for function '%s': %v", fnName, errs[0])
+	}
 
 	if schema == nil {
-		return fmt.Errorf("failed to get schema for function '%s'", fn.Name)
+		return nil, fmt.Errorf("failed to get schema for function '%s'", fnName)
 	}
 
 	// Extract the relevant part of the schema
 	defs, ok := schema.Definitions[argType.Name()]
 	if !ok {
-		return fmt.Errorf("failed to find schema definition for %s", argType.Name())
+		return nil, fmt.Errorf("failed to find schema definition for %s", argType.Name())
 	}
 
 	defsString, err := json.Marshal(defs)
 	if err != nil {
-		return fmt.Errorf("failed to marshal schema for function '%s': %v", fn.Name, err)
+		return nil, fmt.Errorf("failed to marshal schema for function '%s': %v", fnName, err)
 	}
 
 	if strings.Contains(string(defsString), "\"$ref\":\"#/$defs") {
-		return fmt.Errorf("schema for function '%s' contains a $ref to an external definition. this is currently not supported. see https://go.inferable.ai/go-schema-limitation for details", fn.Name)
+		return nil, fmt.Errorf("schema for function '%s' contains a $ref to an external definition. this is currently not supported. see https://go.inferable.ai/go-schema-limitation for details", fnName)
 	}
 
 	defs.AdditionalProperties = nil
-	fn.schema = defs
-
-	s.Functions[fn.Name] = fn
-	return nil
+	applyOptionalFields(defs, argType)
+	applyValidateTags(defs, argType)
+	schemaCache.Store(argType, defs)
+	return defs, nil
 }
 
 func (s *Service) registerMachine() error {
 	// Check if there are any registered functions
-	if len(s.Functions) == 0 {
+	functions := s.functions()
+	if len(functions) == 0 {
 		return fmt.Errorf("cannot register service '%s': no functions registered", s.Name)
 	}
 
 	// Prepare the payload for registration
 	payload := struct {
-		Service   string `json:"service"`
-		Functions []struct {
-			Name        string `json:"name"`
-			Description string `json:"description,omitempty"`
-			Schema      string `json:"schema,omitempty"`
-		} `json:"functions,omitempty"`
+		Service   string                 `json:"service"`
+		Labels    []string               `json:"labels,omitempty"`
+		Functions []functionRegistration `json:"functions,omitempty"`
+		Changes   *RegistrationChanges   `json:"changes,omitempty"`
 	}{
 		Service: s.Name,
+		Labels:  s.inferable.machineLabels,
 	}
 
 	// Add registered functions to the payload
-	for _, fn := range s.Functions {
+	for _, fn := range functions {
 		schemaJSON, err := json.Marshal(fn.schema)
 		if err != nil {
 			return fmt.Errorf("failed to marshal schema for function '%s': %v", fn.Name, err)
 		}
 
-		payload.Functions = append(payload.Functions, struct {
-			Name        string `json:"name"`
-			Description string `json:"description,omitempty"`
-			Schema      string `json:"schema,omitempty"`
-		}{
-			Name:        fn.Name,
-			Description: fn.Description,
-			Schema:      string(schemaJSON),
+		payload.Functions = append(payload.Functions, functionRegistration{
+			Name:              fn.Name,
+			Description:       fn.Description,
+			Schema:            string(schemaJSON),
+			CanReturnError:    returnsError(reflect.TypeOf(fn.Func)),
+			ErrorCodes:        fn.Config.ErrorCodes,
+			RequiredLabels:    fn.Config.RequiredLabels,
+			ResultContentType: fn.Config.ResultContentType,
+			Version:           fn.Version,
+			Config:            fn.Config.registrationConfig(),
 		})
 	}
 
+	sort.Slice(payload.Functions, func(a, b int) bool {
+		return payload.Functions[a].Name < payload.Functions[b].Name
+	})
+
+	changes := s.registrationChanges(payload.Functions)
+	if s.options.ReportRegistrationChanges {
+		payload.Changes = changes
+	}
+
 	// Marshal the payload to JSON
 	jsonPayload, err := json.Marshal(payload)
 	if err != nil {
@@ -128,12 +606,7 @@ func (s *Service) registerMachine() error {
 	}
 
 	// Prepare headers
-	headers := map[string]string{
-		"Authorization":          "Bearer " + s.inferable.apiSecret,
-		"X-Machine-ID":           s.inferable.machineID,
-		"X-Machine-SDK-Version":  Version,
-		"X-Machine-SDK-Language": "go",
-	}
+	headers := s.machineHeaders()
 
 	// Call the registerMachine endpoint
 	options := FetchDataOptions{
@@ -145,16 +618,19 @@ func (s *Service) registerMachine() error {
 
 	responseData, err := s.inferable.FetchData(options)
 	if err != nil {
-		return fmt.Errorf("failed to register machine: %v", err)
+		return fmt.Errorf("failed to register machine: %w", err)
 	}
 
 	// Parse the response
 	var response struct {
-		QueueURL    string    `json:"queueUrl"`
-		Region      string    `json:"region"`
-		Enabled     bool      `json:"enabled"`
-		Expiration  time.Time `json:"expiration"`
-		Credentials struct {
+		QueueURL   string    `json:"queueUrl"`
+		Region     string    `json:"region"`
+		Enabled    bool      `json:"enabled"`
+		Expiration time.Time `json:"expiration"`
+		ClusterID  string    `json:"clusterId"`
+		// ResultEncryptionKey is a PEM encoded RSA public key, if the cluster encrypts sensitive results
+		ResultEncryptionKey string `json:"resultEncryptionKey"`
+		Credentials         struct {
 			AccessKeyID     string `json:"accessKeyId"`
 			SecretAccessKey string `json:"secretAccessKey"`
 			SessionToken    string `json:"sessionToken"`
@@ -166,216 +642,682 @@ func (s *Service) registerMachine() error {
 		return fmt.Errorf("failed to parse registration response: %v", err)
 	}
 
+	var resultKey *rsa.PublicKey
+	if response.ResultEncryptionKey != "" {
+		resultKey, err = parseResultEncryptionKey(response.ResultEncryptionKey)
+		if err != nil {
+			return err
+		}
+	}
+
+	if s.inferable.registrationHistoryDir != "" {
+		if err := saveRegistration(registrationHistoryPath(s.inferable.registrationHistoryDir, s.Name), payload.Functions); err != nil {
+			s.inferable.logf(LogLevelError, "Failed to save registration of service '%s': %v", s.Name, err)
+		}
+	}
+
 	// Store the registration details in the Service struct
+	s.credentialsMu.Lock()
+	if resultKey != nil {
+		s.resultKey = resultKey
+	}
 	s.queueURL = response.QueueURL
 	s.region = response.Region
 	s.enabled = response.Enabled
+	s.clusterID = response.ClusterID
 	s.expiration = response.Expiration
 	s.credentials.AccessKeyID = response.Credentials.AccessKeyID
 	s.credentials.SecretAccessKey = response.Credentials.SecretAccessKey
 	s.credentials.SessionToken = response.Credentials.SessionToken
+	s.credentialsMu.Unlock()
+	s.registered.Store(true)
+	s.stopped.Store(false)
 
 	return nil
 }
 
+// ClusterID returns the ID of the cluster the service registered with, or InferableOptions.ClusterID
+// if the service has not registered yet
+func (s *Service) ClusterID() string {
+	s.credentialsMu.RLock()
+	defer s.credentialsMu.RUnlock()
+
+	if s.clusterID != "" {
+		return s.clusterID
+	}
+
+	return s.inferable.clusterID
+}
+
+// IsRegistered reports whether the machine has registered the service and it has not been stopped since
+func (s *Service) IsRegistered() bool {
+	return s.registered.Load()
+}
+
+func (s *Service) registrationRetryWindow() time.Duration {
+	if s.options.RegistrationRetryWindow == 0 {
+		return DefaultRegistrationRetryWindow
+	}
+
+	return s.options.RegistrationRetryWindow
+}
+
+func (s *Service) pollLimit() int {
+	if s.options.PollLimit == 0 {
+		return DefaultMaxMessages
+	}
+
+	return s.options.PollLimit
+}
+
+// maxConcurrentCalls returns how many calls are handled at once, see ServiceOptions.MaxConcurrentCalls
+// and RuntimeConfig.MaxConcurrentCalls
+func (s *Service) maxConcurrentCalls() int {
+	limit := s.options.MaxConcurrentCalls
+	if override := int(s.inferable.maxConcurrentCalls.Load()); override > 0 && !s.options.BatchResults {
+		limit = override
+	}
+
+	if limit <= 1 {
+		return 1
+	}
+
+	return limit
+}
+
+func (s *Service) pollWaitTime() time.Duration {
+	return s.options.effectiveWaitTime()
+}
+
+func (o ServiceOptions) effectiveWaitTime() time.Duration {
+	switch {
+	case o.WaitTime == 0:
+		return DefaultPollWaitTime
+	case o.WaitTime < 0:
+		return 0
+	}
+
+	return o.WaitTime
+}
+
+func (s *Service) pollTimeout() time.Duration {
+	if s.options.PollTimeout == 0 {
+		return s.pollWaitTime() + pollTimeoutMargin
+	}
+
+	return s.options.PollTimeout
+}
+
+func (s *Service) pollInterval() time.Duration {
+	if s.options.Interval == 0 {
+		return DefaultPollInterval
+	}
+
+	return s.options.Interval
+}
+
+func (o ServiceOptions) validate() error {
+	if o.PollLimit < 0 || o.PollLimit > maxPollLimit {
+		return fmt.Errorf("poll limit must be between 1 and %d, got %d", maxPollLimit, o.PollLimit)
+	}
+
+	if o.WaitTime > maxPollWaitTime {
+		return fmt.Errorf("wait time must be at most %s, got %s", maxPollWaitTime, o.WaitTime)
+	}
+
+	if o.Interval < 0 {
+		return fmt.Errorf("poll interval must not be negative, got %s", o.Interval)
+	}
+
+	if o.MaxCallAttempts < 0 {
+		return fmt.Errorf("max call attempts must not be negative, got %d", o.MaxCallAttempts)
+	}
+
+	if o.RetryDelay < 0 {
+		return fmt.Errorf("retry delay must not be negative, got %s", o.RetryDelay)
+	}
+
+	if o.ResultChunkSize < 0 {
+		return fmt.Errorf("result chunk size must not be negative, got %d", o.ResultChunkSize)
+	}
+
+	if o.MaxResultSize < 0 {
+		return fmt.Errorf("max result size must not be negative, got %d", o.MaxResultSize)
+	}
+
+	if o.MaxInputSize < 0 || o.MaxInputDepth < 0 {
+		return fmt.Errorf("max input size and depth must not be negative")
+	}
+
+	switch o.OversizedResults {
+	case "", OversizedResultUpload, OversizedResultTruncate:
+	default:
+		return fmt.Errorf("invalid oversized result policy '%s'", o.OversizedResults)
+	}
+
+	if o.PollTimeout < 0 {
+		return fmt.Errorf("poll timeout must not be negative, got %s", o.PollTimeout)
+	}
+
+	if o.PollTimeout > 0 && o.PollTimeout <= o.effectiveWaitTime() {
+		return fmt.Errorf("poll timeout must exceed the wait time of %s, got %s", o.effectiveWaitTime(), o.PollTimeout)
+	}
+
+	if err := o.CallLogging.validate(); err != nil {
+		return err
+	}
+
+	if o.MaxConcurrentCalls < 0 {
+		return fmt.Errorf("max concurrent calls must not be negative, got %d", o.MaxConcurrentCalls)
+	}
+
+	if o.UtilizationInterval < 0 {
+		return fmt.Errorf("utilization interval must not be negative, got %s", o.UtilizationInterval)
+	}
+
+	if o.MaxConcurrentCalls > 1 && o.BatchResults {
+		return fmt.Errorf("max concurrent calls can not be combined with batched results")
+	}
+
+	return nil
+}
+
+// registerMachineWithRetry registers the machine, retrying transient failures with backoff
+// until the registration retry window has passed
+func (s *Service) registerMachineWithRetry() error {
+	deadline := time.Now().Add(s.registrationRetryWindow())
+	delay := initialRegistrationBackoff
+
+	for attempt := 1; ; attempt++ {
+		err := s.registerMachine()
+		if err == nil || !isRetryableError(err) {
+			return err
+		}
+
+		var rateLimited *RateLimitedError
+		if errors.As(err, &rateLimited) && rateLimited.RetryAfter > delay {
+			delay = rateLimited.RetryAfter
+		}
+
+		if time.Now().Add(delay).After(deadline) {
+			return err
+		}
+
+		s.inferable.logf(LogLevelInfo, "Failed to register service '%s' (attempt %d). Retrying in %s: %v", s.Name, attempt, delay, err)
+		s.options.Hooks.onRegisterRetry(s, attempt, err, delay)
+
+		time.Sleep(delay)
+		delay *= 2
+		if delay > maxRegistrationBackoff {
+			delay = maxRegistrationBackoff
+		}
+	}
+}
+
 // Start initializes the service, registers the machine, and starts polling for messages
 func (s *Service) Start() error {
-	err := s.registerMachine()
+	s.draining.Store(false)
+	s.resetDone()
+
+	err := s.registerMachineWithRetry()
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusBadRequest || apiErr.StatusCode == http.StatusUnprocessableEntity) {
+		s.reportMachineError(MachineErrorRegistrationRejected, err, "")
+	}
 	if err != nil {
-		return fmt.Errorf("failed to register machine: %v", err)
+		return fmt.Errorf("failed to register machine: %w", unauthorizedError(err, machineSecretGuidance))
 	}
 
-	// Create a new SQSConsumer with credentials
-	consumer, err := NewSQSConsumer(
-		s.region,
-		s.queueURL,
-		s.handleMessage,
-		s.credentials.AccessKeyID,
-		s.credentials.SecretAccessKey,
-		s.credentials.SessionToken,
-	)
+	s.recoverInterruptedCalls(context.Background())
 
+	consumer, err := s.newConsumer()
 	if err != nil {
-		return fmt.Errorf("failed to create SQS consumer: %v", err)
+		return err
 	}
 
+	s.consumerMu.Lock()
 	s.consumer = consumer
+	s.consumerMu.Unlock()
+	s.logStartupBanner()
 
 	// Create a new context with cancellation
-	s.ctx, s.cancel = context.WithCancel(context.Background())
-
-	// Start polling for messages and handle potential errors
-	go func() {
-		if err := s.consumer.Start(s.ctx); err != nil {
-			log.Printf("Error starting SQS consumer: %v", err)
-			s.Stop() // Stop the service if there's an error starting the consumer
-		}
-	}()
+	ctx, cancel := context.WithCancel(context.Background())
+	s.lifecycleMu.Lock()
+	s.ctx, s.cancel = ctx, cancel
+	s.lifecycleMu.Unlock()
+
+	// Start polling for messages, supervised by the watchdog
+	s.runConsumer(consumer)
+	if interval := s.watchdogInterval(); interval > 0 {
+		go s.watchdog(ctx, interval)
+	}
+	go s.refreshCredentialsLoop(ctx)
+	go s.reportUtilizationLoop(ctx)
 
-	log.Printf("Service '%s' started and polling for messages", s.Name)
+	s.inferable.logf(LogLevelInfo, "Service '%s' started and polling for messages", s.Name)
+	s.options.Hooks.onStart(s)
 	return nil
 }
 
 // Stop stops the service and cancels the polling
 func (s *Service) Stop() {
-	if s.cancel != nil {
-		s.cancel()
-		log.Printf("Service '%s' stopped", s.Name)
+	s.registered.Store(false)
+	s.stopped.Store(true)
+
+	s.lifecycleMu.Lock()
+	cancel := s.cancel
+	s.lifecycleMu.Unlock()
+
+	if cancel != nil {
+		cancel()
+		s.closeDone(nil)
+		s.inferable.logf(LogLevelInfo, "Service '%s' stopped", s.Name)
+		s.options.Hooks.onStop(s)
 	}
 }
 
-// handleMessage is a dummy message handler that just logs the received message
-func (s *Service) handleMessage(msg *sqs.Message) error {
-	log.Printf("Received message: %s", *msg.Body)
-
+// handleMessage executes the function targeted by the message and persists its result
+func (s *Service) handleMessage(msg *sqs.Message, receivedAt time.Time) error {
 	// Define a struct to unmarshal the outer JSON structure
 	var outerPayload struct {
 		Value struct {
-			ID         string `json:"id"`
-			Service    string `json:"service"`
-			TargetFn   string `json:"targetFn"`
-			TargetArgs string `json:"targetArgs"` // Changed to string
+			ID         string          `json:"id"`
+			Service    string          `json:"service"`
+			TargetFn   string          `json:"targetFn"`
+			TargetArgs json.RawMessage `json:"targetArgs"`
 		} `json:"value"`
 	}
 
+	// Bodies far beyond the input limits are rejected before decoding them allocates memory for their contents.
+	// They would fail the same way every time they are delivered, so the message is deleted once the call
+	// was rejected.
+	if limitErr := s.checkMessageLimits(*msg.Body); limitErr != nil {
+		callID := messageCallID(*msg.Body)
+		if err := s.rejectOversizedMessage(s.baseContext(), callID, limitErr); err != nil {
+			return err
+		}
+		s.inferable.logf(LogLevelError, "Dropped message of service '%s': %v", s.Name, limitErr)
+		s.reportMachineError(MachineErrorMessageRejected, fmt.Errorf("dropped message body: %v", limitErr), callID)
+		return nil
+	}
+
 	// Unmarshal the message body into the outer payload struct
 	if err := json.Unmarshal([]byte(*msg.Body), &outerPayload); err != nil {
 		return fmt.Errorf("failed to unmarshal message body: %v", err)
 	}
 
-	// Call acknowledgeJob
-	if err := s.acknowledgeJob(outerPayload.Value.ID); err != nil {
-		log.Printf("Failed to acknowledge job: %v", err)
-		// Continue processing the job even if acknowledgement fails
+	targetArgs, err := callInput(outerPayload.Value.TargetArgs)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal target arguments: %v", err)
 	}
 
-	// Find the target function
-	fn, ok := s.Functions[outerPayload.Value.TargetFn]
-	if !ok {
-		return fmt.Errorf("function not found: %s", outerPayload.Value.TargetFn)
+	call := CallInfo{
+		ID:       outerPayload.Value.ID,
+		Service:  s.Name,
+		Function: outerPayload.Value.TargetFn,
+		Attempt:  callAttempt(msg),
 	}
 
-	// Unmarshal the target arguments string into a map
-	var argsMap map[string]json.RawMessage
-	if err := json.Unmarshal([]byte(outerPayload.Value.TargetArgs), &argsMap); err != nil {
-		return fmt.Errorf("failed to unmarshal target arguments: %v", err)
+	fn, registered := s.function(call.Function)
+	if fn.Config.Sensitive {
+		size, hash := inputDigest(targetArgs)
+		s.inferable.logf(LogLevelDebug, "Received call '%s' for sensitive function '%s' (input: %d bytes, sha256: %s)", call.ID, call.Function, size, hash)
+	} else if redacted, ok := redactedInput(fn, registered, targetArgs); ok {
+		s.inferable.logf(LogLevelDebug, "Received call '%s' for function '%s': %s", call.ID, call.Function, redacted)
+	} else {
+		s.inferable.logf(LogLevelDebug, "Received message: %s", *msg.Body)
+	}
+	s.logCallReceived(call, fn, registered, targetArgs)
+	defer func() {
+		if r := recover(); r != nil {
+			s.stats.acknowledged(call.ID)
+			s.stats.failed.Add(1)
+			panicErr := fmt.Errorf("call '%s' to '%s' panicked: %v", call.ID, call.Function, r)
+			s.reportError(panicErr, ErrorContext{Kind: ErrorKindPanic, Call: &call, Stack: string(debug.Stack())})
+			s.recordPanic(panicErr, call.ID)
+			panic(callPanic{value: r})
+		}
+	}()
+	ctx := s.baseContext()
+
+	if s.options.StartTrace != nil {
+		var traceID string
+		var end func()
+		ctx, traceID, end = s.options.StartTrace(ctx, call)
+		if end != nil {
+			defer end()
+		}
+		call.TraceID = traceID
 	}
 
-	// Extract the "value" field from the argsMap
-	valueJSON, ok := argsMap["value"]
-	if !ok {
-		return fmt.Errorf("'value' field not found in target arguments")
+	ctx = withCallInfo(ctx, call)
+
+	defer s.recordGauges()
+	s.inFlight.Add(1)
+	defer s.inFlight.Add(-1)
+	s.stats.received(call.ID)
+	defer s.finishJournaledCall(call.ID)
+	s.recordGauges()
+
+	started := time.Now()
+	err = s.handleCall(ctx, call, targetArgs, receivedAt)
+	duration := time.Since(started)
+	s.stats.handled(call.ID, err, duration)
+	s.recordCall(call, err, duration)
+	if err != nil {
+		s.options.Hooks.onError(ctx, call, err)
+		return err
 	}
 
-	// Create a new instance of the function's input type
-	fnType := reflect.TypeOf(fn.Func)
-	argType := fnType.In(0)
-	argPtr := reflect.New(argType)
+	return nil
+}
+
+func (s *Service) handleCall(ctx context.Context, call CallInfo, targetArgs []byte, receivedAt time.Time) error {
+	// Find the target function
+	fn, ok := s.function(call.Function)
 
-	// Unmarshal the value JSON into the function's input type
-	if err := json.Unmarshal(valueJSON, argPtr.Interface()); err != nil {
-		return fmt.Errorf("failed to unmarshal value into function argument: %v", err)
+	// Acknowledge the call before executing the function, unless it is acknowledged explicitly or afterwards
+	acknowledgeAfter := ok && fn.Config.Execution == ExecutionAtLeastOnce
+	if !s.options.DisableAutoAcknowledge && !acknowledgeAfter {
+		s.autoAcknowledge(ctx, call)
 	}
 
-	// Call the function with the unmarshaled argument
-	fnValue := reflect.ValueOf(fn.Func)
-	returnValues := fnValue.Call([]reflect.Value{argPtr.Elem()})
+	if !ok {
+		return fmt.Errorf("function not found: %s", call.Function)
+	}
 
-	log.Printf("Function '%s' called successfully", fn.Name)
+	// Unmarshal the "value" field of the target arguments directly into the function's input type,
+	// unless the input exceeds the limits and the call is rejected
+	compiled := fn.compiled()
+	limitErr := s.checkInputLimits(targetArgs)
+	var argPtr reflect.Value
+	if limitErr == nil {
+		var err error
+		argPtr, err = compiled.decodeArgsWith(s.jsonCodec(), targetArgs)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal target arguments: %v", err)
+		}
+	}
 
+	// time.Now carries a monotonic clock reading, so durations are unaffected by wall clock adjustments
 	start := time.Now()
-	// Prepare the result
-	result, err := s.prepareResult(returnValues)
+	worker := s.workers.acquire()
+	defer s.workers.release(worker)
+	meta := resultMetadata{
+		QueueWaitTime:   start.Sub(receivedAt).Milliseconds(),
+		ContentType:     fn.Config.ResultContentType,
+		TraceID:         call.TraceID,
+		FunctionVersion: fn.Version,
+		MachineID:       s.inferable.machineID,
+		SDKVersion:      Version,
+		Attempt:         call.Attempt,
+		WorkerID:        worker,
+	}
+	if fn.Config.Sensitive {
+		meta.InputSize, meta.InputHash = inputDigest(targetArgs)
+	}
+
+	s.logCallStarted(call, meta)
+	hooks := s.callHooks(fn)
+
+	var result CallResult
+	if s.inferable.isFunctionDisabled(s.Name, fn.Name) {
+		disabled, err := rejectionResult(fmt.Errorf("function '%s' is disabled", fn.Name))
+		if err != nil {
+			return fmt.Errorf("failed to prepare result: %v", err)
+		}
+		result = disabled
+	} else if limitErr != nil {
+		s.inferable.logf(LogLevelError, "Rejected call '%s' to '%s': %v", call.ID, fn.Name, limitErr)
+		rejection, err := rejectionResult(inputLimitRejection(limitErr))
+		if err != nil {
+			return fmt.Errorf("failed to prepare result: %v", err)
+		}
+		result = rejection
+	} else if err := s.validateInput(argPtr.Elem().Interface()); err != nil {
+		rejection, marshalErr := rejectionResult(validationRejection(err))
+		if marshalErr != nil {
+			return fmt.Errorf("failed to prepare result: %v", marshalErr)
+		}
+		result = rejection
+	} else if err := runOnCall(ctx, hooks, call, argPtr.Elem().Interface()); err != nil {
+		// The call was rejected by a hook, so the function is not executed
+		rejection, marshalErr := rejectionResult(err)
+		if marshalErr != nil {
+			return fmt.Errorf("failed to prepare result: %v", marshalErr)
+		}
+		result = rejection
+	} else if cached, ok := s.resultCache.get(fn, targetArgs); ok {
+		s.inferable.logf(LogLevelDebug, "Call '%s' to '%s' answered from the local cache", call.ID, fn.Name)
+		result = cached
+		meta.Cached = true
+	} else {
+		// Call the function with the unmarshaled argument
+		fnCtx, group := withCallGroup(ctx)
+		fnCtx, tags := withResultTags(fnCtx)
+		returnValues := s.callWithLocalRetry(fnCtx, call, fn, compiled, argPtr.Elem())
+		meta.FunctionExecutionTime = time.Since(start).Milliseconds()
+		meta.Tags = tags.get()
+
+		// Transient failures are dispatched again instead of being persisted, until the call runs out of attempts
+		if retryErr := retryableCallError(returnValues); retryErr != nil && call.Attempt < s.maxCallAttempts() {
+			group.finish(callGroupWaitTimeout)
+			s.inferable.logf(LogLevelInfo, "Call '%s' to '%s' failed on attempt %d and will be retried: %v", call.ID, fn.Name, call.Attempt, retryErr)
+			return retryErr
+		}
+
+		// Streamed results are uploaded before waiting for the call group, whose goroutines may be producing them
+		var prepared CallResult
+		var err error
+		stream, streamed := resultStream(returnValues)
+		if streamed {
+			meta.Chunked = true
+			prepared, err = s.uploadResultStream(ctx, call.ID, stream)
+		}
+
+		if !group.finish(callGroupWaitTimeout) {
+			s.inferable.logf(LogLevelError, "Goroutines spawned by call '%s' to '%s' did not exit within %s of the call finishing", call.ID, fn.Name, callGroupWaitTimeout)
+		}
+
+		s.inferable.logf(LogLevelDebug, "Function '%s' called successfully", fn.Name)
+
+		// Prepare the result
+		if !streamed {
+			prepared, err = s.prepareResult(returnValues, resultMarshaler(fn, s.jsonCodec()))
+		}
+		if err != nil {
+			return fmt.Errorf("failed to prepare result: %v", err)
+		}
+		result = prepared
+		if !streamed {
+			s.resultCache.put(fn, targetArgs, result)
+		}
+	}
+
+	// Results too large for the API are uploaded or truncated instead of being rejected
+	guarded, err := s.guardResultSize(ctx, call, result, &meta)
 	if err != nil {
-		return fmt.Errorf("failed to prepare result: %v", err)
+		return fmt.Errorf("failed to persist oversized result: %w", err)
+	}
+	result = guarded
+
+	if !s.options.DisableAutoAcknowledge && acknowledgeAfter {
+		s.autoAcknowledge(ctx, call)
 	}
 
-	// Persist the job result
-	if err := s.persistJobResult(outerPayload.Value.ID, result, time.Since(start)); err != nil {
-		return fmt.Errorf("failed to persist job result: %v", err)
+	runOnResult(ctx, hooks, call, result, time.Since(start))
+	s.logCallFinished(call, fn, result, time.Since(start), meta)
+
+	// Collect the result if calls are being batched
+	if s.addToBatch(ctx, call, result, meta) {
+		return nil
+	}
+
+	// Persist the job result, handing it to the background worker if the API is slow to respond
+	if err := s.persistWithTimeout(ctx, call, result, meta); err != nil {
+		s.reportError(err, ErrorContext{Kind: ErrorKindPersist, Call: &call})
+		return fmt.Errorf("failed to persist job result: %w", err)
 	}
 
 	return nil
 }
 
-func (s *Service) prepareResult(returnValues []reflect.Value) (struct {
+// baseContext returns the context of the running service, which is canceled when the service stops
+func (s *Service) baseContext() context.Context {
+	s.lifecycleMu.Lock()
+	defer s.lifecycleMu.Unlock()
+
+	if s.ctx == nil {
+		return context.Background()
+	}
+
+	return s.ctx
+}
+
+// CallResult is the outcome of a call which is persisted to the cluster
+type CallResult struct {
+	// Value is the JSON encoded result
 	Value string `json:"value"`
-	Type  string `json:"type"`
-}, error) {
-	var result struct {
-		Value string `json:"value"`
-		Type  string `json:"type"`
+	// Type is either "resolution" or "rejection"
+	Type string `json:"type"`
+}
+
+func rejectionResult(err error) (CallResult, error) {
+	var rejection interface{} = err.Error()
+	var toolErr *ToolError
+	if errors.As(err, &toolErr) {
+		rejection = toolErr
+	}
+
+	messageJSON, marshalErr := json.Marshal(rejection)
+	if marshalErr != nil {
+		return CallResult{}, fmt.Errorf("failed to marshal rejection: %v", marshalErr)
 	}
 
+	return CallResult{Value: string(messageJSON), Type: "rejection"}, nil
+}
+
+// prepareResult serializes the result of a call, using marshal if it is set
+func (s *Service) prepareResult(returnValues []reflect.Value, marshal func(interface{}) ([]byte, error)) (CallResult, error) {
+	var result CallResult
+
 	if len(returnValues) > 0 {
-		if errInterface, ok := returnValues[0].Interface().(error); ok {
-			if errInterface != nil {
-				result.Value = errInterface.Error()
-				result.Type = "rejection"
-			}
-		} else {
-			resultJSON, err := json.Marshal(returnValues[0].Interface())
+		// A non-nil error in the last return value (e.g. from a (T, error) function) rejects the call
+		if errInterface, ok := returnValues[len(returnValues)-1].Interface().(error); ok && errInterface != nil {
+			return rejectionResult(errInterface)
+		}
+
+		resultJSON, err := encodeResult(returnValues[0].Interface(), marshal, s.jsonCodec())
+		if err != nil {
+			return result, fmt.Errorf("failed to marshal result: %v", err)
+		}
+
+		if key := s.resultEncryptionKey(); key != nil {
+			resultJSON, err = encryptSensitiveFields(returnValues[0].Interface(), resultJSON, key)
 			if err != nil {
-				return result, fmt.Errorf("failed to marshal result: %v", err)
+				return result, err
 			}
-			result.Value = string(resultJSON)
-			result.Type = "resolution"
 		}
+		result.Value = string(resultJSON)
+		result.Type = "resolution"
 	}
 
 	return result, nil
 }
 
-func (s *Service) persistJobResult(jobID string, result struct {
-	Value string `json:"value"`
-	Type  string `json:"type"`
-}, duration time.Duration) error {
-	payload := struct {
-		Result                string `json:"result"`
-		ResultType            string `json:"resultType"`
-		FunctionExecutionTime int64  `json:"functionExecutionTime,omitempty"`
-	}{
+// returnsError reports whether any of the return values of fnType is an error
+func returnsError(fnType reflect.Type) bool {
+	for idx := 0; idx < fnType.NumOut(); idx++ {
+		if fnType.Out(idx).Implements(errorType) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// persistedResult is the payload submitted for the result of a call
+type persistedResult struct {
+	JobID                 string         `json:"jobId,omitempty"`
+	Result                string         `json:"result"`
+	ResultType            string         `json:"resultType"`
+	FunctionExecutionTime int64          `json:"functionExecutionTime,omitempty"`
+	Meta                  resultMetadata `json:"meta"`
+}
+
+func newPersistedResult(result CallResult, meta resultMetadata) persistedResult {
+	return persistedResult{
 		Result:                fmt.Sprintf("{\"value\": %s }", result.Value),
 		ResultType:            result.Type,
-		FunctionExecutionTime: duration.Milliseconds(),
+		FunctionExecutionTime: meta.FunctionExecutionTime,
+		Meta:                  meta,
 	}
+}
+
+func (s *Service) persistJobResult(ctx context.Context, jobID string, result CallResult, meta resultMetadata) error {
+	payload := newPersistedResult(result, meta)
 
 	payloadJSON, err := json.Marshal(payload)
 	if err != nil {
 		return fmt.Errorf("failed to marshal payload for persistJobResult: %v", err)
 	}
 
-	headers := map[string]string{
-		"Authorization":          "Bearer " + s.inferable.apiSecret,
-		"X-Machine-ID":           s.inferable.machineID,
-		"X-Machine-SDK-Version":  Version,
-		"X-Machine-SDK-Language": "go",
-	}
+	headers := s.machineHeaders()
 
 	options := FetchDataOptions{
 		Path:    fmt.Sprintf("/jobs/%s/result", jobID),
 		Method:  "POST",
 		Headers: headers,
 		Body:    string(payloadJSON),
+		Context: ctx,
 	}
 
 	_, err = s.inferable.FetchData(options)
 	if err != nil {
-		return fmt.Errorf("failed to persist job result: %v", err)
+		return fmt.Errorf("failed to persist job result: %w", err)
 	}
 
 	return nil
 }
 
-// Add the new acknowledgeJob function
-func (s *Service) acknowledgeJob(jobID string) error {
-	// Prepare headers
+// machineHeaders identify the machine and SDK to the API
+func (s *Service) machineHeaders() map[string]string {
 	headers := map[string]string{
-		"Authorization":          "Bearer " + s.inferable.apiSecret,
 		"X-Machine-ID":           s.inferable.machineID,
 		"X-Machine-SDK-Version":  Version,
 		"X-Machine-SDK-Language": "go",
 	}
+	if s.inferable.application != "" {
+		headers["X-Machine-SDK-Application"] = s.inferable.application
+	}
+
+	return headers
+}
+
+// Ack acknowledges a call explicitly. This is only required when the service is registered with
+// ServiceOptions.DisableAutoAcknowledge, for example to acknowledge a call only after it has been
+// durably enqueued by the function. The call ID is available via CallInfoFromContext.
+func (s *Service) Ack(callID string) error {
+	if callID == "" {
+		return fmt.Errorf("call ID is required")
+	}
+
+	return s.acknowledgeJob(callID)
+}
+
+// autoAcknowledge acknowledges a call on behalf of the function. Failures are reported, and do not
+// stop the call from being handled.
+func (s *Service) autoAcknowledge(ctx context.Context, call CallInfo) {
+	if err := s.acknowledgeJob(call.ID); err != nil {
+		s.inferable.logf(LogLevelError, "Failed to acknowledge job: %v", err)
+		s.options.Hooks.onError(ctx, call, err)
+	}
+}
+
+// Add the new acknowledgeJob function
+func (s *Service) acknowledgeJob(jobID string) error {
+	// Prepare headers
+	headers := s.machineHeaders()
 
 	// Call the acknowledgeJob endpoint
 	options := FetchDataOptions{
@@ -386,8 +1328,10 @@ func (s *Service) acknowledgeJob(jobID string) error {
 
 	_, err := s.inferable.FetchData(options)
 	if err != nil {
-		return fmt.Errorf("failed to acknowledge job: %v", err)
+		return fmt.Errorf("failed to acknowledge job: %w", err)
 	}
+	s.stats.acknowledged(jobID)
+	s.journalCall(jobID)
 
 	return nil
 }
@@ -407,6 +1351,9 @@ type Config struct {
 
 // GetConfig returns the current configuration with obfuscated sensitive details
 func (s *Service) GetConfig() Config {
+	s.credentialsMu.RLock()
+	defer s.credentialsMu.RUnlock()
+
 	config := Config{
 		QueueURL:   s.queueURL,
 		Region:     s.region,
@@ -418,13 +1365,14 @@ func (s *Service) GetConfig() Config {
 }
 
 func (s *Service) GetSchema() (map[string]interface{}, error) {
-	if len(s.Functions) == 0 {
+	functions := s.functions()
+	if len(functions) == 0 {
 		return nil, fmt.Errorf("no functions registered for service '%s'", s.Name)
 	}
 
 	schema := make(map[string]interface{})
 
-	for _, fn := range s.Functions {
+	for _, fn := range functions {
 		schema[fn.Name] = map[string]interface{}{
 			"input": fn.schema,
 			"name":  fn.Name,
@@ -1,15 +1,24 @@
 package inferable
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"net/http/httptest"
 	"os"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"bytes"
 	"net/http"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
 	"github.com/joho/godotenv"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -51,6 +60,663 @@ func TestRegisterFunc(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestExplicitAcknowledge(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/ping" {
+			requests = append(requests, r.Method+" "+r.URL.Path)
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	i, err := New(InferableOptions{
+		APIEndpoint: server.URL,
+		APISecret:   "test-secret",
+	})
+	require.NoError(t, err)
+
+	service, err := i.RegisterServiceWithOptions("TestService", ServiceOptions{
+		DisableAutoAcknowledge: true,
+	})
+	require.NoError(t, err)
+
+	type TestInput struct {
+		Message string `json:"message"`
+	}
+
+	var received CallInfo
+	err = service.RegisterFunc(Function{
+		Name: "TestFunc",
+		Func: func(ctx context.Context, input TestInput) string {
+			received, _ = CallInfoFromContext(ctx)
+			return input.Message
+		},
+	})
+	require.NoError(t, err)
+
+	body := `{"value": {"id": "call-1", "service": "TestService", "targetFn": "TestFunc", "targetArgs": "{\"value\": {\"message\": \"hello\"}}"}}`
+	err = service.handleMessage(&sqs.Message{Body: aws.String(body)}, time.Now())
+	require.NoError(t, err)
+
+	assert.Equal(t, CallInfo{ID: "call-1", Service: "TestService", Function: "TestFunc", Attempt: 1}, received)
+	assert.Equal(t, []string{"POST /jobs/call-1/result"}, requests)
+
+	require.NoError(t, service.Ack(received.ID))
+	assert.Equal(t, []string{"POST /jobs/call-1/result", "PUT /jobs/call-1"}, requests)
+}
+
+func TestResultMetadata(t *testing.T) {
+	var persisted struct {
+		FunctionExecutionTime int64          `json:"functionExecutionTime"`
+		Meta                  resultMetadata `json:"meta"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/jobs/call-1/result" {
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&persisted))
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	i, err := New(InferableOptions{
+		APIEndpoint: server.URL,
+		APISecret:   "test-secret",
+	})
+	require.NoError(t, err)
+
+	type TestInput struct{}
+
+	err = i.Default.RegisterFunc(Function{
+		Name: "SlowFunc",
+		Func: func(input TestInput) string {
+			time.Sleep(50 * time.Millisecond)
+			return "done"
+		},
+	})
+	require.NoError(t, err)
+
+	body := `{"value": {"id": "call-1", "service": "default", "targetFn": "SlowFunc", "targetArgs": "{\"value\": {}}"}}`
+	err = i.Default.handleMessage(&sqs.Message{Body: aws.String(body)}, time.Now().Add(-100*time.Millisecond))
+	require.NoError(t, err)
+
+	assert.GreaterOrEqual(t, persisted.Meta.FunctionExecutionTime, int64(50))
+	assert.GreaterOrEqual(t, persisted.Meta.QueueWaitTime, int64(100))
+	assert.Equal(t, persisted.Meta.FunctionExecutionTime, persisted.FunctionExecutionTime)
+}
+
+func TestResultTraceID(t *testing.T) {
+	var persisted struct {
+		Meta resultMetadata `json:"meta"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/jobs/call-1/result" {
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&persisted))
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	i, err := New(InferableOptions{
+		APIEndpoint: server.URL,
+		APISecret:   "test-secret",
+	})
+	require.NoError(t, err)
+
+	ended := false
+	var resultTraceID string
+	service, err := i.RegisterServiceWithOptions("traced", ServiceOptions{
+		StartTrace: func(ctx context.Context, call CallInfo) (context.Context, string, func()) {
+			return ctx, "trace-" + call.ID, func() { ended = true }
+		},
+		Hooks: Hooks{
+			OnResult: func(ctx context.Context, call CallInfo, result CallResult, duration time.Duration) {
+				resultTraceID = call.TraceID
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	type TestInput struct{}
+
+	var fnTraceID string
+	err = service.RegisterFunc(Function{
+		Name: "Traced",
+		Func: func(ctx context.Context, input TestInput) string {
+			call, _ := CallInfoFromContext(ctx)
+			fnTraceID = call.TraceID
+			return "done"
+		},
+	})
+	require.NoError(t, err)
+
+	body := `{"value": {"id": "call-1", "service": "traced", "targetFn": "Traced", "targetArgs": "{\"value\": {}}"}}`
+	err = service.handleMessage(&sqs.Message{Body: aws.String(body)}, time.Now())
+	require.NoError(t, err)
+
+	assert.Equal(t, "trace-call-1", persisted.Meta.TraceID)
+	assert.Equal(t, "trace-call-1", fnTraceID)
+	assert.Equal(t, "trace-call-1", resultTraceID)
+	assert.True(t, ended)
+}
+
+func TestSensitiveInput(t *testing.T) {
+	var persisted struct {
+		Meta resultMetadata `json:"meta"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/jobs/call-1/result" {
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&persisted))
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	i, err := New(InferableOptions{
+		APIEndpoint: server.URL,
+		APISecret:   "test-secret",
+		LogLevel:    LogLevelDebug,
+	})
+	require.NoError(t, err)
+
+	type TestInput struct {
+		SSN string `json:"ssn"`
+	}
+
+	err = i.Default.RegisterFunc(Function{
+		Name:   "Lookup",
+		Func:   func(input TestInput) string { return "found" },
+		Config: FunctionConfig{Sensitive: true},
+	})
+	require.NoError(t, err)
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	targetArgs := `{"value": {"ssn": "123-45-6789"}}`
+	body, err := json.Marshal(map[string]interface{}{
+		"value": map[string]string{"id": "call-1", "service": "default", "targetFn": "Lookup", "targetArgs": targetArgs},
+	})
+	require.NoError(t, err)
+
+	err = i.Default.handleMessage(&sqs.Message{Body: aws.String(string(body))}, time.Now())
+	require.NoError(t, err)
+
+	size, hash := inputDigest([]byte(targetArgs))
+	assert.NotContains(t, logs.String(), "123-45-6789")
+	assert.Contains(t, logs.String(), hash)
+	assert.Equal(t, size, persisted.Meta.InputSize)
+	assert.Equal(t, hash, persisted.Meta.InputHash)
+}
+
+func TestResultContentType(t *testing.T) {
+	var persisted struct {
+		Meta resultMetadata `json:"meta"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/jobs/call-1/result" {
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&persisted))
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	i, err := New(InferableOptions{
+		APIEndpoint: server.URL,
+		APISecret:   "test-secret",
+	})
+	require.NoError(t, err)
+
+	type TestInput struct{}
+
+	err = i.Default.RegisterFunc(Function{
+		Name:   "Report",
+		Func:   func(input TestInput) string { return "# Report" },
+		Config: FunctionConfig{ResultContentType: ContentTypeMarkdown},
+	})
+	require.NoError(t, err)
+
+	err = i.Default.RegisterFunc(Function{
+		Name:   "Count",
+		Func:   func(input TestInput) int { return 1 },
+		Config: FunctionConfig{ResultContentType: ContentTypeCSV},
+	})
+	assert.Error(t, err)
+
+	err = i.Default.RegisterFunc(Function{
+		Name:   "Unknown",
+		Func:   func(input TestInput) string { return "" },
+		Config: FunctionConfig{ResultContentType: "xml"},
+	})
+	assert.Error(t, err)
+
+	body := `{"value": {"id": "call-1", "service": "default", "targetFn": "Report", "targetArgs": "{\"value\": {}}"}}`
+	require.NoError(t, i.Default.handleMessage(&sqs.Message{Body: aws.String(body)}, time.Now()))
+	assert.Equal(t, ContentTypeMarkdown, persisted.Meta.ContentType)
+}
+
+func TestResultMarshaler(t *testing.T) {
+	type Account struct {
+		Owner   string
+		Balance float64
+	}
+
+	marshal := func(value interface{}) ([]byte, error) {
+		account := value.(Account)
+		return []byte(fmt.Sprintf(`{"owner": "redacted", "balance": "%.2f"}`, account.Balance)), nil
+	}
+
+	s := &Service{}
+	result, err := s.prepareResult([]reflect.Value{reflect.ValueOf(Account{Owner: "Ada", Balance: 10})}, marshal)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"owner": "redacted", "balance": "10.00"}`, result.Value)
+
+	invalid := func(value interface{}) ([]byte, error) { return []byte("10.00 EUR"), nil }
+	_, err = s.prepareResult([]reflect.Value{reflect.ValueOf(Account{})}, invalid)
+	assert.ErrorContains(t, err, "invalid JSON")
+}
+
+func TestRegistrationRetry(t *testing.T) {
+	attempts := 0
+	status := http.StatusServiceUnavailable
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/machines" {
+			attempts++
+			if attempts < 3 {
+				w.WriteHeader(status)
+				return
+			}
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	i, err := New(InferableOptions{
+		APIEndpoint: server.URL,
+		APISecret:   "test-secret",
+	})
+	require.NoError(t, err)
+
+	var retries []int
+	service, err := i.RegisterServiceWithOptions("TestService", ServiceOptions{
+		Hooks: Hooks{
+			OnRegisterRetry: func(service *Service, attempt int, err error, delay time.Duration) {
+				retries = append(retries, attempt)
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	type TestInput struct{}
+	require.NoError(t, service.RegisterFunc(Function{Name: "TestFunc", Func: func(input TestInput) int { return 1 }}))
+
+	require.NoError(t, service.registerMachineWithRetry())
+	assert.Equal(t, 3, attempts)
+	assert.Equal(t, []int{1, 2}, retries)
+
+	// Non-retryable errors fail immediately
+	attempts = 0
+	retries = nil
+	status = http.StatusBadRequest
+	assert.Error(t, service.registerMachineWithRetry())
+	assert.Equal(t, 1, attempts)
+	assert.Empty(t, retries)
+}
+
+func TestUnauthorized(t *testing.T) {
+	var mu sync.Mutex
+	requests := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.Path+" "+r.Header.Get("Authorization")]++
+		mu.Unlock()
+		if r.Header.Get("Authorization") != "Bearer valid" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	i, err := New(InferableOptions{
+		APIEndpoint: server.URL,
+		APISecret:   "invalid",
+	})
+	require.NoError(t, err)
+
+	type TestInput struct{}
+	require.NoError(t, i.Default.RegisterFunc(Function{Name: "TestFunc", Func: func(input TestInput) int { return 1 }}))
+
+	err = i.Default.Start()
+	assert.True(t, errors.Is(err, ErrUnauthorized))
+	assert.False(t, errors.Is(err, ErrAuthExpired))
+	assert.Contains(t, err.Error(), "machine secret")
+
+	// Pings pause once the secret was rejected, and resume once it is updated
+	i.pingCluster()
+	i.pingCluster()
+	require.NoError(t, i.UpdateSecret("valid"))
+	i.pingCluster()
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 1, requests["/machines Bearer invalid"])
+	// The initial background ping may race with the first explicit ping
+	assert.LessOrEqual(t, requests["/v2/ping Bearer invalid"], 2)
+	assert.Equal(t, 1, requests["/v2/ping Bearer valid"])
+}
+
+func TestRegistrationState(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/machines" {
+			w.Write([]byte(`{"clusterId": "cluster-1"}`))
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	i, err := New(InferableOptions{
+		APIEndpoint: server.URL,
+		APISecret:   "test-secret",
+		MachineID:   "machine-1",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "machine-1", i.MachineID())
+	assert.Empty(t, i.ClusterID())
+
+	type TestInput struct{}
+	require.NoError(t, i.Default.RegisterFunc(Function{Name: "TestFunc", Func: func(input TestInput) int { return 1 }}))
+	assert.False(t, i.Default.IsRegistered())
+
+	require.NoError(t, i.Default.registerMachine())
+	assert.True(t, i.Default.IsRegistered())
+	assert.Equal(t, "cluster-1", i.Default.ClusterID())
+	assert.Equal(t, "cluster-1", i.ClusterID())
+
+	i.Default.Stop()
+	assert.False(t, i.Default.IsRegistered())
+}
+
+func TestFunctionVersion(t *testing.T) {
+	var registration struct {
+		Functions []functionRegistration `json:"functions"`
+	}
+	var persisted persistedResult
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/machines":
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&registration))
+		case "/jobs/call-1/result":
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&persisted))
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	i, err := New(InferableOptions{
+		APIEndpoint: server.URL,
+		APISecret:   "test-secret",
+	})
+	require.NoError(t, err)
+
+	type TestInput struct{}
+	require.NoError(t, i.Default.RegisterFunc(Function{
+		Name:    "TestFunc",
+		Version: "1.4.2",
+		Func:    func(input TestInput) int { return 1 },
+	}))
+
+	require.NoError(t, i.Default.registerMachine())
+	require.Len(t, registration.Functions, 1)
+	assert.Equal(t, "1.4.2", registration.Functions[0].Version)
+
+	body := `{"value": {"id": "call-1", "service": "default", "targetFn": "TestFunc", "targetArgs": "{\"value\": {}}"}}`
+	require.NoError(t, i.Default.handleMessage(&sqs.Message{Body: aws.String(body)}, time.Now()))
+	assert.Equal(t, "1.4.2", persisted.Meta.FunctionVersion)
+}
+
+func TestExecutionMetadata(t *testing.T) {
+	var persisted persistedResult
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/jobs/call-1/result" {
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&persisted))
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	i, err := New(InferableOptions{
+		APIEndpoint: server.URL,
+		APISecret:   "test-secret",
+	})
+	require.NoError(t, err)
+
+	type TestInput struct{}
+	require.NoError(t, i.Default.RegisterFunc(Function{
+		Name: "TestFunc",
+		Func: func(ctx context.Context, input TestInput) int {
+			SetResultMeta(ctx, "tenant", "acme")
+			SetResultMeta(ctx, "region", "eu")
+			return 1
+		},
+	}))
+
+	// Setting result metadata outside of a call has no effect
+	SetResultMeta(context.Background(), "tenant", "ignored")
+
+	body := `{"value": {"id": "call-1", "service": "default", "targetFn": "TestFunc", "targetArgs": "{\"value\": {}}"}}`
+	require.NoError(t, i.Default.handleMessage(&sqs.Message{Body: aws.String(body)}, time.Now()))
+
+	assert.Equal(t, i.machineID, persisted.Meta.MachineID)
+	assert.Equal(t, Version, persisted.Meta.SDKVersion)
+	assert.Equal(t, 1, persisted.Meta.Attempt)
+	assert.Equal(t, 1, persisted.Meta.WorkerID)
+	assert.Equal(t, map[string]string{"tenant": "acme", "region": "eu"}, persisted.Meta.Tags)
+}
+
+func TestExecutionPolicy(t *testing.T) {
+	var mu sync.Mutex
+	events := []string{}
+	record := func(event string) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, event)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/jobs/"):
+			record("ack " + strings.TrimPrefix(r.URL.Path, "/jobs/"))
+		case strings.HasSuffix(r.URL.Path, "/result"):
+			record("persist " + strings.Split(r.URL.Path, "/")[2])
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	i, err := New(InferableOptions{
+		APIEndpoint: server.URL,
+		APISecret:   "test-secret",
+	})
+	require.NoError(t, err)
+
+	type TestInput struct{}
+	require.NoError(t, i.Default.RegisterFunc(Function{
+		Name: "AtMostOnce",
+		Func: func(input TestInput) int { record("execute call-1"); return 1 },
+	}))
+	require.NoError(t, i.Default.RegisterFunc(Function{
+		Name:   "AtLeastOnce",
+		Func:   func(input TestInput) int { record("execute call-2"); return 1 },
+		Config: FunctionConfig{Execution: ExecutionAtLeastOnce},
+	}))
+	assert.Error(t, i.Default.RegisterFunc(Function{
+		Name:   "Unknown",
+		Func:   func(input TestInput) int { return 1 },
+		Config: FunctionConfig{Execution: "exactly-once"},
+	}))
+
+	for id, fn := range map[string]string{"call-1": "AtMostOnce", "call-2": "AtLeastOnce"} {
+		body := `{"value": {"id": "` + id + `", "service": "default", "targetFn": "` + fn + `", "targetArgs": "{\"value\": {}}"}}`
+		require.NoError(t, i.Default.handleMessage(&sqs.Message{Body: aws.String(body)}, time.Now()))
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	calls := map[string][]string{}
+	for _, event := range events {
+		id := event[strings.Index(event, " ")+1:]
+		calls[id] = append(calls[id], event)
+	}
+	assert.Equal(t, []string{"ack call-1", "execute call-1", "persist call-1"}, calls["call-1"])
+	assert.Equal(t, []string{"execute call-2", "ack call-2", "persist call-2"}, calls["call-2"])
+}
+
+func TestPrivateFunction(t *testing.T) {
+	var registration struct {
+		Functions []json.RawMessage `json:"functions"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/machines" {
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&registration))
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	i, err := New(InferableOptions{
+		APIEndpoint: server.URL,
+		APISecret:   "test-secret",
+	})
+	require.NoError(t, err)
+
+	type TestInput struct{}
+	require.NoError(t, i.Default.RegisterFunc(Function{
+		Name:   "Internal",
+		Func:   func(input TestInput) int { return 1 },
+		Config: FunctionConfig{Private: true},
+	}))
+	require.NoError(t, i.Default.RegisterFunc(Function{
+		Name: "Public",
+		Func: func(input TestInput) int { return 1 },
+	}))
+	require.NoError(t, i.Default.RegisterFunc(Function{
+		Name:   "Slow",
+		Func:   func(input TestInput) int { return 1 },
+		Config: FunctionConfig{TimeoutSeconds: 120, RetryCountOnStall: 2},
+	}))
+	assert.Error(t, i.Default.RegisterFunc(Function{
+		Name:   "Invalid",
+		Func:   func(input TestInput) int { return 1 },
+		Config: FunctionConfig{TimeoutSeconds: -1},
+	}))
+
+	require.NoError(t, i.Default.registerMachine())
+	require.Len(t, registration.Functions, 3)
+	assert.Contains(t, string(registration.Functions[0]), `"config":{"private":true}`)
+	assert.NotContains(t, string(registration.Functions[1]), `"config"`)
+	assert.Contains(t, string(registration.Functions[2]), `"config":{"retryCountOnStall":2,"timeoutSeconds":120}`)
+}
+
+func TestRegistrationLabels(t *testing.T) {
+	var registration struct {
+		Labels    []string               `json:"labels"`
+		Functions []functionRegistration `json:"functions"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/machines" {
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&registration))
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	i, err := New(InferableOptions{
+		APIEndpoint:   server.URL,
+		APISecret:     "test-secret",
+		MachineLabels: []string{"gpu", "region:eu"},
+	})
+	require.NoError(t, err)
+
+	type TestInput struct {
+		A int `json:"a"`
+	}
+
+	err = i.Default.RegisterFunc(Function{
+		Name:   "TestFunc",
+		Func:   func(input TestInput) int { return input.A },
+		Config: FunctionConfig{RequiredLabels: []string{"gpu"}},
+	})
+	require.NoError(t, err)
+
+	err = i.Default.RegisterFunc(Function{
+		Name:   "InvalidLabels",
+		Func:   func(input TestInput) int { return input.A },
+		Config: FunctionConfig{RequiredLabels: []string{"has space"}},
+	})
+	assert.Error(t, err)
+
+	require.NoError(t, i.Default.registerMachine())
+	assert.Equal(t, []string{"gpu", "region:eu"}, registration.Labels)
+	require.Len(t, registration.Functions, 1)
+	assert.Equal(t, []string{"gpu"}, registration.Functions[0].RequiredLabels)
+}
+
+func TestErrorContract(t *testing.T) {
+	var registration struct {
+		Functions []functionRegistration `json:"functions"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/machines" {
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&registration))
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	i, err := New(InferableOptions{
+		APIEndpoint: server.URL,
+		APISecret:   "test-secret",
+	})
+	require.NoError(t, err)
+
+	type TestInput struct {
+		ID string `json:"id"`
+	}
+
+	getUser := func(input TestInput) (string, error) {
+		return "", fmt.Errorf("user '%s' not found", input.ID)
+	}
+
+	err = i.Default.RegisterFunc(Function{
+		Name: "GetUser",
+		Func: getUser,
+		Config: FunctionConfig{
+			ErrorCodes: []ErrorCode{{Code: "NOT_FOUND", Description: "The user does not exist"}},
+		},
+	})
+	require.NoError(t, err)
+
+	err = i.Default.RegisterFunc(Function{
+		Name:   "NoError",
+		Func:   func(input TestInput) string { return input.ID },
+		Config: FunctionConfig{ErrorCodes: []ErrorCode{{Code: "NOT_FOUND"}}},
+	})
+	assert.Error(t, err)
+
+	require.NoError(t, i.Default.registerMachine())
+	require.Len(t, registration.Functions, 1)
+	assert.True(t, registration.Functions[0].CanReturnError)
+	assert.Equal(t, []ErrorCode{{Code: "NOT_FOUND", Description: "The user does not exist"}}, registration.Functions[0].ErrorCodes)
+
+	result, err := i.Default.prepareResult(reflect.ValueOf(getUser).Call([]reflect.Value{reflect.ValueOf(TestInput{ID: "1"})}), nil)
+	require.NoError(t, err)
+	assert.Equal(t, "rejection", result.Type)
+	assert.Equal(t, `"user '1' not found"`, result.Value)
+}
+
 func TestRegistrationAndConfig(t *testing.T) {
 	// Load environment variables
 	if os.Getenv("INFERABLE_API_SECRET") == "" {
@@ -2,10 +2,17 @@ package inferable
 
 import (
 	"context"
-	"log"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/sqs"
@@ -14,27 +21,115 @@ import (
 // MessageHandler is a function type that processes SQS messages
 type MessageHandler func(msg *sqs.Message) error
 
+// timedMessageHandler processes SQS messages like MessageHandler. receivedAt is the time the message
+// was received by the consumer, including a monotonic clock reading.
+type timedMessageHandler func(msg *sqs.Message, receivedAt time.Time) error
+
+const (
+	// DefaultPollInterval is the delay between polls of the queue
+	DefaultPollInterval = 20 * time.Second
+	// DefaultMaxMessages is the maximum number of messages received in one batch
+	DefaultMaxMessages = 10
+	// DefaultVisibilityTimeout is how long received messages are hidden from other consumers, in seconds
+	DefaultVisibilityTimeout = 30
+	// DefaultPollWaitTime is how long a poll waits for messages to arrive (long polling)
+	DefaultPollWaitTime = 20 * time.Second
+
+	// pausedCheckInterval is how often a paused consumer checks whether it was resumed
+	pausedCheckInterval = time.Second
+
+	// minRateLimitBackoff and maxRateLimitBackoff bound how long polls are held back after a call was
+	// rate limited, see rateLimited
+	minRateLimitBackoff = time.Second
+	maxRateLimitBackoff = 5 * time.Minute
+
+	// pollTimeoutMargin is how much longer than the wait time a poll may take by default, see SetPollTimeout
+	pollTimeoutMargin = 10 * time.Second
+
+	// maxPollLimit and maxPollWaitTime are the limits imposed by SQS
+	maxPollLimit    = 10
+	maxPollWaitTime = 20 * time.Second
+)
+
 // SQSConsumer represents an SQS consumer
 type SQSConsumer struct {
 	svc            *sqs.SQS
 	queueURL       string
-	handler        MessageHandler
+	handler        timedMessageHandler
 	pollInterval   time.Duration
 	maxMessages    int64
+	waitTime       time.Duration
 	visibleTimeout int64
+	// heartbeatInterval is how often the visibility of a message is extended while it is handled
+	heartbeatInterval time.Duration
+	// maxPollInterval caps the poll interval as it is lengthened while the queue is idle.
+	// Adaptive polling is disabled if it is not greater than pollInterval.
+	maxPollInterval time.Duration
+	// currentInterval is the adapted poll interval (in nanoseconds), see adaptPollInterval
+	currentInterval atomic.Int64
+	// beginBatch and flushBatch collect the results of the calls received in one poll,
+	// so that they can be submitted together. See SetResultBatching.
+	beginBatch func()
+	flushBatch func(ctx context.Context) error
+	// lastActivity is the time (in unix nanoseconds) the poll loop last made progress, see touch
+	lastActivity atomic.Int64
+	// backoffUntil holds back polls until then (in unix nanoseconds) when the API asked us to slow down
+	backoffUntil atomic.Int64
+	// rateLimitBackoff is the backoff (in nanoseconds) after the last of consecutive rate limited calls,
+	// see rateLimited
+	rateLimitBackoff atomic.Int64
+	// retryDelay is how long a message whose handler failed with a RetryableError stays hidden
+	// before it is received again. The visibility timeout applies if it is not set.
+	retryDelay time.Duration
+	// pollTimeout is how long a poll may take before it is abandoned, see effectivePollTimeout
+	pollTimeout time.Duration
+	// observePoll is called with the outcome of every poll, see SetPollObserver
+	observePoll func(err error)
+	// isPaused stops the consumer from polling while it returns true, see SetPauseFunc
+	isPaused func() bool
+	// credentials are refreshed before they expire, see SetCredentialsRefresher
+	credentials *refreshingCredentials
+	// waitRateLimit is called before each poll, see SetRateLimiter
+	waitRateLimit func(ctx context.Context) error
+	// onPanic is called with the panics recovered from workers, see SetPanicHandler
+	onPanic func(r interface{})
+	// logger receives the logs of the consumer, see SetLogger
+	logger Logger
+	// slots limits how many messages are handled at once, see SetConcurrency
+	slots workerSlots
+	// stop is closed once polling was stopped, see StopPolling
+	stop     chan struct{}
+	stopMu   sync.Mutex
+	stopOnce sync.Once
+	// workers tracks the workers handling messages, so that Start returns only once they have finished
+	workers sync.WaitGroup
 }
 
 // NewSQSConsumer creates a new SQS consumer
 func NewSQSConsumer(region, queueURL string, handler MessageHandler, accessKeyID, secretAccessKey, sessionToken string) (*SQSConsumer, error) {
-	// Create a new AWS session with the provided credentials
-	sess, err := session.NewSession(&aws.Config{
+	timed := func(msg *sqs.Message, receivedAt time.Time) error {
+		return handler(msg)
+	}
+
+	return newSQSConsumer("", region, queueURL, timed, accessKeyID, secretAccessKey, sessionToken)
+}
+
+// newSQSConsumer creates a new SQS consumer, optionally overriding the SQS endpoint
+func newSQSConsumer(endpoint, region, queueURL string, handler timedMessageHandler, accessKeyID, secretAccessKey, sessionToken string) (*SQSConsumer, error) {
+	config := &aws.Config{
 		Region: aws.String(region),
 		Credentials: credentials.NewStaticCredentials(
 			accessKeyID,
 			secretAccessKey,
 			sessionToken,
 		),
-	})
+	}
+	if endpoint != "" {
+		config.Endpoint = aws.String(endpoint)
+	}
+
+	// Create a new AWS session with the provided credentials
+	sess, err := session.NewSession(config)
 	if err != nil {
 		return nil, err
 	}
@@ -46,61 +141,519 @@ func NewSQSConsumer(region, queueURL string, handler MessageHandler, accessKeyID
 		svc:            sqsClient,
 		queueURL:       queueURL,
 		handler:        handler,
-		pollInterval:   20 * time.Second, // Default to long polling
-		maxMessages:    10,               // Default to 10 messages per batch
-		visibleTimeout: 30,               // Default visibility timeout of 30 seconds
+		pollInterval:   DefaultPollInterval,
+		maxMessages:    DefaultMaxMessages,
+		waitTime:       DefaultPollWaitTime,
+		visibleTimeout: DefaultVisibilityTimeout,
 	}, nil
 }
 
-// Start begins polling for messages
+// Start begins polling for messages. It returns once ctx is done, polling was stopped with StopPolling
+// or failed, and the messages being handled by workers have been handled.
 func (c *SQSConsumer) Start(ctx context.Context) error {
+	defer c.workers.Wait()
+
 	for {
 		select {
 		case <-ctx.Done():
 			return nil
+		case <-c.stopped():
+			return nil
 		default:
+			c.touch()
+			if c.isPaused != nil && c.isPaused() {
+				c.sleep(ctx, pausedCheckInterval)
+				continue
+			}
+			// While rate limited, calls are left in the queue until the backoff has passed
+			if backoff := c.remainingBackoff(); backoff > 0 {
+				c.sleep(ctx, min(backoff, pausedCheckInterval))
+				continue
+			}
+			// While all workers are busy, calls are left in the queue for other machines
+			if c.freeSlots() == 0 {
+				c.sleep(ctx, pausedCheckInterval)
+				continue
+			}
+
 			err := c.poll(ctx)
 			if err != nil {
 				return err
 			}
 		}
 
-		time.Sleep(c.pollInterval)
+		c.sleep(ctx, c.nextPollDelay())
+	}
+}
+
+// StopPolling stops the consumer from receiving new messages, aborting a poll waiting for messages to
+// arrive. Unlike canceling the context passed to Start, messages already received are still handled,
+// their visibility extended and their batched results submitted, before Start returns.
+func (c *SQSConsumer) StopPolling() {
+	c.stopOnce.Do(func() {
+		close(c.stopped())
+	})
+}
+
+// stopped returns a channel which is closed once StopPolling was called
+func (c *SQSConsumer) stopped() chan struct{} {
+	c.stopMu.Lock()
+	defer c.stopMu.Unlock()
+
+	if c.stop == nil {
+		c.stop = make(chan struct{})
+	}
+
+	return c.stop
+}
+
+// sleep waits for d, or until ctx is done or polling was stopped
+func (c *SQSConsumer) sleep(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+	case <-c.stopped():
+	case <-timer.C:
 	}
 }
 
+// nextPollDelay returns the poll interval, extended by any rate limit backoff requested by the API
+func (c *SQSConsumer) nextPollDelay() time.Duration {
+	return max(c.currentPollInterval(), c.remainingBackoff())
+}
+
+// remainingBackoff returns how long polls are still held back after a call was rate limited
+func (c *SQSConsumer) remainingBackoff() time.Duration {
+	return time.Until(time.Unix(0, c.backoffUntil.Load()))
+}
+
+// rateLimited holds back polls after a call was rate limited, for at least retryAfter. The backoff doubles
+// with every consecutive rate limited call, from minRateLimitBackoff up to maxRateLimitBackoff.
+func (c *SQSConsumer) rateLimited(retryAfter time.Duration) time.Duration {
+	backoff := min(max(2*time.Duration(c.rateLimitBackoff.Load()), minRateLimitBackoff, retryAfter), maxRateLimitBackoff)
+	c.rateLimitBackoff.Store(int64(backoff))
+	c.backoffUntil.Store(time.Now().Add(backoff).UnixNano())
+	return backoff
+}
+
 func (c *SQSConsumer) poll(ctx context.Context) error {
-	output, err := c.svc.ReceiveMessageWithContext(ctx, &sqs.ReceiveMessageInput{
+	if c.waitRateLimit != nil {
+		if err := c.waitRateLimit(ctx); err != nil {
+			return nil
+		}
+	}
+
+	receiveCtx, cancel := context.WithTimeout(ctx, c.effectivePollTimeout())
+	defer cancel()
+	// Stopping the consumer abandons the poll, while ctx stays alive for the messages already received
+	go func() {
+		select {
+		case <-c.stopped():
+			cancel()
+		case <-receiveCtx.Done():
+		}
+	}()
+
+	output, err := c.svc.ReceiveMessageWithContext(receiveCtx, &sqs.ReceiveMessageInput{
 		QueueUrl:            aws.String(c.queueURL),
-		MaxNumberOfMessages: aws.Int64(c.maxMessages),
+		MaxNumberOfMessages: aws.Int64(c.receiveLimit()),
 		VisibilityTimeout:   aws.Int64(c.visibleTimeout),
-		WaitTimeSeconds:     aws.Int64(20), // Enable long polling
+		WaitTimeSeconds:     aws.Int64(int64(c.waitTime.Seconds())),
+		AttributeNames:      []*string{aws.String(sqs.MessageSystemAttributeNameApproximateReceiveCount)},
 	})
 
+	if err != nil && isClosed(c.stopped()) {
+		// The poll was abandoned because polling was stopped
+		return nil
+	}
+
+	if c.observePoll != nil && ctx.Err() == nil {
+		c.observePoll(err)
+	}
+
 	if err != nil {
-		log.Printf("Error receiving SQS message: %v", err)
-		return err
+		if ctx.Err() == nil && errors.Is(receiveCtx.Err(), context.DeadlineExceeded) {
+			// A stalled connection must not stop the poll loop, the next poll uses a new request
+			c.logf(LogLevelInfo, "Receiving SQS messages timed out after %s, polling again", c.effectivePollTimeout())
+			return nil
+		}
+
+		c.logf(LogLevelError, "Error receiving SQS message: %v", err)
+		return queueAuthError(err)
 	}
 
+	receivedAt := time.Now()
+	c.adaptPollInterval(len(output.Messages))
+
+	// Batched results are submitted before any message is deleted, so that messages are redelivered
+	// if their results could not be persisted
+	batching := c.beginBatch != nil && len(output.Messages) > 1
+	if batching {
+		c.beginBatch()
+	}
+	handled := []*sqs.Message{}
+	// The heartbeats of batched messages keep running until their results have been submitted,
+	// so that they are not redelivered while later messages of the poll are handled
+	heartbeats := []func(){}
+	defer func() {
+		for _, stop := range heartbeats {
+			stop()
+		}
+	}()
+
+	concurrent := c.slots.concurrent()
 	for _, message := range output.Messages {
-		if err := c.handler(message); err != nil {
-			log.Printf("Error processing message: %v", err)
+		if concurrent {
+			if c.remainingBackoff() > 0 {
+				// A worker was rate limited, so the remaining messages become visible again once it has passed
+				break
+			}
+			c.dispatch(ctx, message, receivedAt)
 			continue
 		}
 
-		_, err := c.svc.DeleteMessage(&sqs.DeleteMessageInput{
-			QueueUrl:      aws.String(c.queueURL),
-			ReceiptHandle: message.ReceiptHandle,
-		})
-
-		if err != nil {
-			log.Printf("Error deleting message: %v", err)
+		ok, rateLimited, stopHeartbeat := c.handleWithHeartbeat(ctx, message, receivedAt)
+		if !ok || !batching {
+			stopHeartbeat()
+		}
+		if rateLimited {
+			// Leave the remaining messages to become visible again once the rate limit has passed
+			break
 		}
+		if !ok {
+			continue
+		}
+		if !batching {
+			c.deleteMessage(message)
+			continue
+		}
+
+		handled = append(handled, message)
+		heartbeats = append(heartbeats, stopHeartbeat)
+	}
+
+	if !batching {
+		return nil
+	}
+
+	if err := c.flushBatch(ctx); err != nil {
+		c.logf(LogLevelError, "Error submitting batched results: %v", err)
+		return nil
+	}
+
+	for _, message := range handled {
+		c.deleteMessage(message)
 	}
 
 	return nil
 }
 
+// queueAuthError makes errors for polls whose credentials were rejected match ErrUnauthorized, so that
+// they are not mistaken for transient failures. Expired credentials are refreshed instead.
+func queueAuthError(err error) error {
+	var failure awserr.RequestFailure
+	if !errors.As(err, &failure) || failure.Code() == "ExpiredToken" {
+		return err
+	}
+	if failure.StatusCode() != http.StatusUnauthorized && failure.StatusCode() != http.StatusForbidden {
+		return err
+	}
+
+	return fmt.Errorf("%w: %v (%s)", ErrUnauthorized, err, queueCredentialsGuidance)
+}
+
+// handle calls the handler with a message, extending its visibility while it is handled. It reports
+// whether the message was handled and can be deleted, and whether the API asked us to slow down.
+func (c *SQSConsumer) handle(ctx context.Context, message *sqs.Message, receivedAt time.Time) (bool, bool) {
+	ok, rateLimited, stopHeartbeat := c.handleWithHeartbeat(ctx, message, receivedAt)
+	stopHeartbeat()
+	return ok, rateLimited
+}
+
+// handleWithHeartbeat handles a message like handle, but leaves its visibility being extended until the
+// returned function is called. The heartbeat stops if the handler panics.
+func (c *SQSConsumer) handleWithHeartbeat(ctx context.Context, message *sqs.Message, receivedAt time.Time) (bool, bool, func()) {
+	stopHeartbeat := c.startHeartbeat(ctx, message)
+	err := func() error {
+		defer func() {
+			if r := recover(); r != nil {
+				stopHeartbeat()
+				panic(r)
+			}
+		}()
+		return c.handler(message, receivedAt)
+	}()
+	c.touch()
+
+	if err == nil {
+		// The backoff resets once calls are handled without being rate limited
+		c.rateLimitBackoff.Store(0)
+		return true, false, stopHeartbeat
+	}
+
+	var rateLimited *RateLimitedError
+	if errors.As(err, &rateLimited) {
+		backoff := c.rateLimited(rateLimited.RetryAfter)
+		c.logf(LogLevelInfo, "Rate limited while processing message, backing off for %s: %v", backoff, err)
+		return false, true, stopHeartbeat
+	}
+
+	var retryable *RetryableError
+	if errors.As(err, &retryable) {
+		c.retryMessage(ctx, message)
+		return false, false, stopHeartbeat
+	}
+
+	c.logf(LogLevelError, "Error processing message: %v", err)
+	return false, false, stopHeartbeat
+}
+
+// dispatch handles a message in a worker of its own, deleting it once it was handled
+func (c *SQSConsumer) dispatch(ctx context.Context, message *sqs.Message, receivedAt time.Time) {
+	c.slots.acquire()
+	c.workers.Add(1)
+
+	go func() {
+		defer c.workers.Done()
+		defer c.slots.release()
+		defer func() {
+			// The message is received again once its visibility timeout has passed
+			if r := recover(); r != nil {
+				c.logf(LogLevelError, "Worker panicked while handling message: %v", r)
+				if c.onPanic != nil {
+					c.onPanic(r)
+				}
+			}
+		}()
+
+		// Rate limited messages become visible again once their visibility timeout has passed, while the
+		// poll loop holds back new polls until the backoff has passed
+		if ok, _ := c.handle(ctx, message, receivedAt); ok {
+			c.deleteMessage(message)
+		}
+	}()
+}
+
+// freeSlots returns how many more messages can be handled at once, or -1 if messages are handled one at a time
+func (c *SQSConsumer) freeSlots() int {
+	return c.slots.free()
+}
+
+// workerSlots limits how many workers handle messages at once. Messages are handled one at a time by the
+// poll loop while the limit is at most 1. The limit can be changed while workers are busy, in which case
+// no further messages are handled until the workers beyond a lowered limit have finished.
+type workerSlots struct {
+	mu    sync.Mutex
+	freed *sync.Cond
+	limit int
+	busy  int
+}
+
+// setLimit changes how many workers may be busy at once
+func (w *workerSlots) setLimit(limit int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.limit = limit
+	if w.freed != nil {
+		w.freed.Broadcast()
+	}
+}
+
+// concurrent reports whether messages are handled by workers rather than the poll loop
+func (w *workerSlots) concurrent() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.limit > 1
+}
+
+// free returns how many more workers may be busy, or -1 if messages are handled by the poll loop
+func (w *workerSlots) free() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.limit <= 1 && w.busy == 0 {
+		return -1
+	}
+
+	return max(w.limit-w.busy, 0)
+}
+
+// acquire waits for a worker to be free, and marks it busy
+func (w *workerSlots) acquire() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.freed == nil {
+		w.freed = sync.NewCond(&w.mu)
+	}
+	for w.busy >= max(w.limit, 1) {
+		w.freed.Wait()
+	}
+	w.busy++
+}
+
+// release marks a worker free again
+func (w *workerSlots) release() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.busy--
+	if w.freed != nil {
+		w.freed.Broadcast()
+	}
+}
+
+// receiveLimit returns how many messages a poll receives, which never exceeds the free workers
+func (c *SQSConsumer) receiveLimit() int64 {
+	if free := int64(c.freeSlots()); free >= 0 && free < c.maxMessages {
+		return free
+	}
+
+	return c.maxMessages
+}
+
+func (c *SQSConsumer) deleteMessage(message *sqs.Message) {
+	_, err := c.svc.DeleteMessage(&sqs.DeleteMessageInput{
+		QueueUrl:      aws.String(c.queueURL),
+		ReceiptHandle: message.ReceiptHandle,
+	})
+
+	if err != nil {
+		c.logf(LogLevelError, "Error deleting message: %v", err)
+	}
+}
+
+// retryMessage makes a message visible again after the retry delay, so that it is dispatched again.
+// Visibility timeouts have a resolution of one second, so the delay is rounded up to whole seconds.
+func (c *SQSConsumer) retryMessage(ctx context.Context, message *sqs.Message) {
+	if c.retryDelay <= 0 {
+		return
+	}
+
+	_, err := c.svc.ChangeMessageVisibilityWithContext(ctx, &sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          aws.String(c.queueURL),
+		ReceiptHandle:     message.ReceiptHandle,
+		VisibilityTimeout: aws.Int64(int64(math.Ceil(c.retryDelay.Seconds()))),
+	})
+	if err != nil {
+		c.logf(LogLevelError, "Error scheduling retry of message: %v", err)
+	}
+}
+
+// startHeartbeat periodically extends the visibility of a message until the returned function is called,
+// so that long running calls are not redelivered to another machine while they are being handled
+func (c *SQSConsumer) startHeartbeat(ctx context.Context, message *sqs.Message) func() {
+	interval := c.effectiveHeartbeatInterval()
+	if interval <= 0 {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				// A handler which is still being heartbeated is making progress
+				c.touch()
+				_, err := c.svc.ChangeMessageVisibilityWithContext(ctx, &sqs.ChangeMessageVisibilityInput{
+					QueueUrl:          aws.String(c.queueURL),
+					ReceiptHandle:     message.ReceiptHandle,
+					VisibilityTimeout: aws.Int64(c.visibleTimeout),
+				})
+				if err != nil {
+					c.logf(LogLevelError, "Error extending message visibility: %v", err)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// adaptPollInterval lengthens the poll interval by half after every empty poll, up to maxPollInterval,
+// and resets it as soon as calls arrive, cutting idle traffic for rarely used services
+func (c *SQSConsumer) adaptPollInterval(received int) {
+	if c.maxPollInterval <= c.pollInterval || received > 0 {
+		c.currentInterval.Store(int64(c.pollInterval))
+		return
+	}
+
+	next := c.currentPollInterval() * 3 / 2
+	if next > c.maxPollInterval {
+		next = c.maxPollInterval
+	}
+	c.currentInterval.Store(int64(next))
+}
+
+// currentPollInterval returns the poll interval, as adapted to the load
+func (c *SQSConsumer) currentPollInterval() time.Duration {
+	if current := time.Duration(c.currentInterval.Load()); current > c.pollInterval {
+		return current
+	}
+
+	return c.pollInterval
+}
+
+// logf writes a log to the logger of the consumer
+func (c *SQSConsumer) logf(level LogLevel, format string, args ...interface{}) {
+	if c.logger == nil {
+		stdLogger{}.Log(level, fmt.Sprintf(format, args...), nil)
+		return
+	}
+
+	c.logger.Log(level, fmt.Sprintf(format, args...), nil)
+}
+
+// touch records that the poll loop made progress
+func (c *SQSConsumer) touch() {
+	c.lastActivity.Store(time.Now().UnixNano())
+}
+
+// LastActivity returns the time the poll loop last polled the queue or handled a message
+func (c *SQSConsumer) LastActivity() time.Time {
+	return time.Unix(0, c.lastActivity.Load())
+}
+
+// IdleCycle is how long the poll loop may make no progress while waiting to poll, see Transport
+func (c *SQSConsumer) IdleCycle() time.Duration {
+	return c.currentPollInterval() + c.waitTime
+}
+
+func (c *SQSConsumer) effectiveHeartbeatInterval() time.Duration {
+	if c.heartbeatInterval == 0 {
+		return time.Duration(c.visibleTimeout) * time.Second / 2
+	}
+
+	return c.heartbeatInterval
+}
+
+// effectivePollTimeout returns the poll timeout, which defaults to the wait time plus a margin
+func (c *SQSConsumer) effectivePollTimeout() time.Duration {
+	if c.pollTimeout <= 0 {
+		return c.waitTime + pollTimeoutMargin
+	}
+
+	return c.pollTimeout
+}
+
 // SetPollInterval sets the polling interval
 func (c *SQSConsumer) SetPollInterval(d time.Duration) {
 	c.pollInterval = d
@@ -111,7 +664,127 @@ func (c *SQSConsumer) SetMaxMessages(n int64) {
 	c.maxMessages = n
 }
 
+// SetWaitTime sets how long a poll waits for messages to arrive, up to 20 seconds
+func (c *SQSConsumer) SetWaitTime(d time.Duration) {
+	c.waitTime = d
+}
+
 // SetVisibilityTimeout sets the visibility timeout for received messages
 func (c *SQSConsumer) SetVisibilityTimeout(seconds int64) {
 	c.visibleTimeout = seconds
 }
+
+// SetHeartbeatInterval sets how often the visibility of a message is extended while it is handled.
+// Defaults to half the visibility timeout.
+func (c *SQSConsumer) SetHeartbeatInterval(d time.Duration) {
+	c.heartbeatInterval = d
+}
+
+// SetMaxPollInterval enables adaptive polling, lengthening the poll interval up to d while the queue is idle
+func (c *SQSConsumer) SetMaxPollInterval(d time.Duration) {
+	c.maxPollInterval = d
+}
+
+// SetRetryDelay sets how long a message whose handler failed with a RetryableError waits to be received again
+func (c *SQSConsumer) SetRetryDelay(d time.Duration) {
+	c.retryDelay = d
+}
+
+// SetPollTimeout sets how long a poll may take before it is abandoned, so that a stalled connection
+// cannot block the poll loop. Defaults to the wait time plus 10 seconds.
+func (c *SQSConsumer) SetPollTimeout(d time.Duration) {
+	c.pollTimeout = d
+}
+
+// SetHTTPClient sets the HTTP client used to reach the queue, e.g. to trust the certificates of a self-hosted endpoint
+func (c *SQSConsumer) SetHTTPClient(client *http.Client) {
+	c.svc.Client.Config.HTTPClient = client
+}
+
+// SetCredentialsRefresher makes the consumer fetch new queue credentials with refresh once current is within
+// window of its expiration, so that polling continues past the lifetime of temporary credentials
+func (c *SQSConsumer) SetCredentialsRefresher(current SQSCredentials, window time.Duration, refresh func() (SQSCredentials, error)) {
+	c.credentials = &refreshingCredentials{
+		current: current,
+		window:  window,
+		refresh: refresh,
+	}
+	c.svc.Client.Config.Credentials = credentials.NewCredentials(c.credentials)
+}
+
+// UpdateCredentials replaces the queue credentials, e.g. once they were refreshed ahead of their expiration.
+// They are used from the next request on, so messages being received or handled are unaffected.
+func (c *SQSConsumer) UpdateCredentials(updated SQSCredentials) {
+	if c.credentials == nil {
+		c.svc.Client.Config.Credentials = credentials.NewStaticCredentials(updated.AccessKeyID, updated.SecretAccessKey, updated.SessionToken)
+		return
+	}
+
+	c.credentials.mu.Lock()
+	c.credentials.current = updated
+	c.credentials.mu.Unlock()
+	c.svc.Client.Config.Credentials.Expire()
+}
+
+// QueueDepth returns the approximate number of messages waiting in the queue
+func (c *SQSConsumer) QueueDepth(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.effectivePollTimeout())
+	defer cancel()
+
+	output, err := c.svc.GetQueueAttributesWithContext(ctx, &sqs.GetQueueAttributesInput{
+		QueueUrl:       aws.String(c.queueURL),
+		AttributeNames: []*string{aws.String(sqs.QueueAttributeNameApproximateNumberOfMessages)},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to get queue attributes: %v", err)
+	}
+
+	depth, err := strconv.ParseInt(aws.StringValue(output.Attributes[sqs.QueueAttributeNameApproximateNumberOfMessages]), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse queue depth: %v", err)
+	}
+
+	return depth, nil
+}
+
+// SetRateLimiter sets a function which blocks each poll until the rate of requests allows it
+func (c *SQSConsumer) SetRateLimiter(wait func(ctx context.Context) error) {
+	c.waitRateLimit = wait
+}
+
+// SetConcurrency handles up to n messages at once, each in a worker of its own. Polls receive no more
+// messages than there are free workers, and the queue is not polled while all workers are busy.
+// Messages are handled one at a time by the poll loop if n is at most 1. It may be called while the
+// consumer is running.
+func (c *SQSConsumer) SetConcurrency(n int) {
+	c.slots.setLimit(n)
+}
+
+// SetLogger sets the logger receiving the logs of the consumer. Defaults to the standard library logger.
+func (c *SQSConsumer) SetLogger(logger Logger) {
+	c.logger = logger
+}
+
+// SetPanicHandler sets a function called with the panics recovered from workers, see SetConcurrency.
+// The poll loop itself does not recover panics, they are left to the caller of Start.
+func (c *SQSConsumer) SetPanicHandler(handle func(r interface{})) {
+	c.onPanic = handle
+}
+
+// SetPollObserver sets a function called with the outcome of every poll of the queue, nil if it succeeded
+func (c *SQSConsumer) SetPollObserver(observe func(err error)) {
+	c.observePoll = observe
+}
+
+// SetPauseFunc sets a function which stops the consumer from polling while it returns true.
+// Messages being handled are unaffected.
+func (c *SQSConsumer) SetPauseFunc(isPaused func() bool) {
+	c.isPaused = isPaused
+}
+
+// SetResultBatching makes the consumer call begin before handling a poll which received more than one
+// message, and flush once they have been handled. Messages are only deleted once flush succeeds.
+func (c *SQSConsumer) SetResultBatching(begin func(), flush func(ctx context.Context) error) {
+	c.beginBatch = begin
+	c.flushBatch = flush
+}
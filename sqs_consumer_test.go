@@ -0,0 +1,736 @@
+package inferable
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQSConsumerHeartbeat(t *testing.T) {
+	var mu sync.Mutex
+	actions := map[string]int{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		action := r.Header.Get("X-Amz-Target")
+		mu.Lock()
+		actions[action]++
+		mu.Unlock()
+
+		if action == "AmazonSQS.ReceiveMessage" {
+			body := "{}"
+			sum := md5.Sum([]byte(body))
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"Messages": []map[string]string{
+					{"MessageId": "msg-1", "ReceiptHandle": "msg-1", "Body": body, "MD5OfBody": hex.EncodeToString(sum[:])},
+				},
+			})
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	handler := func(msg *sqs.Message, receivedAt time.Time) error {
+		time.Sleep(120 * time.Millisecond)
+		return nil
+	}
+
+	consumer, err := newSQSConsumer(server.URL, "us-east-1", server.URL+"/queue", handler, "key", "secret", "token")
+	require.NoError(t, err)
+	consumer.SetHeartbeatInterval(30 * time.Millisecond)
+
+	require.NoError(t, consumer.poll(context.Background()))
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.GreaterOrEqual(t, actions["AmazonSQS.ChangeMessageVisibility"], 2)
+	assert.Equal(t, 1, actions["AmazonSQS.DeleteMessage"])
+
+	// The heartbeat stops once the message has been handled
+	heartbeats := actions["AmazonSQS.ChangeMessageVisibility"]
+	mu.Unlock()
+	time.Sleep(100 * time.Millisecond)
+	mu.Lock()
+	assert.Equal(t, heartbeats, actions["AmazonSQS.ChangeMessageVisibility"])
+}
+
+func TestNewSQSConsumerHandler(t *testing.T) {
+	var handled *sqs.Message
+	handler := func(msg *sqs.Message) error {
+		handled = msg
+		return nil
+	}
+
+	consumer, err := NewSQSConsumer("us-east-1", "https://sqs.us-east-1.amazonaws.com/queue", handler, "key", "secret", "token")
+	require.NoError(t, err)
+
+	message := &sqs.Message{MessageId: aws.String("msg-1")}
+	require.NoError(t, consumer.handler(message, time.Now()))
+	assert.Same(t, message, handled)
+}
+
+type levelLogger struct {
+	mu   sync.Mutex
+	logs map[LogLevel][]string
+}
+
+func (l *levelLogger) Log(level LogLevel, msg string, fields map[string]interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.logs == nil {
+		l.logs = map[LogLevel][]string{}
+	}
+	l.logs[level] = append(l.logs[level], msg)
+}
+
+func (l *levelLogger) messages(level LogLevel) []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string{}, l.logs[level]...)
+}
+
+func TestSQSConsumerLogger(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Amz-Target") == "AmazonSQS.ReceiveMessage" {
+			body := "{}"
+			sum := md5.Sum([]byte(body))
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"Messages": []map[string]string{
+					{"MessageId": "msg-1", "ReceiptHandle": "msg-1", "Body": body, "MD5OfBody": hex.EncodeToString(sum[:])},
+				},
+			})
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"__type": "ReceiptHandleIsInvalid", "message": "invalid"}`))
+	}))
+	defer server.Close()
+
+	handler := func(msg *sqs.Message, receivedAt time.Time) error { return nil }
+
+	consumer, err := newSQSConsumer(server.URL, "us-east-1", server.URL+"/queue", handler, "key", "secret", "token")
+	require.NoError(t, err)
+	logger := &levelLogger{}
+	consumer.SetLogger(logger)
+
+	// Failures of the consumer reach the configured logger rather than the standard library logger
+	require.NoError(t, consumer.poll(context.Background()))
+	require.Len(t, logger.messages(LogLevelError), 1)
+	assert.Contains(t, logger.messages(LogLevelError)[0], "Error deleting message")
+}
+
+func TestAdaptivePollInterval(t *testing.T) {
+	consumer := &SQSConsumer{pollInterval: time.Second}
+
+	// Adaptive polling is disabled by default
+	consumer.adaptPollInterval(0)
+	assert.Equal(t, time.Second, consumer.nextPollDelay())
+
+	consumer.SetMaxPollInterval(3 * time.Second)
+
+	consumer.adaptPollInterval(0)
+	assert.Equal(t, 1500*time.Millisecond, consumer.nextPollDelay())
+	consumer.adaptPollInterval(0)
+	assert.Equal(t, 2250*time.Millisecond, consumer.nextPollDelay())
+	consumer.adaptPollInterval(0)
+	assert.Equal(t, 3*time.Second, consumer.nextPollDelay())
+	consumer.adaptPollInterval(0)
+	assert.Equal(t, 3*time.Second, consumer.nextPollDelay())
+
+	// Calls arriving reset the interval
+	consumer.adaptPollInterval(2)
+	assert.Equal(t, time.Second, consumer.nextPollDelay())
+}
+
+func TestSQSConsumerPollTimeout(t *testing.T) {
+	var mu sync.Mutex
+	receives := 0
+	release := make(chan struct{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		receives++
+		first := receives == 1
+		mu.Unlock()
+
+		// The first poll stalls until it is abandoned
+		if first {
+			select {
+			case <-r.Context().Done():
+			case <-release:
+			}
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+	defer close(release)
+
+	handler := func(msg *sqs.Message, receivedAt time.Time) error { return nil }
+
+	consumer, err := newSQSConsumer(server.URL, "us-east-1", server.URL+"/queue", handler, "key", "secret", "token")
+	require.NoError(t, err)
+	consumer.SetWaitTime(0)
+	consumer.SetPollTimeout(100 * time.Millisecond)
+
+	start := time.Now()
+	require.NoError(t, consumer.poll(context.Background()))
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	require.NoError(t, consumer.poll(context.Background()))
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 2, receives)
+	assert.Equal(t, 10*time.Second, (&SQSConsumer{}).effectivePollTimeout())
+}
+
+func TestSQSConsumerPause(t *testing.T) {
+	var receives atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Amz-Target") == "AmazonSQS.ReceiveMessage" {
+			receives.Add(1)
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	handler := func(msg *sqs.Message, receivedAt time.Time) error { return nil }
+
+	consumer, err := newSQSConsumer(server.URL, "us-east-1", server.URL+"/queue", handler, "key", "secret", "token")
+	require.NoError(t, err)
+	consumer.SetWaitTime(0)
+	consumer.SetPollInterval(10 * time.Millisecond)
+
+	var paused atomic.Bool
+	paused.Store(true)
+	consumer.SetPauseFunc(paused.Load)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go consumer.Start(ctx)
+
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, int32(0), receives.Load())
+	// A paused consumer keeps making progress, so that the watchdog does not restart it
+	assert.WithinDuration(t, time.Now(), consumer.LastActivity(), time.Second)
+
+	paused.Store(false)
+	require.Eventually(t, func() bool { return receives.Load() > 0 }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestSQSConsumerCredentialsRefresh(t *testing.T) {
+	var mu sync.Mutex
+	keys := []string{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		keys = append(keys, strings.SplitN(strings.TrimPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential="), "/", 2)[0])
+		mu.Unlock()
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	handler := func(msg *sqs.Message, receivedAt time.Time) error { return nil }
+
+	consumer, err := newSQSConsumer(server.URL, "us-east-1", server.URL+"/queue", handler, "key-1", "secret", "token")
+	require.NoError(t, err)
+	consumer.SetWaitTime(0)
+
+	refreshes := 0
+	failRefresh := false
+	refresh := func() (SQSCredentials, error) {
+		refreshes++
+		if failRefresh {
+			return SQSCredentials{}, errors.New("registration failed")
+		}
+		return SQSCredentials{AccessKeyID: fmt.Sprintf("key-%d", refreshes+1), SecretAccessKey: "secret", Expiration: time.Now().Add(30 * time.Second)}, nil
+	}
+	consumer.SetCredentialsRefresher(
+		SQSCredentials{AccessKeyID: "key-1", SecretAccessKey: "secret", Expiration: time.Now().Add(time.Hour)},
+		time.Minute,
+		refresh,
+	)
+
+	// Credentials far from their expiration are used as they are
+	require.NoError(t, consumer.poll(context.Background()))
+	assert.Equal(t, 0, refreshes)
+
+	// Credentials within the refresh window are refreshed before the next poll
+	consumer.SetCredentialsRefresher(
+		SQSCredentials{AccessKeyID: "key-1", SecretAccessKey: "secret", Expiration: time.Now().Add(30 * time.Second)},
+		time.Minute,
+		refresh,
+	)
+	require.NoError(t, consumer.poll(context.Background()))
+	assert.Equal(t, 1, refreshes)
+
+	// Credentials which failed to refresh are used until they expire
+	failRefresh = true
+	require.NoError(t, consumer.poll(context.Background()))
+	assert.Equal(t, 2, refreshes)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"key-1", "key-2", "key-2"}, keys)
+}
+
+func TestRefreshCredentialsLoop(t *testing.T) {
+	var registrations atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/machines" {
+			count := registrations.Add(1)
+			// The first credentials are about to expire, the refreshed ones last an hour
+			expiration := time.Now().Add(time.Minute)
+			if count > 1 {
+				expiration = time.Now().Add(time.Hour)
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"queueUrl":   "http://localhost/queue",
+				"region":     "us-east-1",
+				"expiration": expiration,
+				"credentials": map[string]string{
+					"accessKeyId":     fmt.Sprintf("key-%d", count),
+					"secretAccessKey": "secret",
+					"sessionToken":    "token",
+				},
+			})
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	i, err := New(InferableOptions{
+		APIEndpoint: server.URL,
+		APISecret:   "test-secret",
+	})
+	require.NoError(t, err)
+
+	type TestInput struct{}
+	require.NoError(t, i.Default.RegisterFunc(Function{Name: "TestFunc", Func: func(input TestInput) int { return 1 }}))
+	require.NoError(t, i.Default.registerMachine())
+
+	consumer, err := i.Default.newQueueConsumer()
+	require.NoError(t, err)
+	i.Default.consumerMu.Lock()
+	i.Default.consumer = consumer
+	i.Default.consumerMu.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		i.Default.refreshCredentialsLoop(ctx)
+	}()
+
+	require.Eventually(t, func() bool { return registrations.Load() == 2 }, time.Second, 10*time.Millisecond)
+	require.Eventually(t, func() bool {
+		value, err := consumer.svc.Client.Config.Credentials.Get()
+		return err == nil && value.AccessKeyID == "key-2"
+	}, time.Second, 10*time.Millisecond)
+
+	// The refreshed credentials are not refreshed again until they are about to expire
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(2), registrations.Load())
+
+	cancel()
+	<-done
+}
+
+func TestConcurrentCredentialsRefresh(t *testing.T) {
+	var registrations atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/machines" {
+			count := registrations.Add(1)
+			time.Sleep(50 * time.Millisecond)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"queueUrl":   "http://localhost/queue",
+				"region":     "us-east-1",
+				"clusterId":  fmt.Sprintf("cluster-%d", count),
+				"expiration": time.Now().Add(time.Duration(count) * time.Hour),
+				"credentials": map[string]string{
+					"accessKeyId":     fmt.Sprintf("key-%d", count),
+					"secretAccessKey": "secret",
+					"sessionToken":    "token",
+				},
+			})
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	i, err := New(InferableOptions{
+		APIEndpoint: server.URL,
+		APISecret:   "test-secret",
+	})
+	require.NoError(t, err)
+
+	type TestInput struct{}
+	require.NoError(t, i.Default.RegisterFunc(Function{Name: "TestFunc", Func: func(input TestInput) int { return 1 }}))
+	require.NoError(t, i.Default.registerMachine())
+
+	// Refreshes by the transport and the refresh loop racing each other register the machine once,
+	// while the registration details are read concurrently
+	var wg sync.WaitGroup
+	keys := make([]string, 4)
+	for idx := range keys {
+		wg.Add(2)
+		go func(idx int) {
+			defer wg.Done()
+			credentials, err := i.Default.refreshCredentials()
+			assert.NoError(t, err)
+			keys[idx] = credentials.AccessKeyID
+		}(idx)
+		go func() {
+			defer wg.Done()
+			i.Default.ClusterID()
+			i.Default.GetConfig()
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(2), registrations.Load())
+	assert.Equal(t, []string{"key-2", "key-2", "key-2", "key-2"}, keys)
+	assert.Equal(t, "cluster-2", i.Default.ClusterID())
+}
+
+func TestSQSConsumerConcurrency(t *testing.T) {
+	var mu sync.Mutex
+	limits := []string{}
+	next := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Amz-Target") != "AmazonSQS.ReceiveMessage" {
+			w.Write([]byte(`{}`))
+			return
+		}
+
+		var input struct{ MaxNumberOfMessages int }
+		json.NewDecoder(r.Body).Decode(&input)
+
+		mu.Lock()
+		limits = append(limits, fmt.Sprint(input.MaxNumberOfMessages))
+		messages := []map[string]string{}
+		for idx := 0; idx < input.MaxNumberOfMessages; idx++ {
+			next++
+			body := "{}"
+			sum := md5.Sum([]byte(body))
+			id := fmt.Sprintf("msg-%d", next)
+			messages = append(messages, map[string]string{"MessageId": id, "ReceiptHandle": id, "Body": body, "MD5OfBody": hex.EncodeToString(sum[:])})
+		}
+		mu.Unlock()
+
+		json.NewEncoder(w).Encode(map[string]interface{}{"Messages": messages})
+	}))
+	defer server.Close()
+
+	release := make(chan struct{})
+	var running, maxRunning atomic.Int32
+	handler := func(msg *sqs.Message, receivedAt time.Time) error {
+		current := running.Add(1)
+		defer running.Add(-1)
+		for {
+			seen := maxRunning.Load()
+			if current <= seen || maxRunning.CompareAndSwap(seen, current) {
+				break
+			}
+		}
+		<-release
+		return nil
+	}
+
+	consumer, err := newSQSConsumer(server.URL, "us-east-1", server.URL+"/queue", handler, "key", "secret", "token")
+	require.NoError(t, err)
+	consumer.SetWaitTime(0)
+	consumer.SetPollInterval(10 * time.Millisecond)
+	consumer.SetMaxMessages(2)
+	consumer.SetConcurrency(3)
+
+	stop := startConsumer(consumer)
+	defer stop()
+
+	// Polls receive no more calls than there are free workers, and stop while all of them are busy
+	require.Eventually(t, func() bool { return running.Load() == 3 }, 5*time.Second, 10*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	mu.Lock()
+	assert.Equal(t, []string{"2", "1"}, limits)
+	mu.Unlock()
+	// A saturated consumer keeps making progress, so that the watchdog does not restart it
+	assert.WithinDuration(t, time.Now(), consumer.LastActivity(), time.Second)
+
+	close(release)
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(limits) > 2
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, int32(3), maxRunning.Load())
+
+	// The poll loop exits only once its workers have finished
+	stop()
+	assert.Equal(t, int32(0), running.Load())
+}
+
+// startConsumer runs the poll loop of consumer in the background. The returned function stops it,
+// waiting for the loop and its workers to exit. It may be called more than once.
+func startConsumer(consumer *SQSConsumer) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		consumer.Start(ctx)
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// newVisibilityTestServer serves two messages, tracking their visibility timeouts. It records the
+// messages which were still hidden from other consumers when they were deleted, returning a copy of them.
+func newVisibilityTestServer(timeout time.Duration) (*httptest.Server, func() map[string]bool) {
+	var mu sync.Mutex
+	visibleAt := map[string]time.Time{}
+	deleted := map[string]bool{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var input struct {
+			ReceiptHandle     string
+			VisibilityTimeout int64
+		}
+		json.NewDecoder(r.Body).Decode(&input)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch r.Header.Get("X-Amz-Target") {
+		case "AmazonSQS.ReceiveMessage":
+			messages := []map[string]string{}
+			for _, id := range []string{"msg-1", "msg-2"} {
+				visibleAt[id] = time.Now().Add(timeout)
+				body := `{"id": "` + id + `"}`
+				sum := md5.Sum([]byte(body))
+				messages = append(messages, map[string]string{"MessageId": id, "ReceiptHandle": id, "Body": body, "MD5OfBody": hex.EncodeToString(sum[:])})
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"Messages": messages})
+			return
+		case "AmazonSQS.ChangeMessageVisibility":
+			visibleAt[input.ReceiptHandle] = time.Now().Add(timeout)
+		case "AmazonSQS.DeleteMessage":
+			deleted[input.ReceiptHandle] = time.Now().Before(visibleAt[input.ReceiptHandle])
+		}
+		w.Write([]byte(`{}`))
+	}))
+
+	return server, func() map[string]bool {
+		mu.Lock()
+		defer mu.Unlock()
+
+		snapshot := make(map[string]bool, len(deleted))
+		for id, hidden := range deleted {
+			snapshot[id] = hidden
+		}
+		return snapshot
+	}
+}
+
+func TestSQSConsumerDeletesHandledMessages(t *testing.T) {
+	server, deleted := newVisibilityTestServer(time.Second)
+	defer server.Close()
+
+	// The second message outlives the visibility timeout of the first, which must not be redelivered
+	handler := func(msg *sqs.Message, receivedAt time.Time) error {
+		if strings.Contains(*msg.Body, "msg-2") {
+			time.Sleep(1500 * time.Millisecond)
+		}
+		return nil
+	}
+
+	consumer, err := newSQSConsumer(server.URL, "us-east-1", server.URL+"/queue", handler, "key", "secret", "token")
+	require.NoError(t, err)
+	consumer.SetVisibilityTimeout(1)
+	consumer.SetHeartbeatInterval(300 * time.Millisecond)
+
+	require.NoError(t, consumer.poll(context.Background()))
+	assert.Equal(t, map[string]bool{"msg-1": true, "msg-2": true}, deleted())
+}
+
+func TestSQSConsumerBatchedHeartbeats(t *testing.T) {
+	server, deleted := newVisibilityTestServer(time.Second)
+	defer server.Close()
+
+	handler := func(msg *sqs.Message, receivedAt time.Time) error {
+		if strings.Contains(*msg.Body, "msg-2") {
+			time.Sleep(1500 * time.Millisecond)
+		}
+		return nil
+	}
+
+	consumer, err := newSQSConsumer(server.URL, "us-east-1", server.URL+"/queue", handler, "key", "secret", "token")
+	require.NoError(t, err)
+	consumer.SetVisibilityTimeout(1)
+	consumer.SetHeartbeatInterval(300 * time.Millisecond)
+
+	flushed := false
+	consumer.SetResultBatching(func() {}, func(ctx context.Context) error {
+		assert.Empty(t, deleted())
+		flushed = true
+		return nil
+	})
+
+	// Batched messages stay hidden until their results have been submitted
+	require.NoError(t, consumer.poll(context.Background()))
+	assert.True(t, flushed)
+	assert.Equal(t, map[string]bool{"msg-1": true, "msg-2": true}, deleted())
+}
+
+func TestSQSConsumerRateLimitBackoff(t *testing.T) {
+	consumer, err := newSQSConsumer("http://localhost", "us-east-1", "http://localhost/queue", nil, "key", "secret", "token")
+	require.NoError(t, err)
+	consumer.SetPollInterval(10 * time.Millisecond)
+
+	// Rate limited calls without a Retry-After still slow the poll loop, doubling with each consecutive one
+	assert.Equal(t, minRateLimitBackoff, consumer.rateLimited(0))
+	assert.Equal(t, 2*minRateLimitBackoff, consumer.rateLimited(0))
+	assert.Equal(t, 10*time.Second, consumer.rateLimited(10*time.Second))
+	assert.Greater(t, consumer.nextPollDelay(), 9*time.Second)
+	for range 10 {
+		consumer.rateLimited(0)
+	}
+	assert.Equal(t, maxRateLimitBackoff, consumer.rateLimited(0))
+
+	// The backoff resets once a call is handled
+	consumer.handler = func(msg *sqs.Message, receivedAt time.Time) error { return nil }
+	consumer.SetHeartbeatInterval(-1)
+	ok, _ := consumer.handle(context.Background(), &sqs.Message{}, time.Now())
+	assert.True(t, ok)
+	assert.Equal(t, minRateLimitBackoff, consumer.rateLimited(0))
+}
+
+func TestSQSConsumerConcurrentRateLimit(t *testing.T) {
+	var polls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Amz-Target") != "AmazonSQS.ReceiveMessage" {
+			w.Write([]byte(`{}`))
+			return
+		}
+
+		polls.Add(1)
+		body := "{}"
+		sum := md5.Sum([]byte(body))
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"Messages": []map[string]string{{"MessageId": "msg-1", "ReceiptHandle": "msg-1", "Body": body, "MD5OfBody": hex.EncodeToString(sum[:])}},
+		})
+	}))
+	defer server.Close()
+
+	handler := func(msg *sqs.Message, receivedAt time.Time) error {
+		return &RateLimitedError{APIError: &APIError{StatusCode: http.StatusTooManyRequests}}
+	}
+
+	consumer, err := newSQSConsumer(server.URL, "us-east-1", server.URL+"/queue", handler, "key", "secret", "token")
+	require.NoError(t, err)
+	consumer.SetWaitTime(0)
+	consumer.SetPollInterval(10 * time.Millisecond)
+	consumer.SetConcurrency(2)
+
+	stop := startConsumer(consumer)
+	defer stop()
+
+	// Workers which were rate limited hold back polls, as the poll loop does when handling calls itself
+	require.Eventually(t, func() bool { return consumer.remainingBackoff() > 0 }, 5*time.Second, 10*time.Millisecond)
+	polled := polls.Load()
+	time.Sleep(500 * time.Millisecond)
+	assert.Equal(t, polled, polls.Load())
+}
+
+func TestSQSConsumerUnauthorized(t *testing.T) {
+	code := "AccessDenied"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprintf(w, `{"__type": "%s", "message": "denied"}`, code)
+	}))
+	defer server.Close()
+
+	consumer, err := newSQSConsumer(server.URL, "us-east-1", server.URL+"/queue", nil, "key", "secret", "token")
+	require.NoError(t, err)
+	consumer.SetWaitTime(0)
+
+	// Rejected queue credentials fail the poll loop on the first poll
+	err = consumer.Start(context.Background())
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	assert.ErrorContains(t, err, queueCredentialsGuidance)
+
+	// Expired credentials are refreshed rather than rejected
+	code = "ExpiredToken"
+	err = consumer.poll(context.Background())
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, ErrUnauthorized)
+}
+
+func TestRefreshCredentialsLoopUnauthorized(t *testing.T) {
+	var registrations atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/machines" {
+			w.Write([]byte(`{}`))
+			return
+		}
+		if registrations.Add(1) > 1 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"queueUrl":    "http://localhost/queue",
+			"region":      "us-east-1",
+			"expiration":  time.Now().Add(time.Minute),
+			"credentials": map[string]string{"accessKeyId": "key", "secretAccessKey": "secret", "sessionToken": "token"},
+		})
+	}))
+	defer server.Close()
+
+	i, err := New(InferableOptions{
+		APIEndpoint: server.URL,
+		APISecret:   "test-secret",
+	})
+	require.NoError(t, err)
+
+	type TestInput struct{}
+	require.NoError(t, i.Default.RegisterFunc(Function{Name: "TestFunc", Func: func(input TestInput) int { return 1 }}))
+	require.NoError(t, i.Default.registerMachine())
+
+	// A rejected secret stops the service instead of being retried
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		i.Default.refreshCredentialsLoop(context.Background())
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("refresh loop did not stop")
+	}
+	assert.Equal(t, int32(2), registrations.Load())
+	assert.ErrorIs(t, i.Default.Err(), ErrAuthExpired)
+	assert.NotErrorIs(t, i.Default.Err(), ErrUnauthorized)
+}
@@ -0,0 +1,81 @@
+package inferable
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ServiceStats reports the calls handled by a service, e.g. to drive autoscaling decisions
+type ServiceStats struct {
+	Service string `json:"service"`
+	// PendingAck is the number of calls received but not yet acknowledged
+	PendingAck int64 `json:"pendingAck"`
+	// InFlight is the number of calls being handled
+	InFlight int64 `json:"inFlight"`
+	// Completed is the number of calls whose result was persisted, including rejections
+	Completed int64 `json:"completed"`
+	// Failed is the number of calls which could not be handled, including calls dispatched again to be retried
+	Failed int64 `json:"failed"`
+	// LastPoll is when the queue was last polled successfully, zero if it has not been
+	LastPoll time.Time `json:"lastPoll,omitempty"`
+	// LastFailedPoll is when a poll of the queue last failed, zero if none has
+	LastFailedPoll time.Time `json:"lastFailedPoll,omitempty"`
+}
+
+// callStats counts the calls handled by a service, see Service.Stats
+type callStats struct {
+	pendingAck     atomic.Int64
+	completed      atomic.Int64
+	failed         atomic.Int64
+	lastFailedPoll atomic.Int64
+	// durations counts the calls handled by how long handling them took
+	durations durationHistogram
+	// unacknowledged holds the IDs of the calls counted by pendingAck
+	unacknowledged sync.Map // call ID -> struct{}
+}
+
+// received counts a call as pending acknowledgement
+func (c *callStats) received(callID string) {
+	if _, loaded := c.unacknowledged.LoadOrStore(callID, struct{}{}); !loaded {
+		c.pendingAck.Add(1)
+	}
+}
+
+// acknowledged stops counting a call as pending acknowledgement
+func (c *callStats) acknowledged(callID string) {
+	if _, loaded := c.unacknowledged.LoadAndDelete(callID); loaded {
+		c.pendingAck.Add(-1)
+	}
+}
+
+// handled counts a call as completed or failed, and no longer pending acknowledgement
+func (c *callStats) handled(callID string, err error, duration time.Duration) {
+	c.acknowledged(callID)
+	c.durations.observe(duration)
+	if err != nil {
+		c.failed.Add(1)
+		return
+	}
+
+	c.completed.Add(1)
+}
+
+// Stats returns the current counts of the calls handled by the service
+func (s *Service) Stats() ServiceStats {
+	stats := ServiceStats{
+		Service:    s.Name,
+		PendingAck: s.stats.pendingAck.Load(),
+		InFlight:   s.inFlight.Load(),
+		Completed:  s.stats.completed.Load(),
+		Failed:     s.stats.failed.Load(),
+	}
+	if lastPoll := s.lastPoll.Load(); lastPoll != 0 {
+		stats.LastPoll = time.Unix(0, lastPoll)
+	}
+	if lastFailedPoll := s.stats.lastFailedPoll.Load(); lastFailedPoll != 0 {
+		stats.LastFailedPoll = time.Unix(0, lastFailedPoll)
+	}
+
+	return stats
+}
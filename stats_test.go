@@ -0,0 +1,79 @@
+package inferable
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServiceStats(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	i, err := New(InferableOptions{
+		APIEndpoint: server.URL,
+		APISecret:   "test-secret",
+	})
+	require.NoError(t, err)
+
+	service, err := i.RegisterServiceWithOptions("manual", ServiceOptions{DisableAutoAcknowledge: true})
+	require.NoError(t, err)
+
+	var during ServiceStats
+	type TestInput struct{}
+	require.NoError(t, service.RegisterFunc(Function{
+		Name: "Acknowledged",
+		Func: func(ctx context.Context, input TestInput) (string, error) {
+			during = service.Stats()
+			call, _ := CallInfoFromContext(ctx)
+			return "done", service.Ack(call.ID)
+		},
+	}))
+
+	call := func(id, fn string) error {
+		body := `{"value": {"id": "` + id + `", "service": "manual", "targetFn": "` + fn + `", "targetArgs": "{\"value\": {}}"}}`
+		return service.handleMessage(&sqs.Message{Body: aws.String(body)}, time.Now())
+	}
+
+	require.NoError(t, call("call-1", "Acknowledged"))
+	assert.Equal(t, int64(1), during.PendingAck)
+	assert.Equal(t, int64(1), during.InFlight)
+
+	require.Error(t, call("call-2", "Unknown"))
+	service.observePoll(nil)
+	service.observePoll(errors.New("poll failed"))
+
+	stats := service.Stats()
+	assert.Equal(t, "manual", stats.Service)
+	assert.Equal(t, int64(0), stats.PendingAck)
+	assert.Equal(t, int64(0), stats.InFlight)
+	assert.Equal(t, int64(1), stats.Completed)
+	assert.Equal(t, int64(1), stats.Failed)
+	assert.False(t, stats.LastPoll.IsZero())
+	assert.False(t, stats.LastFailedPoll.IsZero())
+
+	// The stats are served as metrics, which the health handlers leave to the application to mount
+	mux := http.NewServeMux()
+	i.RegisterHealthHandlers(mux)
+	mux.Handle("/metrics", http.NotFoundHandler())
+
+	recorder := httptest.NewRecorder()
+	i.MetricsHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	metrics := recorder.Body.String()
+	assert.Contains(t, metrics, "# TYPE inferable_calls_in_flight gauge\n")
+	assert.Contains(t, metrics, `inferable_calls_completed_total{service="manual"} 1`+"\n")
+	assert.Contains(t, metrics, `inferable_calls_failed_total{service="manual"} 1`+"\n")
+	assert.False(t, strings.Contains(metrics, `service="default"`))
+}
@@ -0,0 +1,118 @@
+package inferable
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+)
+
+// DefaultResultChunkSize is the size of the chunks in which streamed results are uploaded
+// when ServiceOptions.ResultChunkSize is not set
+const DefaultResultChunkSize = 1 << 20
+
+func (s *Service) resultChunkSize() int {
+	if s.options.ResultChunkSize <= 0 {
+		return DefaultResultChunkSize
+	}
+
+	return s.options.ResultChunkSize
+}
+
+// resultStream returns the stream returned by a function, if it returned one without an error.
+//
+// A function can stream a large result instead of returning it as a value, by returning an io.Reader
+// or a channel of chunks (<-chan []byte) which is closed once the result is complete. The result is
+// uploaded to the cluster in chunks of ServiceOptions.ResultChunkSize as it is produced, so that it
+// never has to be held in memory as a whole. Readers implementing io.Closer are closed once uploaded.
+// The persisted result describes the upload, e.g. {"chunks": 3, "size": 2500000}, and is flagged as
+// chunked in its metadata so that the cluster assembles the chunks.
+func resultStream(returnValues []reflect.Value) (io.Reader, bool) {
+	if len(returnValues) == 0 {
+		return nil, false
+	}
+	if err, ok := returnValues[len(returnValues)-1].Interface().(error); ok && err != nil {
+		return nil, false
+	}
+
+	switch value := returnValues[0].Interface().(type) {
+	case io.Reader:
+		return value, value != nil
+	case <-chan []byte:
+		return &chunkReader{chunks: value}, value != nil
+	case chan []byte:
+		return &chunkReader{chunks: value}, value != nil
+	}
+
+	return nil, false
+}
+
+// chunkReader reads the chunks sent on a channel until it is closed
+type chunkReader struct {
+	chunks  <-chan []byte
+	current []byte
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for len(r.current) == 0 {
+		chunk, ok := <-r.chunks
+		if !ok {
+			return 0, io.EOF
+		}
+		r.current = chunk
+	}
+
+	n := copy(p, r.current)
+	r.current = r.current[n:]
+	return n, nil
+}
+
+// uploadResultStream uploads a streamed result in chunks, returning the result which describes the upload
+func (s *Service) uploadResultStream(ctx context.Context, jobID string, stream io.Reader) (CallResult, error) {
+	if closer, ok := stream.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	buf := make([]byte, s.resultChunkSize())
+	chunks, size := 0, 0
+
+	for {
+		n, readErr := io.ReadFull(stream, buf)
+		if n > 0 {
+			headers := s.machineHeaders()
+			headers["Content-Type"] = "application/octet-stream"
+
+			_, err := s.inferable.FetchData(FetchDataOptions{
+				Path:        fmt.Sprintf("/jobs/%s/result/chunks", jobID),
+				Method:      "POST",
+				Body:        string(buf[:n]),
+				Headers:     headers,
+				QueryParams: map[string]string{"index": strconv.Itoa(chunks)},
+				Context:     ctx,
+			})
+			if err != nil {
+				return CallResult{}, fmt.Errorf("failed to upload result chunk %d: %w", chunks, err)
+			}
+
+			chunks++
+			size += n
+		}
+
+		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+			break
+		}
+		if readErr != nil {
+			// The stream failed part way, so the call is rejected rather than resolved with a partial result
+			return rejectionResult(fmt.Errorf("failed to read result stream: %v", readErr))
+		}
+	}
+
+	summary, err := json.Marshal(map[string]int{"chunks": chunks, "size": size})
+	if err != nil {
+		return CallResult{}, fmt.Errorf("failed to marshal result summary: %v", err)
+	}
+
+	return CallResult{Value: string(summary), Type: "resolution"}, nil
+}
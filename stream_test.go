@@ -0,0 +1,88 @@
+package inferable
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamedResult(t *testing.T) {
+	var mu sync.Mutex
+	chunks := map[string][]string{}
+	persisted := map[string]persistedResult{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/result/chunks"):
+			assert.Equal(t, "application/octet-stream", r.Header.Get("Content-Type"))
+			body, _ := io.ReadAll(r.Body)
+			chunks[r.URL.Path] = append(chunks[r.URL.Path], r.URL.Query().Get("index")+":"+string(body))
+		case strings.HasSuffix(r.URL.Path, "/result"):
+			var result persistedResult
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&result))
+			persisted[r.URL.Path] = result
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	i, err := New(InferableOptions{
+		APIEndpoint: server.URL,
+		APISecret:   "test-secret",
+	})
+	require.NoError(t, err)
+
+	service, err := i.RegisterServiceWithOptions("streaming", ServiceOptions{ResultChunkSize: 4})
+	require.NoError(t, err)
+
+	type TestInput struct{}
+
+	require.NoError(t, service.RegisterFunc(Function{
+		Name: "Reader",
+		Func: func(input TestInput) (io.Reader, error) {
+			return strings.NewReader("0123456789"), nil
+		},
+	}))
+	require.NoError(t, service.RegisterFunc(Function{
+		Name: "Channel",
+		Func: func(ctx context.Context, input TestInput) <-chan []byte {
+			chunks := make(chan []byte)
+			Go(ctx, func(ctx context.Context) error {
+				defer close(chunks)
+				chunks <- []byte("ab")
+				chunks <- []byte("cdef")
+				return nil
+			})
+			return chunks
+		},
+	}))
+
+	for _, fn := range []string{"Reader", "Channel"} {
+		body := `{"value": {"id": "` + fn + `", "service": "streaming", "targetFn": "` + fn + `", "targetArgs": "{\"value\": {}}"}}`
+		require.NoError(t, service.handleMessage(&sqs.Message{Body: aws.String(body)}, time.Now()))
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	assert.Equal(t, []string{"0:0123", "1:4567", "2:89"}, chunks["/jobs/Reader/result/chunks"])
+	assert.JSONEq(t, `{"value": {"chunks": 3, "size": 10}}`, persisted["/jobs/Reader/result"].Result)
+	assert.True(t, persisted["/jobs/Reader/result"].Meta.Chunked)
+
+	assert.Equal(t, []string{"0:abcd", "1:ef"}, chunks["/jobs/Channel/result/chunks"])
+	assert.JSONEq(t, `{"value": {"chunks": 2, "size": 6}}`, persisted["/jobs/Channel/result"].Result)
+}
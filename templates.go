@@ -0,0 +1,108 @@
+package inferable
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// PromptTemplate is a reusable run configuration stored in the cluster
+type PromptTemplate struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Prompt string `json:"initialPrompt"`
+	// Functions the runs created from the template may call, as "service.function"
+	Functions []string `json:"attachedFunctions,omitempty"`
+}
+
+type PromptTemplateInput struct {
+	Name   string `json:"name"`
+	Prompt string `json:"initialPrompt"`
+	// Functions the runs created from the template may call, as "service.function"
+	Functions []string `json:"attachedFunctions,omitempty"`
+}
+
+func (input PromptTemplateInput) validate() error {
+	if input.Name == "" {
+		return fmt.Errorf("prompt template name is required")
+	}
+
+	if input.Prompt == "" {
+		return fmt.Errorf("prompt template '%s' must have a prompt", input.Name)
+	}
+
+	for _, function := range input.Functions {
+		if service, fn, ok := strings.Cut(function, "."); !ok || service == "" || fn == "" {
+			return fmt.Errorf("prompt template '%s' references function '%s', expected 'service.function'", input.Name, function)
+		}
+	}
+
+	return nil
+}
+
+// CreatePromptTemplate creates a prompt template in the cluster
+func (i *Inferable) CreatePromptTemplate(input PromptTemplateInput) (*PromptTemplate, error) {
+	return i.savePromptTemplate("POST", fmt.Sprintf("/clusters/%s/prompt-templates", i.clusterID), input)
+}
+
+// UpdatePromptTemplate replaces the configuration of an existing prompt template
+func (i *Inferable) UpdatePromptTemplate(id string, input PromptTemplateInput) (*PromptTemplate, error) {
+	if id == "" {
+		return nil, fmt.Errorf("prompt template ID is required")
+	}
+
+	return i.savePromptTemplate("PUT", fmt.Sprintf("/clusters/%s/prompt-templates/%s", i.clusterID, id), input)
+}
+
+// ListPromptTemplates returns the prompt templates of the cluster
+func (i *Inferable) ListPromptTemplates() ([]PromptTemplate, error) {
+	if i.clusterID == "" {
+		return nil, fmt.Errorf("cluster ID must be provided to manage prompt templates")
+	}
+
+	responseData, err := i.FetchData(FetchDataOptions{
+		Path:   fmt.Sprintf("/clusters/%s/prompt-templates", i.clusterID),
+		Method: "GET",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list prompt templates: %w", err)
+	}
+
+	templates := []PromptTemplate{}
+	if err := json.Unmarshal(responseData, &templates); err != nil {
+		return nil, fmt.Errorf("failed to parse prompt templates: %v", err)
+	}
+
+	return templates, nil
+}
+
+func (i *Inferable) savePromptTemplate(method string, path string, input PromptTemplateInput) (*PromptTemplate, error) {
+	if i.clusterID == "" {
+		return nil, fmt.Errorf("cluster ID must be provided to manage prompt templates")
+	}
+
+	if err := input.validate(); err != nil {
+		return nil, err
+	}
+
+	jsonPayload, err := json.Marshal(input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal prompt template: %v", err)
+	}
+
+	responseData, err := i.FetchData(FetchDataOptions{
+		Path:   path,
+		Method: method,
+		Body:   string(jsonPayload),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save prompt template '%s': %w", input.Name, err)
+	}
+
+	template := &PromptTemplate{}
+	if err := json.Unmarshal(responseData, template); err != nil {
+		return nil, fmt.Errorf("failed to parse prompt template response: %v", err)
+	}
+
+	return template, nil
+}
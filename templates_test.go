@@ -0,0 +1,65 @@
+package inferable
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPromptTemplates(t *testing.T) {
+	var saved []map[string]interface{}
+	var methods []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/clusters/test-cluster/prompt-templates", "/clusters/test-cluster/prompt-templates/tpl-1":
+			if r.Method == "GET" {
+				w.Write([]byte(`[{"id": "tpl-1", "name": "triage", "initialPrompt": "Triage the ticket", "attachedFunctions": ["support.getTicket"]}]`))
+				return
+			}
+
+			var payload map[string]interface{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+			saved = append(saved, payload)
+			methods = append(methods, r.Method)
+			w.Write([]byte(`{"id": "tpl-1", "name": "triage", "initialPrompt": "` + payload["initialPrompt"].(string) + `"}`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer server.Close()
+
+	i, err := New(InferableOptions{
+		APIEndpoint: server.URL,
+		APISecret:   "test-secret",
+		ClusterID:   "test-cluster",
+	})
+	require.NoError(t, err)
+
+	template, err := i.CreatePromptTemplate(PromptTemplateInput{
+		Name:      "triage",
+		Prompt:    "Triage the ticket",
+		Functions: []string{"support.getTicket"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "tpl-1", template.ID)
+
+	template, err = i.UpdatePromptTemplate("tpl-1", PromptTemplateInput{Name: "triage", Prompt: "Triage the ticket urgently"})
+	require.NoError(t, err)
+	assert.Equal(t, "Triage the ticket urgently", template.Prompt)
+
+	assert.Equal(t, []string{"POST", "PUT"}, methods)
+	assert.Equal(t, []interface{}{"support.getTicket"}, saved[0]["attachedFunctions"])
+
+	templates, err := i.ListPromptTemplates()
+	require.NoError(t, err)
+	require.Len(t, templates, 1)
+	assert.Equal(t, []string{"support.getTicket"}, templates[0].Functions)
+
+	_, err = i.CreatePromptTemplate(PromptTemplateInput{Name: "triage", Prompt: "x", Functions: []string{"getTicket"}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,42 @@
+package inferable
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ToolError rejects a call with a structured error, so that agents can tell failure modes apart
+// (e.g. "not found" from "rate limited") instead of parsing a message. Functions return it, or an
+// error wrapping it, and it is persisted as the rejection instead of the error message.
+// Code should be one of the FunctionConfig.ErrorCodes declared for the function.
+type ToolError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// Retryable tells the agent that the call may succeed if it is made again later
+	Retryable bool        `json:"retryable"`
+	Details   interface{} `json:"details,omitempty"`
+	// Err is the underlying error. It is not sent to the cluster.
+	Err error `json:"-"`
+}
+
+func (e *ToolError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Message, e.Err)
+	}
+
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func (e *ToolError) Unwrap() error {
+	return e.Err
+}
+
+// ToolError returns the structured error the call was rejected with, if the function returned a ToolError
+func (e *CallRejectedError) ToolError() (*ToolError, bool) {
+	toolErr := &ToolError{}
+	if err := json.Unmarshal(e.Value, toolErr); err != nil || toolErr.Code == "" {
+		return nil, false
+	}
+
+	return toolErr, true
+}
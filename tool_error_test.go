@@ -0,0 +1,47 @@
+package inferable
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToolError(t *testing.T) {
+	cause := errors.New("connection refused")
+	lookup := func(input struct{ ID string }) (string, error) {
+		return "", fmt.Errorf("lookup failed: %w", &ToolError{
+			Code:      "UPSTREAM_UNAVAILABLE",
+			Message:   "the user directory is unavailable",
+			Retryable: true,
+			Details:   map[string]string{"id": input.ID},
+			Err:       cause,
+		})
+	}
+
+	s := &Service{}
+	result, err := s.prepareResult(reflect.ValueOf(lookup).Call([]reflect.Value{reflect.ValueOf(struct{ ID string }{ID: "1"})}), nil)
+	require.NoError(t, err)
+	assert.Equal(t, "rejection", result.Type)
+	assert.JSONEq(t, `{
+		"code": "UPSTREAM_UNAVAILABLE",
+		"message": "the user directory is unavailable",
+		"retryable": true,
+		"details": {"id": "1"}
+	}`, result.Value)
+
+	rejected := &CallRejectedError{Value: json.RawMessage(result.Value)}
+	toolErr, ok := rejected.ToolError()
+	require.True(t, ok)
+	assert.Equal(t, "UPSTREAM_UNAVAILABLE", toolErr.Code)
+	assert.True(t, toolErr.Retryable)
+
+	_, ok = (&CallRejectedError{Value: json.RawMessage(`"not found"`)}).ToolError()
+	assert.False(t, ok)
+
+	assert.ErrorIs(t, &ToolError{Code: "X", Err: cause}, cause)
+}
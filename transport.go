@@ -0,0 +1,103 @@
+package inferable
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	// DefaultMaxIdleConnsPerHost is the number of idle connections kept open to the API, so that
+	// concurrent polls and result submissions reuse connections rather than opening new ones
+	DefaultMaxIdleConnsPerHost = 16
+	// DefaultMaxIdleConns is the number of idle connections kept open across all hosts
+	DefaultMaxIdleConns = 100
+	// DefaultIdleConnTimeout is how long an idle connection is kept open
+	DefaultIdleConnTimeout = 90 * time.Second
+	// DefaultKeepAlive is the interval between TCP keep-alive probes of open connections
+	DefaultKeepAlive = 30 * time.Second
+)
+
+// TransportOptions tunes the connections made to the API. The zero value applies the defaults.
+type TransportOptions struct {
+	// MaxIdleConnsPerHost defaults to DefaultMaxIdleConnsPerHost
+	MaxIdleConnsPerHost int
+	// MaxIdleConns defaults to DefaultMaxIdleConns
+	MaxIdleConns int
+	// IdleConnTimeout defaults to DefaultIdleConnTimeout
+	IdleConnTimeout time.Duration
+	// KeepAlive is the interval between TCP keep-alive probes. Defaults to DefaultKeepAlive.
+	// A negative value disables keep-alive probes.
+	KeepAlive time.Duration
+	// DisableKeepAlives opens a new connection for every request
+	DisableKeepAlives bool
+	// DisableHTTP2 restricts connections to HTTP/1.1, e.g. for proxies which do not support HTTP/2
+	DisableHTTP2 bool
+	// TLSConfig configures TLS, e.g. custom root CAs, the minimum version or client certificates for
+	// self-hosted deployments behind a private PKI. Defaults to the system root CAs.
+	TLSConfig *tls.Config
+	// ClientCertificate authenticates the machine with mutual TLS
+	ClientCertificate *ClientCertificate
+}
+
+// customTLS reports whether the options change the TLS configuration of connections
+func (o TransportOptions) customTLS() bool {
+	return o.TLSConfig != nil || o.ClientCertificate != nil
+}
+
+// newTransport creates the transport of the API client
+func (o TransportOptions) newTransport() (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	keepAlive := o.KeepAlive
+	if keepAlive == 0 {
+		keepAlive = DefaultKeepAlive
+	}
+	transport.DialContext = (&net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: keepAlive,
+	}).DialContext
+
+	transport.MaxIdleConnsPerHost = o.MaxIdleConnsPerHost
+	if transport.MaxIdleConnsPerHost <= 0 {
+		transport.MaxIdleConnsPerHost = DefaultMaxIdleConnsPerHost
+	}
+
+	transport.MaxIdleConns = o.MaxIdleConns
+	if transport.MaxIdleConns <= 0 {
+		transport.MaxIdleConns = DefaultMaxIdleConns
+	}
+
+	transport.IdleConnTimeout = o.IdleConnTimeout
+	if transport.IdleConnTimeout <= 0 {
+		transport.IdleConnTimeout = DefaultIdleConnTimeout
+	}
+
+	transport.DisableKeepAlives = o.DisableKeepAlives
+
+	if o.TLSConfig != nil {
+		transport.TLSClientConfig = o.TLSConfig.Clone()
+	}
+
+	if o.ClientCertificate != nil {
+		loader, err := newCertificateLoader(*o.ClientCertificate)
+		if err != nil {
+			return nil, err
+		}
+		if transport.TLSClientConfig == nil {
+			transport.TLSClientConfig = &tls.Config{}
+		}
+		transport.TLSClientConfig.GetClientCertificate = loader.getClientCertificate
+	}
+
+	if o.DisableHTTP2 {
+		transport.ForceAttemptHTTP2 = false
+		// A non-nil, empty map disables HTTP/2
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	} else {
+		transport.ForceAttemptHTTP2 = true
+	}
+
+	return transport, nil
+}
@@ -0,0 +1,87 @@
+package inferable
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransportOptions(t *testing.T) {
+	transport, err := TransportOptions{}.newTransport()
+	require.NoError(t, err)
+	assert.Equal(t, DefaultMaxIdleConnsPerHost, transport.MaxIdleConnsPerHost)
+	assert.Equal(t, DefaultMaxIdleConns, transport.MaxIdleConns)
+	assert.Equal(t, DefaultIdleConnTimeout, transport.IdleConnTimeout)
+	assert.True(t, transport.ForceAttemptHTTP2)
+
+	transport, err = TransportOptions{MaxIdleConnsPerHost: 4, IdleConnTimeout: time.Minute, DisableHTTP2: true}.newTransport()
+	require.NoError(t, err)
+	assert.Equal(t, 4, transport.MaxIdleConnsPerHost)
+	assert.Equal(t, time.Minute, transport.IdleConnTimeout)
+	assert.False(t, transport.ForceAttemptHTTP2)
+	assert.NotNil(t, transport.TLSNextProto)
+	assert.Empty(t, transport.TLSNextProto)
+}
+
+func TestConnectionReuse(t *testing.T) {
+	var mu sync.Mutex
+	connections := 0
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			mu.Lock()
+			connections++
+			mu.Unlock()
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	client, err := NewClient(ClientOptions{Endpoint: server.URL, Secret: "secret"})
+	require.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		_, err := client.FetchData(FetchDataOptions{Path: "/live", Method: "GET"})
+		require.NoError(t, err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 1, connections)
+}
+
+func TestCustomTLSConfig(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	// The certificate of the server is not trusted by the system root CAs
+	client, err := NewClient(ClientOptions{Endpoint: server.URL, Secret: "secret"})
+	require.NoError(t, err)
+	_, err = client.FetchData(FetchDataOptions{Path: "/live", Method: "GET"})
+	assert.Error(t, err)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+
+	client, err = NewClient(ClientOptions{
+		Endpoint:  server.URL,
+		Secret:    "secret",
+		Transport: TransportOptions{TLSConfig: &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12}},
+	})
+	require.NoError(t, err)
+	_, err = client.FetchData(FetchDataOptions{Path: "/live", Method: "GET"})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,200 @@
+package inferable
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/invopop/jsonschema"
+)
+
+// Union holds a value of one of the variants registered for the interface T with RegisterUnion.
+// Input fields of type Union[T] are described by a oneOf schema, and decoded into the variant named by
+// the discriminator property of the input.
+type Union[T any] struct {
+	Value T
+}
+
+// unionVariants are the variants of an interface, keyed by the value of their discriminator property
+type unionVariants struct {
+	discriminator string
+	variants      map[string]reflect.Type
+	schema        *jsonschema.Schema
+}
+
+// unions holds the variants registered for interfaces
+var unions sync.Map // reflect.Type -> *unionVariants
+
+// unionType is implemented by Union, so that its schema is built from the registered variants
+type unionType interface {
+	unionSchema() (*jsonschema.Schema, error)
+}
+
+// RegisterUnion registers the variants of the interface T, keyed by the value of the discriminator property
+// which selects them, e.g. RegisterUnion[Shape]("kind", map[string]Shape{"circle": Circle{}, "square": &Square{}}).
+// Variants must be structs, or pointers to structs.
+func RegisterUnion[T any](discriminator string, variants map[string]T) error {
+	ifaceType := reflect.TypeOf((*T)(nil)).Elem()
+	if ifaceType.Kind() != reflect.Interface {
+		return fmt.Errorf("union type %s must be an interface", ifaceType)
+	}
+	if discriminator == "" {
+		return fmt.Errorf("union %s must have a discriminator", ifaceType)
+	}
+	if len(variants) == 0 {
+		return fmt.Errorf("union %s must have at least one variant", ifaceType)
+	}
+
+	union := &unionVariants{
+		discriminator: discriminator,
+		variants:      map[string]reflect.Type{},
+		schema:        &jsonschema.Schema{},
+	}
+
+	names := make([]string, 0, len(variants))
+	for name := range variants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		variantType := reflect.TypeOf(variants[name])
+		if variantType == nil {
+			return fmt.Errorf("variant '%s' of union %s must not be nil", name, ifaceType)
+		}
+
+		structType := variantType
+		if structType.Kind() == reflect.Ptr {
+			structType = structType.Elem()
+		}
+		if structType.Kind() != reflect.Struct {
+			return fmt.Errorf("variant '%s' of union %s must be a struct", name, ifaceType)
+		}
+
+		schema, err := variantSchema(structType, discriminator, name)
+		if err != nil {
+			return fmt.Errorf("variant '%s' of union %s: %v", name, ifaceType, err)
+		}
+
+		union.variants[name] = variantType
+		union.schema.OneOf = append(union.schema.OneOf, schema)
+	}
+
+	unions.Store(ifaceType, union)
+	return nil
+}
+
+// variantSchema returns the schema of a variant, with its discriminator property fixed to its name
+func variantSchema(structType reflect.Type, discriminator, name string) (*jsonschema.Schema, error) {
+	reflected, err := reflectSchema(structType.Name(), structType)
+	if err != nil {
+		return nil, err
+	}
+
+	// Reflected schemas are cached and shared, so they are copied before they are modified
+	schema, err := withFieldSchemas(reflected, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if schema.Properties == nil {
+		schema.Properties = jsonschema.NewProperties()
+	}
+	schema.Properties.Set(discriminator, &jsonschema.Schema{Type: "string", Const: name})
+
+	for _, required := range schema.Required {
+		if required == discriminator {
+			return schema, nil
+		}
+	}
+	schema.Required = append([]string{discriminator}, schema.Required...)
+
+	return schema, nil
+}
+
+func lookupUnion(ifaceType reflect.Type) (*unionVariants, error) {
+	union, ok := unions.Load(ifaceType)
+	if !ok {
+		return nil, fmt.Errorf("no variants registered for union %s", ifaceType)
+	}
+
+	return union.(*unionVariants), nil
+}
+
+func (Union[T]) unionSchema() (*jsonschema.Schema, error) {
+	union, err := lookupUnion(reflect.TypeOf((*T)(nil)).Elem())
+	if err != nil {
+		return nil, err
+	}
+
+	return union.schema, nil
+}
+
+// UnmarshalJSON decodes the variant named by the discriminator property
+func (u *Union[T]) UnmarshalJSON(data []byte) error {
+	union, err := lookupUnion(reflect.TypeOf((*T)(nil)).Elem())
+	if err != nil {
+		return err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	var name string
+	if err := json.Unmarshal(fields[union.discriminator], &name); err != nil || name == "" {
+		return fmt.Errorf("missing discriminator '%s'", union.discriminator)
+	}
+
+	variantType, ok := union.variants[name]
+	if !ok {
+		return fmt.Errorf("unknown variant '%s' of discriminator '%s'", name, union.discriminator)
+	}
+
+	var value reflect.Value
+	if variantType.Kind() == reflect.Ptr {
+		value = reflect.New(variantType.Elem())
+		if err := json.Unmarshal(data, value.Interface()); err != nil {
+			return err
+		}
+	} else {
+		ptr := reflect.New(variantType)
+		if err := json.Unmarshal(data, ptr.Interface()); err != nil {
+			return err
+		}
+		value = ptr.Elem()
+	}
+
+	u.Value = value.Interface().(T)
+	return nil
+}
+
+// MarshalJSON encodes the value with its discriminator property
+func (u Union[T]) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(u.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	union, err := lookupUnion(reflect.TypeOf((*T)(nil)).Elem())
+	if err != nil {
+		return data, nil
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil || fields == nil {
+		return data, nil
+	}
+
+	valueType := reflect.TypeOf(u.Value)
+	for name, variantType := range union.variants {
+		if variantType == valueType {
+			fields[union.discriminator] = name
+		}
+	}
+
+	return json.Marshal(fields)
+}
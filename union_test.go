@@ -0,0 +1,111 @@
+package inferable
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testShape interface {
+	Area() float64
+}
+
+type testCircle struct {
+	Radius float64 `json:"radius"`
+}
+
+func (c testCircle) Area() float64 { return 3 * c.Radius * c.Radius }
+
+type testRectangle struct {
+	Kind   string  `json:"kind"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+func (r *testRectangle) Area() float64 { return r.Width * r.Height }
+
+type testUnregistered interface {
+	Unregistered()
+}
+
+func TestUnion(t *testing.T) {
+	require.NoError(t, RegisterUnion[testShape]("kind", map[string]testShape{
+		"circle":    testCircle{},
+		"rectangle": &testRectangle{},
+	}))
+
+	i, err := New(InferableOptions{
+		APIEndpoint: DefaultAPIEndpoint,
+		APISecret:   "test-secret",
+	})
+	require.NoError(t, err)
+
+	type AreaInput struct {
+		Shape Union[testShape] `json:"shape"`
+	}
+
+	require.NoError(t, i.Default.RegisterFunc(Function{
+		Name: "area",
+		Func: func(input AreaInput) float64 { return input.Shape.Value.Area() },
+	}))
+
+	schema, err := json.Marshal(i.Default.Functions["area"].schema)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"type": "object",
+		"properties": {
+			"shape": {"oneOf": [
+				{
+					"type": "object",
+					"properties": {"radius": {"type": "number"}, "kind": {"type": "string", "const": "circle"}},
+					"required": ["kind", "radius"]
+				},
+				{
+					"type": "object",
+					"properties": {
+						"kind": {"type": "string", "const": "rectangle"},
+						"width": {"type": "number"},
+						"height": {"type": "number"}
+					},
+					"required": ["kind", "width", "height"]
+				}
+			]}
+		},
+		"required": ["shape"]
+	}`, string(schema))
+
+	compiled := i.Default.Functions["area"].compiled()
+
+	arg, err := compiled.decodeArgs([]byte(`{"value": {"shape": {"kind": "circle", "radius": 2}}}`))
+	require.NoError(t, err)
+	assert.Equal(t, float64(12), compiled.call(context.Background(), arg.Elem())[0].Interface())
+
+	arg, err = compiled.decodeArgs([]byte(`{"value": {"shape": {"kind": "rectangle", "width": 2, "height": 3}}}`))
+	require.NoError(t, err)
+	assert.Equal(t, &testRectangle{Kind: "rectangle", Width: 2, Height: 3}, arg.Elem().Interface().(AreaInput).Shape.Value)
+
+	_, err = compiled.decodeArgs([]byte(`{"value": {"shape": {"kind": "triangle"}}}`))
+	assert.ErrorContains(t, err, "unknown variant 'triangle'")
+
+	_, err = compiled.decodeArgs([]byte(`{"value": {"shape": {"radius": 2}}}`))
+	assert.ErrorContains(t, err, "missing discriminator 'kind'")
+
+	encoded, err := json.Marshal(Union[testShape]{Value: testCircle{Radius: 1}})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"kind": "circle", "radius": 1}`, string(encoded))
+
+	type UnregisteredInput struct {
+		Value Union[testUnregistered] `json:"value"`
+	}
+	err = i.Default.RegisterFunc(Function{
+		Name: "unregistered",
+		Func: func(input UnregisteredInput) string { return "ok" },
+	})
+	assert.ErrorContains(t, err, "no variants registered for union inferable.testUnregistered")
+
+	assert.Error(t, RegisterUnion[testCircle]("kind", map[string]testCircle{"circle": {}}))
+	assert.Error(t, RegisterUnion[testShape]("", map[string]testShape{"circle": testCircle{}}))
+}
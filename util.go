@@ -3,15 +3,20 @@ package inferable
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"math/rand"
 	"os"
+	"path/filepath"
 	"runtime"
 	"strings"
 )
 
 const MachineIDFile = "inferable_machine_id.json"
 
+// MachineIDEnvVar can be used to set the machine ID when it is not provided in InferableOptions
+const MachineIDEnvVar = "INFERABLE_MACHINE_ID"
+
 func getMachineID() string {
 	hostname, _ := os.Hostname()
 	cpuInfo := runtime.GOARCH + runtime.GOOS + runtime.Version()
@@ -39,3 +44,59 @@ func generateMachineID(length int) string {
 
 	return fmt.Sprintf("go-%s", sb.String())
 }
+
+// loadOrCreateMachineID reads the machine ID persisted at path.
+// If path is a directory, MachineIDFile is used within it.
+// If no machine ID has been persisted yet, a new one is generated and written to path.
+func loadOrCreateMachineID(path string) (string, error) {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		path = filepath.Join(path, MachineIDFile)
+	}
+
+	var persisted struct {
+		MachineID string `json:"machineId"`
+	}
+
+	data, err := os.ReadFile(path)
+	if err == nil {
+		if err := json.Unmarshal(data, &persisted); err != nil {
+			return "", fmt.Errorf("failed to parse machine ID file %s: %v", path, err)
+		}
+		if persisted.MachineID != "" {
+			return persisted.MachineID, nil
+		}
+	} else if !os.IsNotExist(err) {
+		return "", fmt.Errorf("failed to read machine ID file %s: %v", path, err)
+	}
+
+	persisted.MachineID = generateMachineID(8)
+
+	data, err = json.Marshal(persisted)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal machine ID: %v", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", fmt.Errorf("failed to create machine ID directory: %v", err)
+	}
+
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return "", fmt.Errorf("failed to write machine ID file %s: %v", path, err)
+	}
+
+	return persisted.MachineID, nil
+}
+
+// validateLabels checks that capability labels are non-empty and contain no whitespace
+func validateLabels(labels []string) error {
+	for _, label := range labels {
+		if label == "" {
+			return fmt.Errorf("label cannot be empty")
+		}
+		if strings.ContainsAny(label, " \t\n") {
+			return fmt.Errorf("label '%s' cannot contain whitespace", label)
+		}
+	}
+
+	return nil
+}
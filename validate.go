@@ -0,0 +1,233 @@
+package inferable
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/invopop/jsonschema"
+)
+
+// InputValidator validates the decoded input of a call before the function is called, e.g. the Struct
+// method of a go-playground/validator Validate. Errors are returned to the agent as ToolErrors with
+// the code ValidationErrorCode.
+type InputValidator func(input interface{}) error
+
+// ValidationErrorCode is the code of the ToolError calls are rejected with when their input is invalid
+const ValidationErrorCode = "VALIDATION_FAILED"
+
+// ValidationIssue is a failed rule of an input field, included in the details of validation rejections
+type ValidationIssue struct {
+	Field string `json:"field"`
+	Rule  string `json:"rule"`
+	Param string `json:"param,omitempty"`
+}
+
+// fieldError matches the field errors of go-playground/validator, without depending on it
+type fieldError interface {
+	Field() string
+	Tag() string
+	Param() string
+}
+
+// validationRejection returns the ToolError a call with invalid input is rejected with
+func validationRejection(err error) *ToolError {
+	toolErr := &ToolError{Code: ValidationErrorCode, Message: err.Error(), Err: err}
+	if issues := validationIssues(err); len(issues) > 0 {
+		toolErr.Details = issues
+	}
+	return toolErr
+}
+
+// validationIssues extracts the failed rules from validator errors, which are slices of field errors
+func validationIssues(err error) []ValidationIssue {
+	issues := []ValidationIssue{}
+	for ; err != nil; err = errors.Unwrap(err) {
+		value := reflect.ValueOf(err)
+		if value.Kind() != reflect.Slice {
+			continue
+		}
+		for idx := 0; idx < value.Len(); idx++ {
+			if fieldErr, ok := value.Index(idx).Interface().(fieldError); ok {
+				issues = append(issues, ValidationIssue{Field: fieldErr.Field(), Rule: fieldErr.Tag(), Param: fieldErr.Param()})
+			}
+		}
+		return issues
+	}
+	return issues
+}
+
+// applyValidateTags adds the constraints of the go-playground/validator `validate` tags of the fields
+// of structType to the properties of its schema
+func applyValidateTags(schema *jsonschema.Schema, structType reflect.Type) {
+	if schema.Properties == nil {
+		return
+	}
+
+	for idx := 0; idx < structType.NumField(); idx++ {
+		field := structType.Field(idx)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property, ok := schema.Properties.Get(name)
+		// Properties without a type may be shared schemas, such as those of unions
+		if !ok || property == nil || property.Type == "" {
+			continue
+		}
+
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if fieldType.Kind() == reflect.Struct && property.Type == "object" {
+			applyValidateTags(property, fieldType)
+		}
+
+		if tag := field.Tag.Get("validate"); tag != "" {
+			if applyValidateTag(property, tag) && !contains(schema.Required, name) {
+				schema.Required = append(schema.Required, name)
+			}
+		}
+	}
+}
+
+// applyValidateTag applies the rules of a validate tag to the schema of a field, returning whether the
+// field is required. Rules without a schema equivalent are left to the InputValidator.
+func applyValidateTag(schema *jsonschema.Schema, tag string) bool {
+	required := false
+
+	for _, rule := range strings.Split(tag, ",") {
+		key, param, _ := strings.Cut(rule, "=")
+		// Rules after dive apply to the elements, and alternatives cannot be expressed per keyword
+		if key == "dive" {
+			break
+		}
+		if strings.Contains(rule, "|") {
+			continue
+		}
+
+		switch key {
+		case "required":
+			required = true
+		case "email":
+			schema.Format = "email"
+		case "url", "uri":
+			schema.Format = "uri"
+		case "uuid":
+			schema.Format = "uuid"
+		case "regexp":
+			schema.Pattern = param
+		case "oneof":
+			schema.Enum = nil
+			for _, value := range strings.Fields(param) {
+				schema.Enum = append(schema.Enum, enumValue(schema.Type, value))
+			}
+		case "len":
+			setLimit(schema, param, true, true)
+		case "min", "gte":
+			setLimit(schema, param, true, false)
+		case "max", "lte":
+			setLimit(schema, param, false, true)
+		case "gt":
+			if isNumeric(schema.Type) && validNumber(param) {
+				schema.ExclusiveMinimum = json.Number(param)
+			}
+		case "lt":
+			if isNumeric(schema.Type) && validNumber(param) {
+				schema.ExclusiveMaximum = json.Number(param)
+			}
+		}
+	}
+
+	return required
+}
+
+// setLimit sets the lower and/or upper bound of a field, which limits the value of numbers,
+// the length of strings and the size of arrays and objects
+func setLimit(schema *jsonschema.Schema, param string, lower, upper bool) {
+	if isNumeric(schema.Type) {
+		if !validNumber(param) {
+			return
+		}
+		if lower {
+			schema.Minimum = json.Number(param)
+		}
+		if upper {
+			schema.Maximum = json.Number(param)
+		}
+		return
+	}
+
+	size, err := strconv.ParseUint(param, 10, 64)
+	if err != nil {
+		return
+	}
+
+	switch schema.Type {
+	case "string":
+		if lower {
+			schema.MinLength = &size
+		}
+		if upper {
+			schema.MaxLength = &size
+		}
+	case "array":
+		if lower {
+			schema.MinItems = &size
+		}
+		if upper {
+			schema.MaxItems = &size
+		}
+	case "object":
+		if lower {
+			schema.MinProperties = &size
+		}
+		if upper {
+			schema.MaxProperties = &size
+		}
+	}
+}
+
+func isNumeric(schemaType string) bool {
+	return schemaType == "integer" || schemaType == "number"
+}
+
+func validNumber(param string) bool {
+	_, err := strconv.ParseFloat(param, 64)
+	return err == nil
+}
+
+// enumValue converts a oneof value to the type of the field
+func enumValue(schemaType, value string) interface{} {
+	if isNumeric(schemaType) {
+		if number, err := strconv.ParseFloat(value, 64); err == nil {
+			return number
+		}
+	}
+	return value
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// validateInput validates the input of a call with the InputValidator of the service, if there is one
+func (s *Service) validateInput(input interface{}) error {
+	if s.options.InputValidator == nil {
+		return nil
+	}
+
+	return s.options.InputValidator(input)
+}
@@ -0,0 +1,104 @@
+package inferable
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testFieldError and testValidationErrors mirror the errors of go-playground/validator
+type testFieldError struct {
+	field, tag, param string
+}
+
+func (e testFieldError) Field() string { return e.field }
+func (e testFieldError) Tag() string   { return e.tag }
+func (e testFieldError) Param() string { return e.param }
+
+type testValidationErrors []testFieldError
+
+func (e testValidationErrors) Error() string {
+	return "Key: 'SignupInput.Age' Error:Field validation for 'Age' failed on the 'gte' tag"
+}
+
+type SignupInput struct {
+	Email    string   `json:"email" validate:"required,email"`
+	Name     string   `json:"name,omitempty" validate:"required,min=2,max=50"`
+	Age      int      `json:"age,omitempty" validate:"gte=18,lt=130"`
+	Plan     string   `json:"plan" validate:"oneof=free pro"`
+	Code     string   `json:"code" validate:"len=6,regexp=^[0-9]+$"`
+	Tags     []string `json:"tags" validate:"max=3,dive,min=1"`
+	Referrer string   `json:"referrer" validate:"omitempty,url|email"`
+}
+
+func TestValidateTags(t *testing.T) {
+	var persisted persistedResult
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/jobs/call-1/result" {
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&persisted))
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	i, err := New(InferableOptions{
+		APIEndpoint: server.URL,
+		APISecret:   "test-secret",
+	})
+	require.NoError(t, err)
+
+	service, err := i.RegisterServiceWithOptions("signup", ServiceOptions{
+		InputValidator: func(input interface{}) error {
+			if input.(SignupInput).Age < 18 {
+				return testValidationErrors{{field: "Age", tag: "gte", param: "18"}}
+			}
+			return nil
+		},
+	})
+	require.NoError(t, err)
+
+	called := false
+	require.NoError(t, service.RegisterFunc(Function{
+		Name: "signup",
+		Func: func(input SignupInput) string {
+			called = true
+			return "ok"
+		},
+	}))
+
+	schema, err := json.Marshal(service.Functions["signup"].schema)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"type": "object",
+		"properties": {
+			"email": {"type": "string", "format": "email"},
+			"name": {"type": "string", "minLength": 2, "maxLength": 50},
+			"age": {"type": "integer", "minimum": 18, "exclusiveMaximum": 130},
+			"plan": {"type": "string", "enum": ["free", "pro"]},
+			"code": {"type": "string", "minLength": 6, "maxLength": 6, "pattern": "^[0-9]+$"},
+			"tags": {"type": "array", "items": {"type": "string"}, "maxItems": 3},
+			"referrer": {"type": "string"}
+		},
+		"required": ["email", "plan", "code", "tags", "referrer", "name"]
+	}`, string(schema))
+
+	body := `{"value": {"id": "call-1", "service": "signup", "targetFn": "signup", "targetArgs": "{\"value\": {\"email\": \"a@example.com\", \"age\": 16}}"}}`
+	require.NoError(t, service.handleMessage(&sqs.Message{Body: aws.String(body)}, time.Now()))
+
+	assert.False(t, called)
+	assert.Equal(t, "rejection", persisted.ResultType)
+
+	var rejection struct {
+		Value ToolError `json:"value"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(persisted.Result), &rejection))
+	assert.Equal(t, ValidationErrorCode, rejection.Value.Code)
+	assert.Equal(t, []interface{}{map[string]interface{}{"field": "Age", "rule": "gte", "param": "18"}}, rejection.Value.Details)
+}
@@ -0,0 +1,193 @@
+package inferable
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"time"
+)
+
+const (
+	DefaultWatchdogInterval        = 1 * time.Minute
+	DefaultWatchdogMissedIntervals = 3
+)
+
+func (s *Service) watchdogInterval() time.Duration {
+	if s.options.WatchdogInterval == 0 {
+		return DefaultWatchdogInterval
+	}
+
+	return s.options.WatchdogInterval
+}
+
+func (s *Service) watchdogMissedIntervals() int {
+	if s.options.WatchdogMissedIntervals <= 0 {
+		return DefaultWatchdogMissedIntervals
+	}
+
+	return s.options.WatchdogMissedIntervals
+}
+
+// newConsumer creates the transport delivering calls to the service
+func (s *Service) newConsumer() (Transport, error) {
+	consumer, err := s.newQueueConsumer()
+	if err != nil {
+		return nil, err
+	}
+
+	return consumer, nil
+}
+
+// newQueueConsumer creates an SQS consumer for the queue the service was registered with
+func (s *Service) newQueueConsumer() (*SQSConsumer, error) {
+	credentials := s.sqsCredentials()
+	s.credentialsMu.RLock()
+	region, queueURL := s.region, s.queueURL
+	s.credentialsMu.RUnlock()
+
+	consumer, err := newSQSConsumer(
+		s.inferable.queueEndpoint,
+		region,
+		queueURL,
+		s.handleMessage,
+		credentials.AccessKeyID,
+		credentials.SecretAccessKey,
+		credentials.SessionToken,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create SQS consumer: %v", err)
+	}
+	consumer.SetCredentialsRefresher(credentials, credentialsRefreshWindow, s.refreshCredentials)
+	consumer.SetPollInterval(s.pollInterval())
+	consumer.SetMaxMessages(int64(s.pollLimit()))
+	consumer.SetWaitTime(s.pollWaitTime())
+	consumer.SetMaxPollInterval(s.options.MaxPollInterval)
+	consumer.SetRetryDelay(s.retryDelay())
+	consumer.SetPollTimeout(s.pollTimeout())
+	consumer.SetLogger(leveledLogger{inferable: s.inferable})
+	consumer.SetPanicHandler(func(r interface{}) { s.reportPanic("worker", r) })
+	consumer.SetPollObserver(s.observePoll)
+	consumer.SetPauseFunc(s.IsPaused)
+	consumer.SetConcurrency(s.maxConcurrentCalls())
+	// The rate limit is looked up for every poll, as it may be changed by ApplyRuntimeConfig
+	consumer.SetRateLimiter(s.inferable.client.waitRateLimit)
+	// A self-hosted queue is reached through the same PKI as the API
+	if s.inferable.queueEndpoint != "" && s.inferable.transport.customTLS() {
+		transport, err := s.inferable.transport.newTransport()
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure SQS consumer transport: %v", err)
+		}
+		consumer.SetHTTPClient(&http.Client{Transport: transport})
+	}
+	if s.options.BatchResults {
+		consumer.SetResultBatching(s.beginBatch, s.flushBatch)
+	}
+
+	return consumer, nil
+}
+
+// runConsumer starts the poll loop of consumer in the background. Panics are recovered,
+// leaving the watchdog to restart the loop.
+func (s *Service) runConsumer(consumer Transport) {
+	ctx, cancel := context.WithCancel(s.baseContext())
+	exited := make(chan struct{})
+
+	s.consumerMu.Lock()
+	s.consumer = consumer
+	s.consumerCancel = cancel
+	s.consumerExited = exited
+	s.consumerMu.Unlock()
+
+	// The loop is considered active from now on, even if it has not started yet
+	if toucher, ok := consumer.(interface{ touch() }); ok {
+		toucher.touch()
+	}
+
+	go func() {
+		defer close(exited)
+		defer func() {
+			if r := recover(); r != nil {
+				s.inferable.logf(LogLevelError, "Poll loop of service '%s' panicked: %v", s.Name, r)
+				s.reportPanic("poll loop", r)
+			}
+		}()
+
+		if err := consumer.Start(ctx); err != nil {
+			s.inferable.logf(LogLevelError, "Error starting transport: %v", err)
+			// Stop the service if there's an error starting the consumer, reporting it through Err
+			s.closeDone(fmt.Errorf("poll loop of service '%s' failed: %w", s.Name, err))
+			s.Stop()
+		}
+	}()
+}
+
+// reportPanic reports a panic recovered from the poll loop or one of its workers, unless it is the panic
+// of a call which was reported by handleMessage already
+func (s *Service) reportPanic(where string, r interface{}) {
+	if _, reported := r.(callPanic); reported {
+		return
+	}
+
+	panicErr := fmt.Errorf("%s panicked: %v", where, r)
+	s.reportError(panicErr, ErrorContext{Kind: ErrorKindPanic, Stack: string(debug.Stack())})
+	s.recordPanic(panicErr, "")
+}
+
+// watchdog restarts the poll loop if it has exited or stalled, until ctx is done
+func (s *Service) watchdog(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	missedThreshold := interval * time.Duration(s.watchdogMissedIntervals())
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		s.consumerMu.Lock()
+		consumer, cancel, exited := s.consumer, s.consumerCancel, s.consumerExited
+		s.consumerMu.Unlock()
+
+		reason := ""
+		select {
+		case <-exited:
+			reason = "poll loop exited"
+		default:
+			if idle := time.Since(consumer.LastActivity()); idle > stallThreshold(consumer, missedThreshold) {
+				reason = fmt.Sprintf("poll loop made no progress for %s", idle.Round(time.Second))
+			}
+		}
+
+		if reason == "" || ctx.Err() != nil || s.draining.Load() {
+			continue
+		}
+
+		s.inferable.logf(LogLevelError, "Restarting service '%s': %s", s.Name, reason)
+
+		// Abandon the stalled loop. It exits once whatever it is blocked on returns.
+		cancel()
+
+		replacement, err := s.newConsumer()
+		if err != nil {
+			s.inferable.logf(LogLevelError, "Failed to restart service '%s': %v", s.Name, err)
+			continue
+		}
+
+		s.runConsumer(replacement)
+		s.options.Hooks.onRestart(s, reason)
+	}
+}
+
+// stallThreshold is how long the poll loop may make no progress before it is considered stalled.
+// It is never shorter than a full poll cycle, so that an idle loop waiting to poll is not restarted.
+func stallThreshold(consumer Transport, missedThreshold time.Duration) time.Duration {
+	if cycle := consumer.IdleCycle(); cycle > missedThreshold {
+		return cycle
+	}
+
+	return missedThreshold
+}
@@ -0,0 +1,84 @@
+package inferable
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchdogRestartsPanickedPollLoop(t *testing.T) {
+	var received atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Amz-Target") == "AmazonSQS.ReceiveMessage" {
+			messages := []map[string]string{}
+			if received.Add(1) == 1 {
+				body := `{"value": {"id": "call-1", "service": "watched", "targetFn": "Boom", "targetArgs": "{\"value\": {}}"}}`
+				sum := md5.Sum([]byte(body))
+				messages = append(messages, map[string]string{
+					"MessageId": "msg-1", "ReceiptHandle": "msg-1", "Body": body, "MD5OfBody": hex.EncodeToString(sum[:]),
+				})
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"Messages": messages})
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	i, err := New(InferableOptions{
+		APIEndpoint:   server.URL,
+		APISecret:     "test-secret",
+		QueueEndpoint: server.URL,
+	})
+	require.NoError(t, err)
+
+	restarts := make(chan string, 10)
+	service, err := i.RegisterServiceWithOptions("watched", ServiceOptions{
+		WatchdogInterval: 20 * time.Millisecond,
+		Hooks: Hooks{
+			OnRestart: func(service *Service, reason string) { restarts <- reason },
+		},
+	})
+	require.NoError(t, err)
+
+	type TestInput struct{}
+	require.NoError(t, service.RegisterFunc(Function{
+		Name: "Boom",
+		Func: func(input TestInput) string { panic("boom") },
+	}))
+
+	service.region = "us-east-1"
+	service.queueURL = server.URL + "/queue"
+	service.credentials.AccessKeyID = "key"
+	service.credentials.SecretAccessKey = "secret"
+
+	consumer, err := service.newConsumer()
+	require.NoError(t, err)
+
+	service.ctx, service.cancel = context.WithCancel(context.Background())
+	defer service.Stop()
+
+	service.runConsumer(consumer)
+	go service.watchdog(service.ctx, service.watchdogInterval())
+
+	select {
+	case reason := <-restarts:
+		assert.Equal(t, "poll loop exited", reason)
+	case <-time.After(5 * time.Second):
+		t.Fatal("poll loop was not restarted")
+	}
+
+	// The restarted loop polls again, and is not restarted while idle between polls
+	require.Eventually(t, func() bool { return received.Load() >= 2 }, 5*time.Second, 10*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	assert.Len(t, restarts, 0)
+}
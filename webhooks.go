@@ -0,0 +1,144 @@
+package inferable
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// WebhookSignatureHeader carries the HMAC-SHA256 signature of the webhook body
+	WebhookSignatureHeader = "X-Inferable-Signature"
+
+	WebhookEventRunCompleted  = "run.completed"
+	WebhookEventCallCompleted = "call.completed"
+)
+
+// maxWebhookBodySize limits the size of webhook payloads accepted by the handler
+const maxWebhookBodySize = 1 << 20
+
+type RegisterWebhookInput struct {
+	URL string `json:"url"`
+	// Events to be notified of. Defaults to all completion events.
+	Events []string `json:"events,omitempty"`
+}
+
+// Webhook represents a registered webhook. Secret is used to verify the signature of incoming events.
+type Webhook struct {
+	ID     string   `json:"id"`
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Secret string   `json:"secret"`
+}
+
+// WebhookEvent is a completion notification delivered to a registered webhook
+type WebhookEvent struct {
+	Type       string          `json:"type"`
+	RunID      string          `json:"runId,omitempty"`
+	CallID     string          `json:"callId,omitempty"`
+	Status     string          `json:"status"`
+	Result     json.RawMessage `json:"result,omitempty"`
+	ResultType string          `json:"resultType,omitempty"`
+	CreatedAt  time.Time       `json:"createdAt"`
+}
+
+// RegisterWebhook registers a URL to be notified when runs or calls in the cluster complete
+func (i *Inferable) RegisterWebhook(input RegisterWebhookInput) (*Webhook, error) {
+	if i.clusterID == "" {
+		return nil, fmt.Errorf("cluster ID must be provided to register webhooks")
+	}
+
+	if !strings.HasPrefix(input.URL, "http://") && !strings.HasPrefix(input.URL, "https://") {
+		return nil, fmt.Errorf("invalid webhook URL: %s", input.URL)
+	}
+
+	if len(input.Events) == 0 {
+		input.Events = []string{WebhookEventRunCompleted, WebhookEventCallCompleted}
+	}
+
+	jsonPayload, err := json.Marshal(input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal webhook payload: %v", err)
+	}
+
+	responseData, err := i.FetchData(FetchDataOptions{
+		Path:   fmt.Sprintf("/clusters/%s/webhooks", i.clusterID),
+		Method: "POST",
+		Body:   string(jsonPayload),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to register webhook: %w", err)
+	}
+
+	webhook := &Webhook{}
+	if err := json.Unmarshal(responseData, webhook); err != nil {
+		return nil, fmt.Errorf("failed to parse webhook response: %v", err)
+	}
+
+	return webhook, nil
+}
+
+// NewWebhookHandler returns an http.Handler which verifies the signature of incoming webhook
+// requests using secret and passes the decoded event to onEvent.
+// Requests with a missing or invalid signature are rejected with 401, bodies larger than 1 MiB with 413.
+// If onEvent returns an error, the request is rejected with 500 so that the delivery is retried.
+func NewWebhookHandler(secret string, onEvent func(WebhookEvent) error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// One byte beyond the limit is read, so that oversized bodies are rejected rather than truncated
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBodySize+1))
+		if err != nil {
+			http.Error(w, "error reading body", http.StatusBadRequest)
+			return
+		}
+		if len(body) > maxWebhookBodySize {
+			http.Error(w, "body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		if !VerifyWebhookSignature(secret, body, r.Header.Get(WebhookSignatureHeader)) {
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+
+		var event WebhookEvent
+		if err := json.Unmarshal(body, &event); err != nil {
+			http.Error(w, "invalid payload", http.StatusBadRequest)
+			return
+		}
+
+		if err := onEvent(event); err != nil {
+			http.Error(w, "error handling event", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// VerifyWebhookSignature checks that signature is the hex encoded HMAC-SHA256 of body, optionally prefixed with "sha256="
+func VerifyWebhookSignature(secret string, body []byte, signature string) bool {
+	if secret == "" || signature == "" {
+		return false
+	}
+
+	expected, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return hmac.Equal(mac.Sum(nil), expected)
+}
@@ -0,0 +1,115 @@
+package inferable
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookHandler(t *testing.T) {
+	secret := "webhook-secret"
+	body := `{"type": "run.completed", "runId": "run-1", "status": "done", "result": {"ok": true}}`
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	var received []WebhookEvent
+	handler := NewWebhookHandler(secret, func(event WebhookEvent) error {
+		received = append(received, event)
+		return nil
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(body))
+	req.Header.Set(WebhookSignatureHeader, signature)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	require.Len(t, received, 1)
+	assert.Equal(t, WebhookEventRunCompleted, received[0].Type)
+	assert.Equal(t, "run-1", received[0].RunID)
+	assert.JSONEq(t, `{"ok": true}`, string(received[0].Result))
+
+	// Tampered body
+	req = httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(strings.Replace(body, "run-1", "run-2", 1)))
+	req.Header.Set(WebhookSignatureHeader, signature)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Len(t, received, 1)
+}
+
+func TestWebhookHandlerBodyTooLarge(t *testing.T) {
+	secret := "webhook-secret"
+	body := `{"type": "run.completed", "runId": "run-1", "status": "done", "result": "` + strings.Repeat("a", maxWebhookBodySize) + `"}`
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	called := false
+	handler := NewWebhookHandler(secret, func(event WebhookEvent) error {
+		called = true
+		return nil
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(body))
+	req.Header.Set(WebhookSignatureHeader, signature)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	assert.False(t, called)
+}
+
+func TestRegisterWebhook(t *testing.T) {
+	var received RegisterWebhookInput
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/clusters/test-cluster/webhooks":
+			assert.Equal(t, "POST", r.Method)
+			assert.Equal(t, "Bearer test-secret", r.Header.Get("Authorization"))
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+			w.Write([]byte(`{"id": "webhook-1", "url": "https://example.com/hook", "events": ["run.completed", "call.completed"], "secret": "webhook-secret"}`))
+		}
+	}))
+	defer server.Close()
+
+	i, err := New(InferableOptions{
+		APIEndpoint: server.URL,
+		APISecret:   "test-secret",
+		ClusterID:   "test-cluster",
+	})
+	require.NoError(t, err)
+
+	webhook, err := i.RegisterWebhook(RegisterWebhookInput{URL: "https://example.com/hook"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "https://example.com/hook", received.URL)
+	assert.Equal(t, []string{WebhookEventRunCompleted, WebhookEventCallCompleted}, received.Events)
+	assert.Equal(t, &Webhook{
+		ID:     "webhook-1",
+		URL:    "https://example.com/hook",
+		Events: []string{WebhookEventRunCompleted, WebhookEventCallCompleted},
+		Secret: "webhook-secret",
+	}, webhook)
+
+	_, err = i.RegisterWebhook(RegisterWebhookInput{URL: "example.com/hook"})
+	assert.ErrorContains(t, err, "invalid webhook URL")
+
+	i, err = New(InferableOptions{APIEndpoint: server.URL, APISecret: "test-secret"})
+	require.NoError(t, err)
+
+	_, err = i.RegisterWebhook(RegisterWebhookInput{URL: "https://example.com/hook"})
+	assert.ErrorContains(t, err, "cluster ID must be provided")
+}